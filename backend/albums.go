@@ -107,7 +107,7 @@ func (a *App) DeleteAlbum(albumID int) error {
 
 func (a *App) GetAlbumsWithSongs(limit, offset int) ([]AlbumWithSongs, error) {
 	rows, err := a.db.Query(`
-		SELECT id, name, artwork_path, genre, year, is_single, created_at, updated_at, synced
+		SELECT id, name, artwork_path, genre, year, is_single, created_at, updated_at, synced, era_id
 		FROM albums
 		ORDER BY created_at DESC
 		LIMIT ? OFFSET ?
@@ -121,7 +121,7 @@ func (a *App) GetAlbumsWithSongs(limit, offset int) ([]AlbumWithSongs, error) {
 	for rows.Next() {
 		var alb Album
 		var createdAt, updatedAt sql.NullInt64
-		err := rows.Scan(&alb.ID, &alb.Name, &alb.ArtworkPath, &alb.Genre, &alb.Year, &alb.IsSingle, &createdAt, &updatedAt, &alb.Synced)
+		err := rows.Scan(&alb.ID, &alb.Name, &alb.ArtworkPath, &alb.Genre, &alb.Year, &alb.IsSingle, &createdAt, &updatedAt, &alb.Synced, &alb.EraID)
 		if err != nil {
 			return nil, err
 		}
@@ -153,9 +153,9 @@ func (a *App) GetAlbumWithArtists(albumID int) (*AlbumWithArtists, error) {
 	var alb Album
 	var createdAt, updatedAt sql.NullInt64
 	err := a.db.QueryRow(`
-		SELECT id, name, artwork_path, genre, year, is_single, created_at, updated_at, synced
+		SELECT id, name, artwork_path, genre, year, is_single, created_at, updated_at, synced, era_id
 		FROM albums WHERE id = ?
-	`, albumID).Scan(&alb.ID, &alb.Name, &alb.ArtworkPath, &alb.Genre, &alb.Year, &alb.IsSingle, &createdAt, &updatedAt, &alb.Synced)
+	`, albumID).Scan(&alb.ID, &alb.Name, &alb.ArtworkPath, &alb.Genre, &alb.Year, &alb.IsSingle, &createdAt, &updatedAt, &alb.Synced, &alb.EraID)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -205,7 +205,7 @@ func (a *App) getArtistsForAlbum(albumID int) ([]Artist, error) {
 
 func (a *App) getSongsForAlbum(albumID int) ([]Song, error) {
 	rows, err := a.db.Query(`
-		SELECT id, name, album_id, artwork_path, genre, year, track_number, duration, filepath, file_type, created_at, updated_at, synced, apple_music_id
+		SELECT id, name, album_id, artwork_path, genre, year, track_number, duration, filepath, file_type, created_at, updated_at, synced, apple_music_id, bitrate, sample_rate, channels, bit_depth, codec, leak_date, leak_source, recording_date, leak_status, notes, era_id
 		FROM songs WHERE album_id = ?
 		ORDER BY track_number, created_at
 	`, albumID)
@@ -218,7 +218,7 @@ func (a *App) getSongsForAlbum(albumID int) ([]Song, error) {
 	for rows.Next() {
 		var song Song
 		var createdAt, updatedAt sql.NullInt64
-		err := rows.Scan(&song.ID, &song.Name, &song.AlbumID, &song.ArtworkPath, &song.Genre, &song.Year, &song.TrackNumber, &song.Duration, &song.Filepath, &song.FileType, &createdAt, &updatedAt, &song.Synced, &song.AppleMusicID)
+		err := rows.Scan(&song.ID, &song.Name, &song.AlbumID, &song.ArtworkPath, &song.Genre, &song.Year, &song.TrackNumber, &song.Duration, &song.Filepath, &song.FileType, &createdAt, &updatedAt, &song.Synced, &song.AppleMusicID, &song.Bitrate, &song.SampleRate, &song.Channels, &song.BitDepth, &song.Codec, &song.LeakDate, &song.LeakSource, &song.RecordingDate, &song.LeakStatus, &song.Notes, &song.EraID)
 		if err != nil {
 			return nil, err
 		}
@@ -258,7 +258,7 @@ func (a *App) ResolveOrCreateAlbum(name string, artistIDs []int, opts AlbumResol
 	err := a.InTx(func(tx *sql.Tx) error {
 		// gather candidates by case-insensitive name
 		rows, err := tx.Query(
-			`SELECT id, name, artwork_path, genre, year, is_single, created_at, updated_at, synced
+			`SELECT id, name, artwork_path, genre, year, is_single, created_at, updated_at, synced, era_id
 			 FROM albums WHERE LOWER(name) = LOWER(?)`,
 			trimmedName,
 		)
@@ -269,7 +269,7 @@ func (a *App) ResolveOrCreateAlbum(name string, artistIDs []int, opts AlbumResol
 		for rows.Next() {
 			var alb Album
 			var createdAt, updatedAt sql.NullInt64
-			if err := rows.Scan(&alb.ID, &alb.Name, &alb.ArtworkPath, &alb.Genre, &alb.Year, &alb.IsSingle, &createdAt, &updatedAt, &alb.Synced); err != nil {
+			if err := rows.Scan(&alb.ID, &alb.Name, &alb.ArtworkPath, &alb.Genre, &alb.Year, &alb.IsSingle, &createdAt, &updatedAt, &alb.Synced, &alb.EraID); err != nil {
 				rows.Close()
 				return err
 			}
@@ -390,9 +390,9 @@ func (a *App) FindAlbumByName(name string) (*Album, error) {
 	var alb Album
 	var createdAt, updatedAt sql.NullInt64
 	err := a.db.QueryRow(
-		`SELECT id, name, artwork_path, genre, year, is_single, created_at, updated_at, synced FROM albums WHERE LOWER(name) = LOWER(?)`,
+		`SELECT id, name, artwork_path, genre, year, is_single, created_at, updated_at, synced, era_id FROM albums WHERE LOWER(name) = LOWER(?)`,
 		name,
-	).Scan(&alb.ID, &alb.Name, &alb.ArtworkPath, &alb.Genre, &alb.Year, &alb.IsSingle, &createdAt, &updatedAt, &alb.Synced)
+	).Scan(&alb.ID, &alb.Name, &alb.ArtworkPath, &alb.Genre, &alb.Year, &alb.IsSingle, &createdAt, &updatedAt, &alb.Synced, &alb.EraID)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -9,6 +9,8 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/sqlite3"
@@ -39,6 +41,25 @@ type App struct {
 	db         *sql.DB
 	dbPath     string
 	staticPath string
+
+	// inbox is the running inbox poller (desktop app only); inboxMu keeps
+	// it from importing a file while a pending item is being resolved
+	inbox   *inboxWatcher
+	inboxMu sync.Mutex
+
+	// identities holds uploads' hashes and fingerprints from the duplicate
+	// check until their songs are created or their files deleted
+	identities   map[string]*songFileIdentity
+	identitiesMu sync.Mutex
+
+	// backfillMu keeps the startup backfill and library scans from hashing
+	// the same songs at once
+	backfillMu sync.Mutex
+
+	// linkedAt is when each hard-linked import entered uploads; the link
+	// keeps its source's mtime
+	linkedAt   map[string]time.Time
+	linkedAtMu sync.Mutex
 }
 
 func NewApp() *App {
@@ -46,6 +67,15 @@ func NewApp() *App {
 }
 
 func (a *App) runMigrations() error {
+	// the search index needs FTS5; say so instead of failing mid-migration
+	var fts5 bool
+	if err := a.db.QueryRow(`SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&fts5); err != nil {
+		return fmt.Errorf("failed to check SQLite compile options: %w", err)
+	}
+	if !fts5 {
+		return fmt.Errorf("SQLite was built without FTS5, which search needs; build with -tags sqlite_fts5")
+	}
+
 	// create migration source from embedded files
 	source, err := iofs.New(migrationsFS, "migrations")
 	if err != nil {
@@ -76,36 +106,60 @@ func (a *App) runMigrations() error {
 func (a *App) Startup(ctx context.Context) {
 	a.ctx = ctx
 
+	if err := a.open(ctx); err != nil {
+		panic(err.Error())
+	}
+	if err := a.gcImportSessions(time.Now()); err != nil {
+		log.Printf("failed to clean up expired import sessions: %v", err)
+	}
+	// songs from before duplicate detection need identities for uploads to
+	// be checked against them; uploads don't wait for this and skip the
+	// songs it hasn't reached yet
+	go func() {
+		if err := a.backfillSongIdentities(); err != nil {
+			log.Printf("failed to hash and fingerprint existing songs: %v", err)
+		}
+	}()
+	a.startInboxWatcher()
+}
+
+// open resolves the app paths, creates the upload and data directories,
+// connects to SQLite, and runs migrations. Shared by the Wails startup hook
+// and the headless CLI.
+func (a *App) open(ctx context.Context) error {
 	var err error
 	a.dbPath, a.staticPath, err = resolveAppPaths(ctx)
 	if err != nil {
-		panic("Failed to determine app paths: " + err.Error())
+		return fmt.Errorf("failed to determine app paths: %w", err)
 	}
 
 	if err := os.MkdirAll(filepath.Join(a.staticPath, "uploads", "songs"), 0755); err != nil {
-		panic("Failed to create uploads songs directory: " + err.Error())
+		return fmt.Errorf("failed to create uploads songs directory: %w", err)
 	}
 	if err := os.MkdirAll(filepath.Join(a.staticPath, "uploads", "artwork"), 0755); err != nil {
-		panic("Failed to create uploads artwork directory: " + err.Error())
+		return fmt.Errorf("failed to create uploads artwork directory: %w", err)
 	}
 	if err := os.MkdirAll(filepath.Dir(a.dbPath), 0755); err != nil {
-		panic("Failed to create app data directory: " + err.Error())
+		return fmt.Errorf("failed to create app data directory: %w", err)
 	}
 
 	// initialize SQLite connection
 	// go's sql.DB handles connection pooling automatically (replaces python's thread_local)
 	a.db, err = sql.Open("sqlite3_custom", a.dbPath)
 	if err != nil {
-		panic("Failed to connect to database: " + err.Error())
+		return fmt.Errorf("failed to connect to database: %w", err)
 	}
 
 	// run migrations
 	if err := a.runMigrations(); err != nil {
-		panic("Failed to run database migrations: " + err.Error())
+		a.db.Close()
+		return fmt.Errorf("failed to run database migrations: %w", err)
 	}
+	return nil
 }
 
 func (a *App) Shutdown(ctx context.Context) {
+	a.stopInboxWatcher()
 	if a.db != nil {
 		a.db.Close()
 	}
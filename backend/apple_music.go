@@ -179,10 +179,10 @@ func (a *App) getSongReadableByID(songID int) (SongReadable, error) {
 	var createdAt, updatedAt int64
 
 	err := a.db.QueryRow(`
-		SELECT id, name, album_id, artwork_path, genre, year, track_number, duration, filepath, file_type, created_at, updated_at, synced, apple_music_id
+		SELECT id, name, album_id, artwork_path, genre, year, track_number, duration, filepath, file_type, created_at, updated_at, synced, apple_music_id, bitrate, sample_rate, channels, bit_depth, codec, leak_date, leak_source, recording_date, leak_status, notes, era_id
 		FROM songs
 		WHERE id = ?
-	`, songID).Scan(&song.ID, &song.Name, &song.AlbumID, &song.ArtworkPath, &song.Genre, &song.Year, &song.TrackNumber, &song.Duration, &song.Filepath, &song.FileType, &createdAt, &updatedAt, &song.Synced, &song.AppleMusicID)
+	`, songID).Scan(&song.ID, &song.Name, &song.AlbumID, &song.ArtworkPath, &song.Genre, &song.Year, &song.TrackNumber, &song.Duration, &song.Filepath, &song.FileType, &createdAt, &updatedAt, &song.Synced, &song.AppleMusicID, &song.Bitrate, &song.SampleRate, &song.Channels, &song.BitDepth, &song.Codec, &song.LeakDate, &song.LeakSource, &song.RecordingDate, &song.LeakStatus, &song.Notes, &song.EraID)
 
 	if err != nil {
 		return SongReadable{}, err
@@ -192,11 +192,8 @@ func (a *App) getSongReadableByID(songID int) (SongReadable, error) {
 	song.UpdatedAt = updatedAt
 
 	// Get artists
-	artists, _ := a.getArtistsForSong(song.ID)
-	artistNames := make([]string, len(artists))
-	for i, art := range artists {
-		artistNames[i] = art.Name
-	}
+	artists, _ := a.getSongArtists(song.ID)
+	artistDisplay, _ := renderArtistCredits(song.Name, artists, featuredStyleArtist)
 
 	// Get producers
 	producers, _ := a.getProducersForSong(song.ID)
@@ -209,7 +206,7 @@ func (a *App) getSongReadableByID(songID int) (SongReadable, error) {
 
 	return SongReadable{
 		Song:      song,
-		Artist:    strings.Join(artistNames, ", "),
+		Artist:    artistDisplay,
 		Artists:   artists,
 		Producers: producers,
 		Album:     album,
@@ -0,0 +1,118 @@
+package backend
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// --- Artist Mapping Memory ---
+
+const (
+	artistMappingKindArtist    = "artist"
+	artistMappingKindCreateNew = "create_new"
+
+	// artistMappingCreateNew is the ArtistMapping value asking for a new artist
+	artistMappingCreateNew = "CREATE_NEW"
+)
+
+// rememberArtistMappings records the choices made in an upload's artist
+// mapping so the same raw credits resolve on their own next time. Raw names
+// are keyed case-insensitively; a later choice replaces an earlier one.
+func (a *App) rememberArtistMappings(mapping map[string]any) error {
+	now := time.Now().Unix()
+	return a.InTx(func(tx *sql.Tx) error {
+		for rawName, resolution := range mapping {
+			key := strings.ToLower(strings.TrimSpace(rawName))
+			if key == "" {
+				continue
+			}
+
+			var kind string
+			var artistID *int
+			if resolution == artistMappingCreateNew {
+				kind = artistMappingKindCreateNew
+			} else if id, ok := resolution.(float64); ok {
+				kind = artistMappingKindArtist
+				v := int(id)
+				artistID = &v
+			} else {
+				continue
+			}
+
+			if _, err := tx.Exec(`
+				INSERT INTO artist_mapping_memory (raw_name, artist_id, kind, created_at, updated_at)
+				VALUES (?, ?, ?, ?, ?)
+				ON CONFLICT(raw_name) DO UPDATE SET artist_id = excluded.artist_id, kind = excluded.kind, updated_at = excluded.updated_at
+			`, key, artistID, kind, now, now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// recallArtistMapping returns the remembered resolution for a raw credit:
+// an artist ID (as float64, matching what the frontend sends back) or
+// "CREATE_NEW". Mappings to artists that no longer exist are ignored.
+func (a *App) recallArtistMapping(rawName string) (any, bool, error) {
+	var kind string
+	var artistID sql.NullInt64
+	err := a.db.QueryRow(`
+		SELECT m.kind, ar.id
+		FROM artist_mapping_memory m
+		LEFT JOIN artists ar ON ar.id = m.artist_id
+		WHERE m.raw_name = ?
+	`, strings.ToLower(strings.TrimSpace(rawName))).Scan(&kind, &artistID)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	switch {
+	case kind == artistMappingKindCreateNew:
+		return artistMappingCreateNew, true, nil
+	case artistID.Valid:
+		return float64(artistID.Int64), true, nil
+	}
+	return nil, false, nil
+}
+
+// GetArtistMappings lists remembered upload artist mappings.
+func (a *App) GetArtistMappings() ([]RememberedArtistMapping, error) {
+	rows, err := a.db.Query(`
+		SELECT m.id, m.raw_name, m.kind, m.artist_id, ar.name, m.created_at, m.updated_at
+		FROM artist_mapping_memory m
+		LEFT JOIN artists ar ON ar.id = m.artist_id
+		ORDER BY m.raw_name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mappings := []RememberedArtistMapping{}
+	for rows.Next() {
+		var m RememberedArtistMapping
+		var createdAt, updatedAt sql.NullInt64
+		if err := rows.Scan(&m.ID, &m.RawName, &m.Kind, &m.ArtistID, &m.ArtistName, &createdAt, &updatedAt); err != nil {
+			return nil, err
+		}
+		m.CreatedAt = createdAt.Int64
+		m.UpdatedAt = updatedAt.Int64
+		mappings = append(mappings, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return mappings, nil
+}
+
+// DeleteArtistMapping forgets a remembered mapping; the raw credit will be
+// reported as unmapped again on the next upload.
+func (a *App) DeleteArtistMapping(mappingID int) error {
+	_, err := a.db.Exec(`DELETE FROM artist_mapping_memory WHERE id = ?`, mappingID)
+	return err
+}
@@ -0,0 +1,95 @@
+package backend
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"testing"
+)
+
+// taggedMP3Upload builds an MP3 upload whose ID3 tag carries the given title
+// and artist credit.
+func taggedMP3Upload(t *testing.T, filename, title, artist string) FileUpload {
+	t.Helper()
+	var buf bytes.Buffer
+	for i := 0; i < 10; i++ {
+		buf.Write(mp3Frame(nil))
+	}
+	path := writeFixture(t, filename, buf.Bytes())
+	if err := (id3Adapter{}).Write(path, SongTags{Title: title, Artist: artist}); err != nil {
+		t.Fatalf("tag fixture: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	return FileUpload{Filename: filename, Base64Data: base64.StdEncoding.EncodeToString(data)}
+}
+
+func TestArtistMappingsAreRememberedAndRevocable(t *testing.T) {
+	app := newTestApp(t)
+
+	thug, err := app.CreateArtist(CreateArtistInput{Name: "Young Thug"})
+	if err != nil {
+		t.Fatalf("CreateArtist: %v", err)
+	}
+
+	first, err := app.UploadAndExtractMetadata([]FileUpload{taggedMP3Upload(t, "a.mp3", "A", "Thugger & Newcomer")}, nil)
+	if err != nil {
+		t.Fatalf("UploadAndExtractMetadata: %v", err)
+	}
+	if len(first.UnmappedArtists) != 2 || len(first.ArtistMapping) != 0 {
+		t.Fatalf("expected two unmapped artists and no remembered mapping, got %v / %v", first.UnmappedArtists, first.ArtistMapping)
+	}
+	if _, err := app.CreateSongsWithMetadata(CreateSongsWithMetadataInput{
+		FilesData:     first.FilesData,
+		ArtistMapping: map[string]any{"Thugger": float64(thug.ID), "Newcomer": "CREATE_NEW"},
+	}); err != nil {
+		t.Fatalf("CreateSongsWithMetadata: %v", err)
+	}
+
+	mappings, err := app.GetArtistMappings()
+	if err != nil {
+		t.Fatalf("GetArtistMappings: %v", err)
+	}
+	if len(mappings) != 2 || mappings[0].RawName != "newcomer" || mappings[0].Kind != artistMappingKindCreateNew ||
+		mappings[1].RawName != "thugger" || mappings[1].ArtistID == nil || *mappings[1].ArtistID != thug.ID {
+		t.Fatalf("unexpected remembered mappings: %+v", mappings)
+	}
+
+	// "Newcomer" now exists as an artist; "THUGGER" resolves from memory
+	second, err := app.UploadAndExtractMetadata([]FileUpload{taggedMP3Upload(t, "b.mp3", "B", "THUGGER & Newcomer")}, nil)
+	if err != nil {
+		t.Fatalf("UploadAndExtractMetadata: %v", err)
+	}
+	if len(second.UnmappedArtists) != 0 || second.FilesData[0].HasUnmappedArtists {
+		t.Fatalf("expected every credit to resolve, got unmapped %v", second.UnmappedArtists)
+	}
+	if got := second.ArtistMapping["THUGGER"]; got != float64(thug.ID) {
+		t.Fatalf("expected THUGGER to be pre-resolved to %d, got %v", thug.ID, got)
+	}
+
+	// the frontend may send back only its own choices; memory still applies
+	songs, err := app.CreateSongsWithMetadata(CreateSongsWithMetadataInput{FilesData: second.FilesData})
+	if err != nil {
+		t.Fatalf("CreateSongsWithMetadata: %v", err)
+	}
+	artists, err := app.getArtistsForSong(songs[0].ID)
+	if err != nil {
+		t.Fatalf("getArtistsForSong: %v", err)
+	}
+	if len(artists) != 2 || artists[0].ID != thug.ID || artists[1].Name != "Newcomer" {
+		t.Fatalf("unexpected credits: %+v", artists)
+	}
+
+	if err := app.DeleteArtistMapping(mappings[1].ID); err != nil {
+		t.Fatalf("DeleteArtistMapping: %v", err)
+	}
+	third, err := app.UploadAndExtractMetadata([]FileUpload{taggedMP3Upload(t, "c.mp3", "C", "Thugger")}, nil)
+	if err != nil {
+		t.Fatalf("UploadAndExtractMetadata: %v", err)
+	}
+	if len(third.UnmappedArtists) != 1 || third.UnmappedArtists[0] != "Thugger" {
+		t.Fatalf("expected revoked mapping to be unmapped again, got %v", third.UnmappedArtists)
+	}
+}
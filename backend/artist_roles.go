@@ -0,0 +1,145 @@
+package backend
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// --- Song Artist Roles ---
+
+const (
+	artistRolePrimary  = "primary"
+	artistRoleFeatured = "featured"
+	artistRoleRemixer  = "remixer"
+)
+
+var validArtistRoles = map[string]bool{
+	artistRolePrimary:  true,
+	artistRoleFeatured: true,
+	artistRoleRemixer:  true,
+}
+
+// How featured artists and remixers are written (settings.featured_artist_style).
+const (
+	featuredStyleList   = "list"
+	featuredStyleArtist = "artist"
+	featuredStyleTitle  = "title"
+)
+
+// titles that already credit a feature or a remix
+var (
+	titleHasFeatPattern  = regexp.MustCompile(`(?i)\b(?:feat\.?|ft\.?|featuring)\s`)
+	titleHasRemixPattern = regexp.MustCompile(`(?i)\bremix\b`)
+)
+
+// normalizeArtistRoles returns one role per artist ID, defaulting missing
+// and empty roles to primary.
+func normalizeArtistRoles(artistIDs []int, roles []string) ([]string, error) {
+	if len(roles) > len(artistIDs) {
+		return nil, fmt.Errorf("got %d artist roles for %d artists", len(roles), len(artistIDs))
+	}
+	out := make([]string, len(artistIDs))
+	for i := range artistIDs {
+		out[i] = artistRolePrimary
+		if i < len(roles) && strings.TrimSpace(roles[i]) != "" {
+			role := strings.ToLower(strings.TrimSpace(roles[i]))
+			if !validArtistRoles[role] {
+				return nil, fmt.Errorf("unknown artist role %q (want primary, featured, or remixer)", roles[i])
+			}
+			out[i] = role
+		}
+	}
+	return out, nil
+}
+
+// linkSongArtistsTx credits artistIDs on a song in order, with roles from
+// normalizeArtistRoles.
+func linkSongArtistsTx(tx *sql.Tx, songID int64, artistIDs []int, roles []string, now int64) error {
+	for i, artistID := range artistIDs {
+		if _, err := tx.Exec(
+			`INSERT INTO song_artists (song_id, artist_id, "order", role, created_at) VALUES (?, ?, ?, ?, ?)`,
+			songID, artistID, i, roles[i], now,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *App) getSongArtists(songID int) ([]SongArtist, error) {
+	rows, err := a.db.Query(`
+		SELECT ar.id, ar.name, ar.image, ar.career_start_year, ar.career_end_year, ar.created_at, ar.updated_at, ar.synced, sa.role
+		FROM artists ar
+		JOIN song_artists sa ON ar.id = sa.artist_id
+		WHERE sa.song_id = ?
+		ORDER BY sa."order"
+	`, songID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	artists := []SongArtist{}
+	for rows.Next() {
+		var art SongArtist
+		var createdAt, updatedAt sql.NullInt64
+		err := rows.Scan(&art.ID, &art.Name, &art.Image, &art.CareerStartYear, &art.CareerEndYear, &createdAt, &updatedAt, &art.Synced, &art.Role)
+		if err != nil {
+			return nil, err
+		}
+		art.CreatedAt = createdAt.Int64
+		art.UpdatedAt = updatedAt.Int64
+		artists = append(artists, art)
+	}
+	return artists, rows.Err()
+}
+
+// renderArtistCredits builds the artist and title strings for a song's
+// credits in one of the featured artist styles. With no primary artist
+// every credit is listed, whatever the style. Titles that already name a
+// feature or a remix aren't given another.
+func renderArtistCredits(title string, credits []SongArtist, style string) (string, string) {
+	var primary, featured, remixers []string
+	for _, credit := range credits {
+		switch credit.Role {
+		case artistRoleFeatured:
+			featured = append(featured, credit.Name)
+		case artistRoleRemixer:
+			remixers = append(remixers, credit.Name)
+		default:
+			primary = append(primary, credit.Name)
+		}
+	}
+	if style == featuredStyleList || len(primary) == 0 {
+		names := make([]string, len(credits))
+		for i, credit := range credits {
+			names[i] = credit.Name
+		}
+		return strings.Join(names, ", "), title
+	}
+
+	artist := strings.Join(primary, ", ")
+	if len(featured) > 0 {
+		if style == featuredStyleTitle {
+			if !titleHasFeatPattern.MatchString(title) {
+				title += " (feat. " + joinNames(featured) + ")"
+			}
+		} else {
+			artist += " feat. " + joinNames(featured)
+		}
+	}
+	if len(remixers) > 0 && !titleHasRemixPattern.MatchString(title) {
+		title += " (" + joinNames(remixers) + " Remix)"
+	}
+	return artist, title
+}
+
+// joinNames joins names as "A", "A & B", or "A, B & C".
+func joinNames(names []string) string {
+	if len(names) < 2 {
+		return strings.Join(names, "")
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " & " + names[len(names)-1]
+}
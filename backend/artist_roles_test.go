@@ -0,0 +1,90 @@
+package backend
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseArtistCredits(t *testing.T) {
+	for _, tc := range []struct {
+		artist, title string
+		names, roles  []string
+	}{
+		{"A feat. B & C", "Song", []string{"A", "B", "C"}, []string{"primary", "featured", "featured"}},
+		{"A, B", "Song (feat. C) [D Remix]", []string{"A", "B", "C", "D"}, []string{"primary", "primary", "featured", "remixer"}},
+		{"A (ft. B)", "Song (with A)", []string{"A", "B"}, []string{"primary", "featured"}},
+		{"", "Song (Remix)", []string{}, []string{}},
+	} {
+		names, roles := ParseArtistCredits(tc.artist, tc.title)
+		if !reflect.DeepEqual(names, tc.names) || !reflect.DeepEqual(roles, tc.roles) {
+			t.Errorf("ParseArtistCredits(%q, %q) = %v %v, want %v %v", tc.artist, tc.title, names, roles, tc.names, tc.roles)
+		}
+	}
+}
+
+func TestFeaturedArtistStyles(t *testing.T) {
+	app := newTestApp(t)
+
+	var ids []int
+	for _, name := range []string{"Travis Scott", "Drake", "Sheck Wes"} {
+		artist, err := app.CreateArtist(CreateArtistInput{Name: name})
+		if err != nil {
+			t.Fatalf("CreateArtist: %v", err)
+		}
+		ids = append(ids, artist.ID)
+	}
+	if _, err := app.CreateSong(CreateSongInput{Name: "Bad", Filepath: "uploads/songs/bad.mp3", ArtistIDs: ids[:1], ArtistRoles: []string{"lead"}}); err == nil {
+		t.Fatal("expected an unknown role to be rejected")
+	}
+
+	relPath := "uploads/songs/sicko-mode.mp3"
+	fullPath := writeSilentMP3(t, app, relPath)
+	song, err := app.CreateSong(CreateSongInput{Name: "SICKO MODE", Filepath: relPath, ArtistIDs: ids,
+		ArtistRoles: []string{"", artistRoleFeatured, artistRoleRemixer}})
+	if err != nil {
+		t.Fatalf("CreateSong: %v", err)
+	}
+	readable, err := app.GetSongReadable(song.ID)
+	if err != nil {
+		t.Fatalf("GetSongReadable: %v", err)
+	}
+	if readable.Artist != "Travis Scott feat. Drake" || readable.Artists[0].Role != artistRolePrimary || readable.Artists[2].Role != artistRoleRemixer {
+		t.Fatalf("unexpected credits: %q %+v", readable.Artist, readable.Artists)
+	}
+
+	for _, tc := range []struct {
+		style, artist, title string
+	}{
+		{featuredStyleList, "Travis Scott, Drake, Sheck Wes", "SICKO MODE"},
+		{featuredStyleArtist, "Travis Scott feat. Drake", "SICKO MODE (Sheck Wes Remix)"},
+		{featuredStyleTitle, "Travis Scott", "SICKO MODE (feat. Drake) (Sheck Wes Remix)"},
+	} {
+		style := tc.style
+		if _, err := app.UpdateSettings(UpdateSettingsInput{FeaturedArtistStyle: &style}); err != nil {
+			t.Fatalf("UpdateSettings: %v", err)
+		}
+		if res, _ := app.WriteSongMetadata(song.ID); !res.Success {
+			t.Fatalf("WriteSongMetadata: %s", res.Error)
+		}
+		tags, err := (id3Adapter{}).Read(fullPath)
+		if err != nil {
+			t.Fatalf("Read: %v", err)
+		}
+		if tags.Artist != tc.artist || tags.Title != tc.title || tags.AlbumArtist != "Travis Scott" {
+			t.Errorf("%s style: got artist %q, title %q, album artist %q", tc.style, tags.Artist, tags.Title, tags.AlbumArtist)
+		}
+	}
+	bogus := "parenthetical"
+	if _, err := app.UpdateSettings(UpdateSettingsInput{FeaturedArtistStyle: &bogus}); err == nil {
+		t.Fatal("expected an unknown style to be rejected")
+	}
+
+	// updating the artists without roles makes everyone primary again
+	updated, err := app.UpdateSong(UpdateSongInput{ID: song.ID, ArtistIDs: ids[:2]})
+	if err != nil {
+		t.Fatalf("UpdateSong: %v", err)
+	}
+	if updated.Artist != "Travis Scott, Drake" || updated.Artists[1].Role != artistRolePrimary {
+		t.Fatalf("unexpected credits after update: %q %+v", updated.Artist, updated.Artists)
+	}
+}
@@ -3,6 +3,7 @@ package backend
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -29,6 +30,288 @@ func (a *App) CreateArtist(input CreateArtistInput) (*Artist, error) {
 	}, nil
 }
 
+// UpdateArtist changes the fields that are set; see UpdateArtistInput. A new
+// name is trimmed and can't be empty or already resolve to another artist.
+// A rename rewrites the metadata of every song crediting the artist directly
+// or through its album.
+func (a *App) UpdateArtist(input UpdateArtistInput) (*UpdateArtistResult, error) {
+	current, err := a.getArtistByID(input.ID)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil {
+		return nil, fmt.Errorf("artist %d not found", input.ID)
+	}
+	if input.Name != nil {
+		name := strings.TrimSpace(*input.Name)
+		if name == "" {
+			return nil, fmt.Errorf("artist name cannot be empty")
+		}
+		existing, err := a.resolveArtistName(name)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil && existing.ID != input.ID {
+			return nil, fmt.Errorf("\"%s\" is already the name or an alias of artist %d", name, existing.ID)
+		}
+		input.Name = &name
+	}
+
+	var songIDs []int
+	now := time.Now().Unix()
+	err = a.InTx(func(tx *sql.Tx) error {
+		result, err := tx.Exec(
+			`UPDATE artists SET
+				name = COALESCE(?, name),
+				career_start_year = NULLIF(COALESCE(?, career_start_year), 0),
+				career_end_year = NULLIF(COALESCE(?, career_end_year), 0),
+				updated_at = ?
+			WHERE id = ?`,
+			input.Name, input.CareerStartYear, input.CareerEndYear, now, input.ID,
+		)
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return fmt.Errorf("artist %d not found", input.ID)
+		}
+		if input.Name == nil || *input.Name == current.Name {
+			return nil
+		}
+		songIDs, err = artistSongIDsTx(tx, input.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	artist, err := a.getArtistByID(input.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &UpdateArtistResult{
+		Artist:   *artist,
+		Metadata: a.writeMetadataBatch(songIDs, fmt.Sprintf("Renamed artist %d", input.ID)),
+	}, nil
+}
+
+// DeleteArtist removes an artist along with its aliases and its song, album,
+// and producer alias links. Foreign keys are not enforced, so the links are
+// deleted explicitly. Metadata is rewritten for every song that credited the
+// artist directly or through its album.
+func (a *App) DeleteArtist(artistID int) (BatchResult, error) {
+	var songIDs []int
+	err := a.InTx(func(tx *sql.Tx) error {
+		var err error
+		if songIDs, err = artistSongIDsTx(tx, artistID); err != nil {
+			return err
+		}
+		for _, query := range []string{
+			`DELETE FROM artist_aliases WHERE artist_id = ?`,
+			`DELETE FROM artist_mapping_memory WHERE artist_id = ?`,
+			`DELETE FROM song_artists WHERE artist_id = ?`,
+			`DELETE FROM album_artists WHERE artist_id = ?`,
+			`DELETE FROM producer_alias_artists WHERE artist_id = ?`,
+			`UPDATE songs SET era_id = NULL WHERE era_id IN (SELECT id FROM eras WHERE artist_id = ?)`,
+			`UPDATE albums SET era_id = NULL WHERE era_id IN (SELECT id FROM eras WHERE artist_id = ?)`,
+			`DELETE FROM eras WHERE artist_id = ?`,
+		} {
+			if _, err := tx.Exec(query, artistID); err != nil {
+				return err
+			}
+		}
+		result, err := tx.Exec(`DELETE FROM artists WHERE id = ?`, artistID)
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return fmt.Errorf("artist %d not found", artistID)
+		}
+		return nil
+	})
+	if err != nil {
+		return BatchResult{}, err
+	}
+
+	return a.writeMetadataBatch(songIDs, fmt.Sprintf("Deleted artist %d", artistID)), nil
+}
+
+// artistSongIDsTx lists the songs whose artist or album artist tags name
+// artistID: those credited directly or through their album's artists.
+func artistSongIDsTx(tx *sql.Tx, artistID int) ([]int, error) {
+	rows, err := tx.Query(`
+		SELECT song_id FROM song_artists WHERE artist_id = ?
+		UNION
+		SELECT s.id FROM songs s JOIN album_artists aa ON aa.album_id = s.album_id WHERE aa.artist_id = ?
+		ORDER BY 1
+	`, artistID, artistID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	songIDs := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		songIDs = append(songIDs, id)
+	}
+	return songIDs, rows.Err()
+}
+
+// MergeArtists folds sourceID into targetID: every song, album, and producer
+// alias link moves to the target with its "order" preserved, the target picks
+// up any career years or image it was missing, and the source is deleted.
+// The source's aliases and remembered upload mappings move to the target and
+// its name becomes one more alias, so uploads crediting the old name keep
+// resolving.
+// Where both artists were already linked, the target keeps the earlier of the
+// two positions. Metadata is rewritten for every song whose artist or album
+// artist tags changed.
+func (a *App) MergeArtists(sourceID, targetID int) (BatchResult, error) {
+	if sourceID == targetID {
+		return BatchResult{}, fmt.Errorf("cannot merge artist %d into itself", sourceID)
+	}
+	artists := make(map[int]*Artist)
+	for _, id := range []int{sourceID, targetID} {
+		artist, err := a.getArtistByID(id)
+		if err != nil {
+			return BatchResult{}, err
+		}
+		if artist == nil {
+			return BatchResult{}, fmt.Errorf("artist %d not found", id)
+		}
+		artists[id] = artist
+	}
+	sourceName := artists[sourceID].Name
+
+	var songIDs []int
+	now := time.Now().Unix()
+	err := a.InTx(func(tx *sql.Tx) error {
+		// collected before the links move
+		var err error
+		if songIDs, err = artistSongIDsTx(tx, sourceID); err != nil {
+			return err
+		}
+
+		for _, link := range []struct{ table, owner string }{
+			{"song_artists", "song_id"},
+			{"album_artists", "album_id"},
+		} {
+			// both linked: keep the earlier position on the target row
+			if _, err := tx.Exec(`
+				UPDATE `+link.table+` AS t SET "order" = src."order"
+				FROM `+link.table+` AS src
+				WHERE t.artist_id = ? AND src.artist_id = ? AND src.`+link.owner+` = t.`+link.owner+` AND src."order" < t."order"
+			`, targetID, sourceID); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`
+				DELETE FROM `+link.table+`
+				WHERE artist_id = ? AND `+link.owner+` IN (SELECT `+link.owner+` FROM `+link.table+` WHERE artist_id = ?)
+			`, sourceID, targetID); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`UPDATE `+link.table+` SET artist_id = ? WHERE artist_id = ?`, targetID, sourceID); err != nil {
+				return err
+			}
+		}
+
+		if _, err := tx.Exec(`
+			DELETE FROM producer_alias_artists
+			WHERE artist_id = ? AND alias_id IN (SELECT alias_id FROM producer_alias_artists WHERE artist_id = ?)
+		`, sourceID, targetID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`UPDATE producer_alias_artists SET artist_id = ? WHERE artist_id = ?`, targetID, sourceID); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(`UPDATE artist_aliases SET artist_id = ? WHERE artist_id = ?`, targetID, sourceID); err != nil {
+			return err
+		}
+		if err := mergeErasTx(tx, sourceID, targetID, now); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`UPDATE artist_mapping_memory SET artist_id = ?, updated_at = ? WHERE artist_id = ?`, targetID, now, sourceID); err != nil {
+			return err
+		}
+		var nameTaken int
+		if err := tx.QueryRow(`
+			SELECT (SELECT COUNT(*) FROM artists WHERE id != ? AND LOWER(name) = LOWER(?))
+			     + (SELECT COUNT(*) FROM artist_aliases WHERE LOWER(alias) = LOWER(?))
+		`, sourceID, sourceName, sourceName).Scan(&nameTaken); err != nil {
+			return err
+		}
+		if nameTaken == 0 {
+			if _, err := tx.Exec(
+				`INSERT INTO artist_aliases (artist_id, alias, created_at) VALUES (?, ?, ?)`,
+				targetID, sourceName, now,
+			); err != nil {
+				return err
+			}
+		}
+
+		if _, err := tx.Exec(`
+			UPDATE artists SET
+				image = COALESCE(artists.image, src.image),
+				career_start_year = COALESCE(artists.career_start_year, src.career_start_year),
+				career_end_year = COALESCE(artists.career_end_year, src.career_end_year),
+				updated_at = ?
+			FROM artists AS src
+			WHERE artists.id = ? AND src.id = ?
+		`, now, targetID, sourceID); err != nil {
+			return err
+		}
+		_, err = tx.Exec(`DELETE FROM artists WHERE id = ?`, sourceID)
+		return err
+	})
+	if err != nil {
+		return BatchResult{}, err
+	}
+
+	return a.writeMetadataBatch(songIDs, fmt.Sprintf("Merged artist %d into %d", sourceID, targetID)), nil
+}
+
+// resolveArtistName finds the artist a parsed credit refers to: by name
+// first, then by alias. Both comparisons ignore case.
+func (a *App) resolveArtistName(name string) (*Artist, error) {
+	artist, err := a.FindArtistByName(name)
+	if err != nil || artist != nil {
+		return artist, err
+	}
+
+	var artistID int
+	err = a.db.QueryRow(`SELECT artist_id FROM artist_aliases WHERE LOWER(alias) = LOWER(?)`, name).Scan(&artistID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return a.getArtistByID(artistID)
+}
+
+func (a *App) getArtistByID(artistID int) (*Artist, error) {
+	var art Artist
+	var createdAt, updatedAt sql.NullInt64
+	err := a.db.QueryRow(
+		`SELECT id, name, image, career_start_year, career_end_year, created_at, updated_at, synced FROM artists WHERE id = ?`,
+		artistID,
+	).Scan(&art.ID, &art.Name, &art.Image, &art.CareerStartYear, &art.CareerEndYear, &createdAt, &updatedAt, &art.Synced)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	art.CreatedAt = createdAt.Int64
+	art.UpdatedAt = updatedAt.Int64
+	return &art, nil
+}
+
 func (a *App) GetArtists() ([]Artist, error) {
 	rows, err := a.db.Query(`SELECT id, name, image, career_start_year, career_end_year, created_at, updated_at, synced FROM artists`)
 	if err != nil {
@@ -70,10 +353,15 @@ func (a *App) GetArtistsWithRelations() ([]ArtistWithRelations, error) {
 		if err != nil {
 			return nil, fmt.Errorf("load songs for artist %d: %w", art.ID, err)
 		}
+		aliases, err := a.GetArtistAliases(art.ID)
+		if err != nil {
+			return nil, fmt.Errorf("load aliases for artist %d: %w", art.ID, err)
+		}
 		result = append(result, ArtistWithRelations{
-			Artist: art,
-			Albums: albums,
-			Songs:  songs,
+			Artist:  art,
+			Albums:  albums,
+			Songs:   songs,
+			Aliases: aliases,
 		})
 	}
 	return result, nil
@@ -99,7 +387,7 @@ func (a *App) FindArtistByName(name string) (*Artist, error) {
 
 func (a *App) getAlbumsByArtist(artistID int) ([]Album, error) {
 	rows, err := a.db.Query(`
-		SELECT a.id, a.name, a.artwork_path, a.genre, a.year, a.created_at, a.updated_at, a.synced
+		SELECT a.id, a.name, a.artwork_path, a.genre, a.year, a.created_at, a.updated_at, a.synced, a.era_id
 		FROM albums a
 		JOIN album_artists aa ON a.id = aa.album_id
 		WHERE aa.artist_id = ?
@@ -114,7 +402,7 @@ func (a *App) getAlbumsByArtist(artistID int) ([]Album, error) {
 	for rows.Next() {
 		var alb Album
 		var createdAt, updatedAt sql.NullInt64
-		err := rows.Scan(&alb.ID, &alb.Name, &alb.ArtworkPath, &alb.Genre, &alb.Year, &createdAt, &updatedAt, &alb.Synced)
+		err := rows.Scan(&alb.ID, &alb.Name, &alb.ArtworkPath, &alb.Genre, &alb.Year, &createdAt, &updatedAt, &alb.Synced, &alb.EraID)
 		if err != nil {
 			return nil, err
 		}
@@ -130,7 +418,7 @@ func (a *App) getAlbumsByArtist(artistID int) ([]Album, error) {
 
 func (a *App) getSongsByArtist(artistID int) ([]Song, error) {
 	rows, err := a.db.Query(`
-		SELECT s.id, s.name, s.album_id, s.artwork_path, s.genre, s.year, s.track_number, s.duration, s.filepath, s.file_type, s.created_at, s.updated_at, s.synced, s.apple_music_id
+		SELECT s.id, s.name, s.album_id, s.artwork_path, s.genre, s.year, s.track_number, s.duration, s.filepath, s.file_type, s.created_at, s.updated_at, s.synced, s.apple_music_id, s.bitrate, s.sample_rate, s.channels, s.bit_depth, s.codec, s.leak_date, s.leak_source, s.recording_date, s.leak_status, s.notes, s.era_id
 		FROM songs s
 		JOIN song_artists sa ON s.id = sa.song_id
 		WHERE sa.artist_id = ?
@@ -145,7 +433,7 @@ func (a *App) getSongsByArtist(artistID int) ([]Song, error) {
 	for rows.Next() {
 		var song Song
 		var createdAt, updatedAt sql.NullInt64
-		err := rows.Scan(&song.ID, &song.Name, &song.AlbumID, &song.ArtworkPath, &song.Genre, &song.Year, &song.TrackNumber, &song.Duration, &song.Filepath, &song.FileType, &createdAt, &updatedAt, &song.Synced, &song.AppleMusicID)
+		err := rows.Scan(&song.ID, &song.Name, &song.AlbumID, &song.ArtworkPath, &song.Genre, &song.Year, &song.TrackNumber, &song.Duration, &song.Filepath, &song.FileType, &createdAt, &updatedAt, &song.Synced, &song.AppleMusicID, &song.Bitrate, &song.SampleRate, &song.Channels, &song.BitDepth, &song.Codec, &song.LeakDate, &song.LeakSource, &song.RecordingDate, &song.LeakStatus, &song.Notes, &song.EraID)
 		if err != nil {
 			return nil, err
 		}
@@ -158,3 +446,110 @@ func (a *App) getSongsByArtist(artistID int) ([]Song, error) {
 	}
 	return songs, nil
 }
+
+// --- Artist Aliases ---
+
+func (a *App) GetArtistAliases(artistID int) ([]ArtistAlias, error) {
+	rows, err := a.db.Query(`SELECT id, artist_id, alias, created_at FROM artist_aliases WHERE artist_id = ? ORDER BY alias`, artistID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	aliases := []ArtistAlias{}
+	for rows.Next() {
+		var alias ArtistAlias
+		var createdAt sql.NullInt64
+		if err := rows.Scan(&alias.ID, &alias.ArtistID, &alias.Alias, &createdAt); err != nil {
+			return nil, err
+		}
+		alias.CreatedAt = createdAt.Int64
+		aliases = append(aliases, alias)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return aliases, nil
+}
+
+// checkArtistAliasAvailable rejects an alias that is empty, already used as
+// an alias (other than excludeAliasID), or is another artist's name, since
+// name matches win during resolution and the alias would never apply.
+func (a *App) checkArtistAliasAvailable(alias string, artistID, excludeAliasID int) error {
+	if alias == "" {
+		return fmt.Errorf("alias cannot be empty")
+	}
+	var conflictArtistID int
+	err := a.db.QueryRow(
+		`SELECT artist_id FROM artist_aliases WHERE LOWER(alias) = LOWER(?) AND id != ?`,
+		alias, excludeAliasID,
+	).Scan(&conflictArtistID)
+	if err == nil {
+		return fmt.Errorf("alias \"%s\" already exists for artist %d", alias, conflictArtistID)
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+	existing, err := a.FindArtistByName(alias)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.ID != artistID {
+		return fmt.Errorf("alias \"%s\" is the name of another artist", alias)
+	}
+	return nil
+}
+
+func (a *App) CreateArtistAlias(artistID int, alias string) (*ArtistAlias, error) {
+	alias = strings.TrimSpace(alias)
+	artist, err := a.getArtistByID(artistID)
+	if err != nil {
+		return nil, err
+	}
+	if artist == nil {
+		return nil, fmt.Errorf("artist %d not found", artistID)
+	}
+	if err := a.checkArtistAliasAvailable(alias, artistID, 0); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	result, err := a.db.Exec(
+		`INSERT INTO artist_aliases (artist_id, alias, created_at) VALUES (?, ?, ?)`,
+		artistID, alias, now,
+	)
+	if err != nil {
+		return nil, err
+	}
+	id, _ := result.LastInsertId()
+	return &ArtistAlias{ID: int(id), ArtistID: artistID, Alias: alias, CreatedAt: now}, nil
+}
+
+func (a *App) UpdateArtistAlias(aliasID int, alias string) (*ArtistAlias, error) {
+	alias = strings.TrimSpace(alias)
+	var existing ArtistAlias
+	var createdAt sql.NullInt64
+	err := a.db.QueryRow(`SELECT id, artist_id, alias, created_at FROM artist_aliases WHERE id = ?`, aliasID).
+		Scan(&existing.ID, &existing.ArtistID, &existing.Alias, &createdAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("artist alias %d not found", aliasID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := a.checkArtistAliasAvailable(alias, existing.ArtistID, aliasID); err != nil {
+		return nil, err
+	}
+
+	if _, err := a.db.Exec(`UPDATE artist_aliases SET alias = ? WHERE id = ?`, alias, aliasID); err != nil {
+		return nil, err
+	}
+	existing.Alias = alias
+	existing.CreatedAt = createdAt.Int64
+	return &existing, nil
+}
+
+func (a *App) DeleteArtistAlias(aliasID int) error {
+	_, err := a.db.Exec(`DELETE FROM artist_aliases WHERE id = ?`, aliasID)
+	return err
+}
@@ -0,0 +1,317 @@
+package backend
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestMergeArtistsMovesLinksAndKeepsOrder(t *testing.T) {
+	app := newTestApp(t)
+
+	startYear := 2010
+	target, err := app.CreateArtist(CreateArtistInput{Name: "Young Thug"})
+	if err != nil {
+		t.Fatalf("CreateArtist: %v", err)
+	}
+	source, err := app.CreateArtist(CreateArtistInput{Name: "Young Thugg", CareerStartYear: &startYear})
+	if err != nil {
+		t.Fatalf("CreateArtist: %v", err)
+	}
+	other, err := app.CreateArtist(CreateArtistInput{Name: "Gunna"})
+	if err != nil {
+		t.Fatalf("CreateArtist: %v", err)
+	}
+
+	// source only, in second position
+	onlySource, err := app.CreateSong(CreateSongInput{
+		Name: "Only Source", Filepath: "uploads/songs/a.mp3", ArtistIDs: []int{other.ID, source.ID},
+	})
+	if err != nil {
+		t.Fatalf("CreateSong: %v", err)
+	}
+	// both credited: target keeps the earlier position
+	both, err := app.CreateSong(CreateSongInput{
+		Name: "Both", Filepath: "uploads/songs/b.mp3", ArtistIDs: []int{source.ID, other.ID, target.ID},
+	})
+	if err != nil {
+		t.Fatalf("CreateSong: %v", err)
+	}
+	album, err := app.CreateAlbum(CreateAlbumInput{Name: "Slime Language", ArtistIDs: []int{source.ID}})
+	if err != nil {
+		t.Fatalf("CreateAlbum: %v", err)
+	}
+	albumSong, err := app.CreateSong(CreateSongInput{
+		Name: "Album Song", Filepath: "uploads/songs/c.mp3", ArtistIDs: []int{other.ID}, AlbumID: &album.ID,
+	})
+	if err != nil {
+		t.Fatalf("CreateSong: %v", err)
+	}
+	producer, err := app.CreateProducerWithAliases(CreateProducerInput{
+		Name:    "Wheezy",
+		Aliases: []AliasInput{{Name: "Wheezy Outta Here", ArtistIDs: []int{source.ID}}},
+	})
+	if err != nil {
+		t.Fatalf("CreateProducerWithAliases: %v", err)
+	}
+
+	result, err := app.MergeArtists(source.ID, target.ID)
+	if err != nil {
+		t.Fatalf("MergeArtists: %v", err)
+	}
+
+	rewritten := []int{}
+	for _, r := range result.Results {
+		rewritten = append(rewritten, r.SongID)
+	}
+	sort.Ints(rewritten)
+	if want := []int{onlySource.ID, both.ID, albumSong.ID}; !reflect.DeepEqual(rewritten, want) {
+		t.Fatalf("expected metadata rewrite for %v, got %v", want, rewritten)
+	}
+
+	artistIDs := func(songID int) []int {
+		t.Helper()
+		artists, err := app.getArtistsForSong(songID)
+		if err != nil {
+			t.Fatalf("getArtistsForSong: %v", err)
+		}
+		ids := []int{}
+		for _, art := range artists {
+			ids = append(ids, art.ID)
+		}
+		return ids
+	}
+	if got, want := artistIDs(onlySource.ID), []int{other.ID, target.ID}; !reflect.DeepEqual(got, want) {
+		t.Errorf("only source: got %v want %v", got, want)
+	}
+	if got, want := artistIDs(both.ID), []int{target.ID, other.ID}; !reflect.DeepEqual(got, want) {
+		t.Errorf("both: got %v want %v", got, want)
+	}
+
+	albumArtists, err := app.getArtistsForAlbum(album.ID)
+	if err != nil {
+		t.Fatalf("getArtistsForAlbum: %v", err)
+	}
+	if len(albumArtists) != 1 || albumArtists[0].ID != target.ID {
+		t.Errorf("expected album to move to target, got %+v", albumArtists)
+	}
+
+	aliases, err := app.getAliasesForProducer(producer.ID)
+	if err != nil {
+		t.Fatalf("getAliasesForProducer: %v", err)
+	}
+	if len(aliases) != 1 || !reflect.DeepEqual(aliases[0].ArtistIDs, []int{target.ID}) {
+		t.Errorf("expected alias restriction to move to target, got %+v", aliases)
+	}
+
+	if gone, err := app.getArtistByID(source.ID); err != nil || gone != nil {
+		t.Fatalf("expected source to be deleted, got %+v (err %v)", gone, err)
+	}
+	merged, err := app.getArtistByID(target.ID)
+	if err != nil {
+		t.Fatalf("getArtistByID: %v", err)
+	}
+	if merged.CareerStartYear == nil || *merged.CareerStartYear != startYear {
+		t.Errorf("expected target to inherit career start year, got %+v", merged)
+	}
+
+	// the search index follows the moved links
+	if results, err := app.Search("thug", SearchFilters{}); err != nil || len(results) != 2 {
+		t.Errorf("expected both relinked songs to be found under the target, got %d results (err %v)", len(results), err)
+	}
+	if results, err := app.Search("thugg", SearchFilters{}); err != nil || len(results) != 0 {
+		t.Errorf("expected the source name to be gone from the index, got %d results (err %v)", len(results), err)
+	}
+}
+
+func TestMergeArtistsRejectsSameArtist(t *testing.T) {
+	app := newTestApp(t)
+
+	artist, err := app.CreateArtist(CreateArtistInput{Name: "Future"})
+	if err != nil {
+		t.Fatalf("CreateArtist: %v", err)
+	}
+	if _, err := app.MergeArtists(artist.ID, artist.ID); err == nil {
+		t.Fatal("expected an error merging an artist into itself")
+	}
+}
+
+func TestUpdateAndDeleteArtist(t *testing.T) {
+	app := newTestApp(t)
+
+	artist, err := app.CreateArtist(CreateArtistInput{Name: "Travis Scot"})
+	if err != nil {
+		t.Fatalf("CreateArtist: %v", err)
+	}
+	update := func(input UpdateArtistInput) (*Artist, error) {
+		t.Helper()
+		result, err := app.UpdateArtist(input)
+		if err != nil {
+			return nil, err
+		}
+		return &result.Artist, nil
+	}
+	name, start := "Travis Scott", 2008
+	updated, err := update(UpdateArtistInput{ID: artist.ID, Name: &name, CareerStartYear: &start})
+	if err != nil {
+		t.Fatalf("UpdateArtist: %v", err)
+	}
+	if updated.Name != name || updated.CareerStartYear == nil || *updated.CareerStartYear != start {
+		t.Fatalf("unexpected artist after update: %+v", updated)
+	}
+
+	// a rename alone keeps the career years, and 0 clears one
+	name = "Cactus Jack"
+	if updated, err = update(UpdateArtistInput{ID: artist.ID, Name: &name}); err != nil {
+		t.Fatalf("UpdateArtist: %v", err)
+	}
+	if updated.Name != name || updated.CareerStartYear == nil || *updated.CareerStartYear != start {
+		t.Fatalf("expected the rename to keep the career years, got %+v", updated)
+	}
+	// names are trimmed, and can't be blank or another artist's name or alias
+	padded := "  Cactus Jack "
+	if updated, err = update(UpdateArtistInput{ID: artist.ID, Name: &padded}); err != nil || updated.Name != name {
+		t.Fatalf("expected the name to be trimmed, got %+v (err %v)", updated, err)
+	}
+	other, err := app.CreateArtist(CreateArtistInput{Name: "Kid Cudi"})
+	if err != nil {
+		t.Fatalf("CreateArtist: %v", err)
+	}
+	if _, err := app.CreateArtistAlias(other.ID, "Mr. Rager"); err != nil {
+		t.Fatalf("CreateArtistAlias: %v", err)
+	}
+	for _, taken := range []string{" ", "kid cudi", "Mr. Rager"} {
+		if _, err := update(UpdateArtistInput{ID: artist.ID, Name: &taken}); err == nil {
+			t.Fatalf("expected renaming to %q to be rejected", taken)
+		}
+	}
+
+	start = 0
+	if updated, err = update(UpdateArtistInput{ID: artist.ID, CareerStartYear: &start}); err != nil {
+		t.Fatalf("UpdateArtist: %v", err)
+	}
+	if updated.Name != name || updated.CareerStartYear != nil {
+		t.Fatalf("expected the career start year to be cleared, got %+v", updated)
+	}
+
+	// a rename retags the artist's songs; other changes don't touch them
+	fullPath := writeSilentMP3(t, app, "uploads/songs/s.mp3")
+	song, err := app.CreateSong(CreateSongInput{Name: "Song", Filepath: "uploads/songs/s.mp3", ArtistIDs: []int{artist.ID, other.ID}})
+	if err != nil {
+		t.Fatalf("CreateSong: %v", err)
+	}
+	start = 2008
+	result, err := app.UpdateArtist(UpdateArtistInput{ID: artist.ID, CareerStartYear: &start})
+	if err != nil || len(result.Metadata.Results) != 0 {
+		t.Fatalf("expected no rewrite without a rename, got %+v (err %v)", result, err)
+	}
+	name = "Travis Scott"
+	if result, err = app.UpdateArtist(UpdateArtistInput{ID: artist.ID, Name: &name}); err != nil {
+		t.Fatalf("UpdateArtist: %v", err)
+	}
+	if result.Metadata.SongsProcessed != 1 || result.Metadata.SongsFailed != 0 {
+		t.Fatalf("expected the song to be rewritten, got %+v", result.Metadata)
+	}
+	if tags, err := (id3Adapter{}).Read(fullPath); err != nil || !strings.Contains(tags.Artist, name) {
+		t.Fatalf("expected the file to carry the new name, got %q (err %v)", tags.Artist, err)
+	}
+
+	batch, err := app.DeleteArtist(artist.ID)
+	if err != nil {
+		t.Fatalf("DeleteArtist: %v", err)
+	}
+	if batch.SongsProcessed != 1 {
+		t.Fatalf("expected the song to be rewritten, got %+v", batch)
+	}
+	if tags, err := (id3Adapter{}).Read(fullPath); err != nil || strings.Contains(tags.Artist, name) {
+		t.Fatalf("expected the file to drop the deleted artist, got %q (err %v)", tags.Artist, err)
+	}
+	if artists, err := app.getArtistsForSong(song.ID); err != nil || len(artists) != 1 || artists[0].ID != other.ID {
+		t.Fatalf("expected song links to be removed, got %+v (err %v)", artists, err)
+	}
+	if _, err := update(UpdateArtistInput{ID: artist.ID, Name: &name}); err == nil {
+		t.Fatal("expected updating a deleted artist to fail")
+	}
+	if _, err := app.DeleteArtist(artist.ID); err == nil {
+		t.Fatal("expected deleting a deleted artist to fail")
+	}
+}
+
+func TestArtistAliasesResolveUploadCredits(t *testing.T) {
+	app := newTestApp(t)
+
+	kanye, err := app.CreateArtist(CreateArtistInput{Name: "Kanye West"})
+	if err != nil {
+		t.Fatalf("CreateArtist: %v", err)
+	}
+	if _, err := app.CreateArtistAlias(kanye.ID, "Ye"); err != nil {
+		t.Fatalf("CreateArtistAlias: %v", err)
+	}
+	if _, err := app.CreateArtistAlias(kanye.ID, "YE"); err == nil {
+		t.Fatal("expected a case-insensitive duplicate alias to be rejected")
+	}
+	other, err := app.CreateArtist(CreateArtistInput{Name: "Kid Cudi"})
+	if err != nil {
+		t.Fatalf("CreateArtist: %v", err)
+	}
+	if _, err := app.CreateArtistAlias(other.ID, "kanye west"); err == nil {
+		t.Fatal("expected an alias matching another artist's name to be rejected")
+	}
+
+	resolved, err := app.resolveArtistName("ye")
+	if err != nil || resolved == nil || resolved.ID != kanye.ID {
+		t.Fatalf("expected alias to resolve to %d, got %+v (err %v)", kanye.ID, resolved, err)
+	}
+
+	songs, err := app.CreateSongsWithMetadata(CreateSongsWithMetadataInput{
+		FilesData: []FileData{{
+			OriginalFilename: "track.mp3",
+			Filepath:         "uploads/songs/track.mp3",
+			Metadata:         ExtractedMetadata{Title: "Track"},
+			ParsedArtists:    []string{"Ye", "Kanye West"},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("CreateSongsWithMetadata: %v", err)
+	}
+	artists, err := app.getArtistsForSong(songs[0].ID)
+	if err != nil {
+		t.Fatalf("getArtistsForSong: %v", err)
+	}
+	if len(artists) != 1 || artists[0].ID != kanye.ID {
+		t.Fatalf("expected a single credit for the aliased artist, got %+v", artists)
+	}
+}
+
+func TestMergeArtistsKeepsSourceNameAsAlias(t *testing.T) {
+	app := newTestApp(t)
+
+	target, err := app.CreateArtist(CreateArtistInput{Name: "Lil Uzi Vert"})
+	if err != nil {
+		t.Fatalf("CreateArtist: %v", err)
+	}
+	source, err := app.CreateArtist(CreateArtistInput{Name: "Uzi"})
+	if err != nil {
+		t.Fatalf("CreateArtist: %v", err)
+	}
+	if _, err := app.CreateArtistAlias(source.ID, "Baby Pluto"); err != nil {
+		t.Fatalf("CreateArtistAlias: %v", err)
+	}
+
+	if _, err := app.MergeArtists(source.ID, target.ID); err != nil {
+		t.Fatalf("MergeArtists: %v", err)
+	}
+
+	aliases, err := app.GetArtistAliases(target.ID)
+	if err != nil {
+		t.Fatalf("GetArtistAliases: %v", err)
+	}
+	names := []string{}
+	for _, alias := range aliases {
+		names = append(names, alias.Alias)
+	}
+	if want := []string{"Baby Pluto", "Uzi"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("expected aliases %v, got %v", want, names)
+	}
+}
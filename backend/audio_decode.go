@@ -0,0 +1,277 @@
+package backend
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// --- Audio Decoding ---
+
+var errNoDecoder = errors.New("no decoder for this format (install ffmpeg to fingerprint compressed audio)")
+
+// canDecode reports whether decodePCM can read a file: WAV and AIFF always,
+// compressed formats only when ffmpeg is installed.
+func canDecode(path string) bool {
+	return isPCMFile(path) || ffmpegAvailable()
+}
+
+// ffmpegAvailable reports whether an ffmpeg binary is on PATH.
+func ffmpegAvailable() bool {
+	_, err := exec.LookPath("ffmpeg")
+	return err == nil
+}
+
+func isPCMFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".wav", ".aif", ".aiff", ".aifc":
+		return true
+	}
+	return false
+}
+
+// decodePCM decodes the first fingerprintSeconds of a file to mono samples
+// at fingerprintSampleRate. WAV and AIFF are read directly; everything else
+// goes through ffmpeg.
+func decodePCM(path string) ([]float64, error) {
+	if isPCMFile(path) {
+		return decodeIFFPCM(path)
+	}
+	if !ffmpegAvailable() {
+		return nil, errNoDecoder
+	}
+	return decodeWithFFmpeg(path)
+}
+
+func decodeWithFFmpeg(path string) ([]float64, error) {
+	cmd := exec.Command("ffmpeg",
+		"-v", "error", "-nostdin",
+		"-i", path,
+		"-t", fmt.Sprint(fingerprintSeconds),
+		"-vn", "-ac", "1", "-ar", fmt.Sprint(fingerprintSampleRate),
+		"-f", "s16le", "-",
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg decode failed: %w (%s)", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	samples := make([]float64, len(out)/2)
+	for i := range samples {
+		samples[i] = float64(int16(binary.LittleEndian.Uint16(out[i*2:]))) / 32768
+	}
+	return samples, nil
+}
+
+// pcmFormat describes the sample layout of a WAV data or AIFF SSND chunk.
+type pcmFormat struct {
+	channels int
+	rate     int
+	bits     int
+	float    bool
+	unsigned bool // 8-bit WAV
+	order    binary.ByteOrder
+}
+
+// decodeIFFPCM reads integer or float PCM from a WAV or AIFF file, mixes it
+// down to mono, and resamples it.
+func decodeIFFPCM(path string) ([]float64, error) {
+	var file *iffFile
+	var err error
+	if strings.ToLower(filepath.Ext(path)) == ".wav" {
+		file, err = openIFF(path, "RIFF", binary.LittleEndian, "WAVE")
+	} else {
+		file, err = openIFF(path, "FORM", binary.BigEndian, "AIFF", "AIFC")
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.f.Close()
+
+	var format *pcmFormat
+	var data *iffChunk
+	for _, c := range file.chunks {
+		switch c.id {
+		case "fmt ", "COMM":
+			b, err := file.read(c)
+			if err != nil {
+				return nil, err
+			}
+			if c.id == "fmt " {
+				format, err = parseWAVFormat(b)
+			} else {
+				format, err = parseAIFFCommon(b, file.formType)
+			}
+			if err != nil {
+				return nil, err
+			}
+		case "data", "SSND":
+			chunk := c
+			if c.id == "SSND" {
+				// SSND data starts after an offset and block size
+				hdr := make([]byte, 8)
+				if c.size < 8 {
+					return nil, fmt.Errorf("invalid SSND chunk")
+				}
+				if _, err := file.f.ReadAt(hdr, c.offset); err != nil {
+					return nil, err
+				}
+				skip := 8 + int64(binary.BigEndian.Uint32(hdr))
+				chunk.offset, chunk.size = c.offset+skip, max(0, c.size-skip)
+			}
+			data = &chunk
+		}
+	}
+	if format == nil || data == nil {
+		return nil, fmt.Errorf("no audio data found")
+	}
+	if format.channels <= 0 || format.rate <= 0 || format.bits <= 0 || format.bits > 64 {
+		return nil, fmt.Errorf("unsupported PCM layout")
+	}
+
+	width := (format.bits + 7) / 8
+	frameSize := width * format.channels
+	frames := min(data.size/int64(frameSize), int64(format.rate)*fingerprintSeconds)
+	raw := make([]byte, frames*int64(frameSize))
+	if _, err := file.f.ReadAt(raw, data.offset); err != nil {
+		return nil, err
+	}
+
+	samples := make([]float64, frames)
+	for i := range samples {
+		sum := 0.0
+		for ch := 0; ch < format.channels; ch++ {
+			sum += pcmSample(raw[(i*format.channels+ch)*width:][:width], format)
+		}
+		samples[i] = sum / float64(format.channels)
+	}
+	return resamplePCM(samples, format.rate), nil
+}
+
+func parseWAVFormat(b []byte) (*pcmFormat, error) {
+	if len(b) < 16 {
+		return nil, fmt.Errorf("invalid fmt chunk")
+	}
+	code := binary.LittleEndian.Uint16(b[0:2])
+	if code == 0xFFFE && len(b) >= 26 { // WAVE_FORMAT_EXTENSIBLE: the sub-format GUID starts with the code
+		code = binary.LittleEndian.Uint16(b[24:26])
+	}
+	format := &pcmFormat{
+		channels: int(binary.LittleEndian.Uint16(b[2:4])),
+		rate:     int(binary.LittleEndian.Uint32(b[4:8])),
+		bits:     int(binary.LittleEndian.Uint16(b[14:16])),
+		order:    binary.LittleEndian,
+	}
+	format.unsigned = format.bits <= 8
+	switch code {
+	case 1:
+	case 3:
+		format.float = true
+	default:
+		return nil, fmt.Errorf("unsupported WAV encoding %#x", code)
+	}
+	return format, nil
+}
+
+func parseAIFFCommon(b []byte, formType string) (*pcmFormat, error) {
+	if len(b) < 18 {
+		return nil, fmt.Errorf("invalid COMM chunk")
+	}
+	format := &pcmFormat{
+		channels: int(binary.BigEndian.Uint16(b[0:2])),
+		bits:     int(binary.BigEndian.Uint16(b[6:8])),
+		rate:     int(math.Round(ieeeExtended(b[8:18]))),
+		order:    binary.BigEndian,
+	}
+	if formType == "AIFC" {
+		if len(b) < 22 {
+			return nil, fmt.Errorf("invalid COMM chunk")
+		}
+		switch string(b[18:22]) {
+		case "NONE":
+		case "sowt":
+			format.order = binary.LittleEndian
+		case "fl32", "FL32", "fl64", "FL64":
+			format.float = true
+		default:
+			return nil, fmt.Errorf("unsupported AIFF-C compression %q", b[18:22])
+		}
+	}
+	return format, nil
+}
+
+// ieeeExtended decodes the 80-bit float AIFF stores its sample rate in.
+func ieeeExtended(b []byte) float64 {
+	exp := int(binary.BigEndian.Uint16(b[0:2]) & 0x7FFF)
+	mantissa := binary.BigEndian.Uint64(b[2:10])
+	if exp == 0 && mantissa == 0 {
+		return 0
+	}
+	return math.Ldexp(float64(mantissa), exp-16383-63)
+}
+
+// pcmSample scales one sample to [-1, 1).
+func pcmSample(b []byte, format *pcmFormat) float64 {
+	if format.float {
+		if len(b) == 8 {
+			return math.Float64frombits(format.order.Uint64(b))
+		}
+		return float64(math.Float32frombits(format.order.Uint32(b)))
+	}
+	if len(b) == 1 {
+		if format.unsigned {
+			return float64(int(b[0])-128) / 128
+		}
+		return float64(int8(b[0])) / 128
+	}
+	// read big-endian, sign-extend from the top byte
+	var v int64
+	for i := range b {
+		j := i
+		if format.order == binary.LittleEndian {
+			j = len(b) - 1 - i
+		}
+		v = v<<8 | int64(b[j])
+	}
+	shift := 64 - 8*len(b)
+	v = v << shift >> shift
+	return float64(v) / math.Pow(2, float64(8*len(b)-1))
+}
+
+// resamplePCM converts mono samples at rate to fingerprintSampleRate with a
+// windowed-sinc low-pass, so content above the new Nyquist frequency doesn't
+// fold into the fingerprint bands.
+func resamplePCM(samples []float64, rate int) []float64 {
+	if rate == fingerprintSampleRate {
+		return samples
+	}
+	step := float64(rate) / fingerprintSampleRate
+	cutoff := 0.45 / max(step, 1) // cycles per input sample
+	half := int(math.Ceil(4 / cutoff))
+
+	out := make([]float64, int(float64(len(samples))/step))
+	for n := range out {
+		center := float64(n) * step
+		base := int(center)
+		sum := 0.0
+		for k := max(0, base-half+1); k <= min(len(samples)-1, base+half); k++ {
+			d := center - float64(k)
+			x := 2 * cutoff * d
+			sinc := 1.0
+			if x != 0 {
+				sinc = math.Sin(math.Pi*x) / (math.Pi * x)
+			}
+			window := 0.5 + 0.5*math.Cos(math.Pi*d/float64(half))
+			sum += samples[k] * 2 * cutoff * sinc * window
+		}
+		out[n] = sum
+	}
+	return out
+}
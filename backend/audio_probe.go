@@ -0,0 +1,696 @@
+package backend
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// --- Audio Probe ---
+
+// AudioProperties are the technical stream properties read from an audio
+// file's headers. Zero values mean "unknown" (e.g. BitDepth for lossy codecs).
+type AudioProperties struct {
+	Duration   float64 `json:"duration"`   // seconds
+	Bitrate    int     `json:"bitrate"`    // average, kbps
+	SampleRate int     `json:"sampleRate"` // Hz
+	Channels   int     `json:"channels"`
+	BitDepth   int     `json:"bitDepth"`
+	Codec      string  `json:"codec"`
+}
+
+var errUnknownAudioFormat = errors.New("unrecognized audio format")
+
+// ProbeAudio reads duration and stream properties straight from the container
+// headers. It never decodes audio, so it is cheap enough to run per upload.
+// Supported: MP3 (Xing/Info, VBRI, or a frame scan), FLAC STREAMINFO, Ogg
+// Vorbis/Opus (last granule position), and MP4/M4A (mvhd/mdhd + stsd).
+func ProbeAudio(path string) (*AudioProperties, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := info.Size()
+
+	head := make([]byte, 12)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	head = head[:n]
+
+	var props *AudioProperties
+	switch {
+	case bytes.HasPrefix(head, []byte("fLaC")):
+		props, err = probeFLAC(f, 0, size)
+	case bytes.HasPrefix(head, []byte("OggS")):
+		props, err = probeOgg(f, size)
+	case len(head) >= 8 && string(head[4:8]) == "ftyp":
+		props, err = probeMP4(f, size)
+	case bytes.HasPrefix(head, []byte("ID3")):
+		// ID3v2 can front both MP3 and (rarely) FLAC
+		start, serr := id3v2End(f)
+		if serr != nil {
+			return nil, serr
+		}
+		marker := make([]byte, 4)
+		if _, rerr := f.ReadAt(marker, start); rerr == nil && string(marker) == "fLaC" {
+			props, err = probeFLAC(f, start, size)
+		} else {
+			props, err = probeMP3(f, start, size)
+		}
+	case len(head) >= 2 && head[0] == 0xFF && head[1]&0xE0 == 0xE0:
+		props, err = probeMP3(f, 0, size)
+	default:
+		return nil, errUnknownAudioFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+	return props, nil
+}
+
+// fileTypeFromPath is the value stored in songs.file_type: the lowercase
+// extension without the dot.
+func fileTypeFromPath(path string) string {
+	return strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+}
+
+// averageKbps is the bitrate implied by a payload size over a duration.
+func averageKbps(bytes int64, seconds float64) int {
+	if bytes <= 0 || seconds <= 0 {
+		return 0
+	}
+	return int(float64(bytes)*8/seconds/1000 + 0.5)
+}
+
+// id3v2End returns the offset of the first byte after a leading ID3v2 tag.
+func id3v2End(r io.ReaderAt) (int64, error) {
+	hdr := make([]byte, 10)
+	if _, err := r.ReadAt(hdr, 0); err != nil {
+		return 0, err
+	}
+	if string(hdr[:3]) != "ID3" {
+		return 0, nil
+	}
+	size := int64(hdr[6]&0x7F)<<21 | int64(hdr[7]&0x7F)<<14 | int64(hdr[8]&0x7F)<<7 | int64(hdr[9]&0x7F)
+	end := 10 + size
+	if hdr[5]&0x10 != 0 { // footer present
+		end += 10
+	}
+	return end, nil
+}
+
+// --- MP3 ---
+
+type mpegFrameHeader struct {
+	version         int // 1, 2, or 25 (MPEG 2.5)
+	layer           int
+	bitrate         int // kbps
+	sampleRate      int
+	padding         int
+	channels        int
+	samplesPerFrame int
+	frameLength     int
+}
+
+var mpegBitrates = map[[2]int][16]int{
+	{1, 1}: {0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448, 0},
+	{1, 2}: {0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384, 0},
+	{1, 3}: {0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0},
+	{2, 1}: {0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256, 0},
+	{2, 2}: {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+	{2, 3}: {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+}
+
+var mpegSampleRates = map[int][3]int{
+	1:  {44100, 48000, 32000},
+	2:  {22050, 24000, 16000},
+	25: {11025, 12000, 8000},
+}
+
+func parseMPEGFrameHeader(b []byte) (mpegFrameHeader, bool) {
+	if len(b) < 4 || b[0] != 0xFF || b[1]&0xE0 != 0xE0 {
+		return mpegFrameHeader{}, false
+	}
+	var h mpegFrameHeader
+	switch (b[1] >> 3) & 0x03 {
+	case 0:
+		h.version = 25
+	case 2:
+		h.version = 2
+	case 3:
+		h.version = 1
+	default:
+		return mpegFrameHeader{}, false
+	}
+	switch (b[1] >> 1) & 0x03 {
+	case 1:
+		h.layer = 3
+	case 2:
+		h.layer = 2
+	case 3:
+		h.layer = 1
+	default:
+		return mpegFrameHeader{}, false
+	}
+
+	bitrateIndex := int(b[2] >> 4)
+	rateIndex := int((b[2] >> 2) & 0x03)
+	if bitrateIndex == 0 || bitrateIndex == 15 || rateIndex == 3 {
+		return mpegFrameHeader{}, false
+	}
+	tableVersion := h.version
+	if tableVersion == 25 {
+		tableVersion = 2
+	}
+	h.bitrate = mpegBitrates[[2]int{tableVersion, h.layer}][bitrateIndex]
+	h.sampleRate = mpegSampleRates[h.version][rateIndex]
+	h.padding = int((b[2] >> 1) & 0x01)
+	h.channels = 2
+	if b[3]>>6 == 3 {
+		h.channels = 1
+	}
+
+	switch {
+	case h.layer == 1:
+		h.samplesPerFrame = 384
+		h.frameLength = (12*h.bitrate*1000/h.sampleRate + h.padding) * 4
+	case h.layer == 3 && h.version != 1:
+		h.samplesPerFrame = 576
+		h.frameLength = 72*h.bitrate*1000/h.sampleRate + h.padding
+	default:
+		h.samplesPerFrame = 1152
+		h.frameLength = 144*h.bitrate*1000/h.sampleRate + h.padding
+	}
+	if h.frameLength < 4 {
+		return mpegFrameHeader{}, false
+	}
+	return h, true
+}
+
+// probeMP3 prefers the Xing/Info or VBRI header in the first frame and falls
+// back to walking every frame, which is exact for CBR and VBR alike.
+func probeMP3(r io.ReaderAt, start, size int64) (*AudioProperties, error) {
+	// locate the first valid frame; tolerate some junk after the tag
+	window := make([]byte, 64*1024)
+	n, err := r.ReadAt(window, start)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	window = window[:n]
+
+	firstOffset := -1
+	var first mpegFrameHeader
+	for i := 0; i+4 <= len(window); i++ {
+		h, ok := parseMPEGFrameHeader(window[i:])
+		if !ok {
+			continue
+		}
+		// confirm with the following frame when it is inside the window
+		next := i + h.frameLength
+		if next+4 <= len(window) {
+			if _, ok := parseMPEGFrameHeader(window[next:]); !ok {
+				continue
+			}
+		}
+		firstOffset = i
+		first = h
+		break
+	}
+	if firstOffset < 0 {
+		return nil, fmt.Errorf("no mpeg audio frame found")
+	}
+
+	props := &AudioProperties{
+		SampleRate: first.sampleRate,
+		Channels:   first.channels,
+		Codec:      fmt.Sprintf("mp%d", first.layer),
+	}
+	audioStart := start + int64(firstOffset)
+	audioBytes := size - audioStart
+	tail := make([]byte, 3)
+	if size >= 128 {
+		if _, err := r.ReadAt(tail, size-128); err == nil && string(tail) == "TAG" {
+			audioBytes -= 128
+		}
+	}
+
+	frame := window[firstOffset:]
+	if frames, bytesField, ok := parseVBRHeader(frame, first); ok && frames > 0 {
+		props.Duration = float64(frames) * float64(first.samplesPerFrame) / float64(first.sampleRate)
+		if bytesField > 0 {
+			audioBytes = bytesField
+		}
+		props.Bitrate = averageKbps(audioBytes, props.Duration)
+		return props, nil
+	}
+
+	frames, scanned, err := scanMPEGFrames(r, audioStart, size)
+	if err != nil {
+		return nil, err
+	}
+	if frames == 0 {
+		return nil, fmt.Errorf("no mpeg audio frames found")
+	}
+	props.Duration = float64(frames) * float64(first.samplesPerFrame) / float64(first.sampleRate)
+	props.Bitrate = averageKbps(scanned, props.Duration)
+	return props, nil
+}
+
+// parseVBRHeader reads a Xing/Info or VBRI header from the first frame.
+// Returns the frame count and (if present) the audio byte count.
+func parseVBRHeader(frame []byte, h mpegFrameHeader) (frames int64, byteCount int64, ok bool) {
+	sideInfo := 32
+	switch {
+	case h.version == 1 && h.channels == 1:
+		sideInfo = 17
+	case h.version != 1 && h.channels == 2:
+		sideInfo = 17
+	case h.version != 1 && h.channels == 1:
+		sideInfo = 9
+	}
+
+	xing := 4 + sideInfo
+	if len(frame) >= xing+16 {
+		tag := string(frame[xing : xing+4])
+		if tag == "Xing" || tag == "Info" {
+			flags := binary.BigEndian.Uint32(frame[xing+4:])
+			pos := xing + 8
+			if flags&0x1 != 0 {
+				frames = int64(binary.BigEndian.Uint32(frame[pos:]))
+				pos += 4
+			}
+			if flags&0x2 != 0 && len(frame) >= pos+4 {
+				byteCount = int64(binary.BigEndian.Uint32(frame[pos:]))
+			}
+			return frames, byteCount, frames > 0
+		}
+	}
+
+	const vbri = 4 + 32
+	if len(frame) >= vbri+18 && string(frame[vbri:vbri+4]) == "VBRI" {
+		byteCount = int64(binary.BigEndian.Uint32(frame[vbri+10:]))
+		frames = int64(binary.BigEndian.Uint32(frame[vbri+14:]))
+		return frames, byteCount, frames > 0
+	}
+	return 0, 0, false
+}
+
+// scanMPEGFrames walks frame headers from start, returning the frame count and
+// the number of bytes they cover. Stops at the first non-frame (ID3v1, APE, junk).
+func scanMPEGFrames(r io.ReaderAt, start, size int64) (frames int64, covered int64, err error) {
+	br := bufio.NewReaderSize(io.NewSectionReader(r, start, size-start), 64*1024)
+	hdr := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(br, hdr); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return frames, covered, nil
+			}
+			return 0, 0, err
+		}
+		h, ok := parseMPEGFrameHeader(hdr)
+		if !ok {
+			return frames, covered, nil
+		}
+		discarded, err := br.Discard(h.frameLength - 4)
+		covered += int64(4 + discarded)
+		if err != nil {
+			// a truncated final frame still counts
+			return frames + 1, covered, nil
+		}
+		frames++
+	}
+}
+
+// --- FLAC ---
+
+// probeFLAC reads STREAMINFO, the mandatory first metadata block.
+func probeFLAC(r io.ReaderAt, start, size int64) (*AudioProperties, error) {
+	pos := start + 4
+	var props *AudioProperties
+	hdr := make([]byte, 4)
+	for {
+		if _, err := r.ReadAt(hdr, pos); err != nil {
+			return nil, fmt.Errorf("failed to read flac metadata block: %w", err)
+		}
+		last := hdr[0]&0x80 != 0
+		blockType := hdr[0] & 0x7F
+		length := int64(hdr[1])<<16 | int64(hdr[2])<<8 | int64(hdr[3])
+
+		if blockType == 0 {
+			if length < 34 {
+				return nil, fmt.Errorf("invalid flac streaminfo")
+			}
+			info := make([]byte, 34)
+			if _, err := r.ReadAt(info, pos+4); err != nil {
+				return nil, err
+			}
+			// bytes 10..17: 20 bits rate, 3 bits channels-1, 5 bits bps-1, 36 bits total samples
+			packed := binary.BigEndian.Uint64(info[10:18])
+			sampleRate := int(packed >> 44)
+			channels := int((packed>>41)&0x07) + 1
+			bitDepth := int((packed>>36)&0x1F) + 1
+			totalSamples := packed & 0xFFFFFFFFF
+			props = &AudioProperties{
+				SampleRate: sampleRate,
+				Channels:   channels,
+				BitDepth:   bitDepth,
+				Codec:      "flac",
+			}
+			if sampleRate > 0 {
+				props.Duration = float64(totalSamples) / float64(sampleRate)
+			}
+		}
+
+		pos += 4 + length
+		if last {
+			break
+		}
+		if pos >= size {
+			return nil, fmt.Errorf("truncated flac metadata")
+		}
+	}
+	if props == nil {
+		return nil, fmt.Errorf("flac streaminfo not found")
+	}
+	props.Bitrate = averageKbps(size-pos, props.Duration)
+	return props, nil
+}
+
+// --- Ogg ---
+
+type oggPageHeader struct {
+	granule    int64
+	serial     uint32
+	headerSize int
+	bodySize   int
+}
+
+func parseOggPageHeader(b []byte) (oggPageHeader, bool) {
+	if len(b) < 27 || string(b[:4]) != "OggS" {
+		return oggPageHeader{}, false
+	}
+	segments := int(b[26])
+	if len(b) < 27+segments {
+		return oggPageHeader{}, false
+	}
+	body := 0
+	for _, s := range b[27 : 27+segments] {
+		body += int(s)
+	}
+	return oggPageHeader{
+		granule:    int64(binary.LittleEndian.Uint64(b[6:14])),
+		serial:     binary.LittleEndian.Uint32(b[14:18]),
+		headerSize: 27 + segments,
+		bodySize:   body,
+	}, true
+}
+
+// probeOgg reads the codec identification packet from the first page and the
+// granule position of the stream's last page.
+func probeOgg(r io.ReaderAt, size int64) (*AudioProperties, error) {
+	first := make([]byte, 27+255+255*255)
+	n, err := r.ReadAt(first, 0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	first = first[:n]
+	page, ok := parseOggPageHeader(first)
+	if !ok || len(first) < page.headerSize+page.bodySize {
+		return nil, fmt.Errorf("invalid ogg page")
+	}
+	packet := first[page.headerSize : page.headerSize+page.bodySize]
+
+	props := &AudioProperties{}
+	var rate, preSkip int64
+	switch {
+	case len(packet) >= 30 && packet[0] == 0x01 && string(packet[1:7]) == "vorbis":
+		props.Codec = "vorbis"
+		props.Channels = int(packet[11])
+		props.SampleRate = int(binary.LittleEndian.Uint32(packet[12:16]))
+		rate = int64(props.SampleRate)
+	case len(packet) >= 19 && string(packet[:8]) == "OpusHead":
+		props.Codec = "opus"
+		props.Channels = int(packet[9])
+		preSkip = int64(binary.LittleEndian.Uint16(packet[10:12]))
+		// the header carries the original input rate; decoding is always 48 kHz
+		props.SampleRate = int(binary.LittleEndian.Uint32(packet[12:16]))
+		if props.SampleRate == 0 {
+			props.SampleRate = 48000
+		}
+		rate = 48000
+	default:
+		return nil, fmt.Errorf("unsupported ogg codec")
+	}
+
+	granule, err := lastOggGranule(r, size, page.serial)
+	if err != nil {
+		return nil, err
+	}
+	if rate > 0 && granule > preSkip {
+		props.Duration = float64(granule-preSkip) / float64(rate)
+	}
+	props.Bitrate = averageKbps(size, props.Duration)
+	return props, nil
+}
+
+// lastOggGranule scans backwards from EOF for the final page of serial.
+func lastOggGranule(r io.ReaderAt, size int64, serial uint32) (int64, error) {
+	const chunk = 64 * 1024
+	end := size
+	for end > 0 {
+		start := end - chunk
+		if start < 0 {
+			start = 0
+		}
+		// overlap by a page header so a capture pattern split across chunks is still seen
+		readEnd := end + 27
+		if readEnd > size {
+			readEnd = size
+		}
+		buf := make([]byte, readEnd-start)
+		if _, err := r.ReadAt(buf, start); err != nil && err != io.EOF {
+			return 0, err
+		}
+		for i := bytes.LastIndex(buf, []byte("OggS")); i >= 0; i = bytes.LastIndex(buf[:i], []byte("OggS")) {
+			page, ok := parseOggPageHeader(buf[i:])
+			if ok && page.serial == serial && page.granule >= 0 {
+				return page.granule, nil
+			}
+		}
+		end = start
+	}
+	return 0, fmt.Errorf("no ogg page with a granule position found")
+}
+
+// --- MP4 ---
+
+type mp4Box struct {
+	kind       string
+	start      int64 // offset of the box header
+	headerSize int64
+	size       int64 // total size including header
+}
+
+// readMP4Boxes lists the boxes between start and end.
+func readMP4Boxes(r io.ReaderAt, start, end int64) ([]mp4Box, error) {
+	boxes := []mp4Box{}
+	hdr := make([]byte, 16)
+	for pos := start; pos+8 <= end; {
+		if _, err := r.ReadAt(hdr[:8], pos); err != nil {
+			return nil, err
+		}
+		box := mp4Box{kind: string(hdr[4:8]), start: pos, headerSize: 8, size: int64(binary.BigEndian.Uint32(hdr[:4]))}
+		switch box.size {
+		case 0:
+			box.size = end - pos
+		case 1:
+			if _, err := r.ReadAt(hdr[8:16], pos+8); err != nil {
+				return nil, err
+			}
+			box.size = int64(binary.BigEndian.Uint64(hdr[8:16]))
+			box.headerSize = 16
+		}
+		if box.size < box.headerSize || pos+box.size > end {
+			return nil, fmt.Errorf("invalid mp4 box %q at %d", box.kind, pos)
+		}
+		boxes = append(boxes, box)
+		pos += box.size
+	}
+	return boxes, nil
+}
+
+func findMP4Box(boxes []mp4Box, kind string) *mp4Box {
+	for i := range boxes {
+		if boxes[i].kind == kind {
+			return &boxes[i]
+		}
+	}
+	return nil
+}
+
+// probeMP4 takes duration from the sound track's mdhd (falling back to mvhd)
+// and stream properties from its first stsd sample entry.
+func probeMP4(r io.ReaderAt, size int64) (*AudioProperties, error) {
+	top, err := readMP4Boxes(r, 0, size)
+	if err != nil {
+		return nil, err
+	}
+	moov := findMP4Box(top, "moov")
+	if moov == nil {
+		return nil, fmt.Errorf("mp4 has no moov box")
+	}
+	var mdatBytes int64
+	for _, b := range top {
+		if b.kind == "mdat" {
+			mdatBytes += b.size - b.headerSize
+		}
+	}
+
+	children, err := readMP4Boxes(r, moov.start+moov.headerSize, moov.start+moov.size)
+	if err != nil {
+		return nil, err
+	}
+
+	props := &AudioProperties{}
+	if mvhd := findMP4Box(children, "mvhd"); mvhd != nil {
+		if d, err := readMP4Duration(r, mvhd); err == nil {
+			props.Duration = d
+		}
+	}
+
+	for _, trak := range children {
+		if trak.kind != "trak" {
+			continue
+		}
+		trakChildren, err := readMP4Boxes(r, trak.start+trak.headerSize, trak.start+trak.size)
+		if err != nil {
+			return nil, err
+		}
+		mdia := findMP4Box(trakChildren, "mdia")
+		if mdia == nil {
+			continue
+		}
+		mdiaChildren, err := readMP4Boxes(r, mdia.start+mdia.headerSize, mdia.start+mdia.size)
+		if err != nil {
+			return nil, err
+		}
+		hdlr := findMP4Box(mdiaChildren, "hdlr")
+		if hdlr == nil {
+			continue
+		}
+		handler := make([]byte, 4)
+		// full box header (4) + pre_defined (4), then handler_type
+		if _, err := r.ReadAt(handler, hdlr.start+hdlr.headerSize+8); err != nil || string(handler) != "soun" {
+			continue
+		}
+
+		if mdhd := findMP4Box(mdiaChildren, "mdhd"); mdhd != nil {
+			if d, err := readMP4Duration(r, mdhd); err == nil && d > 0 {
+				props.Duration = d
+			}
+		}
+		if stsd := findMP4Path(r, mdiaChildren, "minf", "stbl", "stsd"); stsd != nil {
+			readMP4SampleEntry(r, stsd, props)
+		}
+		break
+	}
+
+	if props.Codec == "" {
+		return nil, fmt.Errorf("mp4 has no audio track")
+	}
+	props.Bitrate = averageKbps(mdatBytes, props.Duration)
+	return props, nil
+}
+
+// findMP4Path descends through nested container boxes.
+func findMP4Path(r io.ReaderAt, boxes []mp4Box, path ...string) *mp4Box {
+	current := boxes
+	for i, kind := range path {
+		box := findMP4Box(current, kind)
+		if box == nil {
+			return nil
+		}
+		if i == len(path)-1 {
+			return box
+		}
+		next, err := readMP4Boxes(r, box.start+box.headerSize, box.start+box.size)
+		if err != nil {
+			return nil
+		}
+		current = next
+	}
+	return nil
+}
+
+// readMP4Duration reads timescale/duration from an mvhd or mdhd full box.
+func readMP4Duration(r io.ReaderAt, box *mp4Box) (float64, error) {
+	body := make([]byte, 32)
+	n, err := r.ReadAt(body, box.start+box.headerSize)
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
+	body = body[:n]
+	if len(body) < 20 {
+		return 0, fmt.Errorf("short %s box", box.kind)
+	}
+	var timescale uint32
+	var duration uint64
+	if body[0] == 1 {
+		if len(body) < 32 {
+			return 0, fmt.Errorf("short %s box", box.kind)
+		}
+		timescale = binary.BigEndian.Uint32(body[20:24])
+		duration = binary.BigEndian.Uint64(body[24:32])
+	} else {
+		timescale = binary.BigEndian.Uint32(body[12:16])
+		duration = uint64(binary.BigEndian.Uint32(body[16:20]))
+	}
+	if timescale == 0 {
+		return 0, fmt.Errorf("zero timescale in %s", box.kind)
+	}
+	return float64(duration) / float64(timescale), nil
+}
+
+// readMP4SampleEntry fills codec, channels, sample size, and rate from the
+// first AudioSampleEntry in stsd.
+func readMP4SampleEntry(r io.ReaderAt, stsd *mp4Box, props *AudioProperties) {
+	// full box header (4) + entry_count (4), then the first entry
+	entry := make([]byte, 36)
+	if _, err := r.ReadAt(entry, stsd.start+stsd.headerSize+8); err != nil {
+		return
+	}
+	format := string(entry[4:8])
+	switch format {
+	case "mp4a":
+		props.Codec = "aac"
+	case "alac":
+		props.Codec = "alac"
+	case "fLaC":
+		props.Codec = "flac"
+	case "Opus":
+		props.Codec = "opus"
+	default:
+		props.Codec = strings.TrimSpace(strings.ToLower(format))
+	}
+	// 8-byte box header, 6 reserved, 2 data_reference_index, 8 version/revision/vendor
+	props.Channels = int(binary.BigEndian.Uint16(entry[24:26]))
+	sampleSize := int(binary.BigEndian.Uint16(entry[26:28]))
+	props.SampleRate = int(binary.BigEndian.Uint32(entry[32:36]) >> 16)
+	if props.Codec == "alac" || props.Codec == "flac" {
+		props.BitDepth = sampleSize
+	}
+}
@@ -0,0 +1,274 @@
+package backend
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFixture(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(p, data, 0644); err != nil {
+		t.Fatalf("write fixture: %v", err)
+	}
+	return p
+}
+
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) < 0.01
+}
+
+// mp3Frame builds one MPEG-1 Layer III frame at 128 kbps / 44.1 kHz stereo.
+func mp3Frame(payload []byte) []byte {
+	frame := make([]byte, 417) // 144 * 128000 / 44100
+	copy(frame, []byte{0xFF, 0xFB, 0x90, 0x00})
+	copy(frame[4:], payload)
+	return frame
+}
+
+// writeSilentMP3 saves ten silent frames at relPath under the app's static
+// directory and returns the full path.
+func writeSilentMP3(t *testing.T, app *App, relPath string) string {
+	t.Helper()
+	var frames []byte
+	for i := 0; i < 10; i++ {
+		frames = append(frames, mp3Frame(nil)...)
+	}
+	fullPath := filepath.Join(app.staticPath, filepath.FromSlash(relPath))
+	if err := os.WriteFile(fullPath, frames, 0644); err != nil {
+		t.Fatalf("write song: %v", err)
+	}
+	return fullPath
+}
+
+func id3v2Header(size int) []byte {
+	return []byte{'I', 'D', '3', 4, 0, 0,
+		byte(size >> 21 & 0x7F), byte(size >> 14 & 0x7F), byte(size >> 7 & 0x7F), byte(size & 0x7F)}
+}
+
+func TestProbeAudioMP3FrameScan(t *testing.T) {
+	var buf bytes.Buffer
+	buf.Write(id3v2Header(20))
+	buf.Write(make([]byte, 20))
+	for i := 0; i < 100; i++ {
+		buf.Write(mp3Frame(nil))
+	}
+
+	props, err := ProbeAudio(writeFixture(t, "cbr.mp3", buf.Bytes()))
+	if err != nil {
+		t.Fatalf("ProbeAudio: %v", err)
+	}
+	if want := 100 * 1152 / 44100.0; !approxEqual(props.Duration, want) {
+		t.Errorf("duration: got %f want %f", props.Duration, want)
+	}
+	if props.Bitrate != 128 || props.SampleRate != 44100 || props.Channels != 2 || props.Codec != "mp3" {
+		t.Errorf("unexpected properties: %+v", props)
+	}
+}
+
+func TestProbeAudioMP3XingHeader(t *testing.T) {
+	xing := make([]byte, 32+16) // side info, then the Xing header
+	copy(xing[32:], "Xing")
+	binary.BigEndian.PutUint32(xing[36:], 0x3)     // frames + bytes present
+	binary.BigEndian.PutUint32(xing[40:], 5000)    // frames
+	binary.BigEndian.PutUint32(xing[44:], 2000000) // bytes
+
+	var buf bytes.Buffer
+	buf.Write(mp3Frame(xing))
+	buf.Write(mp3Frame(nil))
+
+	props, err := ProbeAudio(writeFixture(t, "vbr.mp3", buf.Bytes()))
+	if err != nil {
+		t.Fatalf("ProbeAudio: %v", err)
+	}
+	wantDuration := 5000 * 1152 / 44100.0
+	if !approxEqual(props.Duration, wantDuration) {
+		t.Errorf("duration: got %f want %f", props.Duration, wantDuration)
+	}
+	if want := averageKbps(2000000, wantDuration); props.Bitrate != want {
+		t.Errorf("bitrate: got %d want %d", props.Bitrate, want)
+	}
+}
+
+func TestProbeAudioFLACStreamInfo(t *testing.T) {
+	info := make([]byte, 34)
+	// 48000 Hz, 2 channels, 24 bits, 480000 samples
+	packed := uint64(48000)<<44 | uint64(2-1)<<41 | uint64(24-1)<<36 | 480000
+	binary.BigEndian.PutUint64(info[10:], packed)
+
+	var buf bytes.Buffer
+	buf.WriteString("fLaC")
+	buf.Write([]byte{0x80, 0, 0, 34}) // last block, STREAMINFO
+	buf.Write(info)
+	buf.Write(make([]byte, 125000)) // 1,000,000 bits of "audio" over 10s
+
+	props, err := ProbeAudio(writeFixture(t, "song.flac", buf.Bytes()))
+	if err != nil {
+		t.Fatalf("ProbeAudio: %v", err)
+	}
+	want := AudioProperties{Duration: 10, Bitrate: 100, SampleRate: 48000, Channels: 2, BitDepth: 24, Codec: "flac"}
+	if *props != want {
+		t.Errorf("got %+v want %+v", *props, want)
+	}
+}
+
+// oggPage builds a single-segment-table Ogg page (CRC left zero; the probe
+// does not verify it).
+func oggPage(serial uint32, granule int64, body []byte) []byte {
+	hdr := make([]byte, 27)
+	copy(hdr, "OggS")
+	binary.LittleEndian.PutUint64(hdr[6:], uint64(granule))
+	binary.LittleEndian.PutUint32(hdr[14:], serial)
+	segments := []byte{}
+	remaining := len(body)
+	for remaining >= 255 {
+		segments = append(segments, 255)
+		remaining -= 255
+	}
+	segments = append(segments, byte(remaining))
+	hdr[26] = byte(len(segments))
+	return append(append(hdr, segments...), body...)
+}
+
+func TestProbeAudioOggOpus(t *testing.T) {
+	head := make([]byte, 19)
+	copy(head, "OpusHead")
+	head[8] = 1
+	head[9] = 2
+	binary.LittleEndian.PutUint16(head[10:], 312)
+	binary.LittleEndian.PutUint32(head[12:], 44100)
+
+	var buf bytes.Buffer
+	buf.Write(oggPage(7, 0, head))
+	buf.Write(oggPage(7, 0, []byte("OpusTags")))
+	buf.Write(oggPage(7, 48000*3+312, make([]byte, 400)))
+
+	props, err := ProbeAudio(writeFixture(t, "snippet.opus", buf.Bytes()))
+	if err != nil {
+		t.Fatalf("ProbeAudio: %v", err)
+	}
+	if !approxEqual(props.Duration, 3) || props.Codec != "opus" || props.Channels != 2 || props.SampleRate != 44100 {
+		t.Errorf("unexpected properties: %+v", props)
+	}
+}
+
+// mp4Atom wraps children in a box of the given kind.
+func mp4Atom(kind string, children ...[]byte) []byte {
+	body := bytes.Join(children, nil)
+	out := make([]byte, 8, 8+len(body))
+	binary.BigEndian.PutUint32(out, uint32(8+len(body)))
+	copy(out[4:], kind)
+	return append(out, body...)
+}
+
+// minimalMP4 builds ftyp + moov (one AAC sound track) + mdat.
+func minimalMP4(timescale, duration uint32, mdatPayload []byte) []byte {
+	mvhd := make([]byte, 100)
+	binary.BigEndian.PutUint32(mvhd[12:], 1000)
+	binary.BigEndian.PutUint32(mvhd[16:], duration*1000/timescale)
+
+	mdhd := make([]byte, 24)
+	binary.BigEndian.PutUint32(mdhd[12:], timescale)
+	binary.BigEndian.PutUint32(mdhd[16:], duration)
+
+	hdlr := make([]byte, 25)
+	copy(hdlr[8:], "soun")
+
+	entry := make([]byte, 28)
+	binary.BigEndian.PutUint16(entry[6:], 1)                  // data_reference_index
+	binary.BigEndian.PutUint16(entry[16:], 2)                 // channels
+	binary.BigEndian.PutUint16(entry[18:], 16)                // sample size
+	binary.BigEndian.PutUint32(entry[24:], uint32(44100)<<16) // 16.16 rate
+	stsd := append([]byte{0, 0, 0, 0, 0, 0, 0, 1}, mp4Atom("mp4a", entry)...)
+
+	stco := make([]byte, 12)
+	binary.BigEndian.PutUint32(stco[4:], 1)
+
+	moov := mp4Atom("moov",
+		mp4Atom("mvhd", mvhd),
+		mp4Atom("trak",
+			mp4Atom("mdia",
+				mp4Atom("mdhd", mdhd),
+				mp4Atom("hdlr", hdlr),
+				mp4Atom("minf",
+					mp4Atom("stbl",
+						mp4Atom("stsd", stsd),
+						mp4Atom("stco", stco),
+					),
+				),
+			),
+		),
+	)
+	ftyp := mp4Atom("ftyp", []byte("M4A "), []byte{0, 0, 0, 0}, []byte("M4A isom"))
+
+	// point the single chunk offset at the mdat payload
+	mdatOffset := len(ftyp) + len(moov) + 8
+	binary.BigEndian.PutUint32(moov[len(moov)-4:], uint32(mdatOffset))
+
+	return bytes.Join([][]byte{ftyp, moov, mp4Atom("mdat", mdatPayload)}, nil)
+}
+
+func TestProbeAudioMP4(t *testing.T) {
+	data := minimalMP4(44100, 44100*4, make([]byte, 64000)) // 4s, 512000 bits
+
+	props, err := ProbeAudio(writeFixture(t, "song.m4a", data))
+	if err != nil {
+		t.Fatalf("ProbeAudio: %v", err)
+	}
+	want := AudioProperties{Duration: 4, Bitrate: 128, SampleRate: 44100, Channels: 2, Codec: "aac"}
+	if *props != want {
+		t.Errorf("got %+v want %+v", *props, want)
+	}
+}
+
+func TestProbeAudioRejectsNonAudio(t *testing.T) {
+	if _, err := ProbeAudio(writeFixture(t, "notes.txt", []byte("not audio at all"))); err == nil {
+		t.Fatal("expected an error for a non-audio file")
+	}
+}
+
+func TestExtractMetadataFillsDurationForUntaggedFiles(t *testing.T) {
+	app := newTestApp(t)
+
+	var buf bytes.Buffer
+	for i := 0; i < 50; i++ {
+		buf.Write(mp3Frame(nil))
+	}
+	relPath := "uploads/songs/untagged.mp3"
+	fullPath, err := app.staticFilePath(relPath)
+	if err != nil {
+		t.Fatalf("staticFilePath: %v", err)
+	}
+	if err := os.WriteFile(fullPath, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("write song: %v", err)
+	}
+
+	metadata, err := app.ExtractMetadata(relPath)
+	if err != nil {
+		t.Fatalf("ExtractMetadata: %v", err)
+	}
+	if metadata.Audio == nil || !approxEqual(metadata.Duration, 50*1152/44100.0) {
+		t.Fatalf("expected probed duration, got %+v", metadata)
+	}
+
+	songs, err := app.CreateSongsWithMetadata(CreateSongsWithMetadataInput{
+		FilesData: []FileData{{OriginalFilename: "untagged.mp3", Filepath: relPath, Metadata: *metadata}},
+	})
+	if err != nil {
+		t.Fatalf("CreateSongsWithMetadata: %v", err)
+	}
+	song, err := app.getSongByID(songs[0].ID)
+	if err != nil || song == nil {
+		t.Fatalf("getSongByID: %v", err)
+	}
+	if song.Duration == nil || song.FileType == nil || *song.FileType != "mp3" {
+		t.Fatalf("expected duration and file type to be stored, got %+v", song)
+	}
+	if song.Bitrate == nil || *song.Bitrate != 128 || song.Codec == nil || *song.Codec != "mp3" || song.BitDepth != nil {
+		t.Fatalf("expected stream properties to be stored, got %+v", song)
+	}
+}
@@ -0,0 +1,481 @@
+package backend
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	sqlite3driver "github.com/mattn/go-sqlite3"
+	wailsruntime "github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// --- Backup / Restore ---
+
+// A backup is a zip holding backupDatabaseName (a VACUUM INTO snapshot of
+// local.db), every upload the database references under its uploads/...
+// path, and backupManifestName, written last, with a SHA-256 per file.
+const (
+	backupFormatVersion = 1
+	backupManifestName  = "manifest.json"
+	backupDatabaseName  = "local.db"
+)
+
+// maxEmbeddedMigration is the highest migration version this build knows.
+func maxEmbeddedMigration() (uint, error) {
+	entries, err := fs.ReadDir(migrationsFS, "migrations")
+	if err != nil {
+		return 0, err
+	}
+	var highest uint
+	for _, entry := range entries {
+		prefix, _, ok := strings.Cut(entry.Name(), "_")
+		if !ok {
+			continue
+		}
+		version, err := strconv.ParseUint(prefix, 10, 64)
+		if err != nil {
+			continue
+		}
+		if uint(version) > highest {
+			highest = uint(version)
+		}
+	}
+	return highest, nil
+}
+
+// currentMigration returns the schema version recorded by golang-migrate.
+func (a *App) currentMigration() (uint, error) {
+	return migrationVersion(a.db)
+}
+
+func migrationVersion(db *sql.DB) (uint, error) {
+	var version uint
+	var dirty bool
+	if err := db.QueryRow(`SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty); err != nil {
+		return 0, err
+	}
+	if dirty {
+		return 0, fmt.Errorf("database is at a partially applied migration %d", version)
+	}
+	return version, nil
+}
+
+// referencedUploads lists every uploads/... path the database points at:
+// song files and artwork, album artwork, artist images, and the files of
+// pending inbox items and import sessions.
+func (a *App) referencedUploads() ([]string, error) {
+	rows, err := a.db.Query(`
+		SELECT filepath FROM songs
+		UNION SELECT artwork_path FROM songs WHERE artwork_path IS NOT NULL
+		UNION SELECT artwork_path FROM albums WHERE artwork_path IS NOT NULL
+		UNION SELECT image FROM artists WHERE image IS NOT NULL
+		UNION SELECT upload_path FROM inbox_files WHERE upload_path IS NOT NULL AND status = 'pending'
+		UNION SELECT json_extract(f.value, '$.filepath')
+			FROM import_sessions s, json_each(s.result, '$.filesData') f
+			WHERE s.status = 'pending'
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	seen := make(map[string]bool)
+	paths := []string{}
+	for rows.Next() {
+		var relPath string
+		if err := rows.Scan(&relPath); err != nil {
+			return nil, err
+		}
+		cleaned, err := normalizeUploadsRootRelPath(relPath)
+		if err != nil || cleaned == uploadsRoot {
+			continue // not an upload (e.g. a remote image URL)
+		}
+		cleaned = filepath.ToSlash(cleaned)
+		if !seen[cleaned] {
+			seen[cleaned] = true
+			paths = append(paths, cleaned)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// addBackupFile copies a file into the archive, recording its checksum.
+func addBackupFile(zw *zip.Writer, manifest *BackupManifest, name, srcPath string, method uint16) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: method, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(w, hash), src)
+	if err != nil {
+		return fmt.Errorf("failed to archive %s: %w", name, err)
+	}
+	manifest.Files = append(manifest.Files, BackupFile{Path: name, Size: size, SHA256: hex.EncodeToString(hash.Sum(nil))})
+	return nil
+}
+
+// CreateBackup writes the database and every referenced upload to a zip
+// archive at path. The database is snapshotted with VACUUM INTO, so the copy
+// is consistent even while the app keeps writing.
+func (a *App) CreateBackup(path string) (*BackupManifest, error) {
+	version, err := a.currentMigration()
+	if err != nil {
+		return nil, err
+	}
+	uploads, err := a.referencedUploads()
+	if err != nil {
+		return nil, err
+	}
+
+	snapshotDir, err := os.MkdirTemp("", "leaks-backup-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(snapshotDir)
+	snapshot := filepath.Join(snapshotDir, backupDatabaseName)
+	if _, err := a.db.Exec(`VACUUM INTO ?`, snapshot); err != nil {
+		return nil, fmt.Errorf("failed to snapshot database: %w", err)
+	}
+
+	out, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := a.writeBackup(out, snapshot, version, uploads)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	return manifest, nil
+}
+
+func (a *App) writeBackup(out io.Writer, snapshot string, version uint, uploads []string) (*BackupManifest, error) {
+	manifest := &BackupManifest{
+		FormatVersion:    backupFormatVersion,
+		CreatedAt:        time.Now().Unix(),
+		MigrationVersion: version,
+		Files:            []BackupFile{},
+		Missing:          []string{},
+	}
+
+	zw := zip.NewWriter(out)
+	if err := addBackupFile(zw, manifest, backupDatabaseName, snapshot, zip.Deflate); err != nil {
+		return nil, err
+	}
+	for _, relPath := range uploads {
+		fullPath, err := a.uploadsFilePath(relPath)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(fullPath); os.IsNotExist(err) {
+			manifest.Missing = append(manifest.Missing, relPath)
+			continue
+		}
+		// audio and artwork are already compressed
+		if err := addBackupFile(zw, manifest, relPath, fullPath, zip.Store); err != nil {
+			return nil, err
+		}
+	}
+
+	w, err := zw.Create(backupManifestName)
+	if err != nil {
+		return nil, err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(manifest); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// readBackupManifest opens an archive and checks it before anything is
+// restored: the manifest must be readable, the schema no newer than this
+// build's migrations, every path safe, and every checksum correct.
+func readBackupManifest(zr *zip.Reader) (*BackupManifest, map[string]*zip.File, error) {
+	entries := make(map[string]*zip.File)
+	for _, f := range zr.File {
+		entries[f.Name] = f
+	}
+
+	manifestFile, ok := entries[backupManifestName]
+	if !ok {
+		return nil, nil, fmt.Errorf("not a backup archive: %s is missing", backupManifestName)
+	}
+	rc, err := manifestFile.Open()
+	if err != nil {
+		return nil, nil, err
+	}
+	var manifest BackupManifest
+	err = json.NewDecoder(rc).Decode(&manifest)
+	rc.Close()
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid backup manifest: %w", err)
+	}
+
+	if manifest.FormatVersion < 1 || manifest.FormatVersion > backupFormatVersion {
+		return nil, nil, fmt.Errorf("unsupported backup format %d", manifest.FormatVersion)
+	}
+	maxVersion, err := maxEmbeddedMigration()
+	if err != nil {
+		return nil, nil, err
+	}
+	if manifest.MigrationVersion > maxVersion {
+		return nil, nil, fmt.Errorf("backup is from a newer version of the app (schema %d, this version supports up to %d)", manifest.MigrationVersion, maxVersion)
+	}
+
+	hasDatabase := false
+	for _, file := range manifest.Files {
+		if file.Path == backupDatabaseName {
+			hasDatabase = true
+		} else if cleaned, err := normalizeUploadsRootRelPath(file.Path); err != nil || filepath.ToSlash(cleaned) != file.Path {
+			return nil, nil, fmt.Errorf("backup contains an unsafe path %q", file.Path)
+		}
+
+		entry, ok := entries[file.Path]
+		if !ok {
+			return nil, nil, fmt.Errorf("backup is missing %s", file.Path)
+		}
+		rc, err := entry.Open()
+		if err != nil {
+			return nil, nil, err
+		}
+		hash := sha256.New()
+		size, err := io.Copy(hash, rc)
+		rc.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s: %w", file.Path, err)
+		}
+		if size != file.Size || hex.EncodeToString(hash.Sum(nil)) != file.SHA256 {
+			return nil, nil, fmt.Errorf("checksum mismatch for %s", file.Path)
+		}
+	}
+	if !hasDatabase {
+		return nil, nil, fmt.Errorf("backup has no database")
+	}
+	return &manifest, entries, nil
+}
+
+// extractBackupFile writes an archive entry next to dest and renames it into
+// place.
+func extractBackupFile(entry *zip.File, dest string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	rc, err := entry.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(dest), "."+filepath.Base(dest)+".restore-*")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, rc); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// RestoreBackup replaces the database with the archive's snapshot and
+// restores its upload files. The whole archive is verified and extracted to
+// a staging directory first, and the files only move into place once the
+// database has been swapped, so a corrupt or too-new backup, or a failed
+// swap, leaves the library untouched. Uploads not in the archive are left on
+// disk. Older snapshots are migrated forward after the swap.
+func (a *App) RestoreBackup(archivePath string) (*BackupManifest, error) {
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	manifest, entries, err := readBackupManifest(&zr.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	// stage the database beside the live one
+	staged := a.dbPath + ".restore"
+	if err := extractBackupFile(entries[backupDatabaseName], staged); err != nil {
+		return nil, fmt.Errorf("failed to extract database: %w", err)
+	}
+	defer os.Remove(staged)
+
+	// the snapshot itself must agree with the manifest's schema check
+	if err := checkSnapshotMigration(staged); err != nil {
+		return nil, err
+	}
+
+	// stage the uploads on the same filesystem, so moving them is a rename
+	stagingDir, err := os.MkdirTemp(a.staticPath, ".restore-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(stagingDir)
+	restored := map[string]string{}
+	for _, file := range manifest.Files {
+		if file.Path == backupDatabaseName {
+			continue
+		}
+		dest, err := a.uploadsFilePath(file.Path)
+		if err != nil {
+			return nil, err
+		}
+		stagedFile := filepath.Join(stagingDir, filepath.FromSlash(file.Path))
+		if err := extractBackupFile(entries[file.Path], stagedFile); err != nil {
+			return nil, fmt.Errorf("failed to restore %s: %w", file.Path, err)
+		}
+		restored[stagedFile] = dest
+	}
+
+	// keep the inbox from importing into the database mid-restore
+	a.inboxMu.Lock()
+	defer a.inboxMu.Unlock()
+	if err := a.restoreDatabase(staged); err != nil {
+		return nil, err
+	}
+	for stagedFile, dest := range restored {
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return nil, err
+		}
+		if err := os.Rename(stagedFile, dest); err != nil {
+			return nil, fmt.Errorf("failed to restore %s: %w", dest, err)
+		}
+	}
+
+	// remembered upload identities and link times describe files the
+	// archive may have replaced
+	a.identitiesMu.Lock()
+	a.identities = nil
+	a.identitiesMu.Unlock()
+	a.linkedAtMu.Lock()
+	a.linkedAt = nil
+	a.linkedAtMu.Unlock()
+	return manifest, nil
+}
+
+// checkSnapshotMigration refuses a database snapshot migrated past this
+// build's migrations.
+func checkSnapshotMigration(snapshot string) error {
+	db, err := sql.Open("sqlite3_custom", snapshot)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	version, err := migrationVersion(db)
+	if err != nil {
+		return fmt.Errorf("backup database is unreadable: %w", err)
+	}
+	maxVersion, err := maxEmbeddedMigration()
+	if err != nil {
+		return err
+	}
+	if version > maxVersion {
+		return fmt.Errorf("backup is from a newer version of the app (schema %d, this version supports up to %d)", version, maxVersion)
+	}
+	return nil
+}
+
+// restoreDatabase copies snapshot over the live database with SQLite's
+// online backup, then migrates it. a.db is never closed or replaced, so
+// bindings and the inbox poller running meanwhile keep a valid handle; SQLite
+// locks the database while pages are copied, and they see either the old
+// contents or the restored ones.
+func (a *App) restoreDatabase(snapshot string) error {
+	src, err := sql.Open("sqlite3_custom", snapshot)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	ctx := context.Background()
+	srcConn, err := src.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open backup database: %w", err)
+	}
+	defer srcConn.Close()
+	destConn, err := a.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer destConn.Close()
+
+	err = destConn.Raw(func(dest any) error {
+		return srcConn.Raw(func(src any) error {
+			backup, err := dest.(*sqlite3driver.SQLiteConn).Backup("main", src.(*sqlite3driver.SQLiteConn), "main")
+			if err != nil {
+				return err
+			}
+			if _, err := backup.Step(-1); err != nil {
+				backup.Finish()
+				return err
+			}
+			return backup.Finish()
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to replace database: %w", err)
+	}
+	if err := a.runMigrations(); err != nil {
+		return fmt.Errorf("failed to migrate restored database: %w", err)
+	}
+	return nil
+}
+
+// SelectBackupPath opens a native save dialog for CreateBackup.
+func (a *App) SelectBackupPath() (string, error) {
+	return wailsruntime.SaveFileDialog(a.ctx, wailsruntime.SaveDialogOptions{
+		Title:           "Create Backup",
+		DefaultFilename: "leaks-backup-" + time.Now().Format("2006-01-02") + ".zip",
+		Filters:         []wailsruntime.FileFilter{{DisplayName: "Zip Archives", Pattern: "*.zip"}},
+	})
+}
+
+// SelectBackupFile opens a native file picker for RestoreBackup.
+func (a *App) SelectBackupFile() (string, error) {
+	return wailsruntime.OpenFileDialog(a.ctx, wailsruntime.OpenDialogOptions{
+		Title:   "Restore Backup",
+		Filters: []wailsruntime.FileFilter{{DisplayName: "Zip Archives", Pattern: "*.zip"}},
+	})
+}
@@ -0,0 +1,216 @@
+package backend
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rewriteArchive copies a zip, letting edit replace any entry's contents.
+func rewriteArchive(t *testing.T, src, dst string, edit func(name string, data []byte) []byte) {
+	t.Helper()
+	zr, err := zip.OpenReader(src)
+	if err != nil {
+		t.Fatalf("open archive: %v", err)
+	}
+	defer zr.Close()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("open entry: %v", err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		w, _ := zw.Create(f.Name)
+		w.Write(edit(f.Name, data))
+	}
+	zw.Close()
+	if err := os.WriteFile(dst, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("write archive: %v", err)
+	}
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	app := newTestApp(t)
+
+	songData := []byte("not really audio")
+	if err := os.WriteFile(filepath.Join(app.staticPath, "uploads", "songs", "keep.mp3"), songData, 0644); err != nil {
+		t.Fatalf("write song: %v", err)
+	}
+	artwork := "uploads/artwork/cover.jpg"
+	if err := os.WriteFile(filepath.Join(app.staticPath, filepath.FromSlash(artwork)), []byte("jpeg"), 0644); err != nil {
+		t.Fatalf("write artwork: %v", err)
+	}
+	if _, err := app.CreateSong(CreateSongInput{Name: "Keep", Filepath: "uploads/songs/keep.mp3", ArtworkPath: &artwork}); err != nil {
+		t.Fatalf("CreateSong: %v", err)
+	}
+	if _, err := app.CreateSong(CreateSongInput{Name: "Gone", Filepath: "uploads/songs/gone.mp3"}); err != nil {
+		t.Fatalf("CreateSong: %v", err)
+	}
+
+	archive := filepath.Join(t.TempDir(), "backup.zip")
+	manifest, err := app.CreateBackup(archive)
+	if err != nil {
+		t.Fatalf("CreateBackup: %v", err)
+	}
+	if len(manifest.Files) != 3 || len(manifest.Missing) != 1 || manifest.Missing[0] != "uploads/songs/gone.mp3" {
+		t.Fatalf("unexpected manifest: %+v", manifest)
+	}
+
+	// diverge from the backup
+	os.Remove(filepath.Join(app.staticPath, "uploads", "songs", "keep.mp3"))
+	if _, err := app.CreateSong(CreateSongInput{Name: "After", Filepath: "uploads/songs/after.mp3"}); err != nil {
+		t.Fatalf("CreateSong: %v", err)
+	}
+
+	// bindings keep querying while the restore runs
+	db := app.db
+	done := make(chan struct{})
+	readErrs := make(chan error, 1)
+	go func() {
+		defer close(readErrs)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if _, err := app.GetSongsCount(); err != nil {
+				readErrs <- err
+				return
+			}
+		}
+	}()
+	_, err = app.RestoreBackup(archive)
+	close(done)
+	if err != nil {
+		t.Fatalf("RestoreBackup: %v", err)
+	}
+	if err := <-readErrs; err != nil {
+		t.Fatalf("query during restore: %v", err)
+	}
+	if app.db != db {
+		t.Fatal("expected the restore to keep the database handle")
+	}
+	if count, err := app.GetSongsCount(); err != nil || count != 2 {
+		t.Fatalf("expected the backed-up songs, got %d (err %v)", count, err)
+	}
+	restored, err := os.ReadFile(filepath.Join(app.staticPath, "uploads", "songs", "keep.mp3"))
+	if err != nil || !bytes.Equal(restored, songData) {
+		t.Fatalf("expected the song file to be restored, got %q (err %v)", restored, err)
+	}
+}
+
+func TestRestoreBackupMovesFilesAfterTheDatabase(t *testing.T) {
+	app := newTestApp(t)
+
+	relPath := "uploads/songs/keep.mp3"
+	fullPath := filepath.Join(app.staticPath, filepath.FromSlash(relPath))
+	if err := os.WriteFile(fullPath, []byte("backed up"), 0644); err != nil {
+		t.Fatalf("write song: %v", err)
+	}
+	if _, err := app.CreateSong(CreateSongInput{Name: "Keep", Filepath: relPath}); err != nil {
+		t.Fatalf("CreateSong: %v", err)
+	}
+	archive := filepath.Join(t.TempDir(), "backup.zip")
+	if _, err := app.CreateBackup(archive); err != nil {
+		t.Fatalf("CreateBackup: %v", err)
+	}
+
+	// a pending upload now sits at the same path
+	if err := os.WriteFile(fullPath, []byte("pending upload"), 0644); err != nil {
+		t.Fatalf("write upload: %v", err)
+	}
+	app.rememberFileIdentity(relPath, &songFileIdentity{ContentHash: "pending"})
+	app.recordLink(relPath, time.Now())
+
+	// a failed swap leaves the files alone
+	db := app.db
+	db.Close()
+	if _, err := app.RestoreBackup(archive); err == nil {
+		t.Fatal("expected the restore to fail without a database")
+	}
+	if data, _ := os.ReadFile(fullPath); string(data) != "pending upload" {
+		t.Fatalf("expected the live file to be untouched, got %q", data)
+	}
+	if staged, _ := filepath.Glob(filepath.Join(app.staticPath, ".restore-*")); len(staged) != 0 {
+		t.Fatalf("expected the staging directory to be removed, got %v", staged)
+	}
+
+	reopened, err := sql.Open("sqlite3_custom", app.dbPath)
+	if err != nil {
+		t.Fatalf("reopen db: %v", err)
+	}
+	t.Cleanup(func() { reopened.Close() })
+	app.db = reopened
+	if _, err := app.RestoreBackup(archive); err != nil {
+		t.Fatalf("RestoreBackup: %v", err)
+	}
+	if data, _ := os.ReadFile(fullPath); string(data) != "backed up" {
+		t.Fatalf("expected the file to be restored, got %q", data)
+	}
+	if app.fileIdentity(relPath) != nil || len(app.linkedAt) != 0 {
+		t.Fatalf("expected the restore to forget upload state, got %v and %v", app.identities, app.linkedAt)
+	}
+}
+
+func TestRestoreBackupRejectsBadArchives(t *testing.T) {
+	app := newTestApp(t)
+
+	if err := os.WriteFile(filepath.Join(app.staticPath, "uploads", "songs", "a.mp3"), []byte("audio"), 0644); err != nil {
+		t.Fatalf("write song: %v", err)
+	}
+	if _, err := app.CreateSong(CreateSongInput{Name: "A", Filepath: "uploads/songs/a.mp3"}); err != nil {
+		t.Fatalf("CreateSong: %v", err)
+	}
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "backup.zip")
+	if _, err := app.CreateBackup(archive); err != nil {
+		t.Fatalf("CreateBackup: %v", err)
+	}
+	if _, err := app.CreateSong(CreateSongInput{Name: "B", Filepath: "uploads/songs/b.mp3"}); err != nil {
+		t.Fatalf("CreateSong: %v", err)
+	}
+
+	tampered := filepath.Join(dir, "tampered.zip")
+	rewriteArchive(t, archive, tampered, func(name string, data []byte) []byte {
+		if name == "uploads/songs/a.mp3" {
+			return []byte("AUDIO")
+		}
+		return data
+	})
+	if _, err := app.RestoreBackup(tampered); err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Fatalf("expected a checksum error, got %v", err)
+	}
+
+	newer := filepath.Join(dir, "newer.zip")
+	rewriteArchive(t, archive, newer, func(name string, data []byte) []byte {
+		if name != backupManifestName {
+			return data
+		}
+		var manifest BackupManifest
+		json.Unmarshal(data, &manifest)
+		maxVersion, _ := maxEmbeddedMigration()
+		manifest.MigrationVersion = maxVersion + 1
+		out, _ := json.Marshal(manifest)
+		return out
+	})
+	if _, err := app.RestoreBackup(newer); err == nil || !strings.Contains(err.Error(), "newer version") {
+		t.Fatalf("expected a newer-version error, got %v", err)
+	}
+
+	// neither attempt touched the library
+	if count, _ := app.GetSongsCount(); count != 2 {
+		t.Fatalf("expected the library to be untouched, got %d songs", count)
+	}
+}
@@ -0,0 +1,602 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// --- Headless CLI ---
+
+const cliUsage = `Usage: leaks-manager <command> [options]
+
+Commands:
+  import [--album-id N] [--create-artists] [--recursive] [--link] <dir>
+        import every audio file in a directory
+  list songs|albums|artists [--json]
+        list library contents
+  write song|album|producer <id>
+        write metadata from the database to files
+  match-producers [--dry-run]
+        match producers from upload filenames and link them to songs
+  export [--format json|csv] [--out file]
+        export the library (JSON can be read back with import-library)
+  export-playlist [--format m3u8|xspf] [--out file] <id>
+        export a playlist with paths relative to uploads/songs
+  import-library [--settings] <export.json>
+        merge a library export into this library
+  check [--json]
+        report missing files, orphaned uploads, and broken links
+  repair [--orphans] [--missing-songs] [--missing-artwork] [--inherit-artists] [--empty-albums] [--links]
+        fix problems found by check
+  backup <archive.zip>
+        archive the database and every referenced upload
+  restore <archive.zip>
+        replace the library with a backup archive
+  help
+        show this message
+
+Without a command the desktop app starts.
+`
+
+// cliCommands maps each subcommand to its handler.
+var cliCommands = map[string]func(a *App, args []string, stdout, stderr io.Writer) int{
+	"import":          cliImport,
+	"list":            cliList,
+	"write":           cliWrite,
+	"match-producers": cliMatchProducers,
+	"export":          cliExport,
+	"export-playlist": cliExportPlaylist,
+	"import-library":  cliImportLibrary,
+	"check":           cliCheck,
+	"repair":          cliRepair,
+	"backup":          cliBackup,
+	"restore":         cliRestore,
+}
+
+// IsCLICommand reports whether args (os.Args[1:]) select a CLI subcommand
+// rather than the desktop app.
+func IsCLICommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		return true
+	}
+	_, ok := cliCommands[args[0]]
+	return ok
+}
+
+// RunCLI opens the same database and uploads directory as the desktop app
+// and runs one subcommand without starting a webview. Returns the process
+// exit code: 0 on success, 1 on failure, 2 on usage errors.
+func RunCLI(args []string, stdout, stderr io.Writer) int {
+	if !IsCLICommand(args) {
+		fmt.Fprint(stderr, cliUsage)
+		return 2
+	}
+	if _, ok := cliCommands[args[0]]; !ok {
+		// help
+		fmt.Fprint(stdout, cliUsage)
+		return 0
+	}
+
+	app := NewApp()
+	if err := app.open(context.Background()); err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 1
+	}
+	defer app.db.Close()
+
+	return runCLI(app, args, stdout, stderr)
+}
+
+// runCLI dispatches to a subcommand on an already-open App.
+func runCLI(a *App, args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, cliUsage)
+		return 2
+	}
+	command, ok := cliCommands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command %q\n\n%s", args[0], cliUsage)
+		return 2
+	}
+	return command(a, args[1:], stdout, stderr)
+}
+
+// newCLIFlagSet returns a flag set that reports errors instead of exiting.
+func newCLIFlagSet(name string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	return fs
+}
+
+// parseCLIFlags parses flags wherever they appear among the arguments, so
+// "list songs --json" and "list --json songs" both work.
+func parseCLIFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	positional := []string{}
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func cliImport(a *App, args []string, stdout, stderr io.Writer) int {
+	fs := newCLIFlagSet("import", stderr)
+	albumID := fs.Int("album-id", 0, "add every file to this album")
+	createArtists := fs.Bool("create-artists", false, "create artists for credits that don't resolve")
+	recursive := fs.Bool("recursive", false, "include subdirectories")
+	hardLink := fs.Bool("link", false, "hard-link files into uploads instead of copying, leaving their tags unwritten")
+	positional, err := parseCLIFlags(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(positional) != 1 {
+		fmt.Fprintln(stderr, "usage: leaks-manager import [--album-id N] [--create-artists] [--recursive] [--link] <dir>")
+		return 2
+	}
+
+	paths, err := collectAudioFiles(positional[0], *recursive)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 1
+	}
+	if len(paths) == 0 {
+		fmt.Fprintln(stdout, "no audio files found")
+		return 0
+	}
+
+	var album *int
+	if *albumID > 0 {
+		album = albumID
+	}
+	extracted, err := a.ImportFromPaths(ImportFromPathsInput{Paths: paths, AlbumID: album, HardLink: *hardLink})
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 1
+	}
+	return cliCreateImported(a, extracted, album, *createArtists, stdout, stderr)
+}
+
+// cliCreateImported finishes an import non-interactively: remembered
+// mappings apply, unresolved credits become new artists with
+// --create-artists and are otherwise dropped with a warning.
+func cliCreateImported(a *App, extracted *UploadAndExtractResult, albumID *int, createArtists bool, stdout, stderr io.Writer) int {
+	mapping := make(map[string]any)
+	for name, resolution := range extracted.ArtistMapping {
+		mapping[name] = resolution
+	}
+	for _, name := range extracted.UnmappedArtists {
+		if createArtists {
+			mapping[name] = artistMappingCreateNew
+		} else {
+			fmt.Fprintf(stderr, "warning: no artist matches %q; credit skipped (use --create-artists)\n", name)
+		}
+	}
+	for _, dup := range extracted.PossibleDuplicates {
+		fmt.Fprintf(stderr, "warning: %s may duplicate song %d %q (%s, %.0f%%)\n",
+			dup.Filepath, dup.SongID, dup.SongName, dup.MatchType, dup.Similarity*100)
+	}
+
+	songs, err := a.CreateSongsWithMetadata(CreateSongsWithMetadataInput{
+		FilesData:          extracted.FilesData,
+		ArtistMapping:      mapping,
+		AlbumID:            albumID,
+		UseEmbeddedArtwork: true,
+		SessionID:          &extracted.SessionID,
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 1
+	}
+	for _, song := range songs {
+		fmt.Fprintf(stdout, "imported %d\t%s\n", song.ID, song.Name)
+	}
+	fmt.Fprintf(stdout, "%d songs imported\n", len(songs))
+	return 0
+}
+
+func cliList(a *App, args []string, stdout, stderr io.Writer) int {
+	fs := newCLIFlagSet("list", stderr)
+	asJSON := fs.Bool("json", false, "print JSON instead of a table")
+	positional, err := parseCLIFlags(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(positional) != 1 {
+		fmt.Fprintln(stderr, "usage: leaks-manager list songs|albums|artists [--json]")
+		return 2
+	}
+
+	var data any
+	var header string
+	var rows [][]string
+	switch positional[0] {
+	case "songs":
+		songs, err := a.GetSongsReadable(-1, 0)
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return 1
+		}
+		data = songs
+		header = "ID\tNAME\tARTIST\tALBUM\tFILE"
+		for _, song := range songs {
+			albumName := ""
+			if song.Album != nil {
+				albumName = song.Album.Name
+			}
+			rows = append(rows, []string{strconv.Itoa(song.ID), song.Name, song.Artist, albumName, song.Filepath})
+		}
+	case "albums":
+		albums, err := a.GetAlbumsWithSongs(-1, 0)
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return 1
+		}
+		data = albums
+		header = "ID\tNAME\tARTISTS\tYEAR\tSONGS"
+		for _, album := range albums {
+			names := make([]string, 0, len(album.Artists))
+			for _, art := range album.Artists {
+				names = append(names, art.Name)
+			}
+			year := ""
+			if album.Year != nil {
+				year = strconv.Itoa(*album.Year)
+			}
+			rows = append(rows, []string{strconv.Itoa(album.ID), album.Name, strings.Join(names, ", "), year, strconv.Itoa(len(album.Songs))})
+		}
+	case "artists":
+		artists, err := a.GetArtists()
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return 1
+		}
+		data = artists
+		header = "ID\tNAME"
+		for _, art := range artists {
+			rows = append(rows, []string{strconv.Itoa(art.ID), art.Name})
+		}
+	default:
+		fmt.Fprintf(stderr, "unknown list target %q (want songs, albums, or artists)\n", positional[0])
+		return 2
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(data); err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, header)
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	tw.Flush()
+	return 0
+}
+
+func cliWrite(a *App, args []string, stdout, stderr io.Writer) int {
+	if len(args) != 2 {
+		fmt.Fprintln(stderr, "usage: leaks-manager write song|album|producer <id>")
+		return 2
+	}
+	id, err := strconv.Atoi(args[1])
+	if err != nil {
+		fmt.Fprintf(stderr, "invalid id %q\n", args[1])
+		return 2
+	}
+
+	var result BatchResult
+	switch args[0] {
+	case "song":
+		var res SongProcessingResult
+		if res, err = a.WriteSongMetadata(id); err != nil {
+			break
+		}
+		result = BatchResult{Success: true, Message: fmt.Sprintf("Processed song %d", id), Results: []SongProcessingResult{res}}
+		if res.Success {
+			result.SongsProcessed = 1
+		} else {
+			result.SongsFailed = 1
+		}
+	case "album":
+		result, err = a.WriteAlbumMetadata(id)
+	case "producer":
+		result, err = a.WriteProducerMetadata(id)
+	default:
+		fmt.Fprintf(stderr, "unknown write target %q (want song, album, or producer)\n", args[0])
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 1
+	}
+	return printBatchResult(result, stdout, stderr)
+}
+
+// printBatchResult reports per-song failures and exits non-zero if any song
+// failed.
+func printBatchResult(result BatchResult, stdout, stderr io.Writer) int {
+	for _, r := range result.Results {
+		if !r.Success {
+			fmt.Fprintf(stderr, "song %d: %s\n", r.SongID, r.Error)
+		}
+	}
+	fmt.Fprintf(stdout, "%s: %d written, %d failed\n", result.Message, result.SongsProcessed, result.SongsFailed)
+	if result.SongsFailed > 0 {
+		return 1
+	}
+	return 0
+}
+
+func cliMatchProducers(a *App, args []string, stdout, stderr io.Writer) int {
+	fs := newCLIFlagSet("match-producers", stderr)
+	dryRun := fs.Bool("dry-run", false, "report matches without linking them")
+	if _, err := parseCLIFlags(fs, args); err != nil {
+		return 2
+	}
+
+	songs, err := a.GetSongsReadable(-1, 0)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 1
+	}
+
+	changed := []int{}
+	for _, song := range songs {
+		artistIDs := make([]int, 0, len(song.Artists))
+		for _, art := range song.Artists {
+			artistIDs = append(artistIDs, art.ID)
+		}
+		producerIDs, err := a.MatchProducersFromFilename(originalUploadFilename(song.Filepath), artistIDs)
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return 1
+		}
+		existing := make(map[int]bool)
+		for _, p := range song.Producers {
+			existing[p.ID] = true
+		}
+		missing := []int{}
+		for _, id := range producerIDs {
+			if !existing[id] {
+				missing = append(missing, id)
+			}
+		}
+		if len(missing) == 0 {
+			continue
+		}
+
+		if *dryRun {
+			fmt.Fprintf(stdout, "song %d %q: would add producers %v\n", song.ID, song.Name, missing)
+			changed = append(changed, song.ID)
+			continue
+		}
+		added, err := a.addProducersToSong(song.ID, missing, nil)
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return 1
+		}
+		if added > 0 {
+			fmt.Fprintf(stdout, "song %d %q: added producers %v\n", song.ID, song.Name, missing)
+			changed = append(changed, song.ID)
+		}
+	}
+
+	if *dryRun || len(changed) == 0 {
+		fmt.Fprintf(stdout, "%d songs matched\n", len(changed))
+		return 0
+	}
+	return printBatchResult(a.writeMetadataBatch(changed, fmt.Sprintf("Matched producers for %d songs", len(changed))), stdout, stderr)
+}
+
+func cliExport(a *App, args []string, stdout, stderr io.Writer) int {
+	fs := newCLIFlagSet("export", stderr)
+	format := fs.String("format", libraryFormatJSON, "json or csv")
+	out := fs.String("out", "", "write to this file instead of stdout")
+	if _, err := parseCLIFlags(fs, args); err != nil {
+		return 2
+	}
+	if *format != libraryFormatJSON && *format != libraryFormatCSV {
+		fmt.Fprintf(stderr, "unknown export format %q (want json or csv)\n", *format)
+		return 2
+	}
+
+	var err error
+	if *out != "" {
+		err = a.ExportLibrary(*format, *out)
+	} else {
+		err = a.writeLibraryExport(stdout, *format)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func cliExportPlaylist(a *App, args []string, stdout, stderr io.Writer) int {
+	fs := newCLIFlagSet("export-playlist", stderr)
+	format := fs.String("format", playlistFormatM3U8, "m3u8 or xspf")
+	out := fs.String("out", "", "write to this file instead of stdout")
+	positional, err := parseCLIFlags(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(positional) != 1 {
+		fmt.Fprintln(stderr, "usage: leaks-manager export-playlist [--format m3u8|xspf] [--out file] <id>")
+		return 2
+	}
+	id, err := strconv.Atoi(positional[0])
+	if err != nil {
+		fmt.Fprintf(stderr, "invalid playlist id %q\n", positional[0])
+		return 2
+	}
+
+	if *out != "" {
+		err = a.ExportPlaylist(id, *format, *out)
+	} else {
+		err = a.writePlaylist(stdout, id, *format)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func cliImportLibrary(a *App, args []string, stdout, stderr io.Writer) int {
+	fs := newCLIFlagSet("import-library", stderr)
+	applySettings := fs.Bool("settings", false, "also replace settings with the exported ones")
+	positional, err := parseCLIFlags(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(positional) != 1 {
+		fmt.Fprintln(stderr, "usage: leaks-manager import-library [--settings] <export.json>")
+		return 2
+	}
+
+	result, err := a.ImportLibrary(ImportLibraryInput{Path: positional[0], ApplySettings: *applySettings})
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 1
+	}
+	for _, warning := range result.Warnings {
+		fmt.Fprintf(stderr, "warning: %s\n", warning)
+	}
+	fmt.Fprintf(stdout, "artists: %d created, %d matched\n", result.ArtistsCreated, result.ArtistsMatched)
+	fmt.Fprintf(stdout, "producers: %d created, %d matched\n", result.ProducersCreated, result.ProducersMatched)
+	fmt.Fprintf(stdout, "albums: %d created, %d matched\n", result.AlbumsCreated, result.AlbumsMatched)
+	fmt.Fprintf(stdout, "songs: %d created, %d matched\n", result.SongsCreated, result.SongsMatched)
+	return 0
+}
+
+func cliBackup(a *App, args []string, stdout, stderr io.Writer) int {
+	if len(args) != 1 {
+		fmt.Fprintln(stderr, "usage: leaks-manager backup <archive.zip>")
+		return 2
+	}
+	manifest, err := a.CreateBackup(args[0])
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 1
+	}
+	for _, missing := range manifest.Missing {
+		fmt.Fprintf(stderr, "warning: %s is referenced but missing; not backed up\n", missing)
+	}
+	fmt.Fprintf(stdout, "backed up %d files (schema %d) to %s\n", len(manifest.Files), manifest.MigrationVersion, args[0])
+	return 0
+}
+
+func cliRestore(a *App, args []string, stdout, stderr io.Writer) int {
+	if len(args) != 1 {
+		fmt.Fprintln(stderr, "usage: leaks-manager restore <archive.zip>")
+		return 2
+	}
+	manifest, err := a.RestoreBackup(args[0])
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "restored %d files from backup made %s\n", len(manifest.Files), time.Unix(manifest.CreatedAt, 0).Format(time.RFC3339))
+	return 0
+}
+
+func cliCheck(a *App, args []string, stdout, stderr io.Writer) int {
+	fs := newCLIFlagSet("check", stderr)
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	if _, err := parseCLIFlags(fs, args); err != nil {
+		return 2
+	}
+
+	report, err := a.CheckLibraryIntegrity()
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 1
+	}
+	if *asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	for _, m := range report.MissingFiles {
+		fmt.Fprintf(stdout, "missing %s\t%d %q\t%s\n", m.Kind, m.ID, m.Name, m.Path)
+	}
+	for _, o := range report.OrphanedUploads {
+		fmt.Fprintf(stdout, "orphaned upload\t%s\n", o.Path)
+	}
+	for _, s := range report.SongsWithoutArtists {
+		fmt.Fprintf(stdout, "song without artists\t%d %q\n", s.ID, s.Name)
+	}
+	for _, alb := range report.EmptyAlbums {
+		fmt.Fprintf(stdout, "empty album\t%d %q\n", alb.ID, alb.Name)
+	}
+	for _, r := range report.DanglingAliasRestrictions {
+		fmt.Fprintf(stdout, "dangling alias restriction\talias %d -> artist %d\n", r.AliasID, r.ArtistID)
+	}
+	for table, count := range report.DanglingLinks {
+		fmt.Fprintf(stdout, "dangling links\t%s: %d\n", table, count)
+	}
+	return 0
+}
+
+func cliRepair(a *App, args []string, stdout, stderr io.Writer) int {
+	fs := newCLIFlagSet("repair", stderr)
+	var input RepairLibraryInput
+	fs.BoolVar(&input.DeleteOrphanedUploads, "orphans", false, "delete unreferenced uploads older than an hour")
+	fs.BoolVar(&input.DeleteMissingSongs, "missing-songs", false, "delete songs whose file is gone")
+	fs.BoolVar(&input.ClearMissingArtwork, "missing-artwork", false, "clear artwork paths whose file is gone")
+	fs.BoolVar(&input.InheritAlbumArtists, "inherit-artists", false, "credit songs without artists to their album's artists")
+	fs.BoolVar(&input.DeleteEmptyAlbums, "empty-albums", false, "delete albums without songs")
+	fs.BoolVar(&input.RemoveDanglingLinks, "links", false, "remove links to deleted records")
+	if _, err := parseCLIFlags(fs, args); err != nil {
+		return 2
+	}
+	if input == (RepairLibraryInput{}) {
+		fmt.Fprintln(stderr, "repair: choose at least one fix (see leaks-manager help)")
+		return 2
+	}
+
+	result, err := a.RepairLibrary(input)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "orphans deleted: %d (%d recent ones kept)\n", result.OrphansDeleted, result.OrphansSkipped)
+	fmt.Fprintf(stdout, "songs deleted: %d\n", result.SongsDeleted)
+	fmt.Fprintf(stdout, "artwork paths cleared: %d\n", result.ArtworkCleared)
+	fmt.Fprintf(stdout, "songs given album artists: %d\n", result.SongsGivenArtists)
+	fmt.Fprintf(stdout, "albums deleted: %d\n", result.AlbumsDeleted)
+	fmt.Fprintf(stdout, "dangling links removed: %d\n", result.DanglingLinksRemoved)
+	if result.SongsGivenArtists > 0 {
+		return printBatchResult(result.Metadata, stdout, stderr)
+	}
+	return 0
+}
@@ -0,0 +1,118 @@
+package backend
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIsCLICommand(t *testing.T) {
+	cases := map[string]bool{
+		"":                false,
+		"import":          true,
+		"list":            true,
+		"match-producers": true,
+		"--help":          true,
+		"-psn_0_12345":    false,
+	}
+	for arg, want := range cases {
+		args := []string{}
+		if arg != "" {
+			args = append(args, arg)
+		}
+		if got := IsCLICommand(args); got != want {
+			t.Errorf("IsCLICommand(%q) = %v, want %v", arg, got, want)
+		}
+	}
+}
+
+func TestCLIImportListAndMatchProducers(t *testing.T) {
+	app := newTestApp(t)
+
+	if _, err := app.CreateProducerWithAliases(CreateProducerInput{Name: "Wheezy"}); err != nil {
+		t.Fatalf("CreateProducerWithAliases: %v", err)
+	}
+
+	dir := t.TempDir()
+	for name, artist := range map[string]string{"Haiti.mp3": "Young Thug", "Sup Mate.mp3": "Young Thug & Future"} {
+		upload := taggedMP3Upload(t, name, strings.TrimSuffix(name, ".mp3"), artist)
+		data, _ := base64.StdEncoding.DecodeString(upload.Base64Data)
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatalf("write import file: %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("skip me"), 0644); err != nil {
+		t.Fatalf("write notes: %v", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := runCLI(app, []string{"import", dir, "--create-artists"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("import exited %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "2 songs imported") {
+		t.Fatalf("unexpected import output: %s", stdout.String())
+	}
+
+	stdout.Reset()
+	if code := runCLI(app, []string{"list", "artists", "--json"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("list exited %d: %s", code, stderr.String())
+	}
+	var artists []Artist
+	if err := json.Unmarshal(stdout.Bytes(), &artists); err != nil {
+		t.Fatalf("list --json output is not JSON: %v", err)
+	}
+	if len(artists) != 2 {
+		t.Fatalf("expected the two credited artists to be created, got %+v", artists)
+	}
+
+	// rename the file so the producer appears in the upload filename
+	songs, err := app.GetSongsReadable(-1, 0)
+	if err != nil {
+		t.Fatalf("GetSongsReadable: %v", err)
+	}
+	if _, err := app.db.Exec(`UPDATE songs SET filepath = ? WHERE id = ?`, "uploads/songs/1700000000000-Haiti [Wheezy].mp3", songs[0].ID); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+
+	stdout.Reset()
+	if code := runCLI(app, []string{"match-producers", "--dry-run"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("match-producers exited %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "1 songs matched") {
+		t.Fatalf("unexpected dry-run output: %s", stdout.String())
+	}
+	if song, _ := app.GetSongReadable(songs[0].ID); len(song.Producers) != 0 {
+		t.Fatalf("dry run should not link producers, got %+v", song.Producers)
+	}
+
+	stdout.Reset()
+	runCLI(app, []string{"match-producers"}, &stdout, &stderr)
+	if song, _ := app.GetSongReadable(songs[0].ID); len(song.Producers) != 1 || song.Producers[0].Name != "Wheezy" {
+		t.Fatalf("expected producer to be linked, got %+v", song.Producers)
+	}
+}
+
+func TestCLIRejectsBadUsage(t *testing.T) {
+	app := newTestApp(t)
+
+	var stdout, stderr bytes.Buffer
+	for _, args := range [][]string{
+		{"list"},
+		{"list", "playlists"},
+		{"write", "song", "abc"},
+		{"import"},
+	} {
+		if code := runCLI(app, args, &stdout, &stderr); code != 2 {
+			t.Errorf("runCLI(%v) = %d, want 2", args, code)
+		}
+	}
+
+	stderr.Reset()
+	if code := runCLI(app, []string{"write", "song", "999"}, &stdout, &stderr); code != 1 || !strings.Contains(stderr.String(), "song 999") {
+		t.Errorf("expected writing a missing song to fail, got %d: %s", code, stderr.String())
+	}
+}
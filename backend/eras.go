@@ -0,0 +1,426 @@
+package backend
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// --- Eras ---
+
+// Where era names go when metadata is written (settings.era_tag).
+const (
+	eraTagOff      = "off"
+	eraTagGrouping = "grouping"
+	eraTagAlbum    = "album"
+)
+
+// How a song got its era (SongReadable.EraSource).
+const (
+	eraSourceSong          = "song"
+	eraSourceAlbum         = "album"
+	eraSourceRecordingDate = "recording_date"
+)
+
+// CreateEra adds an era after the artist's existing ones.
+func (a *App) CreateEra(input CreateEraInput) (*Era, error) {
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		return nil, fmt.Errorf("era name is required")
+	}
+	artist, err := a.getArtistByID(input.ArtistID)
+	if err != nil {
+		return nil, err
+	}
+	if artist == nil {
+		return nil, fmt.Errorf("artist %d not found", input.ArtistID)
+	}
+	startDate, endDate, err := normalizeEraRange(input.StartDate, input.EndDate)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.checkEraNameFree(input.ArtistID, name, 0); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	res, err := a.db.Exec(`
+		INSERT INTO eras (artist_id, name, start_date, end_date, "order", created_at, updated_at)
+		VALUES (?, ?, ?, ?, (SELECT COALESCE(MAX("order"), -1) + 1 FROM eras WHERE artist_id = ?), ?, ?)
+	`, input.ArtistID, name, startDate, endDate, input.ArtistID, now, now)
+	if err != nil {
+		return nil, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return a.getEraByID(int(id))
+}
+
+// UpdateEra renames an era or changes its dates. Nil fields are kept; an
+// empty date opens that end of the range.
+func (a *App) UpdateEra(input UpdateEraInput) (*Era, error) {
+	era, err := a.getEraByID(input.ID)
+	if err != nil {
+		return nil, err
+	}
+	if era == nil {
+		return nil, fmt.Errorf("era %d not found", input.ID)
+	}
+
+	name := era.Name
+	if input.Name != nil {
+		name = strings.TrimSpace(*input.Name)
+		if name == "" {
+			return nil, fmt.Errorf("era name is required")
+		}
+		if err := a.checkEraNameFree(era.ArtistID, name, era.ID); err != nil {
+			return nil, err
+		}
+	}
+	start, end := era.StartDate, era.EndDate
+	if input.StartDate != nil {
+		start = input.StartDate
+	}
+	if input.EndDate != nil {
+		end = input.EndDate
+	}
+	startDate, endDate, err := normalizeEraRange(start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := a.db.Exec(`UPDATE eras SET name = ?, start_date = ?, end_date = ?, updated_at = ? WHERE id = ?`,
+		name, startDate, endDate, time.Now().Unix(), era.ID); err != nil {
+		return nil, err
+	}
+	return a.getEraByID(era.ID)
+}
+
+// DeleteEra removes an era; its songs and albums are unassigned.
+func (a *App) DeleteEra(eraID int) error {
+	return a.InTx(func(tx *sql.Tx) error {
+		for _, query := range []string{
+			`UPDATE songs SET era_id = NULL WHERE era_id = ?`,
+			`UPDATE albums SET era_id = NULL WHERE era_id = ?`,
+			`DELETE FROM eras WHERE id = ?`,
+		} {
+			if _, err := tx.Exec(query, eraID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetEras returns an artist's eras in order.
+func (a *App) GetEras(artistID int) ([]Era, error) {
+	rows, err := a.db.Query(`
+		SELECT id, artist_id, name, start_date, end_date, "order", created_at, updated_at
+		FROM eras WHERE artist_id = ?
+		ORDER BY "order", id
+	`, artistID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	eras := []Era{}
+	for rows.Next() {
+		era, err := scanEra(rows)
+		if err != nil {
+			return nil, err
+		}
+		eras = append(eras, *era)
+	}
+	return eras, rows.Err()
+}
+
+// ReorderEras sets the order of an artist's eras. eraIDs must list every
+// one of them.
+func (a *App) ReorderEras(artistID int, eraIDs []int) ([]Era, error) {
+	current, err := a.GetEras(artistID)
+	if err != nil {
+		return nil, err
+	}
+	owned := make(map[int]bool)
+	for _, era := range current {
+		owned[era.ID] = true
+	}
+	seen := make(map[int]bool)
+	for _, id := range eraIDs {
+		if !owned[id] || seen[id] {
+			return nil, fmt.Errorf("era %d is not one of artist %d's eras or is listed twice", id, artistID)
+		}
+		seen[id] = true
+	}
+	if len(eraIDs) != len(current) {
+		return nil, fmt.Errorf("expected all %d eras of artist %d, got %d", len(current), artistID, len(eraIDs))
+	}
+
+	now := time.Now().Unix()
+	if err := a.InTx(func(tx *sql.Tx) error {
+		for i, id := range eraIDs {
+			if _, err := tx.Exec(`UPDATE eras SET "order" = ?, updated_at = ? WHERE id = ?`, i, now, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return a.GetEras(artistID)
+}
+
+// AssignSongsToEra sets the songs' era explicitly, overriding their album's
+// era and their recording date. A nil eraID clears the assignment.
+func (a *App) AssignSongsToEra(songIDs []int, eraID *int) error {
+	if err := a.checkEraExists(eraID); err != nil {
+		return err
+	}
+	now := time.Now().Unix()
+	return a.InTx(func(tx *sql.Tx) error {
+		for _, id := range songIDs {
+			if _, err := tx.Exec(`UPDATE songs SET era_id = ?, updated_at = ? WHERE id = ?`, eraID, now, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// AssignAlbumToEra puts an album, and with it every song on it that has no
+// era of its own, in an era. A nil eraID clears the assignment.
+func (a *App) AssignAlbumToEra(albumID int, eraID *int) error {
+	if err := a.checkEraExists(eraID); err != nil {
+		return err
+	}
+	_, err := a.db.Exec(`UPDATE albums SET era_id = ?, updated_at = ? WHERE id = ?`, eraID, time.Now().Unix(), albumID)
+	return err
+}
+
+// GetEraWithSongs lists an era's albums and every song whose effective era
+// it is, ordered by recording date.
+func (a *App) GetEraWithSongs(eraID int) (*EraWithSongs, error) {
+	era, err := a.getEraByID(eraID)
+	if err != nil {
+		return nil, err
+	}
+	if era == nil {
+		return nil, fmt.Errorf("era %d not found", eraID)
+	}
+
+	result := &EraWithSongs{Era: *era, Albums: []Album{}, Songs: []SongReadable{}}
+	albumRows, err := a.db.Query(`SELECT id FROM albums WHERE era_id = ? ORDER BY year, name`, eraID)
+	if err != nil {
+		return nil, err
+	}
+	albumIDs, err := scanIDs(albumRows)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range albumIDs {
+		album, err := a.getAlbumByID(id)
+		if err != nil {
+			return nil, err
+		}
+		if album != nil {
+			result.Albums = append(result.Albums, *album)
+		}
+	}
+
+	// candidates: assigned directly, through the album, or by recording date
+	// to one of the era artist's songs; resolveSongEra picks the winner
+	songRows, err := a.db.Query(`
+		SELECT s.id FROM songs s
+		LEFT JOIN albums al ON al.id = s.album_id
+		WHERE s.era_id = ?
+		   OR (s.era_id IS NULL AND al.era_id = ?)
+		   OR (s.era_id IS NULL AND al.era_id IS NULL AND s.recording_date IS NOT NULL
+		       AND s.id IN (SELECT song_id FROM song_artists WHERE artist_id = ?))
+		ORDER BY s.recording_date IS NULL, s.recording_date, s.created_at
+	`, eraID, eraID, era.ArtistID)
+	if err != nil {
+		return nil, err
+	}
+	songIDs, err := scanIDs(songRows)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range songIDs {
+		readable, err := a.GetSongReadable(id)
+		if err != nil {
+			return nil, err
+		}
+		if readable != nil && readable.Era != nil && readable.Era.ID == eraID {
+			result.Songs = append(result.Songs, *readable)
+		}
+	}
+	return result, nil
+}
+
+// resolveSongEra finds a song's effective era: its own assignment, else its
+// album's, else the first era (by credited artist, then era order) whose
+// date range holds its recording date.
+func (a *App) resolveSongEra(song Song) (*Era, string, error) {
+	if song.EraID != nil {
+		era, err := a.getEraByID(*song.EraID)
+		if err != nil || era != nil {
+			return era, eraSourceSong, err
+		}
+	}
+	if song.AlbumID != nil {
+		var albumEraID sql.NullInt64
+		err := a.db.QueryRow(`SELECT era_id FROM albums WHERE id = ?`, *song.AlbumID).Scan(&albumEraID)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, "", err
+		}
+		if albumEraID.Valid {
+			era, err := a.getEraByID(int(albumEraID.Int64))
+			if err != nil || era != nil {
+				return era, eraSourceAlbum, err
+			}
+		}
+	}
+	if song.RecordingDate == nil || *song.RecordingDate == "" {
+		return nil, "", nil
+	}
+
+	artists, err := a.getArtistsForSong(song.ID)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, artist := range artists {
+		eras, err := a.GetEras(artist.ID)
+		if err != nil {
+			return nil, "", err
+		}
+		for i := range eras {
+			if eraCoversDate(eras[i], *song.RecordingDate) {
+				return &eras[i], eraSourceRecordingDate, nil
+			}
+		}
+	}
+	return nil, "", nil
+}
+
+// eraCoversDate reports whether date falls in the era's range. A partial end
+// date covers its whole period; an era without dates covers nothing.
+func eraCoversDate(era Era, date string) bool {
+	if era.StartDate == nil && era.EndDate == nil {
+		return false
+	}
+	if era.StartDate != nil && date < *era.StartDate {
+		return false
+	}
+	if era.EndDate != nil && date > *era.EndDate+partialDateEnd {
+		return false
+	}
+	return true
+}
+
+func normalizeEraRange(start, end *string) (*string, *string, error) {
+	startDate, endDate := trimmedOrNil(start), trimmedOrNil(end)
+	for _, date := range []*string{startDate, endDate} {
+		if date != nil && !validPartialDate(*date) {
+			return nil, nil, fmt.Errorf("era date %q is not a date like 2019, 2019-06, or 2019-06-14", *date)
+		}
+	}
+	if startDate != nil && endDate != nil && *startDate > *endDate+partialDateEnd {
+		return nil, nil, fmt.Errorf("era starts (%s) after it ends (%s)", *startDate, *endDate)
+	}
+	return startDate, endDate, nil
+}
+
+func (a *App) checkEraNameFree(artistID int, name string, exceptID int) error {
+	var count int
+	if err := a.db.QueryRow(`SELECT COUNT(*) FROM eras WHERE artist_id = ? AND LOWER(name) = LOWER(?) AND id != ?`,
+		artistID, name, exceptID).Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("artist %d already has an era named %q", artistID, name)
+	}
+	return nil
+}
+
+func (a *App) checkEraExists(eraID *int) error {
+	if eraID == nil {
+		return nil
+	}
+	era, err := a.getEraByID(*eraID)
+	if err != nil {
+		return err
+	}
+	if era == nil {
+		return fmt.Errorf("era %d not found", *eraID)
+	}
+	return nil
+}
+
+func (a *App) getEraByID(eraID int) (*Era, error) {
+	era, err := scanEra(a.db.QueryRow(`
+		SELECT id, artist_id, name, start_date, end_date, "order", created_at, updated_at
+		FROM eras WHERE id = ?
+	`, eraID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return era, err
+}
+
+func scanEra(row interface{ Scan(...any) error }) (*Era, error) {
+	var era Era
+	var createdAt, updatedAt sql.NullInt64
+	if err := row.Scan(&era.ID, &era.ArtistID, &era.Name, &era.StartDate, &era.EndDate, &era.Order, &createdAt, &updatedAt); err != nil {
+		return nil, err
+	}
+	era.CreatedAt = createdAt.Int64
+	era.UpdatedAt = updatedAt.Int64
+	return &era, nil
+}
+
+func scanIDs(rows *sql.Rows) ([]int, error) {
+	defer rows.Close()
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// mergeErasTx moves sourceID's eras to targetID after the target's own.
+// An era whose name the target already uses is folded into that era.
+func mergeErasTx(tx *sql.Tx, sourceID, targetID int, now int64) error {
+	for _, query := range []string{
+		`UPDATE songs SET era_id = (
+			SELECT t.id FROM eras t JOIN eras s ON LOWER(s.name) = LOWER(t.name)
+			WHERE s.id = songs.era_id AND t.artist_id = ?
+		) WHERE era_id IN (SELECT s.id FROM eras s JOIN eras t ON LOWER(s.name) = LOWER(t.name) WHERE s.artist_id = ? AND t.artist_id = ?)`,
+		`UPDATE albums SET era_id = (
+			SELECT t.id FROM eras t JOIN eras s ON LOWER(s.name) = LOWER(t.name)
+			WHERE s.id = albums.era_id AND t.artist_id = ?
+		) WHERE era_id IN (SELECT s.id FROM eras s JOIN eras t ON LOWER(s.name) = LOWER(t.name) WHERE s.artist_id = ? AND t.artist_id = ?)`,
+	} {
+		if _, err := tx.Exec(query, targetID, sourceID, targetID); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`
+		DELETE FROM eras WHERE artist_id = ? AND LOWER(name) IN (SELECT LOWER(name) FROM eras WHERE artist_id = ?)
+	`, sourceID, targetID); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`
+		UPDATE eras SET artist_id = ?, updated_at = ?,
+			"order" = "order" + (SELECT COALESCE(MAX("order"), -1) + 1 FROM eras WHERE artist_id = ?)
+		WHERE artist_id = ?
+	`, targetID, now, targetID, sourceID)
+	return err
+}
@@ -0,0 +1,178 @@
+package backend
+
+import (
+	"testing"
+)
+
+func TestErasAssignmentAndView(t *testing.T) {
+	app := newTestApp(t)
+
+	carti, err := app.CreateArtist(CreateArtistInput{Name: "Playboi Carti"})
+	if err != nil {
+		t.Fatalf("CreateArtist: %v", err)
+	}
+	start, end := "2018-06", "2019"
+	wlr1, err := app.CreateEra(CreateEraInput{ArtistID: carti.ID, Name: "WLR V1", StartDate: &start, EndDate: &end})
+	if err != nil {
+		t.Fatalf("CreateEra: %v", err)
+	}
+	nextStart := "2020"
+	wlr2, err := app.CreateEra(CreateEraInput{ArtistID: carti.ID, Name: "WLR V2", StartDate: &nextStart})
+	if err != nil {
+		t.Fatalf("CreateEra: %v", err)
+	}
+	if _, err := app.CreateEra(CreateEraInput{ArtistID: carti.ID, Name: "wlr v1"}); err == nil {
+		t.Fatal("expected a duplicate era name to be rejected")
+	}
+	backwards := "2017"
+	if _, err := app.UpdateEra(UpdateEraInput{ID: wlr2.ID, EndDate: &backwards}); err == nil {
+		t.Fatal("expected an era ending before it starts to be rejected")
+	}
+
+	relPath := "uploads/songs/kid-cudi.mp3"
+	fullPath := writeSilentMP3(t, app, relPath)
+	recorded := "2019-03-02"
+	byDate, err := app.CreateSong(CreateSongInput{Name: "Kid Cudi", Filepath: relPath, ArtistIDs: []int{carti.ID},
+		Provenance: Provenance{RecordingDate: &recorded}})
+	if err != nil {
+		t.Fatalf("CreateSong: %v", err)
+	}
+	album, err := app.CreateAlbum(CreateAlbumInput{Name: "Whole Lotta Red", ArtistIDs: []int{carti.ID}})
+	if err != nil {
+		t.Fatalf("CreateAlbum: %v", err)
+	}
+	onAlbum, err := app.CreateSong(CreateSongInput{Name: "Stop Breathing", Filepath: "uploads/songs/sb.mp3", ArtistIDs: []int{carti.ID},
+		AlbumID: &album.ID, Provenance: Provenance{RecordingDate: &recorded}})
+	if err != nil {
+		t.Fatalf("CreateSong: %v", err)
+	}
+	explicit, err := app.CreateSong(CreateSongInput{Name: "Molly", Filepath: "uploads/songs/molly.mp3", ArtistIDs: []int{carti.ID}})
+	if err != nil {
+		t.Fatalf("CreateSong: %v", err)
+	}
+	if err := app.AssignAlbumToEra(album.ID, &wlr2.ID); err != nil {
+		t.Fatalf("AssignAlbumToEra: %v", err)
+	}
+	if err := app.AssignSongsToEra([]int{explicit.ID}, &wlr1.ID); err != nil {
+		t.Fatalf("AssignSongsToEra: %v", err)
+	}
+
+	// an artist's albums carry their era
+	withRelations, err := app.GetArtistsWithRelations()
+	if err != nil {
+		t.Fatalf("GetArtistsWithRelations: %v", err)
+	}
+	if len(withRelations) != 1 || len(withRelations[0].Albums) != 1 ||
+		withRelations[0].Albums[0].EraID == nil || *withRelations[0].Albums[0].EraID != wlr2.ID {
+		t.Fatalf("expected the artist's album in era %d, got %+v", wlr2.ID, withRelations)
+	}
+
+	for _, tc := range []struct {
+		songID int
+		eraID  int
+		source string
+	}{
+		{byDate.ID, wlr1.ID, eraSourceRecordingDate},
+		{onAlbum.ID, wlr2.ID, eraSourceAlbum},
+		{explicit.ID, wlr1.ID, eraSourceSong},
+	} {
+		readable, err := app.GetSongReadable(tc.songID)
+		if err != nil {
+			t.Fatalf("GetSongReadable: %v", err)
+		}
+		if readable.Era == nil || readable.Era.ID != tc.eraID || readable.EraSource != tc.source {
+			t.Errorf("song %q: expected era %d from %s, got %+v (%s)", readable.Name, tc.eraID, tc.source, readable.Era, readable.EraSource)
+		}
+	}
+
+	view, err := app.GetEraWithSongs(wlr1.ID)
+	if err != nil {
+		t.Fatalf("GetEraWithSongs: %v", err)
+	}
+	if len(view.Songs) != 2 || view.Songs[0].ID != byDate.ID || len(view.Albums) != 0 {
+		t.Fatalf("unexpected era view: %+v", view)
+	}
+
+	eras, err := app.ReorderEras(carti.ID, []int{wlr2.ID, wlr1.ID})
+	if err != nil {
+		t.Fatalf("ReorderEras: %v", err)
+	}
+	if eras[0].ID != wlr2.ID || eras[1].Order != 1 {
+		t.Fatalf("unexpected order: %+v", eras)
+	}
+
+	// era names feed the grouping tag
+	grouping := eraTagGrouping
+	if _, err := app.UpdateSettings(UpdateSettingsInput{EraTag: &grouping}); err != nil {
+		t.Fatalf("UpdateSettings: %v", err)
+	}
+	if res, _ := app.WriteSongMetadata(byDate.ID); !res.Success {
+		t.Fatalf("WriteSongMetadata: %s", res.Error)
+	}
+	tags, err := (id3Adapter{}).Read(fullPath)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if tags.Grouping != "WLR V1" {
+		t.Fatalf("expected the era in the grouping tag, got %q", tags.Grouping)
+	}
+	albumMode := eraTagAlbum
+	if _, err := app.UpdateSettings(UpdateSettingsInput{EraTag: &albumMode}); err != nil {
+		t.Fatalf("UpdateSettings: %v", err)
+	}
+	if built, _, err := app.buildSongTags(byDate.ID); err != nil || built.Album != "WLR V1" || built.Grouping != "" {
+		t.Fatalf("expected the era as album, got %+v (err %v)", built, err)
+	}
+
+	if err := app.DeleteEra(wlr1.ID); err != nil {
+		t.Fatalf("DeleteEra: %v", err)
+	}
+	if readable, _ := app.GetSongReadable(explicit.ID); readable.Era != nil || readable.EraID != nil {
+		t.Fatalf("expected the deleted era to be unassigned, got %+v", readable.Era)
+	}
+}
+
+func TestMergeArtistsMovesEras(t *testing.T) {
+	app := newTestApp(t)
+
+	source, err := app.CreateArtist(CreateArtistInput{Name: "Thugger"})
+	if err != nil {
+		t.Fatalf("CreateArtist: %v", err)
+	}
+	target, err := app.CreateArtist(CreateArtistInput{Name: "Young Thug"})
+	if err != nil {
+		t.Fatalf("CreateArtist: %v", err)
+	}
+	shared, err := app.CreateEra(CreateEraInput{ArtistID: target.ID, Name: "Slime Season"})
+	if err != nil {
+		t.Fatalf("CreateEra: %v", err)
+	}
+	dup, err := app.CreateEra(CreateEraInput{ArtistID: source.ID, Name: "slime season"})
+	if err != nil {
+		t.Fatalf("CreateEra: %v", err)
+	}
+	if _, err := app.CreateEra(CreateEraInput{ArtistID: source.ID, Name: "Barter 6"}); err != nil {
+		t.Fatalf("CreateEra: %v", err)
+	}
+	song, err := app.CreateSong(CreateSongInput{Name: "Power", Filepath: "uploads/songs/power.mp3", ArtistIDs: []int{source.ID}})
+	if err != nil {
+		t.Fatalf("CreateSong: %v", err)
+	}
+	if err := app.AssignSongsToEra([]int{song.ID}, &dup.ID); err != nil {
+		t.Fatalf("AssignSongsToEra: %v", err)
+	}
+
+	if _, err := app.MergeArtists(source.ID, target.ID); err != nil {
+		t.Fatalf("MergeArtists: %v", err)
+	}
+	eras, err := app.GetEras(target.ID)
+	if err != nil {
+		t.Fatalf("GetEras: %v", err)
+	}
+	if len(eras) != 2 || eras[0].ID != shared.ID || eras[1].Name != "Barter 6" {
+		t.Fatalf("unexpected merged eras: %+v", eras)
+	}
+	if readable, _ := app.GetSongReadable(song.ID); readable.Era == nil || readable.Era.ID != shared.ID {
+		t.Fatalf("expected the song to move to the target's era, got %+v", readable.Era)
+	}
+}
@@ -0,0 +1,390 @@
+package backend
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// --- Filename Templates ---
+
+// filenamePlaceholders are the fields a template can capture. {skip}
+// matches anything and may repeat; the rest may appear once each.
+var filenamePlaceholders = map[string]string{
+	"title":    `.+?`,
+	"artist":   `.+?`,
+	"feat":     `.+?`,
+	"producer": `.+?`,
+	"version":  `.+?`,
+	"track":    `\d{1,3}`,
+	"skip":     `.*?`,
+}
+
+var templatePlaceholderPattern = regexp.MustCompile(`\{([a-z]+)\}`)
+
+// Decorations found anywhere in a filename, whatever the template.
+var (
+	bracketedFeatPattern    = regexp.MustCompile(`(?i)[(\[]\s*(?:feat\.?|ft\.?|featuring|with)\s+([^)\]]+)[)\]]`)
+	bracketedProdPattern    = regexp.MustCompile(`(?i)[(\[]\s*prod(?:uced)?\b\.?(?:\s+by\b\.?)?\s*([^)\]]+)[)\]]`)
+	bracketedVersionPattern = regexp.MustCompile(`(?i)[(\[]\s*((?:v|ver\.?|version)\s*\d+(?:\.\d+)?)\s*[)\]]`)
+	trailingVersionPattern  = regexp.MustCompile(`(?i)\s+(v\d+(?:\.\d+)?)$`)
+	inlineFeatPattern       = regexp.MustCompile(`(?i)\s+(?:feat\.?|ft\.?|featuring)\s+`)
+	inlineProdPattern       = regexp.MustCompile(`(?i)\s+prod(?:uced)?\b\.?(?:\s+by\b\.?)?\s+`)
+)
+
+// GetFilenameTemplates returns every template in the order they're tried.
+func (a *App) GetFilenameTemplates() ([]FilenameTemplate, error) {
+	rows, err := a.db.Query(`
+		SELECT id, name, pattern, enabled, "order", created_at, updated_at
+		FROM filename_templates ORDER BY "order", id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	templates := []FilenameTemplate{}
+	for rows.Next() {
+		t, err := scanFilenameTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, *t)
+	}
+	return templates, rows.Err()
+}
+
+// CreateFilenameTemplate adds an enabled template after the existing ones.
+func (a *App) CreateFilenameTemplate(input CreateFilenameTemplateInput) (*FilenameTemplate, error) {
+	name := strings.TrimSpace(input.Name)
+	pattern := strings.TrimSpace(input.Pattern)
+	if _, err := compileFilenameTemplate(pattern); err != nil {
+		return nil, err
+	}
+	if name == "" {
+		name = pattern
+	}
+
+	now := time.Now().Unix()
+	res, err := a.db.Exec(`
+		INSERT INTO filename_templates (name, pattern, enabled, "order", created_at, updated_at)
+		VALUES (?, ?, 1, (SELECT COALESCE(MAX("order"), -1) + 1 FROM filename_templates), ?, ?)
+	`, name, pattern, now, now)
+	if err != nil {
+		return nil, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return a.getFilenameTemplateByID(int(id))
+}
+
+// UpdateFilenameTemplate renames, changes, or toggles a template. Nil fields
+// are kept.
+func (a *App) UpdateFilenameTemplate(input UpdateFilenameTemplateInput) (*FilenameTemplate, error) {
+	t, err := a.getFilenameTemplateByID(input.ID)
+	if err != nil {
+		return nil, err
+	}
+	if input.Name != nil {
+		if t.Name = strings.TrimSpace(*input.Name); t.Name == "" {
+			return nil, fmt.Errorf("template name is required")
+		}
+	}
+	if input.Pattern != nil {
+		t.Pattern = strings.TrimSpace(*input.Pattern)
+		if _, err := compileFilenameTemplate(t.Pattern); err != nil {
+			return nil, err
+		}
+	}
+	if input.Enabled != nil {
+		t.Enabled = *input.Enabled
+	}
+
+	if _, err := a.db.Exec(`UPDATE filename_templates SET name = ?, pattern = ?, enabled = ?, updated_at = ? WHERE id = ?`,
+		t.Name, t.Pattern, t.Enabled, time.Now().Unix(), t.ID); err != nil {
+		return nil, err
+	}
+	return a.getFilenameTemplateByID(t.ID)
+}
+
+func (a *App) DeleteFilenameTemplate(id int) error {
+	_, err := a.db.Exec(`DELETE FROM filename_templates WHERE id = ?`, id)
+	return err
+}
+
+// ReorderFilenameTemplates sets the order templates are tried in. ids must
+// list every template.
+func (a *App) ReorderFilenameTemplates(ids []int) ([]FilenameTemplate, error) {
+	current, err := a.GetFilenameTemplates()
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[int]bool)
+	for _, t := range current {
+		known[t.ID] = true
+	}
+	seen := make(map[int]bool)
+	for _, id := range ids {
+		if !known[id] || seen[id] {
+			return nil, fmt.Errorf("filename template %d doesn't exist or is listed twice", id)
+		}
+		seen[id] = true
+	}
+	if len(ids) != len(current) {
+		return nil, fmt.Errorf("expected all %d filename templates, got %d", len(current), len(ids))
+	}
+
+	now := time.Now().Unix()
+	if err := a.InTx(func(tx *sql.Tx) error {
+		for i, id := range ids {
+			if _, err := tx.Exec(`UPDATE filename_templates SET "order" = ?, updated_at = ? WHERE id = ?`, i, now, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return a.GetFilenameTemplates()
+}
+
+// TestFilenameTemplate parses filename with a single pattern, so a template
+// can be tried before it's saved. It returns nil when the pattern doesn't
+// match.
+func (a *App) TestFilenameTemplate(pattern, filename string) (*ParsedFilename, error) {
+	if _, err := compileFilenameTemplate(pattern); err != nil {
+		return nil, err
+	}
+	parsed := parseFilename(filename, []FilenameTemplate{{Pattern: pattern, Enabled: true}})
+	if parsed.Template == "" {
+		return nil, nil
+	}
+	return parsed, nil
+}
+
+// ParseFilename parses filename with the enabled templates, first match
+// wins. Without a match only the decorations are parsed and the rest of the
+// name becomes the title.
+func (a *App) ParseFilename(filename string) (*ParsedFilename, error) {
+	templates, err := a.GetFilenameTemplates()
+	if err != nil {
+		return nil, err
+	}
+	return parseFilename(filename, templates), nil
+}
+
+// mergeParsedFilename fills the metadata fields the tags left empty. The
+// version stays in the title so versions of a song keep distinct names.
+func mergeParsedFilename(metadata *ExtractedMetadata, parsed *ParsedFilename) {
+	metadata.Filename = parsed
+	if metadata.Title == "" && parsed.Title != "" {
+		metadata.Title = parsed.Title
+		if parsed.Version != "" {
+			metadata.Title += " (" + parsed.Version + ")"
+		}
+	}
+	if metadata.Artist == "" && len(parsed.Artists) > 0 {
+		metadata.Artist = strings.Join(parsed.Artists, ", ")
+		if len(parsed.FeaturedArtists) > 0 {
+			metadata.Artist += " feat. " + strings.Join(parsed.FeaturedArtists, ", ")
+		}
+	}
+	if metadata.Producer == "" && len(parsed.Producers) > 0 {
+		metadata.Producer = strings.Join(parsed.Producers, ", ")
+	}
+	if metadata.TrackNumber == 0 {
+		metadata.TrackNumber = parsed.TrackNumber
+	}
+}
+
+// parseFilename strips the extension and the bracketed decorations - (feat.
+// X), [prod. Y & Z], (v2) - then tries each enabled template against what's
+// left. Underscores count as spaces.
+func parseFilename(filename string, templates []FilenameTemplate) *ParsedFilename {
+	base := filepath.Base(filename)
+	stem := strings.ReplaceAll(strings.TrimSuffix(base, filepath.Ext(base)), "_", " ")
+	parsed := &ParsedFilename{Artists: []string{}, FeaturedArtists: []string{}, Producers: []string{}}
+
+	stem = cutDecorations(stem, bracketedFeatPattern, func(v string) {
+		parsed.FeaturedArtists = appendUniqueNames(parsed.FeaturedArtists, ParseArtists(v)...)
+	})
+	stem = cutDecorations(stem, bracketedProdPattern, func(v string) {
+		parsed.Producers = appendUniqueNames(parsed.Producers, ParseArtists(v)...)
+	})
+	stem = cutDecorations(stem, bracketedVersionPattern, func(v string) { parsed.Version = v })
+	stem = cutDecorations(singleLine(stem), trailingVersionPattern, func(v string) { parsed.Version = v })
+	stem = singleLine(stem)
+
+	for _, t := range templates {
+		if !t.Enabled {
+			continue
+		}
+		re, err := compileFilenameTemplate(t.Pattern)
+		if err != nil {
+			continue
+		}
+		if applyFilenameTemplate(re, stem, parsed) {
+			parsed.Template = t.Pattern
+			break
+		}
+	}
+	if parsed.Template == "" {
+		parsed.Title = stem
+	}
+
+	// credits written inline in the title: "Title feat. X prod. Y"
+	title, producers := splitInline(parsed.Title, inlineProdPattern)
+	title, featured := splitInline(title, inlineFeatPattern)
+	parsed.Title = title
+	parsed.FeaturedArtists = appendUniqueNames(parsed.FeaturedArtists, ParseArtists(featured)...)
+	parsed.Producers = appendUniqueNames(parsed.Producers, ParseArtists(producers)...)
+	return parsed
+}
+
+func applyFilenameTemplate(re *regexp.Regexp, stem string, parsed *ParsedFilename) bool {
+	match := re.FindStringSubmatch(stem)
+	if match == nil {
+		return false
+	}
+	for i, name := range re.SubexpNames() {
+		value := strings.TrimSpace(match[i])
+		if name == "" || value == "" {
+			continue
+		}
+		switch name {
+		case "title":
+			parsed.Title = value
+		case "artist":
+			artists, featured := splitInline(value, inlineFeatPattern)
+			parsed.Artists = appendUniqueNames(parsed.Artists, ParseArtists(artists)...)
+			parsed.FeaturedArtists = appendUniqueNames(parsed.FeaturedArtists, ParseArtists(featured)...)
+		case "feat":
+			parsed.FeaturedArtists = appendUniqueNames(parsed.FeaturedArtists, ParseArtists(value)...)
+		case "producer":
+			parsed.Producers = appendUniqueNames(parsed.Producers, ParseArtists(value)...)
+		case "version":
+			parsed.Version = value
+		case "track":
+			parsed.TrackNumber, _ = strconv.Atoi(value)
+		}
+	}
+	return true
+}
+
+// compileFilenameTemplate turns a pattern into an anchored, case-insensitive
+// regexp. A space in the pattern matches any run of whitespace; where the
+// pattern has none, the filename mustn't either, so "{artist} - {title}"
+// keeps "Jay-Z" whole.
+func compileFilenameTemplate(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString(`(?i)^`)
+	seen := make(map[string]bool)
+	last := 0
+	for _, loc := range templatePlaceholderPattern.FindAllStringSubmatchIndex(pattern, -1) {
+		b.WriteString(templateLiteral(pattern[last:loc[0]]))
+		name := pattern[loc[2]:loc[3]]
+		expr, ok := filenamePlaceholders[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown placeholder {%s} (want title, artist, feat, producer, version, track, or skip)", name)
+		}
+		if name == "skip" {
+			b.WriteString("(?:" + expr + ")")
+		} else {
+			if seen[name] {
+				return nil, fmt.Errorf("placeholder {%s} is used more than once", name)
+			}
+			fmt.Fprintf(&b, "(?P<%s>%s)", name, expr)
+		}
+		seen[name] = true
+		last = loc[1]
+	}
+	b.WriteString(templateLiteral(pattern[last:]))
+	b.WriteString(`$`)
+	if !seen["title"] {
+		return nil, fmt.Errorf("template %q needs a {title} placeholder", pattern)
+	}
+	return regexp.Compile(b.String())
+}
+
+func templateLiteral(lit string) string {
+	if lit == "" {
+		return ""
+	}
+	if strings.TrimSpace(lit) == "" {
+		return `\s+`
+	}
+	words := strings.Fields(lit)
+	for i, w := range words {
+		words[i] = regexp.QuoteMeta(w)
+	}
+	expr := strings.Join(words, `\s+`)
+	if strings.TrimLeft(lit, " \t") != lit {
+		expr = `\s+` + expr
+	}
+	if strings.TrimRight(lit, " \t") != lit {
+		expr += `\s+`
+	}
+	return expr
+}
+
+// cutDecorations removes every match of re from s, passing each one's first
+// group to found.
+func cutDecorations(s string, re *regexp.Regexp, found func(string)) string {
+	for _, m := range re.FindAllStringSubmatch(s, -1) {
+		found(strings.TrimSpace(m[1]))
+	}
+	return re.ReplaceAllString(s, " ")
+}
+
+// splitInline splits s at the first match of sep.
+func splitInline(s string, sep *regexp.Regexp) (string, string) {
+	loc := sep.FindStringIndex(s)
+	if loc == nil {
+		return s, ""
+	}
+	return strings.TrimSpace(s[:loc[0]]), strings.TrimSpace(s[loc[1]:])
+}
+
+func appendUniqueNames(names []string, more ...string) []string {
+	for _, name := range more {
+		duplicate := false
+		for _, existing := range names {
+			if strings.EqualFold(existing, name) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func (a *App) getFilenameTemplateByID(id int) (*FilenameTemplate, error) {
+	t, err := scanFilenameTemplate(a.db.QueryRow(`
+		SELECT id, name, pattern, enabled, "order", created_at, updated_at
+		FROM filename_templates WHERE id = ?
+	`, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("filename template %d not found", id)
+	}
+	return t, err
+}
+
+func scanFilenameTemplate(row interface{ Scan(...any) error }) (*FilenameTemplate, error) {
+	var t FilenameTemplate
+	var createdAt, updatedAt sql.NullInt64
+	if err := row.Scan(&t.ID, &t.Name, &t.Pattern, &t.Enabled, &t.Order, &createdAt, &updatedAt); err != nil {
+		return nil, err
+	}
+	t.CreatedAt = createdAt.Int64
+	t.UpdatedAt = updatedAt.Int64
+	return &t, nil
+}
@@ -0,0 +1,115 @@
+package backend
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseFilenameWithDefaultTemplates(t *testing.T) {
+	app := newTestApp(t)
+
+	for _, tc := range []struct {
+		filename string
+		want     ParsedFilename
+	}{
+		{
+			"Artist - Title (feat. X) [prod. Y & Z] v2.mp3",
+			ParsedFilename{Template: "{artist} - {title}", Title: "Title", Artists: []string{"Artist"},
+				FeaturedArtists: []string{"X"}, Producers: []string{"Y", "Z"}, Version: "v2"},
+		},
+		{
+			"07. Jay-Z & Kanye West ft. Frank Ocean - No Church in the Wild.flac",
+			ParsedFilename{Template: "{track}. {artist} - {title}", Title: "No Church in the Wild", Artists: []string{"Jay-Z", "Kanye West"},
+				FeaturedArtists: []string{"Frank Ocean"}, Producers: []string{}, TrackNumber: 7},
+		},
+		{
+			"12. Molly_(Produced by Cardo) [V3].m4a",
+			ParsedFilename{Template: "{track}. {title}", Title: "Molly", Artists: []string{},
+				FeaturedArtists: []string{}, Producers: []string{"Cardo"}, Version: "V3", TrackNumber: 12},
+		},
+		{
+			"unknown snippet feat. Someone.wav",
+			ParsedFilename{Title: "unknown snippet", Artists: []string{}, FeaturedArtists: []string{"Someone"}, Producers: []string{}},
+		},
+	} {
+		got, err := app.ParseFilename(tc.filename)
+		if err != nil {
+			t.Fatalf("ParseFilename(%q): %v", tc.filename, err)
+		}
+		if !reflect.DeepEqual(*got, tc.want) {
+			t.Errorf("ParseFilename(%q):\n got %+v\nwant %+v", tc.filename, *got, tc.want)
+		}
+	}
+}
+
+func TestFilenameTemplateManagement(t *testing.T) {
+	app := newTestApp(t)
+
+	for _, bad := range []string{"{artist} - {song}", "{artist} - {artist}", "{track} {artist}"} {
+		if _, err := app.CreateFilenameTemplate(CreateFilenameTemplateInput{Pattern: bad}); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+
+	parsed, err := app.TestFilenameTemplate("{title} [{skip}] - {artist}", "Kid Cudi [CDQ] - Playboi Carti.mp3")
+	if err != nil {
+		t.Fatalf("TestFilenameTemplate: %v", err)
+	}
+	if parsed == nil || parsed.Title != "Kid Cudi" || !reflect.DeepEqual(parsed.Artists, []string{"Playboi Carti"}) {
+		t.Fatalf("unexpected parse: %+v", parsed)
+	}
+	if parsed, err := app.TestFilenameTemplate("{artist} - {title}", "no separator here.mp3"); err != nil || parsed != nil {
+		t.Fatalf("expected no match, got %+v (err %v)", parsed, err)
+	}
+
+	// a new template goes last, so it only wins once moved up
+	custom, err := app.CreateFilenameTemplate(CreateFilenameTemplateInput{Name: "Title by Artist", Pattern: "{title} by {artist}"})
+	if err != nil {
+		t.Fatalf("CreateFilenameTemplate: %v", err)
+	}
+	templates, err := app.GetFilenameTemplates()
+	if err != nil {
+		t.Fatalf("GetFilenameTemplates: %v", err)
+	}
+	if len(templates) != 4 || templates[3].ID != custom.ID || !templates[3].Enabled {
+		t.Fatalf("unexpected templates: %+v", templates)
+	}
+	ids := []int{custom.ID}
+	for _, tmpl := range templates[:3] {
+		ids = append(ids, tmpl.ID)
+	}
+	if _, err := app.ReorderFilenameTemplates(ids[:2]); err == nil {
+		t.Fatal("expected a partial reorder to be rejected")
+	}
+	if _, err := app.ReorderFilenameTemplates(ids); err != nil {
+		t.Fatalf("ReorderFilenameTemplates: %v", err)
+	}
+	if parsed, _ := app.ParseFilename("Magnolia by Playboi Carti.mp3"); parsed.Template != custom.Pattern || parsed.Artists[0] != "Playboi Carti" {
+		t.Fatalf("expected the custom template to match first, got %+v", parsed)
+	}
+	disabled := false
+	if _, err := app.UpdateFilenameTemplate(UpdateFilenameTemplateInput{ID: custom.ID, Enabled: &disabled}); err != nil {
+		t.Fatalf("UpdateFilenameTemplate: %v", err)
+	}
+	if parsed, _ := app.ParseFilename("Magnolia by Playboi Carti.mp3"); parsed.Template != "" || parsed.Title != "Magnolia by Playboi Carti" {
+		t.Fatalf("expected the disabled template to be skipped, got %+v", parsed)
+	}
+}
+
+func TestExtractMetadataFallsBackToFilename(t *testing.T) {
+	app := newTestApp(t)
+
+	relPath := "uploads/songs/1700000000000-03. Future - Mask Off [prod. Metro Boomin].mp3"
+	writeSilentMP3(t, app, relPath)
+
+	metadata, err := app.ExtractMetadata(relPath)
+	if err != nil {
+		t.Fatalf("ExtractMetadata: %v", err)
+	}
+	if metadata.Title != "Mask Off" || metadata.Artist != "Future" || metadata.Producer != "Metro Boomin" || metadata.TrackNumber != 3 {
+		t.Fatalf("expected the filename to fill the empty tags, got %+v", metadata)
+	}
+	if metadata.Filename == nil || metadata.Filename.Template != "{track}. {artist} - {title}" {
+		t.Fatalf("expected the parse to be reported, got %+v", metadata.Filename)
+	}
+}
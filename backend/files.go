@@ -3,10 +3,16 @@ package backend
 import (
 	"encoding/base64"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	wailsruntime "github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
 // --- File Operations ---
@@ -33,6 +39,123 @@ func (a *App) SaveUploadedFile(filename string, base64Data string) (string, erro
 	return a.saveBase64("songs", filename, base64Data)
 }
 
+// importableAudioExts are the extensions picked up when importing a directory.
+var importableAudioExts = map[string]bool{
+	".mp3": true, ".flac": true, ".m4a": true, ".mp4": true, ".m4b": true, ".m4p": true,
+	".ogg": true, ".oga": true, ".opus": true, ".wav": true, ".aif": true, ".aiff": true, ".aifc": true,
+}
+
+func collectAudioFiles(dir string, recursive bool) ([]string, error) {
+	paths := []string{}
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if importableAudioExts[strings.ToLower(filepath.Ext(path))] {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	sort.Strings(paths)
+	return paths, err
+}
+
+// importFile brings a file from anywhere on disk into uploads/songs without
+// loading it into memory: a streamed copy by default, or a hard link when
+// requested (falling back to a copy across filesystems). A hard-linked upload
+// shares its bytes with the original until its tags are explicitly written:
+// the adapters write a temp file and rename it, which breaks the link, so
+// song creation leaves such files untagged.
+func (a *App) importFile(srcPath string, hardLink bool) (string, error) {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %v", srcPath, err)
+	}
+	defer src.Close()
+
+	// upload names are timestamped to the millisecond; retry on the rare
+	// same-name collision within one import
+	var relPath, fullPath string
+	for attempt := 0; ; attempt++ {
+		relPath, fullPath, err = a.newUploadPath("songs", filepath.Base(srcPath))
+		if err != nil {
+			return "", err
+		}
+		if _, statErr := os.Lstat(fullPath); os.IsNotExist(statErr) || attempt == 4 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if hardLink {
+		if err := os.Link(srcPath, fullPath); err == nil {
+			a.recordLink(relPath, time.Now())
+			return relPath, nil
+		}
+	}
+
+	dst, err := os.OpenFile(fullPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return "", fmt.Errorf("failed to write file: %v", err)
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(fullPath)
+		return "", fmt.Errorf("failed to copy %s: %v", srcPath, err)
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(fullPath)
+		return "", fmt.Errorf("failed to write file: %v", err)
+	}
+	return relPath, nil
+}
+
+// importPaths imports files and (recursively) the audio files inside
+// directories, in the order given.
+func (a *App) importPaths(paths []string, hardLink bool) ([]savedUpload, error) {
+	saved := []savedUpload{}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		files := []string{path}
+		if info.IsDir() {
+			if files, err = collectAudioFiles(path, true); err != nil {
+				return nil, err
+			}
+		}
+		for _, file := range files {
+			relPath, err := a.importFile(file, hardLink)
+			if err != nil {
+				return nil, err
+			}
+			saved = append(saved, savedUpload{OriginalFilename: filepath.Base(file), Filepath: relPath})
+		}
+	}
+	return saved, nil
+}
+
+// SelectImportFiles opens a native file picker for audio files and returns
+// the chosen paths, for ImportFromPaths.
+func (a *App) SelectImportFiles() ([]string, error) {
+	patterns := make([]string, 0, len(importableAudioExts))
+	for ext := range importableAudioExts {
+		patterns = append(patterns, "*"+ext)
+	}
+	sort.Strings(patterns)
+	return wailsruntime.OpenMultipleFilesDialog(a.ctx, wailsruntime.OpenDialogOptions{
+		Title:   "Import Songs",
+		Filters: []wailsruntime.FileFilter{{DisplayName: "Audio Files", Pattern: strings.Join(patterns, ";")}},
+	})
+}
+
 func (a *App) SaveArtwork(filename string, base64Data string) (string, error) {
 	return a.saveBase64("artwork", filename, base64Data)
 }
@@ -42,9 +165,28 @@ func (a *App) DeleteFile(relPath string) error {
 	if err != nil {
 		return err
 	}
+	a.forgetFileIdentity(relPath)
+	a.linkedAtMu.Lock()
+	delete(a.linkedAt, relPath)
+	a.linkedAtMu.Unlock()
 	return os.Remove(fullPath)
 }
 
+// uploadHasOtherLinks reports whether an upload is hard-linked to a file
+// elsewhere, such as the original of a linked import.
+func (a *App) uploadHasOtherLinks(relPath string) bool {
+	fullPath, err := a.uploadsFilePath(relPath)
+	if err != nil {
+		return false
+	}
+	f, err := os.Open(fullPath)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	return hasOtherLinks(f)
+}
+
 func (a *App) CleanupFiles(relPaths []string) int {
 	deleted := 0
 	for _, relPath := range relPaths {
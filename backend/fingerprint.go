@@ -0,0 +1,832 @@
+package backend
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/bits"
+	"math/cmplx"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// --- Fingerprinting ---
+
+// Acoustic fingerprints follow Haitsma & Kalker ("A Highly Robust Audio
+// Fingerprinting System"): the first fingerprintSeconds of audio are decoded
+// to mono PCM at fingerprintSampleRate, and every fingerprintHop samples a
+// 32-bit sub-fingerprint is derived from the sign of energy differences
+// between 33 log-spaced bands across adjacent frames. Re-encodes and bitrate
+// changes flip few bits, so two files are the same recording when their
+// sub-fingerprints line up at some offset with a low bit error rate.
+const (
+	fingerprintSampleRate = 5512
+	fingerprintFrameSize  = 2048
+	fingerprintHop        = 64
+	fingerprintSeconds    = 120
+	fingerprintBands      = 33
+	fingerprintMinFreq    = 300.0
+	fingerprintMaxFreq    = 2000.0
+
+	// fingerprintIndexStride indexes every Nth sub-fingerprint of the library;
+	// queries probe every position, so aligned matches are still found.
+	fingerprintIndexStride = 2
+	// fingerprintMaxPostings skips values that occur everywhere (silence).
+	fingerprintMaxPostings = 64
+	fingerprintMinVotes    = 2
+	fingerprintMinOverlap  = 256 // ~3s
+	// fingerprintMatchThreshold is the minimum similarity (1 - bit error rate)
+	// reported as an acoustic duplicate.
+	fingerprintMatchThreshold = 0.75
+)
+
+const (
+	duplicateMatchExact    = "exact"
+	duplicateMatchAcoustic = "acoustic"
+)
+
+// hashFile returns the hex SHA-256 of a file's audio. Tags are left out,
+// since metadata write-back rewrites them, so a byte-identical re-upload of a
+// leak matches a song whose file has been retagged since. Files whose audio
+// can't be told apart from their tags are hashed whole.
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	ranges, err := audioPayload(f, info.Size())
+	if err != nil {
+		ranges = []byteRange{{0, info.Size()}}
+	}
+
+	h := sha256.New()
+	for _, r := range ranges {
+		if _, err := io.Copy(h, io.NewSectionReader(f, r.start, r.end-r.start)); err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// byteRange is the span [start, end) of a file.
+type byteRange struct{ start, end int64 }
+
+// audioPayload locates a file's audio, leaving out its tags: the MP3 frames
+// between ID3v2 and any trailing ID3v1/APE tag, the FLAC frames after the
+// metadata blocks, the WAV data or AIFF SSND chunk, MP4 mdat boxes, and the
+// bodies of the Ogg pages after the header packets.
+func audioPayload(r io.ReaderAt, size int64) ([]byteRange, error) {
+	head := make([]byte, 12)
+	n, err := r.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	head = head[:n]
+
+	switch {
+	case bytes.HasPrefix(head, []byte("OggS")):
+		return oggPayload(r, size)
+	case len(head) >= 8 && string(head[4:8]) == "ftyp":
+		return mp4Payload(r, size)
+	case len(head) >= 12 && string(head[:4]) == "RIFF":
+		return iffPayload(r, size, binary.LittleEndian, "data")
+	case len(head) >= 12 && string(head[:4]) == "FORM":
+		return iffPayload(r, size, binary.BigEndian, "SSND")
+	}
+
+	// ID3v2 can front both MP3 and FLAC
+	start, err := id3v2End(r)
+	if err != nil {
+		return nil, err
+	}
+	marker := make([]byte, 4)
+	if _, err := r.ReadAt(marker, start); err == nil && string(marker) == "fLaC" {
+		return flacPayload(r, start, size)
+	}
+	return mp3Payload(r, start, size)
+}
+
+func mp3Payload(r io.ReaderAt, start, size int64) ([]byteRange, error) {
+	end := size
+	footer := make([]byte, 32)
+	if end-start >= 128 {
+		if _, err := r.ReadAt(footer[:3], end-128); err == nil && string(footer[:3]) == "TAG" {
+			end -= 128
+		}
+	}
+	if end-start >= 32 {
+		if _, err := r.ReadAt(footer, end-32); err == nil && string(footer[:8]) == "APETAGEX" {
+			tagSize := int64(binary.LittleEndian.Uint32(footer[12:16]))
+			if binary.LittleEndian.Uint32(footer[20:24])&(1<<31) != 0 { // header present
+				tagSize += 32
+			}
+			if tagSize <= end-start {
+				end -= tagSize
+			}
+		}
+	}
+	return []byteRange{{start, end}}, nil
+}
+
+func flacPayload(r io.ReaderAt, start, size int64) ([]byteRange, error) {
+	hdr := make([]byte, 4)
+	for pos := start + 4; pos+4 <= size; {
+		if _, err := r.ReadAt(hdr, pos); err != nil {
+			return nil, err
+		}
+		pos += 4 + (int64(hdr[1])<<16 | int64(hdr[2])<<8 | int64(hdr[3]))
+		if hdr[0]&0x80 != 0 {
+			return []byteRange{{min(pos, size), size}}, nil
+		}
+	}
+	return nil, fmt.Errorf("flac metadata runs past the end of the file")
+}
+
+func iffPayload(r io.ReaderAt, size int64, order binary.ByteOrder, audioChunk string) ([]byteRange, error) {
+	hdr := make([]byte, 8)
+	for pos := int64(12); pos+8 <= size; {
+		if _, err := r.ReadAt(hdr, pos); err != nil {
+			return nil, err
+		}
+		chunkSize := int64(order.Uint32(hdr[4:8]))
+		if string(hdr[:4]) == audioChunk {
+			return []byteRange{{pos + 8, min(pos+8+chunkSize, size)}}, nil
+		}
+		pos += 8 + chunkSize + chunkSize&1
+	}
+	return nil, fmt.Errorf("no %s chunk", audioChunk)
+}
+
+func mp4Payload(r io.ReaderAt, size int64) ([]byteRange, error) {
+	boxes, err := readMP4Boxes(r, 0, size)
+	if err != nil {
+		return nil, err
+	}
+	ranges := []byteRange{}
+	for _, box := range boxes {
+		if box.kind == "mdat" {
+			ranges = append(ranges, byteRange{box.start + box.headerSize, box.start + box.size})
+		}
+	}
+	if len(ranges) == 0 {
+		return nil, fmt.Errorf("no mdat box")
+	}
+	return ranges, nil
+}
+
+// oggPayload leaves out page headers too: retagging repaginates the comment
+// packet, which renumbers every page after it.
+func oggPayload(r io.ReaderAt, size int64) ([]byteRange, error) {
+	ranges := []byteRange{}
+	buf := make([]byte, 27+255)
+	headerPackets, packets := 0, 0
+	for pos := int64(0); pos < size; {
+		n, err := r.ReadAt(buf, pos)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		hdr, ok := parseOggPageHeader(buf[:n])
+		if !ok {
+			return nil, fmt.Errorf("invalid ogg page at offset %d", pos)
+		}
+		body := pos + int64(hdr.headerSize)
+		if headerPackets == 0 {
+			id := make([]byte, 8)
+			if _, err := r.ReadAt(id, body); err != nil {
+				return nil, err
+			}
+			switch {
+			case string(id[1:7]) == "vorbis":
+				headerPackets = 3
+			case string(id) == "OpusHead":
+				headerPackets = 2
+			default:
+				return nil, errUnknownAudioFormat
+			}
+		}
+		if packets >= headerPackets {
+			ranges = append(ranges, byteRange{body, min(body+int64(hdr.bodySize), size)})
+		} else {
+			for _, lacing := range buf[27:hdr.headerSize] {
+				if lacing < 255 {
+					packets++
+				}
+			}
+		}
+		pos = body + int64(hdr.bodySize)
+	}
+	return ranges, nil
+}
+
+// fingerprintFile decodes a file and fingerprints it.
+func fingerprintFile(path string) ([]uint32, error) {
+	samples, err := decodePCM(path)
+	if err != nil {
+		return nil, err
+	}
+	fp := fingerprintPCM(samples)
+	if len(fp) == 0 {
+		return nil, fmt.Errorf("audio too short to fingerprint")
+	}
+	return fp, nil
+}
+
+// fingerprintPCM computes sub-fingerprints from mono samples at
+// fingerprintSampleRate.
+func fingerprintPCM(samples []float64) []uint32 {
+	if len(samples) < fingerprintFrameSize {
+		return nil
+	}
+
+	window := make([]float64, fingerprintFrameSize)
+	for i := range window {
+		window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(fingerprintFrameSize-1))
+	}
+
+	// band edges as FFT bin indexes, log-spaced between min and max frequency
+	edges := make([]int, fingerprintBands+1)
+	binHz := float64(fingerprintSampleRate) / fingerprintFrameSize
+	ratio := fingerprintMaxFreq / fingerprintMinFreq
+	for i := range edges {
+		freq := fingerprintMinFreq * math.Pow(ratio, float64(i)/fingerprintBands)
+		edges[i] = int(math.Round(freq / binHz))
+	}
+
+	frames := (len(samples)-fingerprintFrameSize)/fingerprintHop + 1
+	fp := make([]uint32, 0, frames-1)
+	buf := make([]complex128, fingerprintFrameSize)
+	prev := make([]float64, fingerprintBands)
+	cur := make([]float64, fingerprintBands)
+
+	for n := 0; n < frames; n++ {
+		start := n * fingerprintHop
+		for i := range buf {
+			buf[i] = complex(samples[start+i]*window[i], 0)
+		}
+		fft(buf)
+
+		for b := 0; b < fingerprintBands; b++ {
+			energy := 0.0
+			for k := edges[b]; k < edges[b+1]; k++ {
+				mag := cmplx.Abs(buf[k])
+				energy += mag * mag
+			}
+			cur[b] = energy
+		}
+
+		if n > 0 {
+			var word uint32
+			for b := 0; b < fingerprintBands-1; b++ {
+				if (cur[b]-cur[b+1])-(prev[b]-prev[b+1]) > 0 {
+					word |= 1 << b
+				}
+			}
+			fp = append(fp, word)
+		}
+		prev, cur = cur, prev
+	}
+	return fp
+}
+
+// fft is an in-place iterative radix-2 FFT; len(x) must be a power of two.
+func fft(x []complex128) {
+	n := len(x)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				u := x[start+k]
+				v := x[start+k+size/2] * w
+				x[start+k] = u + v
+				x[start+k+size/2] = u - v
+				w *= step
+			}
+		}
+	}
+}
+
+func encodeFingerprint(fp []uint32) []byte {
+	out := make([]byte, len(fp)*4)
+	for i, v := range fp {
+		binary.LittleEndian.PutUint32(out[i*4:], v)
+	}
+	return out
+}
+
+func decodeFingerprint(data []byte) []uint32 {
+	fp := make([]uint32, len(data)/4)
+	for i := range fp {
+		fp[i] = binary.LittleEndian.Uint32(data[i*4:])
+	}
+	return fp
+}
+
+// fingerprintSimilarityAt returns 1 - bit error rate of a against b shifted by
+// offset (a[i] pairs with b[i-offset]), and the number of overlapping frames.
+func fingerprintSimilarityAt(a, b []uint32, offset int) (float64, int) {
+	start := max(0, offset)
+	end := min(len(a), len(b)+offset)
+	overlap := end - start
+	if overlap <= 0 {
+		return 0, 0
+	}
+	errorBits := 0
+	for i := start; i < end; i++ {
+		errorBits += bits.OnesCount32(a[i] ^ b[i-offset])
+	}
+	return 1 - float64(errorBits)/float64(overlap*32), overlap
+}
+
+type fingerprintPosting struct {
+	id  int
+	pos int
+}
+
+type fingerprintMatch struct {
+	ID         int
+	Similarity float64
+}
+
+// fingerprintIndex is an inverted index over sub-fingerprint values. Queries
+// vote on (song, offset) pairs for exact sub-fingerprint hits, then verify
+// the best-voted alignments by bit error rate.
+type fingerprintIndex struct {
+	fps      map[int][]uint32
+	postings map[uint32][]fingerprintPosting
+}
+
+func newFingerprintIndex() *fingerprintIndex {
+	return &fingerprintIndex{
+		fps:      make(map[int][]uint32),
+		postings: make(map[uint32][]fingerprintPosting),
+	}
+}
+
+func (ix *fingerprintIndex) add(id int, fp []uint32) {
+	ix.fps[id] = fp
+	for pos := 0; pos < len(fp); pos += fingerprintIndexStride {
+		v := fp[pos]
+		if v == 0 || len(ix.postings[v]) >= fingerprintMaxPostings {
+			continue
+		}
+		ix.postings[v] = append(ix.postings[v], fingerprintPosting{id: id, pos: pos})
+	}
+}
+
+// query returns indexed songs matching fp at or above
+// fingerprintMatchThreshold, best first.
+func (ix *fingerprintIndex) query(fp []uint32) []fingerprintMatch {
+	type alignment struct{ id, offset int }
+	votes := make(map[alignment]int)
+	for j, v := range fp {
+		if v == 0 {
+			continue
+		}
+		for _, p := range ix.postings[v] {
+			votes[alignment{p.id, p.pos - j}]++
+		}
+	}
+
+	best := make(map[int]float64)
+	for al, count := range votes {
+		if count < fingerprintMinVotes {
+			continue
+		}
+		sim, overlap := fingerprintSimilarityAt(ix.fps[al.id], fp, al.offset)
+		if overlap < min(fingerprintMinOverlap, len(fp), len(ix.fps[al.id])) {
+			continue
+		}
+		if sim >= fingerprintMatchThreshold && sim > best[al.id] {
+			best[al.id] = sim
+		}
+	}
+
+	matches := make([]fingerprintMatch, 0, len(best))
+	for id, sim := range best {
+		matches = append(matches, fingerprintMatch{ID: id, Similarity: sim})
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Similarity != matches[j].Similarity {
+			return matches[i].Similarity > matches[j].Similarity
+		}
+		return matches[i].ID < matches[j].ID
+	})
+	return matches
+}
+
+// songFileIdentity is what duplicate detection knows about one file.
+type songFileIdentity struct {
+	ContentHash string
+	// Fingerprint is nil when the file wasn't decoded (no decoder for its
+	// format) and empty when decoding failed, which isn't retried.
+	Fingerprint []uint32
+}
+
+// identifyFile hashes a file under static/ and, when asked and a decoder is
+// available, fingerprints it.
+func (a *App) identifyFile(relPath string, fingerprint bool) (*songFileIdentity, error) {
+	fullPath, err := a.staticFilePath(relPath)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := hashFile(fullPath)
+	if err != nil {
+		return nil, err
+	}
+	identity := &songFileIdentity{ContentHash: hash}
+	if fingerprint && canDecode(fullPath) {
+		fp, err := fingerprintFile(fullPath)
+		if err != nil {
+			log.Printf("fingerprint: %s: %v", relPath, err)
+			fp = []uint32{}
+		}
+		identity.Fingerprint = fp
+	}
+	return identity, nil
+}
+
+// rememberFileIdentity keeps an upload's identity from the duplicate check
+// until its song is created, so the file isn't hashed and decoded twice.
+// Deleting the upload (a cancelled or expired session, a discarded inbox
+// item) forgets it.
+func (a *App) rememberFileIdentity(relPath string, identity *songFileIdentity) {
+	a.identitiesMu.Lock()
+	defer a.identitiesMu.Unlock()
+	if a.identities == nil {
+		a.identities = make(map[string]*songFileIdentity)
+	}
+	a.identities[relPath] = identity
+}
+
+// fileIdentity returns a remembered identity, or nil.
+func (a *App) fileIdentity(relPath string) *songFileIdentity {
+	a.identitiesMu.Lock()
+	defer a.identitiesMu.Unlock()
+	return a.identities[relPath]
+}
+
+// takeFileIdentity returns and forgets a remembered identity, or nil.
+func (a *App) takeFileIdentity(relPath string) *songFileIdentity {
+	a.identitiesMu.Lock()
+	defer a.identitiesMu.Unlock()
+	identity := a.identities[relPath]
+	delete(a.identities, relPath)
+	return identity
+}
+
+// forgetFileIdentity drops a remembered identity whose upload is gone.
+func (a *App) forgetFileIdentity(relPath string) {
+	a.identitiesMu.Lock()
+	defer a.identitiesMu.Unlock()
+	delete(a.identities, relPath)
+}
+
+// storeSongIdentity records a song's content hash and fingerprint. A file
+// that wasn't decoded keeps the fingerprint it had.
+func (a *App) storeSongIdentity(songID int, identity *songFileIdentity) error {
+	var fingerprint []byte
+	if identity.Fingerprint != nil {
+		fingerprint = encodeFingerprint(identity.Fingerprint)
+	}
+	_, err := a.db.Exec(
+		"UPDATE songs SET content_hash = ?, fingerprint = COALESCE(?, fingerprint) WHERE id = ?",
+		identity.ContentHash, fingerprint, songID,
+	)
+	return err
+}
+
+// loadFingerprintIndex indexes every fingerprinted song in the library.
+func (a *App) loadFingerprintIndex() (*fingerprintIndex, error) {
+	rows, err := a.db.Query("SELECT id, fingerprint FROM songs WHERE length(fingerprint) > 0")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ix := newFingerprintIndex()
+	for rows.Next() {
+		var id int
+		var data []byte
+		if err := rows.Scan(&id, &data); err != nil {
+			return nil, err
+		}
+		ix.add(id, decodeFingerprint(data))
+	}
+	return ix, rows.Err()
+}
+
+// findPossibleDuplicates checks freshly uploaded files against the library:
+// first by content hash, then acoustically. Only songs that already have a
+// hash or fingerprint are compared; the startup backfill fills in the rest,
+// and until then they can't be flagged. It also returns the files that
+// couldn't be fingerprinted, which were only checked for exact copies. The
+// library index is only built if at least one upload could be fingerprinted.
+// Identities are remembered for createSongsFromSpecs, and reused when a
+// resumed session is checked again.
+func (a *App) findPossibleDuplicates(filesData []FileData) ([]PossibleDuplicate, []string, error) {
+	duplicates := []PossibleDuplicate{}
+	unfingerprinted := []string{}
+	var ix *fingerprintIndex
+
+	for _, fileData := range filesData {
+		identity := a.fileIdentity(fileData.Filepath)
+		if identity == nil {
+			var err error
+			if identity, err = a.identifyFile(fileData.Filepath, true); err != nil {
+				log.Printf("duplicates: %s: %v", fileData.Filepath, err)
+				continue
+			}
+			a.rememberFileIdentity(fileData.Filepath, identity)
+		}
+
+		exact := make(map[int]bool)
+		rows, err := a.db.Query("SELECT id, name FROM songs WHERE content_hash = ? ORDER BY id", identity.ContentHash)
+		if err != nil {
+			return nil, nil, err
+		}
+		for rows.Next() {
+			var id int
+			var name string
+			if err := rows.Scan(&id, &name); err != nil {
+				rows.Close()
+				return nil, nil, err
+			}
+			exact[id] = true
+			duplicates = append(duplicates, PossibleDuplicate{
+				Filepath:   fileData.Filepath,
+				SongID:     id,
+				SongName:   name,
+				MatchType:  duplicateMatchExact,
+				Similarity: 1,
+			})
+		}
+		if err := rows.Err(); err != nil {
+			rows.Close()
+			return nil, nil, err
+		}
+		rows.Close()
+
+		if len(identity.Fingerprint) == 0 {
+			unfingerprinted = append(unfingerprinted, fileData.Filepath)
+			continue
+		}
+		if ix == nil {
+			if ix, err = a.loadFingerprintIndex(); err != nil {
+				return nil, nil, err
+			}
+		}
+		for _, match := range ix.query(identity.Fingerprint) {
+			if exact[match.ID] {
+				continue
+			}
+			var name string
+			if err := a.db.QueryRow("SELECT name FROM songs WHERE id = ?", match.ID).Scan(&name); err != nil {
+				if err == sql.ErrNoRows {
+					continue
+				}
+				return nil, nil, err
+			}
+			duplicates = append(duplicates, PossibleDuplicate{
+				Filepath:   fileData.Filepath,
+				SongID:     match.ID,
+				SongName:   name,
+				MatchType:  duplicateMatchAcoustic,
+				Similarity: match.Similarity,
+			})
+		}
+	}
+
+	return duplicates, unfingerprinted, nil
+}
+
+// backfillSongIdentities hashes and fingerprints songs that predate
+// fingerprinting. Missing fingerprints are only computed for files a decoder
+// is available for; files that failed to decode before aren't retried.
+// Files that can't be read are skipped.
+func (a *App) backfillSongIdentities() error {
+	a.backfillMu.Lock()
+	defer a.backfillMu.Unlock()
+
+	rows, err := a.db.Query("SELECT id, filepath, content_hash IS NULL, fingerprint IS NULL FROM songs WHERE content_hash IS NULL OR fingerprint IS NULL")
+	if err != nil {
+		return err
+	}
+	type pending struct {
+		id       int
+		filepath string
+		noHash   bool
+		noFP     bool
+	}
+	var todo []pending
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.id, &p.filepath, &p.noHash, &p.noFP); err != nil {
+			rows.Close()
+			return err
+		}
+		todo = append(todo, p)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return err
+	}
+	rows.Close()
+
+	// canDecode looks for ffmpeg, so ask once per extension
+	decodable := make(map[string]bool)
+	for _, p := range todo {
+		ext := strings.ToLower(filepath.Ext(p.filepath))
+		if _, ok := decodable[ext]; !ok {
+			decodable[ext] = canDecode(p.filepath)
+		}
+		fingerprint := p.noFP && decodable[ext]
+		if !p.noHash && !fingerprint {
+			continue
+		}
+		identity, err := a.identifyFile(p.filepath, fingerprint)
+		if err != nil {
+			log.Printf("duplicates: skipping song %d (%s): %v", p.id, p.filepath, err)
+			continue
+		}
+		if err := a.storeSongIdentity(p.id, identity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FindDuplicateSongs scans the whole library for songs that are the same
+// file (identical content hash) or the same recording (acoustic fingerprint
+// match). Missing hashes and fingerprints are computed first. Matches are
+// grouped transitively; each group's Similarity is its weakest link. Songs
+// that couldn't be fingerprinted are counted, and flagged when that's for
+// want of ffmpeg.
+func (a *App) FindDuplicateSongs() (*DuplicateScan, error) {
+	if err := a.backfillSongIdentities(); err != nil {
+		return nil, err
+	}
+	scan := &DuplicateScan{}
+	var err error
+	if scan.UnfingerprintedSongs, scan.FingerprintingUnavailable, err = a.countUnfingerprintedSongs(); err != nil {
+		return nil, err
+	}
+
+	type edge struct {
+		a, b       int
+		matchType  string
+		similarity float64
+	}
+	edges := []edge{}
+
+	rows, err := a.db.Query(`
+		SELECT s1.id, s2.id
+		FROM songs s1
+		JOIN songs s2 ON s2.content_hash = s1.content_hash AND s2.id > s1.id
+		WHERE s1.content_hash IS NOT NULL
+	`)
+	if err != nil {
+		return nil, err
+	}
+	exactPairs := make(map[[2]int]bool)
+	for rows.Next() {
+		var e edge
+		if err := rows.Scan(&e.a, &e.b); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		e.matchType, e.similarity = duplicateMatchExact, 1
+		exactPairs[[2]int{e.a, e.b}] = true
+		edges = append(edges, e)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return nil, err
+	}
+	rows.Close()
+
+	ix, err := a.loadFingerprintIndex()
+	if err != nil {
+		return nil, err
+	}
+	for id, fp := range ix.fps {
+		for _, match := range ix.query(fp) {
+			if match.ID <= id || exactPairs[[2]int{id, match.ID}] {
+				continue
+			}
+			edges = append(edges, edge{a: id, b: match.ID, matchType: duplicateMatchAcoustic, similarity: match.Similarity})
+		}
+	}
+
+	// union-find over matched pairs
+	parent := make(map[int]int)
+	var find func(int) int
+	find = func(x int) int {
+		if p, ok := parent[x]; ok && p != x {
+			parent[x] = find(p)
+			return parent[x]
+		}
+		parent[x] = x
+		return x
+	}
+	for _, e := range edges {
+		ra, rb := find(e.a), find(e.b)
+		if ra != rb {
+			parent[max(ra, rb)] = min(ra, rb)
+		}
+	}
+
+	groups := make(map[int]*DuplicateGroup)
+	members := make(map[int][]int)
+	for _, e := range edges {
+		root := find(e.a)
+		g, ok := groups[root]
+		if !ok {
+			g = &DuplicateGroup{MatchType: duplicateMatchExact, Similarity: 1}
+			groups[root] = g
+		}
+		if e.matchType == duplicateMatchAcoustic {
+			g.MatchType = duplicateMatchAcoustic
+		}
+		g.Similarity = math.Min(g.Similarity, e.similarity)
+	}
+	for id := range parent {
+		if _, ok := groups[find(id)]; ok {
+			members[find(id)] = append(members[find(id)], id)
+		}
+	}
+
+	roots := make([]int, 0, len(groups))
+	for root := range groups {
+		roots = append(roots, root)
+	}
+	sort.Ints(roots)
+
+	result := make([]DuplicateGroup, 0, len(roots))
+	for _, root := range roots {
+		ids := members[root]
+		sort.Ints(ids)
+		g := groups[root]
+		g.Songs = make([]SongReadable, 0, len(ids))
+		for _, id := range ids {
+			song, err := a.GetSongReadable(id)
+			if err != nil {
+				return nil, err
+			}
+			if song != nil {
+				g.Songs = append(g.Songs, *song)
+			}
+		}
+		result = append(result, *g)
+	}
+	scan.Groups = result
+	return scan, nil
+}
+
+// countUnfingerprintedSongs counts the songs without a usable fingerprint
+// and reports whether any of them went undecoded for lack of ffmpeg.
+func (a *App) countUnfingerprintedSongs() (int, bool, error) {
+	rows, err := a.db.Query("SELECT filepath, fingerprint IS NULL FROM songs WHERE fingerprint IS NULL OR length(fingerprint) = 0")
+	if err != nil {
+		return 0, false, err
+	}
+	defer rows.Close()
+
+	count, noDecoder := 0, false
+	for rows.Next() {
+		var path string
+		var undecoded bool
+		if err := rows.Scan(&path, &undecoded); err != nil {
+			return 0, false, err
+		}
+		count++
+		if undecoded && !canDecode(path) {
+			noDecoder = true
+		}
+	}
+	return count, noDecoder, rows.Err()
+}
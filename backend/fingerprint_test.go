@@ -0,0 +1,357 @@
+package backend
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// syntheticMusic renders a new broadband "chord" of random partials every
+// quarter second at rate, so every band carries signal.
+func syntheticMusic(seed int64, seconds, rate int) []float64 {
+	rng := rand.New(rand.NewSource(seed))
+	out := make([]float64, seconds*rate)
+	noteLen := rate / 4
+	for start := 0; start < len(out); start += noteLen {
+		freqs := make([]float64, 40)
+		amps := make([]float64, len(freqs))
+		for k := range freqs {
+			freqs[k] = 250 + rng.Float64()*1900
+			amps[k] = 0.02 * rng.Float64()
+		}
+		for i := start; i < min(start+noteLen, len(out)); i++ {
+			t := float64(i) / float64(rate)
+			for k, f := range freqs {
+				out[i] += amps[k] * math.Sin(2*math.Pi*f*t)
+			}
+		}
+	}
+	return out
+}
+
+func TestFingerprintMatchesTrimmedNoisyCopy(t *testing.T) {
+	original := syntheticMusic(1, 20, fingerprintSampleRate)
+
+	// a copy that starts 1.3s in, is quieter, and carries some noise
+	rng := rand.New(rand.NewSource(99))
+	skip := 13 * fingerprintSampleRate / 10
+	copied := make([]float64, len(original)-skip)
+	for i := range copied {
+		copied[i] = 0.7*original[i+skip] + 0.005*rng.NormFloat64()
+	}
+
+	ix := newFingerprintIndex()
+	ix.add(1, fingerprintPCM(original))
+	ix.add(2, fingerprintPCM(syntheticMusic(2, 20, fingerprintSampleRate)))
+
+	matches := ix.query(fingerprintPCM(copied))
+	if len(matches) != 1 || matches[0].ID != 1 {
+		t.Fatalf("expected only song 1 to match, got %+v", matches)
+	}
+	if matches[0].Similarity < 0.9 {
+		t.Fatalf("expected a close match, got similarity %f", matches[0].Similarity)
+	}
+}
+
+func TestFingerprintEncodingRoundTrip(t *testing.T) {
+	fp := []uint32{0, 1, 0xDEADBEEF, math.MaxUint32}
+	got := decodeFingerprint(encodeFingerprint(fp))
+	if len(got) != len(fp) {
+		t.Fatalf("got %v want %v", got, fp)
+	}
+	for i := range fp {
+		if got[i] != fp[i] {
+			t.Fatalf("got %v want %v", got, fp)
+		}
+	}
+}
+
+func TestUploadFlagsExactDuplicates(t *testing.T) {
+	app := newTestApp(t)
+
+	var buf bytes.Buffer
+	for i := 0; i < 20; i++ {
+		buf.Write(mp3Frame(nil))
+	}
+	upload := FileUpload{Filename: "leak.mp3", Base64Data: base64.StdEncoding.EncodeToString(buf.Bytes())}
+
+	first, err := app.UploadAndExtractMetadata([]FileUpload{upload}, nil)
+	if err != nil {
+		t.Fatalf("UploadAndExtractMetadata: %v", err)
+	}
+	if len(first.PossibleDuplicates) != 0 {
+		t.Fatalf("expected no duplicates in an empty library, got %+v", first.PossibleDuplicates)
+	}
+	songs, err := app.CreateSongsWithMetadata(CreateSongsWithMetadataInput{FilesData: first.FilesData})
+	if err != nil {
+		t.Fatalf("CreateSongsWithMetadata: %v", err)
+	}
+
+	upload.Filename = "leak (1).mp3"
+	second, err := app.UploadAndExtractMetadata([]FileUpload{upload}, nil)
+	if err != nil {
+		t.Fatalf("UploadAndExtractMetadata: %v", err)
+	}
+	if len(second.PossibleDuplicates) != 1 {
+		t.Fatalf("expected one duplicate, got %+v", second.PossibleDuplicates)
+	}
+	dup := second.PossibleDuplicates[0]
+	if dup.SongID != songs[0].ID || dup.MatchType != duplicateMatchExact || dup.Filepath != second.FilesData[0].Filepath {
+		t.Fatalf("unexpected duplicate: %+v", dup)
+	}
+}
+
+func TestFindDuplicateSongsBackfillsHashes(t *testing.T) {
+	app := newTestApp(t)
+
+	writeSong := func(name string, data []byte) int {
+		t.Helper()
+		relPath := "uploads/songs/" + name
+		fullPath, err := app.staticFilePath(relPath)
+		if err != nil {
+			t.Fatalf("staticFilePath: %v", err)
+		}
+		if err := os.WriteFile(fullPath, data, 0644); err != nil {
+			t.Fatalf("write song: %v", err)
+		}
+		song, err := app.CreateSong(CreateSongInput{Name: name, Filepath: relPath})
+		if err != nil {
+			t.Fatalf("CreateSong: %v", err)
+		}
+		return song.ID
+	}
+
+	a := writeSong("a.mp3", []byte("same bytes"))
+	b := writeSong("b.mp3", []byte("same bytes"))
+	writeSong("c.mp3", []byte("different bytes"))
+
+	scan, err := app.FindDuplicateSongs()
+	if err != nil {
+		t.Fatalf("FindDuplicateSongs: %v", err)
+	}
+	if scan.UnfingerprintedSongs != 3 || scan.FingerprintingUnavailable != !ffmpegAvailable() {
+		t.Fatalf("expected the three undecodable songs to be counted, got %+v", scan)
+	}
+	groups := scan.Groups
+	if len(groups) != 1 || len(groups[0].Songs) != 2 {
+		t.Fatalf("expected one group of two songs, got %+v", groups)
+	}
+	if groups[0].Songs[0].ID != a || groups[0].Songs[1].ID != b || groups[0].MatchType != duplicateMatchExact {
+		t.Fatalf("unexpected group: %+v", groups[0])
+	}
+}
+
+func TestUploadCheckSkipsSongsWithoutIdentities(t *testing.T) {
+	app := newTestApp(t)
+
+	var buf bytes.Buffer
+	for i := 0; i < 20; i++ {
+		buf.Write(mp3Frame(nil))
+	}
+	relPath := "uploads/songs/old.mp3"
+	fullPath, err := app.staticFilePath(relPath)
+	if err != nil {
+		t.Fatalf("staticFilePath: %v", err)
+	}
+	if err := os.WriteFile(fullPath, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("write song: %v", err)
+	}
+	song, err := app.CreateSong(CreateSongInput{Name: "old", Filepath: relPath})
+	if err != nil {
+		t.Fatalf("CreateSong: %v", err)
+	}
+
+	// the startup backfill hasn't reached the song yet
+	upload := FileUpload{Filename: "leak.mp3", Base64Data: base64.StdEncoding.EncodeToString(buf.Bytes())}
+	first, err := app.UploadAndExtractMetadata([]FileUpload{upload}, nil)
+	if err != nil {
+		t.Fatalf("UploadAndExtractMetadata: %v", err)
+	}
+	if len(first.PossibleDuplicates) != 0 {
+		t.Fatalf("expected the unhashed song to be skipped, got %+v", first.PossibleDuplicates)
+	}
+	if first.FingerprintingUnavailable != !ffmpegAvailable() {
+		t.Fatalf("expected FingerprintingUnavailable to follow ffmpeg, got %v", first.FingerprintingUnavailable)
+	}
+	var hashed bool
+	if err := app.db.QueryRow("SELECT content_hash IS NOT NULL FROM songs WHERE id = ?", song.ID).Scan(&hashed); err != nil {
+		t.Fatalf("query song: %v", err)
+	}
+	if hashed {
+		t.Fatal("expected the upload check not to hash library songs")
+	}
+
+	if err := app.backfillSongIdentities(); err != nil {
+		t.Fatalf("backfillSongIdentities: %v", err)
+	}
+	resumed, err := app.ResumeImportSession(first.SessionID)
+	if err != nil {
+		t.Fatalf("ResumeImportSession: %v", err)
+	}
+	if len(resumed.PossibleDuplicates) != 1 || resumed.PossibleDuplicates[0].SongID != song.ID {
+		t.Fatalf("expected the backfilled song to be flagged, got %+v", resumed.PossibleDuplicates)
+	}
+}
+
+// pcmFile renders mono samples as 16-bit stereo WAV or 24-bit mono AIFF.
+func pcmFile(ext string, rate int, samples []float64) []byte {
+	var chunks bytes.Buffer
+	var magic, form string
+	var order binary.ByteOrder
+	if ext == ".wav" {
+		magic, form, order = "RIFF", "WAVE", binary.LittleEndian
+		fmtChunk := make([]byte, 16)
+		binary.LittleEndian.PutUint16(fmtChunk[0:], 1)
+		binary.LittleEndian.PutUint16(fmtChunk[2:], 2)
+		binary.LittleEndian.PutUint32(fmtChunk[4:], uint32(rate))
+		binary.LittleEndian.PutUint32(fmtChunk[8:], uint32(rate*4))
+		binary.LittleEndian.PutUint16(fmtChunk[12:], 4)
+		binary.LittleEndian.PutUint16(fmtChunk[14:], 16)
+		data := make([]byte, len(samples)*4)
+		for i, v := range samples {
+			binary.LittleEndian.PutUint16(data[i*4:], uint16(int16(v*32767)))
+			binary.LittleEndian.PutUint16(data[i*4+2:], uint16(int16(v*0.8*32767)))
+		}
+		writeIFFChunk(&chunks, order, "fmt ", fmtChunk)
+		writeIFFChunk(&chunks, order, "data", data)
+	} else {
+		magic, form, order = "FORM", "AIFF", binary.BigEndian
+		frac, exp := math.Frexp(float64(rate))
+		comm := make([]byte, 18)
+		binary.BigEndian.PutUint16(comm[0:], 1)
+		binary.BigEndian.PutUint32(comm[2:], uint32(len(samples)))
+		binary.BigEndian.PutUint16(comm[6:], 24)
+		binary.BigEndian.PutUint16(comm[8:], uint16(exp-1+16383))
+		binary.BigEndian.PutUint64(comm[10:], uint64(frac*(1<<64)))
+		data := make([]byte, 8+len(samples)*3)
+		for i, v := range samples {
+			s := int32(v * 8388607)
+			copy(data[8+i*3:], []byte{byte(s >> 16), byte(s >> 8), byte(s)})
+		}
+		writeIFFChunk(&chunks, order, "COMM", comm)
+		writeIFFChunk(&chunks, order, "SSND", data)
+	}
+	hdr := make([]byte, 12)
+	copy(hdr, magic)
+	order.PutUint32(hdr[4:], uint32(4+chunks.Len()))
+	copy(hdr[8:], form)
+	return append(hdr, chunks.Bytes()...)
+}
+
+func TestUploadFlagsAcousticDuplicatesWithoutFFmpeg(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	app := newTestApp(t)
+
+	// the same recording as a 44.1kHz WAV and a trimmed 22.05kHz AIFF
+	original := syntheticMusic(1, 20, 44100)
+	trimmed := syntheticMusic(1, 20, 22050)[22050*13/10:]
+	upload := func(name string, data []byte) *UploadAndExtractResult {
+		t.Helper()
+		result, err := app.UploadAndExtractMetadata([]FileUpload{{Filename: name, Base64Data: base64.StdEncoding.EncodeToString(data)}}, nil)
+		if err != nil {
+			t.Fatalf("UploadAndExtractMetadata: %v", err)
+		}
+		return result
+	}
+
+	first := upload("leak.wav", pcmFile(".wav", 44100, original))
+	songs, err := app.CreateSongsWithMetadata(CreateSongsWithMetadataInput{FilesData: first.FilesData})
+	if err != nil {
+		t.Fatalf("CreateSongsWithMetadata: %v", err)
+	}
+	if len(app.identities) != 0 {
+		t.Fatalf("expected the checked identity to be used up, got %v", app.identities)
+	}
+	var stored int
+	if err := app.db.QueryRow(`SELECT length(fingerprint) FROM songs WHERE id = ?`, songs[0].ID).Scan(&stored); err != nil || stored == 0 {
+		t.Fatalf("expected a stored fingerprint, got %d bytes (err %v)", stored, err)
+	}
+
+	second := upload("leak (cdq).aiff", pcmFile(".aiff", 22050, trimmed))
+	if len(second.PossibleDuplicates) != 1 || len(second.UnfingerprintedFiles) != 0 {
+		t.Fatalf("expected one acoustic duplicate, got %+v", second)
+	}
+	if dup := second.PossibleDuplicates[0]; dup.SongID != songs[0].ID || dup.MatchType != duplicateMatchAcoustic || dup.Similarity < 0.9 {
+		t.Fatalf("unexpected duplicate: %+v", dup)
+	}
+
+	// compressed audio needs ffmpeg, and says so
+	var frames bytes.Buffer
+	for i := 0; i < 20; i++ {
+		frames.Write(mp3Frame(nil))
+	}
+	if third := upload("leak.mp3", frames.Bytes()); len(third.UnfingerprintedFiles) != 1 || third.UnfingerprintedFiles[0] != third.FilesData[0].Filepath {
+		t.Fatalf("expected the mp3 to be reported as unfingerprinted, got %+v", third.UnfingerprintedFiles)
+	}
+}
+
+func TestContentHashIgnoresRetagging(t *testing.T) {
+	app := newTestApp(t)
+
+	var buf bytes.Buffer
+	for i := 0; i < 20; i++ {
+		buf.Write(mp3Frame(nil))
+	}
+	original := buf.Bytes()
+
+	// a song from before hashing, whose file has been retagged since
+	relPath := "uploads/songs/old.mp3"
+	if err := os.WriteFile(filepath.Join(app.staticPath, relPath), original, 0644); err != nil {
+		t.Fatalf("write song: %v", err)
+	}
+	song, err := app.CreateSong(CreateSongInput{Name: "Old Leak", Filepath: relPath})
+	if err != nil {
+		t.Fatalf("CreateSong: %v", err)
+	}
+	if res, _ := app.WriteSongMetadata(song.ID); !res.Success {
+		t.Fatalf("WriteSongMetadata: %s", res.Error)
+	}
+
+	// the startup backfill hashes it as it is now
+	if err := app.backfillSongIdentities(); err != nil {
+		t.Fatalf("backfillSongIdentities: %v", err)
+	}
+	result, err := app.UploadAndExtractMetadata([]FileUpload{{Filename: "old.mp3", Base64Data: base64.StdEncoding.EncodeToString(original)}}, nil)
+	if err != nil {
+		t.Fatalf("UploadAndExtractMetadata: %v", err)
+	}
+	if len(result.PossibleDuplicates) != 1 || result.PossibleDuplicates[0].SongID != song.ID || result.PossibleDuplicates[0].MatchType != duplicateMatchExact {
+		t.Fatalf("expected the re-upload to match the retagged song exactly, got %+v", result.PossibleDuplicates)
+	}
+}
+
+func TestFailedFingerprintsAreNotRetried(t *testing.T) {
+	app := newTestApp(t)
+
+	relPath := "uploads/songs/broken.wav"
+	if err := os.WriteFile(filepath.Join(app.staticPath, relPath), []byte("RIFF\x04\x00\x00\x00WAVE"), 0644); err != nil {
+		t.Fatalf("write song: %v", err)
+	}
+	song, err := app.CreateSong(CreateSongInput{Name: "Broken", Filepath: relPath})
+	if err != nil {
+		t.Fatalf("CreateSong: %v", err)
+	}
+	if _, err := app.FindDuplicateSongs(); err != nil {
+		t.Fatalf("FindDuplicateSongs: %v", err)
+	}
+	var marked bool
+	if err := app.db.QueryRow(`SELECT fingerprint IS NOT NULL AND length(fingerprint) = 0 FROM songs WHERE id = ?`, song.ID).Scan(&marked); err != nil || !marked {
+		t.Fatalf("expected the failed fingerprint to be marked (err %v)", err)
+	}
+
+	// a later scan doesn't decode it again, even if the file changes
+	if err := os.WriteFile(filepath.Join(app.staticPath, relPath), pcmFile(".wav", 44100, syntheticMusic(1, 5, 44100)), 0644); err != nil {
+		t.Fatalf("write song: %v", err)
+	}
+	if _, err := app.FindDuplicateSongs(); err != nil {
+		t.Fatalf("FindDuplicateSongs: %v", err)
+	}
+	if err := app.db.QueryRow(`SELECT length(fingerprint) = 0 FROM songs WHERE id = ?`, song.ID).Scan(&marked); err != nil || !marked {
+		t.Fatalf("expected the failed fingerprint not to be retried (err %v)", err)
+	}
+}
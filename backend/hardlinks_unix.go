@@ -0,0 +1,20 @@
+//go:build unix
+
+package backend
+
+import (
+	"os"
+	"syscall"
+)
+
+// hasOtherLinks reports whether f's bytes are shared with another path, so
+// writing to it in place would change that file too. When it can't tell, it
+// says yes.
+func hasOtherLinks(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return true
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	return !ok || stat.Nlink > 1
+}
@@ -0,0 +1,19 @@
+//go:build windows
+
+package backend
+
+import (
+	"os"
+	"syscall"
+)
+
+// hasOtherLinks reports whether f's bytes are shared with another path, so
+// writing to it in place would change that file too. When it can't tell, it
+// says yes.
+func hasOtherLinks(f *os.File) bool {
+	var info syscall.ByHandleFileInformation
+	if err := syscall.GetFileInformationByHandle(syscall.Handle(f.Fd()), &info); err != nil {
+		return true
+	}
+	return info.NumberOfLinks > 1
+}
@@ -0,0 +1,247 @@
+package backend
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// --- Import Sessions ---
+
+// importSessionTTL is how long a pending session waits to be resumed before
+// Startup cancels it and deletes its files. Finished sessions are pruned
+// after the same time.
+const importSessionTTL = 7 * 24 * time.Hour
+
+const (
+	importSessionPending   = "pending"
+	importSessionCompleted = "completed"
+	importSessionCancelled = "cancelled"
+)
+
+// openImportSession records the files saved by the first phase of an upload
+// and stamps the session ID on the result.
+func (a *App) openImportSession(albumID *int, result *UploadAndExtractResult) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	now := time.Now().Unix()
+	res, err := a.db.Exec(`
+		INSERT INTO import_sessions (status, album_id, result, created_at, updated_at) VALUES (?, ?, ?, ?, ?)
+	`, importSessionPending, albumID, string(data), now, now)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	result.SessionID = int(id)
+	return nil
+}
+
+func (a *App) getImportSession(sessionID int) (*ImportSession, error) {
+	var session ImportSession
+	var data string
+	var createdAt, updatedAt sql.NullInt64
+	err := a.db.QueryRow(`
+		SELECT id, status, album_id, result, created_at, updated_at FROM import_sessions WHERE id = ?
+	`, sessionID).Scan(&session.ID, &session.Status, &session.AlbumID, &data, &createdAt, &updatedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("import session %d not found", sessionID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(data), &session.Result); err != nil {
+		return nil, fmt.Errorf("import session %d: %w", sessionID, err)
+	}
+	session.CreatedAt = createdAt.Int64
+	session.UpdatedAt = updatedAt.Int64
+	return &session, nil
+}
+
+// GetImportSessions lists uploads whose songs haven't been created yet,
+// newest first, so the frontend can offer to resume them.
+func (a *App) GetImportSessions() ([]ImportSession, error) {
+	rows, err := a.db.Query(`SELECT id FROM import_sessions WHERE status = ? ORDER BY created_at DESC, id DESC`, importSessionPending)
+	if err != nil {
+		return nil, err
+	}
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sessions := []ImportSession{}
+	for _, id := range ids {
+		session, err := a.getImportSession(id)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, *session)
+	}
+	return sessions, nil
+}
+
+// ResumeImportSession re-runs extraction on a pending session's files, so
+// artists and mappings added since it was opened are picked up, and returns
+// the refreshed result to continue the mapping step with.
+func (a *App) ResumeImportSession(sessionID int) (*UploadAndExtractResult, error) {
+	session, err := a.getImportSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session.Status != importSessionPending {
+		return nil, fmt.Errorf("import session %d is %s", sessionID, session.Status)
+	}
+
+	saved := make([]savedUpload, 0, len(session.Result.FilesData))
+	for _, file := range session.Result.FilesData {
+		saved = append(saved, savedUpload{OriginalFilename: file.OriginalFilename, Filepath: file.Filepath})
+	}
+	result, err := a.extractUploads(saved, session.AlbumID)
+	if err != nil {
+		return nil, err
+	}
+	result.SessionID = sessionID
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := a.db.Exec(`UPDATE import_sessions SET result = ?, updated_at = ? WHERE id = ?`, string(data), time.Now().Unix(), sessionID); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// CancelImportSession abandons a pending upload and deletes its files.
+func (a *App) CancelImportSession(sessionID int) error {
+	session, err := a.getImportSession(sessionID)
+	if err != nil {
+		return err
+	}
+	if session.Status != importSessionPending {
+		return fmt.Errorf("import session %d is %s", sessionID, session.Status)
+	}
+	_, err = a.closeImportSession(session, importSessionCancelled, nil)
+	return err
+}
+
+// closeImportSession marks a session finished and deletes its files except
+// those in keep and those a song already points to, returning how many it
+// deleted.
+func (a *App) closeImportSession(session *ImportSession, status string, keep map[string]bool) (int, error) {
+	if _, err := a.db.Exec(`UPDATE import_sessions SET status = ?, updated_at = ? WHERE id = ?`, status, time.Now().Unix(), session.ID); err != nil {
+		return 0, err
+	}
+	deleted := 0
+	for _, file := range session.Result.FilesData {
+		if keep[file.Filepath] {
+			continue
+		}
+		songID, err := a.songIDByFilepath(file.Filepath)
+		if err != nil {
+			log.Printf("import session %d: failed to check %s: %v", session.ID, file.Filepath, err)
+			continue
+		}
+		if songID != 0 {
+			continue
+		}
+		if err := a.DeleteFile(file.Filepath); err != nil {
+			if !os.IsNotExist(err) {
+				log.Printf("import session %d: failed to delete %s: %v", session.ID, file.Filepath, err)
+			}
+			continue
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// completeImportSessionsFor completes the pending sessions holding any of
+// paths, for callers of CreateSongsWithMetadata that don't pass a session ID.
+// Their other files are deleted like files dropped from the preview.
+func (a *App) completeImportSessionsFor(paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	created := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		created[path] = true
+	}
+
+	sessions, err := a.GetImportSessions()
+	if err != nil {
+		return err
+	}
+	for i := range sessions {
+		owns := false
+		for _, file := range sessions[i].Result.FilesData {
+			if created[file.Filepath] {
+				owns = true
+				break
+			}
+		}
+		if !owns {
+			continue
+		}
+		if _, err := a.closeImportSession(&sessions[i], importSessionCompleted, created); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// gcImportSessions cancels pending sessions older than importSessionTTL,
+// deleting their files, and prunes finished sessions past it.
+func (a *App) gcImportSessions(now time.Time) error {
+	cutoff := now.Add(-importSessionTTL).Unix()
+
+	rows, err := a.db.Query(`SELECT id FROM import_sessions WHERE status = ? AND updated_at < ?`, importSessionPending, cutoff)
+	if err != nil {
+		return err
+	}
+	expired := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		expired = append(expired, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range expired {
+		session, err := a.getImportSession(id)
+		if err != nil {
+			return err
+		}
+		deleted, err := a.closeImportSession(session, importSessionCancelled, nil)
+		if err != nil {
+			return err
+		}
+		log.Printf("import session %d expired; deleted %d files", id, deleted)
+	}
+
+	_, err = a.db.Exec(`DELETE FROM import_sessions WHERE status != ? AND updated_at < ?`, importSessionPending, cutoff)
+	return err
+}
@@ -0,0 +1,224 @@
+package backend
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestImportSessionCompletesAndCleansUp(t *testing.T) {
+	app := newTestApp(t)
+
+	extracted, err := app.UploadAndExtractMetadata([]FileUpload{
+		taggedMP3Upload(t, "keep.mp3", "Keep", "Future"),
+		taggedMP3Upload(t, "drop.mp3", "Drop", "Future"),
+	}, nil)
+	if err != nil {
+		t.Fatalf("UploadAndExtractMetadata: %v", err)
+	}
+	if extracted.SessionID == 0 {
+		t.Fatal("expected a session ID")
+	}
+
+	report, err := app.CheckLibraryIntegrity()
+	if err != nil {
+		t.Fatalf("CheckLibraryIntegrity: %v", err)
+	}
+	if len(report.OrphanedUploads) != 0 {
+		t.Fatalf("files of a pending session are not orphans, got %+v", report.OrphanedUploads)
+	}
+
+	sessions, err := app.GetImportSessions()
+	if err != nil {
+		t.Fatalf("GetImportSessions: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].ID != extracted.SessionID || len(sessions[0].Result.FilesData) != 2 {
+		t.Fatalf("expected the pending session, got %+v", sessions)
+	}
+
+	// the user removed "drop.mp3" from the preview
+	if _, err := app.CreateSongsWithMetadata(CreateSongsWithMetadataInput{
+		FilesData:     extracted.FilesData[:1],
+		ArtistMapping: map[string]any{"Future": "CREATE_NEW"},
+		SessionID:     &extracted.SessionID,
+	}); err != nil {
+		t.Fatalf("CreateSongsWithMetadata: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(app.staticPath, extracted.FilesData[0].Filepath)); err != nil {
+		t.Fatalf("expected the created song's file to stay: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(app.staticPath, extracted.FilesData[1].Filepath)); !os.IsNotExist(err) {
+		t.Fatalf("expected the dropped file to be deleted, got %v", err)
+	}
+	if sessions, _ := app.GetImportSessions(); len(sessions) != 0 {
+		t.Fatalf("expected no pending sessions, got %+v", sessions)
+	}
+	if _, err := app.CreateSongsWithMetadata(CreateSongsWithMetadataInput{
+		FilesData: extracted.FilesData[:1],
+		SessionID: &extracted.SessionID,
+	}); err == nil {
+		t.Fatal("expected a completed session to be rejected")
+	}
+}
+
+func TestImportSessionResumeCancelAndExpiry(t *testing.T) {
+	app := newTestApp(t)
+
+	first, err := app.UploadAndExtractMetadata([]FileUpload{taggedMP3Upload(t, "a.mp3", "A", "Lil Baby")}, nil)
+	if err != nil {
+		t.Fatalf("UploadAndExtractMetadata: %v", err)
+	}
+	if len(first.UnmappedArtists) != 1 {
+		t.Fatalf("expected an unmapped artist, got %v", first.UnmappedArtists)
+	}
+
+	// after a restart the artist was added elsewhere
+	if _, err := app.CreateArtist(CreateArtistInput{Name: "Lil Baby"}); err != nil {
+		t.Fatalf("CreateArtist: %v", err)
+	}
+	resumed, err := app.ResumeImportSession(first.SessionID)
+	if err != nil {
+		t.Fatalf("ResumeImportSession: %v", err)
+	}
+	if resumed.SessionID != first.SessionID || len(resumed.UnmappedArtists) != 0 {
+		t.Fatalf("expected the resumed session to see the new artist, got %+v", resumed)
+	}
+
+	if err := app.CancelImportSession(first.SessionID); err != nil {
+		t.Fatalf("CancelImportSession: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(app.staticPath, first.FilesData[0].Filepath)); !os.IsNotExist(err) {
+		t.Fatalf("expected cancel to delete the file, got %v", err)
+	}
+	if len(app.identities) != 0 {
+		t.Fatalf("expected cancel to forget the file's identity, got %v", app.identities)
+	}
+	if _, err := app.ResumeImportSession(first.SessionID); err == nil {
+		t.Fatal("expected a cancelled session not to resume")
+	}
+
+	stale, err := app.UploadAndExtractMetadata([]FileUpload{taggedMP3Upload(t, "b.mp3", "B", "Lil Baby")}, nil)
+	if err != nil {
+		t.Fatalf("UploadAndExtractMetadata: %v", err)
+	}
+	fresh, err := app.UploadAndExtractMetadata([]FileUpload{taggedMP3Upload(t, "c.mp3", "C", "Lil Baby")}, nil)
+	if err != nil {
+		t.Fatalf("UploadAndExtractMetadata: %v", err)
+	}
+	old := time.Now().Add(-2 * importSessionTTL).Unix()
+	if _, err := app.db.Exec(`UPDATE import_sessions SET updated_at = ? WHERE id = ?`, old, stale.SessionID); err != nil {
+		t.Fatalf("age session: %v", err)
+	}
+
+	if err := app.gcImportSessions(time.Now()); err != nil {
+		t.Fatalf("gcImportSessions: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(app.staticPath, stale.FilesData[0].Filepath)); !os.IsNotExist(err) {
+		t.Fatalf("expected the expired session's file to be deleted, got %v", err)
+	}
+	sessions, err := app.GetImportSessions()
+	if err != nil {
+		t.Fatalf("GetImportSessions: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].ID != fresh.SessionID {
+		t.Fatalf("expected only the fresh session to remain, got %+v", sessions)
+	}
+}
+
+func TestImportSessionExpiryKeepsCreatedSongFiles(t *testing.T) {
+	app := newTestApp(t)
+
+	extracted, err := app.UploadAndExtractMetadata([]FileUpload{
+		taggedMP3Upload(t, "keep.mp3", "Keep", "Future"),
+		taggedMP3Upload(t, "drop.mp3", "Drop", "Future"),
+	}, nil)
+	if err != nil {
+		t.Fatalf("UploadAndExtractMetadata: %v", err)
+	}
+
+	// the frontend creates songs without passing the session ID
+	if _, err := app.CreateSongsWithMetadata(CreateSongsWithMetadataInput{
+		FilesData:     extracted.FilesData[:1],
+		ArtistMapping: map[string]any{"Future": "CREATE_NEW"},
+	}); err != nil {
+		t.Fatalf("CreateSongsWithMetadata: %v", err)
+	}
+	if sessions, _ := app.GetImportSessions(); len(sessions) != 0 {
+		t.Fatalf("expected the owning session to be completed, got %+v", sessions)
+	}
+	if _, err := os.Stat(filepath.Join(app.staticPath, extracted.FilesData[1].Filepath)); !os.IsNotExist(err) {
+		t.Fatalf("expected the dropped file to be deleted, got %v", err)
+	}
+
+	// a session left pending by an older build still expires safely
+	if _, err := app.db.Exec(`UPDATE import_sessions SET status = ? WHERE id = ?`, importSessionPending, extracted.SessionID); err != nil {
+		t.Fatalf("reopen session: %v", err)
+	}
+	if err := app.gcImportSessions(time.Now().Add(importSessionTTL + 24*time.Hour)); err != nil {
+		t.Fatalf("gcImportSessions: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(app.staticPath, extracted.FilesData[0].Filepath)); err != nil {
+		t.Fatalf("expected the song's file to survive expiry: %v", err)
+	}
+	if sessions, _ := app.GetImportSessions(); len(sessions) != 0 {
+		t.Fatalf("expected the expired session to be cancelled, got %+v", sessions)
+	}
+}
+
+func TestImportSessionRetryAfterPartialCreate(t *testing.T) {
+	app := newTestApp(t)
+
+	extracted, err := app.UploadAndExtractMetadata([]FileUpload{
+		taggedMP3Upload(t, "first.mp3", "First", "Future"),
+		taggedMP3Upload(t, "second.mp3", "Second", "Future"),
+	}, nil)
+	if err != nil {
+		t.Fatalf("UploadAndExtractMetadata: %v", err)
+	}
+
+	// the second song fails to insert
+	if _, err := app.db.Exec(`
+		CREATE TRIGGER fail_second BEFORE INSERT ON songs WHEN NEW.filepath = '` + extracted.FilesData[1].Filepath + `'
+		BEGIN SELECT RAISE(ABORT, 'disk full'); END
+	`); err != nil {
+		t.Fatalf("create trigger: %v", err)
+	}
+	input := CreateSongsWithMetadataInput{
+		FilesData:     extracted.FilesData,
+		ArtistMapping: map[string]any{"Future": "CREATE_NEW"},
+		SessionID:     &extracted.SessionID,
+	}
+	if _, err := app.CreateSongsWithMetadata(input); err == nil {
+		t.Fatal("expected the second song to fail")
+	}
+	if _, err := app.db.Exec(`DROP TRIGGER fail_second`); err != nil {
+		t.Fatalf("drop trigger: %v", err)
+	}
+
+	resumed, err := app.ResumeImportSession(extracted.SessionID)
+	if err != nil {
+		t.Fatalf("ResumeImportSession: %v", err)
+	}
+	input.FilesData = resumed.FilesData
+	input.ArtistMapping = resumed.ArtistMapping
+	songs, err := app.CreateSongsWithMetadata(input)
+	if err != nil {
+		t.Fatalf("CreateSongsWithMetadata: %v", err)
+	}
+	if len(songs) != 1 || songs[0].Filepath != extracted.FilesData[1].Filepath {
+		t.Fatalf("expected only the failed file to be created, got %+v", songs)
+	}
+	for _, file := range extracted.FilesData {
+		var count int
+		if err := app.db.QueryRow(`SELECT COUNT(*) FROM songs WHERE filepath = ?`, file.Filepath).Scan(&count); err != nil {
+			t.Fatalf("count songs: %v", err)
+		}
+		if count != 1 {
+			t.Fatalf("expected one song for %s, got %d", file.Filepath, count)
+		}
+	}
+	if sessions, _ := app.GetImportSessions(); len(sessions) != 0 {
+		t.Fatalf("expected the session to be completed, got %+v", sessions)
+	}
+}
@@ -0,0 +1,398 @@
+package backend
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// --- Watched Inbox ---
+
+// The inbox is polled rather than watched with OS notifications: it works the
+// same on every platform and on network shares, and a poll doubles as the
+// debounce. A file is imported once its size and modification time have held
+// still for inboxSettleTime, so copies and downloads still being written are
+// left alone.
+const (
+	inboxPollInterval = 2 * time.Second
+	inboxSettleTime   = 3 * time.Second
+
+	inboxStatusImported  = "imported"
+	inboxStatusPending   = "pending"
+	inboxStatusFailed    = "failed"
+	inboxStatusDiscarded = "discarded"
+)
+
+// inboxObservation is the last size and modification time seen for a file
+// and when they last changed.
+type inboxObservation struct {
+	size    int64
+	modTime int64
+	since   time.Time
+}
+
+type inboxWatcher struct {
+	observed map[string]inboxObservation
+	lastErr  string
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func newInboxWatcher() *inboxWatcher {
+	return &inboxWatcher{
+		observed: make(map[string]inboxObservation),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// startInboxWatcher polls the inbox folder from settings until
+// stopInboxWatcher. The folder is read from settings on every poll, so
+// changing it needs no restart.
+func (a *App) startInboxWatcher() {
+	w := newInboxWatcher()
+	a.inbox = w
+	go func() {
+		defer close(w.done)
+		ticker := time.NewTicker(inboxPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.stop:
+				return
+			case now := <-ticker.C:
+				// log each distinct failure once rather than every poll
+				if err := a.scanInbox(w, now); err != nil {
+					if msg := err.Error(); msg != w.lastErr {
+						log.Printf("inbox: %v", err)
+						w.lastErr = msg
+					}
+				} else {
+					w.lastErr = ""
+				}
+			}
+		}
+	}()
+}
+
+// stopInboxWatcher stops the poller and waits for an import in progress to
+// finish.
+func (a *App) stopInboxWatcher() {
+	if a.inbox == nil {
+		return
+	}
+	close(a.inbox.stop)
+	<-a.inbox.done
+	a.inbox = nil
+}
+
+// scanInbox runs one poll: files that have settled and weren't processed
+// before (at their current size and modification time) are imported.
+func (a *App) scanInbox(w *inboxWatcher, now time.Time) error {
+	settings, err := a.GetSettings()
+	if err != nil {
+		return err
+	}
+	if settings.InboxPath == nil {
+		w.observed = make(map[string]inboxObservation)
+		return nil
+	}
+	dir := *settings.InboxPath
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		// e.g. an unmounted drive; pick up again when it returns
+		return nil
+	}
+
+	paths, err := collectAudioFiles(dir, true)
+	if err != nil {
+		return err
+	}
+	processed, err := a.processedInboxFiles()
+	if err != nil {
+		return err
+	}
+
+	present := make(map[string]bool)
+	for _, path := range paths {
+		// skip hidden files such as macOS "._" resource forks
+		if strings.HasPrefix(filepath.Base(path), ".") {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			continue // removed mid-scan
+		}
+		size, modTime := info.Size(), info.ModTime().UnixNano()
+		present[path] = true
+
+		if seen, ok := processed[path]; ok && seen.size == size && seen.modTime == modTime {
+			delete(w.observed, path)
+			continue
+		}
+		obs, ok := w.observed[path]
+		if !ok || obs.size != size || obs.modTime != modTime {
+			w.observed[path] = inboxObservation{size: size, modTime: modTime, since: now}
+			continue
+		}
+		if size == 0 || now.Sub(obs.since) < inboxSettleTime {
+			continue
+		}
+
+		delete(w.observed, path)
+		if err := a.processInboxFile(path, size, modTime); err != nil {
+			return err
+		}
+	}
+
+	for path := range w.observed {
+		if !present[path] {
+			delete(w.observed, path)
+		}
+	}
+	return nil
+}
+
+// processedInboxFiles returns the size and modification time each recorded
+// inbox file had when it was processed.
+func (a *App) processedInboxFiles() (map[string]inboxObservation, error) {
+	rows, err := a.db.Query(`SELECT source_path, size, mod_time FROM inbox_files`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	processed := make(map[string]inboxObservation)
+	for rows.Next() {
+		var path string
+		var obs inboxObservation
+		if err := rows.Scan(&path, &obs.size, &obs.modTime); err != nil {
+			return nil, err
+		}
+		processed[path] = obs
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return processed, nil
+}
+
+// inboxRecord is one row of inbox_files.
+type inboxRecord struct {
+	sourcePath string
+	size       int64
+	modTime    int64
+	status     string
+	uploadPath *string
+	fileData   *string
+	duplicates *string
+	songID     *int
+	err        *string
+}
+
+func (a *App) saveInboxRecord(rec inboxRecord) error {
+	now := time.Now().Unix()
+	_, err := a.db.Exec(`
+		INSERT INTO inbox_files (source_path, size, mod_time, status, upload_path, file_data, duplicates, song_id, error, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(source_path) DO UPDATE SET
+			size = excluded.size, mod_time = excluded.mod_time, status = excluded.status,
+			upload_path = excluded.upload_path, file_data = excluded.file_data, duplicates = excluded.duplicates,
+			song_id = excluded.song_id, error = excluded.error, updated_at = excluded.updated_at
+	`, rec.sourcePath, rec.size, rec.modTime, rec.status, rec.uploadPath, rec.fileData, rec.duplicates, rec.songID, rec.err, now, now)
+	return err
+}
+
+// processInboxFile imports one settled inbox file the way the upload preview
+// would, without the preview: if every credit resolves (by name, alias, or
+// remembered mapping) and nothing in the library looks like the same file,
+// the song is created with producers matched from the filename. Otherwise
+// the extracted upload waits in the pending queue. The original stays in the
+// inbox; the record keeps it from being imported twice.
+func (a *App) processInboxFile(path string, size, modTime int64) error {
+	a.inboxMu.Lock()
+	defer a.inboxMu.Unlock()
+
+	// a changed file replaces its earlier pending copy
+	var oldStatus string
+	var oldUpload sql.NullString
+	err := a.db.QueryRow(`SELECT status, upload_path FROM inbox_files WHERE source_path = ?`, path).Scan(&oldStatus, &oldUpload)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if oldStatus == inboxStatusPending && oldUpload.Valid {
+		a.DeleteFile(oldUpload.String)
+	}
+
+	rec := inboxRecord{sourcePath: path, size: size, modTime: modTime}
+	fail := func(err error) error {
+		msg := err.Error()
+		rec.status, rec.err = inboxStatusFailed, &msg
+		log.Printf("inbox: failed to import %s: %v", path, err)
+		return a.saveInboxRecord(rec)
+	}
+
+	relPath, err := a.importFile(path, false)
+	if err != nil {
+		return fail(err)
+	}
+	extracted, err := a.extractUploads([]savedUpload{{OriginalFilename: filepath.Base(path), Filepath: relPath}}, nil)
+	if err != nil {
+		a.DeleteFile(relPath)
+		return fail(err)
+	}
+
+	if !extracted.FilesData[0].HasUnmappedArtists && len(extracted.PossibleDuplicates) == 0 {
+		songs, err := a.CreateSongsWithMetadata(CreateSongsWithMetadataInput{
+			FilesData:          extracted.FilesData,
+			ArtistMapping:      extracted.ArtistMapping,
+			UseEmbeddedArtwork: true,
+		})
+		if err != nil {
+			a.DeleteFile(relPath)
+			return fail(err)
+		}
+		rec.status, rec.songID = inboxStatusImported, &songs[0].ID
+		return a.saveInboxRecord(rec)
+	}
+
+	fileData, err := json.Marshal(extracted.FilesData[0])
+	if err != nil {
+		a.DeleteFile(relPath)
+		return fail(err)
+	}
+	duplicates, err := json.Marshal(extracted.PossibleDuplicates)
+	if err != nil {
+		a.DeleteFile(relPath)
+		return fail(err)
+	}
+	fd, dups := string(fileData), string(duplicates)
+	rec.status, rec.uploadPath, rec.fileData, rec.duplicates = inboxStatusPending, &relPath, &fd, &dups
+	return a.saveInboxRecord(rec)
+}
+
+// GetInboxItems lists inbox files waiting for artist mapping or duplicate
+// review, oldest first.
+func (a *App) GetInboxItems() ([]InboxItem, error) {
+	rows, err := a.db.Query(`
+		SELECT id, source_path, file_data, duplicates, created_at
+		FROM inbox_files
+		WHERE status = ?
+		ORDER BY created_at, id
+	`, inboxStatusPending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []InboxItem{}
+	for rows.Next() {
+		var item InboxItem
+		var fileData, duplicates sql.NullString
+		var createdAt sql.NullInt64
+		if err := rows.Scan(&item.ID, &item.SourcePath, &fileData, &duplicates, &createdAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(fileData.String), &item.FileData); err != nil {
+			return nil, fmt.Errorf("inbox item %d: %w", item.ID, err)
+		}
+		item.PossibleDuplicates = []PossibleDuplicate{}
+		if duplicates.Valid {
+			if err := json.Unmarshal([]byte(duplicates.String), &item.PossibleDuplicates); err != nil {
+				return nil, fmt.Errorf("inbox item %d: %w", item.ID, err)
+			}
+		}
+		item.CreatedAt = createdAt.Int64
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range items {
+		items[i].UnmappedArtists = []string{}
+		for _, name := range items[i].FileData.ParsedArtists {
+			if artist, _ := a.resolveArtistName(name); artist != nil {
+				continue
+			}
+			if _, ok, _ := a.recallArtistMapping(name); ok {
+				continue
+			}
+			items[i].UnmappedArtists = append(items[i].UnmappedArtists, name)
+		}
+		items[i].FileData.HasUnmappedArtists = len(items[i].UnmappedArtists) > 0
+	}
+	return items, nil
+}
+
+// pendingInboxItem loads a pending item's upload.
+func (a *App) pendingInboxItem(id int) (string, FileData, error) {
+	var fileData FileData
+	var uploadPath, data sql.NullString
+	err := a.db.QueryRow(`
+		SELECT upload_path, file_data FROM inbox_files WHERE id = ? AND status = ?
+	`, id, inboxStatusPending).Scan(&uploadPath, &data)
+	if err == sql.ErrNoRows {
+		return "", fileData, fmt.Errorf("inbox item %d is not pending", id)
+	}
+	if err != nil {
+		return "", fileData, err
+	}
+	if err := json.Unmarshal([]byte(data.String), &fileData); err != nil {
+		return "", fileData, fmt.Errorf("inbox item %d: %w", id, err)
+	}
+	return uploadPath.String, fileData, nil
+}
+
+// ResolveInboxItem creates the song for a pending inbox item using the given
+// artist mapping, as CreateSongsWithMetadata does for a previewed upload.
+func (a *App) ResolveInboxItem(input ResolveInboxItemInput) (*Song, error) {
+	a.inboxMu.Lock()
+	defer a.inboxMu.Unlock()
+
+	_, fileData, err := a.pendingInboxItem(input.ID)
+	if err != nil {
+		return nil, err
+	}
+	songs, err := a.CreateSongsWithMetadata(CreateSongsWithMetadataInput{
+		FilesData:          []FileData{fileData},
+		ArtistMapping:      input.ArtistMapping,
+		AlbumID:            input.AlbumID,
+		UseEmbeddedArtwork: input.UseEmbeddedArtwork,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := a.db.Exec(`
+		UPDATE inbox_files SET status = ?, song_id = ?, file_data = NULL, duplicates = NULL, updated_at = ? WHERE id = ?
+	`, inboxStatusImported, songs[0].ID, time.Now().Unix(), input.ID); err != nil {
+		return nil, err
+	}
+	return &songs[0], nil
+}
+
+// DiscardInboxItem drops a pending inbox item and its copy in uploads. The
+// original stays in the inbox and isn't picked up again unless it changes.
+func (a *App) DiscardInboxItem(id int) error {
+	a.inboxMu.Lock()
+	defer a.inboxMu.Unlock()
+
+	uploadPath, _, err := a.pendingInboxItem(id)
+	if err != nil {
+		return err
+	}
+	if uploadPath != "" {
+		if err := a.DeleteFile(uploadPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	_, err = a.db.Exec(`
+		UPDATE inbox_files SET status = ?, upload_path = NULL, file_data = NULL, duplicates = NULL, updated_at = ? WHERE id = ?
+	`, inboxStatusDiscarded, time.Now().Unix(), id)
+	return err
+}
@@ -0,0 +1,150 @@
+package backend
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// dropInInbox writes a tagged MP3 into the inbox folder.
+func dropInInbox(t *testing.T, dir, filename, title, artist string) string {
+	t.Helper()
+	upload := taggedMP3Upload(t, filename, title, artist)
+	data, _ := base64.StdEncoding.DecodeString(upload.Base64Data)
+	path := filepath.Join(dir, filename)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("write inbox file: %v", err)
+	}
+	return path
+}
+
+func TestInboxImportsSettledFilesAndQueuesUnmapped(t *testing.T) {
+	app := newTestApp(t)
+
+	inbox := t.TempDir()
+	if _, err := app.UpdateSettings(UpdateSettingsInput{InboxPath: &inbox}); err != nil {
+		t.Fatalf("UpdateSettings: %v", err)
+	}
+	if _, err := app.CreateArtist(CreateArtistInput{Name: "Future"}); err != nil {
+		t.Fatalf("CreateArtist: %v", err)
+	}
+	dropInInbox(t, inbox, "Mask Off.mp3", "Mask Off", "Future")
+	dropInInbox(t, inbox, "Unknown.mp3", "Unknown", "Newcomer")
+
+	songCount := func() int {
+		t.Helper()
+		songs, err := app.GetSongsReadable(-1, 0)
+		if err != nil {
+			t.Fatalf("GetSongsReadable: %v", err)
+		}
+		return len(songs)
+	}
+
+	w := newInboxWatcher()
+	now := time.Now()
+	if err := app.scanInbox(w, now); err != nil {
+		t.Fatalf("scanInbox: %v", err)
+	}
+	if n := songCount(); n != 0 {
+		t.Fatalf("files must settle before import, got %d songs", n)
+	}
+
+	if err := app.scanInbox(w, now.Add(inboxSettleTime)); err != nil {
+		t.Fatalf("scanInbox: %v", err)
+	}
+	if n := songCount(); n != 1 {
+		t.Fatalf("expected the fully resolved file to be imported, got %d songs", n)
+	}
+	items, err := app.GetInboxItems()
+	if err != nil {
+		t.Fatalf("GetInboxItems: %v", err)
+	}
+	if len(items) != 1 || len(items[0].UnmappedArtists) != 1 || items[0].UnmappedArtists[0] != "Newcomer" {
+		t.Fatalf("expected the unmapped file to be pending, got %+v", items)
+	}
+
+	// processed files aren't picked up again
+	w = newInboxWatcher()
+	app.scanInbox(w, now.Add(2*inboxSettleTime))
+	app.scanInbox(w, now.Add(3*inboxSettleTime))
+	if n := songCount(); n != 1 {
+		t.Fatalf("expected no re-import, got %d songs", n)
+	}
+
+	song, err := app.ResolveInboxItem(ResolveInboxItemInput{ID: items[0].ID, ArtistMapping: map[string]any{"Newcomer": "CREATE_NEW"}})
+	if err != nil {
+		t.Fatalf("ResolveInboxItem: %v", err)
+	}
+	if artists, _ := app.getArtistsForSong(song.ID); len(artists) != 1 || artists[0].Name != "Newcomer" {
+		t.Fatalf("expected the mapped credit, got %+v", artists)
+	}
+	if items, _ := app.GetInboxItems(); len(items) != 0 {
+		t.Fatalf("expected an empty queue after resolving, got %+v", items)
+	}
+	if _, err := app.ResolveInboxItem(ResolveInboxItemInput{ID: items[0].ID}); err == nil {
+		t.Fatal("expected resolving twice to fail")
+	}
+}
+
+func TestInboxWaitsForFilesStillBeingWritten(t *testing.T) {
+	app := newTestApp(t)
+
+	inbox := t.TempDir()
+	if _, err := app.UpdateSettings(UpdateSettingsInput{InboxPath: &inbox}); err != nil {
+		t.Fatalf("UpdateSettings: %v", err)
+	}
+	path := dropInInbox(t, inbox, "Growing.mp3", "Growing", "Someone")
+
+	w := newInboxWatcher()
+	now := time.Now()
+	app.scanInbox(w, now)
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	f.Write(mp3Frame(nil))
+	f.Close()
+
+	app.scanInbox(w, now.Add(inboxSettleTime))
+	if items, _ := app.GetInboxItems(); len(items) != 0 {
+		t.Fatalf("a file that changed since the last poll must not be imported, got %+v", items)
+	}
+	app.scanInbox(w, now.Add(2*inboxSettleTime))
+	items, err := app.GetInboxItems()
+	if err != nil || len(items) != 1 {
+		t.Fatalf("expected the settled file to be queued, got %+v (err %v)", items, err)
+	}
+
+	if app.identities[items[0].FileData.Filepath] == nil {
+		t.Fatal("expected the duplicate check to remember the upload's identity")
+	}
+	if err := app.DiscardInboxItem(items[0].ID); err != nil {
+		t.Fatalf("DiscardInboxItem: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(app.staticPath, items[0].FileData.Filepath)); !os.IsNotExist(err) {
+		t.Fatalf("expected the uploaded copy to be removed, got %v", err)
+	}
+	if len(app.identities) != 0 {
+		t.Fatalf("expected the discarded upload's identity to be forgotten, got %v", app.identities)
+	}
+	if items, _ := app.GetInboxItems(); len(items) != 0 {
+		t.Fatalf("expected an empty queue after discarding, got %+v", items)
+	}
+}
+
+func TestUpdateSettingsRejectsMissingInbox(t *testing.T) {
+	app := newTestApp(t)
+
+	missing := filepath.Join(t.TempDir(), "nope")
+	if _, err := app.UpdateSettings(UpdateSettingsInput{InboxPath: &missing}); err == nil {
+		t.Fatal("expected a missing inbox folder to be rejected")
+	}
+	empty := ""
+	settings, err := app.UpdateSettings(UpdateSettingsInput{InboxPath: &empty})
+	if err != nil || settings.InboxPath != nil {
+		t.Fatalf("expected an empty path to turn the inbox off, got %+v (err %v)", settings, err)
+	}
+}
@@ -0,0 +1,421 @@
+package backend
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// --- Library Integrity ---
+
+// orphanGracePeriod protects files an upload has saved but not yet recorded
+// in an import session or a song, so only orphans that entered uploads
+// longer ago than this are deleted. Files in the preview step are referenced
+// by their pending import session.
+const orphanGracePeriod = time.Hour
+
+// danglingLinkChecks are the rows that point at a deleted record. Foreign
+// keys aren't enforced, so a delete that misses a link leaves one behind.
+// Order matters for repair: aliases go before their restrictions.
+var danglingLinkChecks = []struct {
+	table string
+	where string
+}{
+	{"song_artists", `song_id NOT IN (SELECT id FROM songs) OR artist_id NOT IN (SELECT id FROM artists)`},
+	{"song_producers", `song_id NOT IN (SELECT id FROM songs) OR producer_id NOT IN (SELECT id FROM producers)`},
+	{"song_variants", `song_id NOT IN (SELECT id FROM songs) OR group_id NOT IN (SELECT id FROM song_groups)`},
+	{"playlist_songs", `song_id NOT IN (SELECT id FROM songs) OR playlist_id NOT IN (SELECT id FROM playlists)`},
+	{"album_artists", `album_id NOT IN (SELECT id FROM albums) OR artist_id NOT IN (SELECT id FROM artists)`},
+	{"artist_aliases", `artist_id NOT IN (SELECT id FROM artists)`},
+	{"producer_aliases", `producer_id NOT IN (SELECT id FROM producers)`},
+	{"producer_alias_artists", `alias_id NOT IN (SELECT id FROM producer_aliases) OR artist_id NOT IN (SELECT id FROM artists)`},
+}
+
+// CheckLibraryIntegrity looks for database paths with no file behind them,
+// upload files nothing references, songs without artists, albums without
+// songs, and links to deleted records. It changes nothing; see RepairLibrary.
+func (a *App) CheckLibraryIntegrity() (*IntegrityReport, error) {
+	report := &IntegrityReport{DanglingLinks: make(map[string]int)}
+	var err error
+
+	if report.MissingFiles, err = a.missingFiles(); err != nil {
+		return nil, err
+	}
+	if report.OrphanedUploads, err = a.orphanedUploads(); err != nil {
+		return nil, err
+	}
+	if report.SongsWithoutArtists, err = a.songsWithoutArtists(); err != nil {
+		return nil, err
+	}
+	if report.EmptyAlbums, err = a.emptyAlbums(); err != nil {
+		return nil, err
+	}
+	if report.DanglingAliasRestrictions, err = a.danglingAliasRestrictions(); err != nil {
+		return nil, err
+	}
+
+	for _, check := range danglingLinkChecks {
+		// restrictions are listed individually above
+		if check.table == "producer_alias_artists" {
+			continue
+		}
+		var count int
+		if err := a.db.QueryRow(fmt.Sprintf(`SELECT COUNT(*) FROM %s WHERE %s`, check.table, check.where)).Scan(&count); err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			report.DanglingLinks[check.table] = count
+		}
+	}
+	var missingAlbums int
+	if err := a.db.QueryRow(`SELECT COUNT(*) FROM songs WHERE album_id IS NOT NULL AND album_id NOT IN (SELECT id FROM albums)`).Scan(&missingAlbums); err != nil {
+		return nil, err
+	}
+	if missingAlbums > 0 {
+		report.DanglingLinks["songs.album_id"] = missingAlbums
+	}
+	return report, nil
+}
+
+// missingFiles checks every upload path stored on songs, albums, and
+// artists. Paths outside uploads/ (e.g. remote images) aren't checked.
+func (a *App) missingFiles() ([]MissingFile, error) {
+	rows, err := a.db.Query(`
+		SELECT 'song', id, name, filepath FROM songs
+		UNION ALL SELECT 'song_artwork', id, name, artwork_path FROM songs WHERE artwork_path IS NOT NULL
+		UNION ALL SELECT 'album_artwork', id, name, artwork_path FROM albums WHERE artwork_path IS NOT NULL
+		UNION ALL SELECT 'artist_image', id, name, image FROM artists WHERE image IS NOT NULL
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	missing := []MissingFile{}
+	for rows.Next() {
+		var m MissingFile
+		if err := rows.Scan(&m.Kind, &m.ID, &m.Name, &m.Path); err != nil {
+			return nil, err
+		}
+		fullPath, err := a.uploadsFilePath(m.Path)
+		if err != nil {
+			if m.Kind == "song" {
+				missing = append(missing, m) // a song must live in uploads
+			}
+			continue
+		}
+		if _, err := os.Stat(fullPath); os.IsNotExist(err) {
+			missing = append(missing, m)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return missing, nil
+}
+
+// orphanedUploads lists files under uploads/songs and uploads/artwork that
+// the database doesn't reference.
+func (a *App) orphanedUploads() ([]OrphanedUpload, error) {
+	referenced, err := a.referencedUploads()
+	if err != nil {
+		return nil, err
+	}
+	isReferenced := make(map[string]bool, len(referenced))
+	for _, relPath := range referenced {
+		isReferenced[relPath] = true
+	}
+
+	orphans := []OrphanedUpload{}
+	for _, category := range []string{"songs", "artwork"} {
+		dir := filepath.Join(a.staticPath, uploadsRoot, category)
+		err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			rel, err := filepath.Rel(a.staticPath, path)
+			if err != nil {
+				return err
+			}
+			relPath := filepath.ToSlash(rel)
+			if isReferenced[relPath] {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil // removed mid-walk
+			}
+			orphans = append(orphans, OrphanedUpload{Path: relPath, Size: info.Size(), ModifiedAt: info.ModTime().Unix()})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(orphans, func(i, j int) bool { return orphans[i].Path < orphans[j].Path })
+	return orphans, nil
+}
+
+func (a *App) songsWithoutArtists() ([]IntegritySong, error) {
+	rows, err := a.db.Query(`
+		SELECT s.id, s.name, s.album_id FROM songs s
+		WHERE NOT EXISTS (
+			SELECT 1 FROM song_artists sa JOIN artists ar ON ar.id = sa.artist_id WHERE sa.song_id = s.id
+		)
+		ORDER BY s.id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	songs := []IntegritySong{}
+	for rows.Next() {
+		var s IntegritySong
+		if err := rows.Scan(&s.ID, &s.Name, &s.AlbumID); err != nil {
+			return nil, err
+		}
+		songs = append(songs, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return songs, nil
+}
+
+func (a *App) emptyAlbums() ([]IntegrityAlbum, error) {
+	rows, err := a.db.Query(`
+		SELECT al.id, al.name FROM albums al
+		WHERE NOT EXISTS (SELECT 1 FROM songs WHERE album_id = al.id)
+		ORDER BY al.id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	albums := []IntegrityAlbum{}
+	for rows.Next() {
+		var alb IntegrityAlbum
+		if err := rows.Scan(&alb.ID, &alb.Name); err != nil {
+			return nil, err
+		}
+		albums = append(albums, alb)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return albums, nil
+}
+
+func (a *App) danglingAliasRestrictions() ([]DanglingAliasRestriction, error) {
+	rows, err := a.db.Query(`
+		SELECT paa.alias_id, pa.alias, paa.artist_id
+		FROM producer_alias_artists paa
+		LEFT JOIN producer_aliases pa ON pa.id = paa.alias_id
+		LEFT JOIN producers p ON p.id = pa.producer_id
+		LEFT JOIN artists ar ON ar.id = paa.artist_id
+		WHERE pa.id IS NULL OR p.id IS NULL OR ar.id IS NULL
+		ORDER BY paa.alias_id, paa.artist_id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	restrictions := []DanglingAliasRestriction{}
+	for rows.Next() {
+		var r DanglingAliasRestriction
+		if err := rows.Scan(&r.AliasID, &r.Alias, &r.ArtistID); err != nil {
+			return nil, err
+		}
+		restrictions = append(restrictions, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return restrictions, nil
+}
+
+// RepairLibrary applies the chosen fixes. Each problem is looked up again
+// rather than taken from an earlier report, so fixes that create new
+// problems (deleting songs can empty an album) are handled in one pass.
+func (a *App) RepairLibrary(input RepairLibraryInput) (*RepairResult, error) {
+	result := &RepairResult{}
+	now := time.Now()
+
+	if input.DeleteMissingSongs || input.ClearMissingArtwork {
+		missing, err := a.missingFiles()
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range missing {
+			switch {
+			case m.Kind == "song" && input.DeleteMissingSongs:
+				if err := a.deleteSongRecord(m.ID); err != nil {
+					return nil, err
+				}
+				result.SongsDeleted++
+			case m.Kind != "song" && input.ClearMissingArtwork:
+				query := map[string]string{
+					"song_artwork":  `UPDATE songs SET artwork_path = NULL, updated_at = ? WHERE id = ?`,
+					"album_artwork": `UPDATE albums SET artwork_path = NULL, updated_at = ? WHERE id = ?`,
+					"artist_image":  `UPDATE artists SET image = NULL, updated_at = ? WHERE id = ?`,
+				}[m.Kind]
+				if _, err := a.db.Exec(query, now.Unix(), m.ID); err != nil {
+					return nil, err
+				}
+				result.ArtworkCleared++
+			}
+		}
+	}
+
+	if input.RemoveDanglingLinks {
+		for _, check := range danglingLinkChecks {
+			res, err := a.db.Exec(fmt.Sprintf(`DELETE FROM %s WHERE %s`, check.table, check.where))
+			if err != nil {
+				return nil, err
+			}
+			n, _ := res.RowsAffected()
+			result.DanglingLinksRemoved += int(n)
+		}
+		res, err := a.db.Exec(`UPDATE songs SET album_id = NULL WHERE album_id IS NOT NULL AND album_id NOT IN (SELECT id FROM albums)`)
+		if err != nil {
+			return nil, err
+		}
+		n, _ := res.RowsAffected()
+		result.DanglingLinksRemoved += int(n)
+	}
+
+	if input.InheritAlbumArtists {
+		songs, err := a.songsWithoutArtists()
+		if err != nil {
+			return nil, err
+		}
+		changed := []int{}
+		for _, song := range songs {
+			if song.AlbumID == nil {
+				continue
+			}
+			artists, err := a.getArtistsForAlbum(*song.AlbumID)
+			if err != nil {
+				return nil, err
+			}
+			if len(artists) == 0 {
+				continue
+			}
+			if err := a.InTx(func(tx *sql.Tx) error {
+				// clear links to deleted artists so the order starts at 0
+				if _, err := tx.Exec(`DELETE FROM song_artists WHERE song_id = ?`, song.ID); err != nil {
+					return err
+				}
+				for i, art := range artists {
+					if _, err := tx.Exec(
+						`INSERT INTO song_artists (song_id, artist_id, "order", created_at) VALUES (?, ?, ?, ?)`,
+						song.ID, art.ID, i, now.Unix(),
+					); err != nil {
+						return err
+					}
+				}
+				return nil
+			}); err != nil {
+				return nil, err
+			}
+			changed = append(changed, song.ID)
+		}
+		result.SongsGivenArtists = len(changed)
+		if len(changed) > 0 {
+			result.Metadata = a.writeMetadataBatch(changed, fmt.Sprintf("Credited %d songs to their album artists", len(changed)))
+		}
+	}
+
+	if input.DeleteEmptyAlbums {
+		albums, err := a.emptyAlbums()
+		if err != nil {
+			return nil, err
+		}
+		for _, alb := range albums {
+			if err := a.DeleteAlbum(alb.ID); err != nil {
+				return nil, err
+			}
+			result.AlbumsDeleted++
+		}
+	}
+
+	// last, so songs deleted above leave their artwork to be collected
+	if input.DeleteOrphanedUploads {
+		orphans, err := a.orphanedUploads()
+		if err != nil {
+			return nil, err
+		}
+		for _, orphan := range orphans {
+			if now.Sub(a.uploadedAt(orphan)) < orphanGracePeriod {
+				result.OrphansSkipped++
+				continue
+			}
+			if err := a.DeleteFile(orphan.Path); err != nil && !os.IsNotExist(err) {
+				return nil, err
+			}
+			result.OrphansDeleted++
+		}
+	}
+	return result, nil
+}
+
+// recordLink notes when a hard-linked import entered uploads. Links past the
+// grace period no longer matter to orphan cleanup and are dropped.
+func (a *App) recordLink(relPath string, now time.Time) {
+	a.linkedAtMu.Lock()
+	defer a.linkedAtMu.Unlock()
+	if a.linkedAt == nil {
+		a.linkedAt = make(map[string]time.Time)
+	}
+	for path, linked := range a.linkedAt {
+		if now.Sub(linked) >= orphanGracePeriod {
+			delete(a.linkedAt, path)
+		}
+	}
+	a.linkedAt[relPath] = now
+}
+
+// uploadedAt is when a file entered uploads: its mtime, or for a hard link
+// made by this process, when it was linked.
+func (a *App) uploadedAt(upload OrphanedUpload) time.Time {
+	modified := time.Unix(upload.ModifiedAt, 0)
+	a.linkedAtMu.Lock()
+	defer a.linkedAtMu.Unlock()
+	if linked, ok := a.linkedAt[upload.Path]; ok && linked.After(modified) {
+		return linked
+	}
+	return modified
+}
+
+// deleteSongRecord removes a song whose file is gone, with its artist and
+// producer links, its place in a song group, and its playlist entries.
+func (a *App) deleteSongRecord(songID int) error {
+	return a.InTx(func(tx *sql.Tx) error {
+		if err := removeSongsFromGroupsTx(tx, []int{songID}); err != nil {
+			return err
+		}
+		for _, query := range []string{
+			`DELETE FROM song_artists WHERE song_id = ?`,
+			`DELETE FROM song_producers WHERE song_id = ?`,
+			`DELETE FROM playlist_songs WHERE song_id = ?`,
+			`DELETE FROM songs WHERE id = ?`,
+		} {
+			if _, err := tx.Exec(query, songID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,179 @@
+package backend
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCheckAndRepairLibrary(t *testing.T) {
+	app := newTestApp(t)
+
+	writeUpload := func(relPath string, age time.Duration) {
+		t.Helper()
+		fullPath := filepath.Join(app.staticPath, filepath.FromSlash(relPath))
+		if err := os.WriteFile(fullPath, []byte("data"), 0644); err != nil {
+			t.Fatalf("write %s: %v", relPath, err)
+		}
+		stamp := time.Now().Add(-age)
+		if err := os.Chtimes(fullPath, stamp, stamp); err != nil {
+			t.Fatalf("chtimes: %v", err)
+		}
+	}
+
+	artist, err := app.CreateArtist(CreateArtistInput{Name: "Playboi Carti"})
+	if err != nil {
+		t.Fatalf("CreateArtist: %v", err)
+	}
+	gone, err := app.CreateArtist(CreateArtistInput{Name: "Deleted"})
+	if err != nil {
+		t.Fatalf("CreateArtist: %v", err)
+	}
+
+	writeUpload("uploads/songs/ok.mp3", 0)
+	if _, err := app.CreateSong(CreateSongInput{Name: "OK", Filepath: "uploads/songs/ok.mp3", ArtistIDs: []int{artist.ID}}); err != nil {
+		t.Fatalf("CreateSong: %v", err)
+	}
+
+	// missing file, on an album that only it is on
+	lonely, err := app.CreateAlbum(CreateAlbumInput{Name: "Lonely", ArtistIDs: []int{artist.ID}})
+	if err != nil {
+		t.Fatalf("CreateAlbum: %v", err)
+	}
+	missingArt := "uploads/artwork/missing.jpg"
+	missing, err := app.CreateSong(CreateSongInput{Name: "Missing", Filepath: "uploads/songs/missing.mp3", AlbumID: &lonely.ID, ArtworkPath: &missingArt})
+	if err != nil {
+		t.Fatalf("CreateSong: %v", err)
+	}
+
+	// no artists, but its album has some
+	album, err := app.CreateAlbum(CreateAlbumInput{Name: "Whole Lotta Red", ArtistIDs: []int{artist.ID}})
+	if err != nil {
+		t.Fatalf("CreateAlbum: %v", err)
+	}
+	writeUpload("uploads/songs/uncredited.mp3", 0)
+	uncredited, err := app.CreateSong(CreateSongInput{Name: "Uncredited", Filepath: "uploads/songs/uncredited.mp3", AlbumID: &album.ID, ArtistIDs: []int{gone.ID}})
+	if err != nil {
+		t.Fatalf("CreateSong: %v", err)
+	}
+
+	if _, err := app.CreateAlbum(CreateAlbumInput{Name: "Empty", ArtistIDs: []int{artist.ID}}); err != nil {
+		t.Fatalf("CreateAlbum: %v", err)
+	}
+	if _, err := app.CreateProducerWithAliases(CreateProducerInput{
+		Name:    "F1lthy",
+		Aliases: []AliasInput{{Name: "Filthy", ArtistIDs: []int{gone.ID}}},
+	}); err != nil {
+		t.Fatalf("CreateProducerWithAliases: %v", err)
+	}
+	// a bare delete, the way links used to be left behind
+	if _, err := app.db.Exec(`DELETE FROM artists WHERE id = ?`, gone.ID); err != nil {
+		t.Fatalf("delete artist: %v", err)
+	}
+
+	writeUpload("uploads/songs/abandoned.mp3", 2*orphanGracePeriod)
+	writeUpload("uploads/songs/in-preview.mp3", 0)
+
+	report, err := app.CheckLibraryIntegrity()
+	if err != nil {
+		t.Fatalf("CheckLibraryIntegrity: %v", err)
+	}
+	if len(report.MissingFiles) != 2 || report.MissingFiles[0].Kind != "song" || report.MissingFiles[0].ID != missing.ID ||
+		report.MissingFiles[1].Kind != "song_artwork" {
+		t.Errorf("unexpected missing files: %+v", report.MissingFiles)
+	}
+	if len(report.OrphanedUploads) != 2 || report.OrphanedUploads[0].Path != "uploads/songs/abandoned.mp3" {
+		t.Errorf("unexpected orphans: %+v", report.OrphanedUploads)
+	}
+	if len(report.SongsWithoutArtists) != 2 {
+		t.Errorf("expected two songs without artists, got %+v", report.SongsWithoutArtists)
+	}
+	if len(report.EmptyAlbums) != 1 || report.EmptyAlbums[0].Name != "Empty" {
+		t.Errorf("unexpected empty albums: %+v", report.EmptyAlbums)
+	}
+	if len(report.DanglingAliasRestrictions) != 1 || report.DanglingAliasRestrictions[0].ArtistID != gone.ID {
+		t.Errorf("unexpected dangling restrictions: %+v", report.DanglingAliasRestrictions)
+	}
+	if report.DanglingLinks["song_artists"] != 1 {
+		t.Errorf("expected one dangling song artist link, got %v", report.DanglingLinks)
+	}
+
+	result, err := app.RepairLibrary(RepairLibraryInput{
+		DeleteOrphanedUploads: true,
+		DeleteMissingSongs:    true,
+		ClearMissingArtwork:   true,
+		InheritAlbumArtists:   true,
+		DeleteEmptyAlbums:     true,
+		RemoveDanglingLinks:   true,
+	})
+	if err != nil {
+		t.Fatalf("RepairLibrary: %v", err)
+	}
+	if result.SongsDeleted != 1 || result.SongsGivenArtists != 1 || result.AlbumsDeleted != 2 ||
+		result.OrphansDeleted != 1 || result.OrphansSkipped != 1 || result.DanglingLinksRemoved != 2 {
+		t.Fatalf("unexpected repair result: %+v", result)
+	}
+	if artists, _ := app.getArtistsForSong(uncredited.ID); len(artists) != 1 || artists[0].ID != artist.ID {
+		t.Fatalf("expected the song to inherit its album artist, got %+v", artists)
+	}
+
+	after, err := app.CheckLibraryIntegrity()
+	if err != nil {
+		t.Fatalf("CheckLibraryIntegrity: %v", err)
+	}
+	if len(after.MissingFiles) != 0 || len(after.SongsWithoutArtists) != 0 || len(after.EmptyAlbums) != 0 ||
+		len(after.DanglingAliasRestrictions) != 0 || len(after.DanglingLinks) != 0 || len(after.OrphanedUploads) != 1 {
+		t.Fatalf("expected only the recent orphan to remain, got %+v", after)
+	}
+}
+
+func TestRepairLibraryKeepsOldHardLinkedImports(t *testing.T) {
+	app := newTestApp(t)
+
+	// a hard link keeps its source's mtime
+	src := filepath.Join(t.TempDir(), "old.mp3")
+	if err := os.WriteFile(src, []byte("old payload"), 0644); err != nil {
+		t.Fatalf("write source: %v", err)
+	}
+	stamp := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(src, stamp, stamp); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	linked, err := app.ImportFromPaths(ImportFromPathsInput{Paths: []string{src}, HardLink: true})
+	if err != nil {
+		t.Fatalf("ImportFromPaths: %v", err)
+	}
+	fullPath := filepath.Join(app.staticPath, filepath.FromSlash(linked.FilesData[0].Filepath))
+
+	repair := func() *RepairResult {
+		t.Helper()
+		result, err := app.RepairLibrary(RepairLibraryInput{DeleteOrphanedUploads: true})
+		if err != nil {
+			t.Fatalf("RepairLibrary: %v", err)
+		}
+		if _, err := os.Stat(fullPath); err != nil {
+			t.Fatalf("expected the linked upload to be kept: %v", err)
+		}
+		return result
+	}
+
+	// in the preview step the open session references it
+	if result := repair(); result.OrphansDeleted != 0 || result.OrphansSkipped != 0 {
+		t.Fatalf("expected the previewed upload not to be an orphan, got %+v", result)
+	}
+
+	// without a session it is still recent by when it was linked
+	if _, err := app.db.Exec(`DELETE FROM import_sessions`); err != nil {
+		t.Fatalf("delete sessions: %v", err)
+	}
+	if result := repair(); result.OrphansSkipped != 1 {
+		t.Fatalf("expected the fresh link to be skipped, got %+v", result)
+	}
+
+	// link times past the grace period are dropped as new ones come in
+	app.recordLink("uploads/songs/later.mp3", time.Now().Add(orphanGracePeriod))
+	if _, ok := app.linkedAt[linked.FilesData[0].Filepath]; ok || len(app.linkedAt) != 1 {
+		t.Fatalf("expected the old link time to be dropped, got %v", app.linkedAt)
+	}
+}
@@ -0,0 +1,898 @@
+package backend
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	wailsruntime "github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// --- Library Export / Import ---
+
+// libraryExportVersion is bumped whenever LibraryExport changes shape.
+// ImportLibrary reads every version up to this one.
+const libraryExportVersion = 1
+
+const (
+	libraryFormatJSON = "json"
+	libraryFormatCSV  = "csv"
+)
+
+// buildLibraryExport gathers the whole catalogue, in ID order.
+func (a *App) buildLibraryExport() (*LibraryExport, error) {
+	doc := &LibraryExport{
+		Version:    libraryExportVersion,
+		ExportedAt: time.Now().Unix(),
+		Artists:    []ExportedArtist{},
+		Albums:     []ExportedAlbum{},
+		Eras:       []ExportedEra{},
+		Producers:  []ExportedProducer{},
+		Songs:      []ExportedSong{},
+		SongGroups: []ExportedSongGroup{},
+		Playlists:  []ExportedPlaylist{},
+	}
+
+	// artists
+	artistRows, err := a.db.Query(`SELECT id, name, image, career_start_year, career_end_year FROM artists ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	for artistRows.Next() {
+		var art ExportedArtist
+		if err := artistRows.Scan(&art.ID, &art.Name, &art.Image, &art.CareerStartYear, &art.CareerEndYear); err != nil {
+			artistRows.Close()
+			return nil, err
+		}
+		doc.Artists = append(doc.Artists, art)
+	}
+	artistRows.Close()
+	if err := artistRows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range doc.Artists {
+		aliases, err := a.GetArtistAliases(doc.Artists[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		doc.Artists[i].Aliases = []string{}
+		for _, alias := range aliases {
+			doc.Artists[i].Aliases = append(doc.Artists[i].Aliases, alias.Alias)
+		}
+	}
+
+	// eras, in each artist's order
+	eraRows, err := a.db.Query(`SELECT id, artist_id, name, start_date, end_date FROM eras ORDER BY artist_id, "order", id`)
+	if err != nil {
+		return nil, err
+	}
+	for eraRows.Next() {
+		var era ExportedEra
+		if err := eraRows.Scan(&era.ID, &era.ArtistID, &era.Name, &era.StartDate, &era.EndDate); err != nil {
+			eraRows.Close()
+			return nil, err
+		}
+		doc.Eras = append(doc.Eras, era)
+	}
+	eraRows.Close()
+	if err := eraRows.Err(); err != nil {
+		return nil, err
+	}
+
+	// albums
+	albumRows, err := a.db.Query(`SELECT id, name, artwork_path, genre, year, is_single, era_id FROM albums ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	for albumRows.Next() {
+		var alb ExportedAlbum
+		if err := albumRows.Scan(&alb.ID, &alb.Name, &alb.ArtworkPath, &alb.Genre, &alb.Year, &alb.IsSingle, &alb.EraID); err != nil {
+			albumRows.Close()
+			return nil, err
+		}
+		doc.Albums = append(doc.Albums, alb)
+	}
+	albumRows.Close()
+	if err := albumRows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range doc.Albums {
+		artists, err := a.getArtistsForAlbum(doc.Albums[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		doc.Albums[i].ArtistIDs = artistIDsOf(artists)
+	}
+
+	// producers
+	producerRows, err := a.db.Query(`SELECT id, name FROM producers ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	for producerRows.Next() {
+		var prod ExportedProducer
+		if err := producerRows.Scan(&prod.ID, &prod.Name); err != nil {
+			producerRows.Close()
+			return nil, err
+		}
+		doc.Producers = append(doc.Producers, prod)
+	}
+	producerRows.Close()
+	if err := producerRows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range doc.Producers {
+		aliases, err := a.getAliasesForProducer(doc.Producers[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		doc.Producers[i].Aliases = []AliasInput{}
+		for _, alias := range aliases {
+			doc.Producers[i].Aliases = append(doc.Producers[i].Aliases, AliasInput{Name: alias.Alias, ArtistIDs: alias.ArtistIDs})
+		}
+	}
+
+	// songs
+	songRows, err := a.db.Query(`
+		SELECT id, name, album_id, filepath, artwork_path, genre, year, track_number, duration, file_type, bitrate, sample_rate, channels, bit_depth, codec,
+			leak_date, leak_source, recording_date, leak_status, notes, era_id
+		FROM songs ORDER BY id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	for songRows.Next() {
+		var s ExportedSong
+		if err := songRows.Scan(&s.ID, &s.Name, &s.AlbumID, &s.Filepath, &s.ArtworkPath, &s.Genre, &s.Year, &s.TrackNumber, &s.Duration, &s.FileType, &s.Bitrate, &s.SampleRate, &s.Channels, &s.BitDepth, &s.Codec,
+			&s.LeakDate, &s.LeakSource, &s.RecordingDate, &s.LeakStatus, &s.Notes, &s.EraID); err != nil {
+			songRows.Close()
+			return nil, err
+		}
+		doc.Songs = append(doc.Songs, s)
+	}
+	songRows.Close()
+	if err := songRows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range doc.Songs {
+		credits, err := a.getSongArtists(doc.Songs[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		doc.Songs[i].ArtistIDs = []int{}
+		doc.Songs[i].ArtistRoles = []string{}
+		for _, credit := range credits {
+			doc.Songs[i].ArtistIDs = append(doc.Songs[i].ArtistIDs, credit.ID)
+			doc.Songs[i].ArtistRoles = append(doc.Songs[i].ArtistRoles, credit.Role)
+		}
+
+		producers, err := a.getProducersForSong(doc.Songs[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		doc.Songs[i].ProducerIDs = []int{}
+		doc.Songs[i].ProducerRoles = []string{}
+		for _, prod := range producers {
+			doc.Songs[i].ProducerIDs = append(doc.Songs[i].ProducerIDs, prod.ID)
+			doc.Songs[i].ProducerRoles = append(doc.Songs[i].ProducerRoles, prod.Role)
+		}
+	}
+
+	// song groups
+	groups, err := a.GetSongGroups()
+	if err != nil {
+		return nil, err
+	}
+	for i := len(groups) - 1; i >= 0; i-- {
+		group := ExportedSongGroup{Name: groups[i].Name, PrimarySongID: groups[i].PrimarySongID, Variants: []ExportedVariant{}}
+		for _, v := range groups[i].Variants {
+			group.Variants = append(group.Variants, ExportedVariant{SongID: v.SongID, VariantType: v.VariantType, QualityLabel: v.QualityLabel})
+		}
+		doc.SongGroups = append(doc.SongGroups, group)
+	}
+
+	// playlists
+	playlists, err := a.GetPlaylists()
+	if err != nil {
+		return nil, err
+	}
+	for i := range playlists {
+		playlist := ExportedPlaylist{Name: playlists[i].Name, Kind: playlists[i].Kind, Query: playlists[i].Query, SongIDs: []int{}}
+		if playlist.Kind == playlistManual {
+			if playlist.SongIDs, err = a.playlistSongIDs(&playlists[i]); err != nil {
+				return nil, err
+			}
+		}
+		doc.Playlists = append(doc.Playlists, playlist)
+	}
+
+	settings, err := a.GetSettings()
+	if err != nil {
+		return nil, err
+	}
+	doc.Settings = ExportedSettings{
+		ClearTrackNumberOnUpload: settings.ClearTrackNumberOnUpload,
+		ImportToAppleMusic:       settings.ImportToAppleMusic,
+		AutomaticallyMakeSingles: settings.AutomaticallyMakeSingles,
+		CollapseSongVariants:     settings.CollapseSongVariants,
+		WriteProvenanceTags:      settings.WriteProvenanceTags,
+		EraTag:                   settings.EraTag,
+		FeaturedArtistStyle:      settings.FeaturedArtistStyle,
+		TagFieldPolicies:         settings.TagFieldPolicies,
+	}
+	return doc, nil
+}
+
+func artistIDsOf(artists []Artist) []int {
+	ids := make([]int, 0, len(artists))
+	for _, art := range artists {
+		ids = append(ids, art.ID)
+	}
+	return ids
+}
+
+// writeLibraryExport writes the library as a LibraryExport JSON document or,
+// for "csv", as one flat row per song (names instead of IDs; not importable).
+func (a *App) writeLibraryExport(w io.Writer, format string) error {
+	doc, err := a.buildLibraryExport()
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case libraryFormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(doc)
+	case libraryFormatCSV:
+		return writeLibraryCSV(w, doc)
+	}
+	return fmt.Errorf("unknown export format %q (want json or csv)", format)
+}
+
+func writeLibraryCSV(w io.Writer, doc *LibraryExport) error {
+	artistNames := make(map[int]string)
+	for _, art := range doc.Artists {
+		artistNames[art.ID] = art.Name
+	}
+	producerNames := make(map[int]string)
+	for _, prod := range doc.Producers {
+		producerNames[prod.ID] = prod.Name
+	}
+	albums := make(map[int]ExportedAlbum)
+	for _, alb := range doc.Albums {
+		albums[alb.ID] = alb
+	}
+	names := func(ids []int, lookup map[int]string) string {
+		parts := make([]string, 0, len(ids))
+		for _, id := range ids {
+			parts = append(parts, lookup[id])
+		}
+		return strings.Join(parts, "; ")
+	}
+	optInt := func(v *int) string {
+		if v == nil {
+			return ""
+		}
+		return strconv.Itoa(*v)
+	}
+	optString := func(v *string) string {
+		if v == nil {
+			return ""
+		}
+		return *v
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{
+		"id", "name", "artists", "album", "album_artists", "producers", "genre", "year", "track_number",
+		"duration", "filepath", "file_type", "bitrate", "sample_rate", "channels", "bit_depth", "codec",
+		"leak_date", "leak_source", "recording_date", "leak_status", "notes",
+	}); err != nil {
+		return err
+	}
+	for _, s := range doc.Songs {
+		var albumName, albumArtists string
+		if s.AlbumID != nil {
+			if alb, ok := albums[*s.AlbumID]; ok {
+				albumName = alb.Name
+				albumArtists = names(alb.ArtistIDs, artistNames)
+			}
+		}
+		duration := ""
+		if s.Duration != nil {
+			duration = strconv.FormatFloat(*s.Duration, 'f', 3, 64)
+		}
+		if err := cw.Write([]string{
+			strconv.Itoa(s.ID), s.Name, names(s.ArtistIDs, artistNames), albumName, albumArtists,
+			names(s.ProducerIDs, producerNames), optString(s.Genre), optInt(s.Year), optInt(s.TrackNumber),
+			duration, s.Filepath, optString(s.FileType), optInt(s.Bitrate), optInt(s.SampleRate),
+			optInt(s.Channels), optInt(s.BitDepth), optString(s.Codec),
+			optString(s.LeakDate), optString(s.LeakSource), optString(s.RecordingDate), optString(s.LeakStatus), optString(s.Notes),
+		}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ExportLibrary writes the catalogue to path as "json" (a LibraryExport that
+// ImportLibrary reads back) or "csv" (a flat song list for spreadsheets).
+func (a *App) ExportLibrary(format, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := a.writeLibraryExport(f, format); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
+
+// SelectLibraryExportPath opens a native save dialog for ExportLibrary.
+func (a *App) SelectLibraryExportPath(format string) (string, error) {
+	return wailsruntime.SaveFileDialog(a.ctx, wailsruntime.SaveDialogOptions{
+		Title:           "Export Library",
+		DefaultFilename: "leaks-library." + format,
+		Filters:         []wailsruntime.FileFilter{{DisplayName: strings.ToUpper(format) + " Files", Pattern: "*." + format}},
+	})
+}
+
+// SelectLibraryImportFile opens a native file picker for ImportLibrary.
+func (a *App) SelectLibraryImportFile() (string, error) {
+	return wailsruntime.OpenFileDialog(a.ctx, wailsruntime.OpenDialogOptions{
+		Title:   "Import Library",
+		Filters: []wailsruntime.FileFilter{{DisplayName: "JSON Files", Pattern: "*.json"}},
+	})
+}
+
+// ImportLibrary merges a JSON library export into this database. Records
+// are matched rather than duplicated: artists by name or alias, producers by
+// name, albums by name and ordered artist set (ResolveOrCreateAlbum), and
+// songs the same way. Matched records gain missing aliases and producer
+// credits; anything else about them is left as it is. Each record is its
+// own transaction, so re-running an interrupted import picks up where it
+// stopped.
+func (a *App) ImportLibrary(input ImportLibraryInput) (*LibraryImportResult, error) {
+	f, err := os.Open(input.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return a.importLibrary(f, input.ApplySettings)
+}
+
+func (a *App) importLibrary(r io.Reader, applySettings bool) (*LibraryImportResult, error) {
+	var doc LibraryExport
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("not a library export: %w", err)
+	}
+	if doc.Version < 1 || doc.Version > libraryExportVersion {
+		return nil, fmt.Errorf("unsupported library export version %d (this version reads up to %d)", doc.Version, libraryExportVersion)
+	}
+
+	result := &LibraryImportResult{Warnings: []string{}}
+	warn := func(format string, args ...any) {
+		result.Warnings = append(result.Warnings, fmt.Sprintf(format, args...))
+	}
+
+	artistIDs, err := a.importLibraryArtists(doc.Artists, result, warn)
+	if err != nil {
+		return nil, err
+	}
+	producerIDs, err := a.importLibraryProducers(doc.Producers, artistIDs, result, warn)
+	if err != nil {
+		return nil, err
+	}
+	eraIDs, err := a.importLibraryEras(doc.Eras, artistIDs, result, warn)
+	if err != nil {
+		return nil, err
+	}
+
+	// albums
+	albumIDs := make(map[int]int)
+	for _, alb := range doc.Albums {
+		ids := mapLibraryIDs(alb.ArtistIDs, artistIDs)
+		if len(ids) == 0 {
+			warn("album %q skipped: it has no artists", alb.Name)
+			continue
+		}
+		album, created, err := a.ResolveOrCreateAlbum(alb.Name, ids, AlbumResolutionOpts{IsSingle: alb.IsSingle})
+		if err != nil {
+			return nil, fmt.Errorf("album %q: %w", alb.Name, err)
+		}
+		if album == nil {
+			warn("album %d skipped: it has no name", alb.ID)
+			continue
+		}
+		albumIDs[alb.ID] = album.ID
+		if !created {
+			result.AlbumsMatched++
+			continue
+		}
+		if _, err := a.db.Exec(`UPDATE albums SET artwork_path = ?, genre = ?, year = ?, era_id = ? WHERE id = ?`,
+			alb.ArtworkPath, alb.Genre, alb.Year, mapLibraryID(alb.EraID, eraIDs), album.ID); err != nil {
+			return nil, err
+		}
+		result.AlbumsCreated++
+	}
+
+	// songs
+	songIDs := make(map[int]int)
+	for _, s := range doc.Songs {
+		if strings.TrimSpace(s.Name) == "" {
+			warn("song %d skipped: it has no name", s.ID)
+			continue
+		}
+		ids, roles := mapLibraryCredits(s.ArtistIDs, s.ArtistRoles, artistIDs, validArtistRoles, artistRolePrimary)
+		prodIDs, prodRoles := mapLibraryCredits(s.ProducerIDs, s.ProducerRoles, producerIDs, validProducerRoles, producerRoleProducer)
+
+		existingID, err := a.findSongByNameAndArtists(s.Name, ids)
+		if err != nil {
+			return nil, err
+		}
+		if existingID != 0 {
+			if _, err := a.addProducersToSong(existingID, prodIDs, prodRoles); err != nil {
+				return nil, err
+			}
+			songIDs[s.ID] = existingID
+			result.SongsMatched++
+			continue
+		}
+
+		var albumID *int
+		if s.AlbumID != nil {
+			if id, ok := albumIDs[*s.AlbumID]; ok {
+				albumID = &id
+			}
+		}
+		song, err := a.CreateSong(CreateSongInput{
+			Name:          s.Name,
+			Filepath:      s.Filepath,
+			ArtistIDs:     ids,
+			ArtistRoles:   roles,
+			ProducerIDs:   prodIDs,
+			ProducerRoles: prodRoles,
+			AlbumID:       albumID,
+			ArtworkPath:   s.ArtworkPath,
+			Genre:         s.Genre,
+			Year:          s.Year,
+			TrackNumber:   s.TrackNumber,
+			Duration:      s.Duration,
+			FileType:      s.FileType,
+			Bitrate:       s.Bitrate,
+			SampleRate:    s.SampleRate,
+			Channels:      s.Channels,
+			BitDepth:      s.BitDepth,
+			Codec:         s.Codec,
+			Provenance:    s.Provenance,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("song %q: %w", s.Name, err)
+		}
+		songIDs[s.ID] = song.ID
+		if eraID := mapLibraryID(s.EraID, eraIDs); eraID != nil {
+			if err := a.AssignSongsToEra([]int{song.ID}, eraID); err != nil {
+				return nil, err
+			}
+		}
+		result.SongsCreated++
+		if fullPath, err := a.uploadsFilePath(s.Filepath); err != nil {
+			warn("song %q: invalid file path %q", s.Name, s.Filepath)
+		} else if _, err := os.Stat(fullPath); err != nil {
+			warn("song %q: file %s is not in this library's uploads", s.Name, s.Filepath)
+		}
+	}
+
+	if err := a.importLibrarySongGroups(doc.SongGroups, songIDs, result, warn); err != nil {
+		return nil, err
+	}
+	if err := a.importLibraryPlaylists(doc.Playlists, songIDs, artistIDs, producerIDs, result, warn); err != nil {
+		return nil, err
+	}
+
+	if applySettings {
+		// settings left out of a hand-edited export keep their current values
+		var eraTag, featuredArtistStyle *string
+		if doc.Settings.EraTag != "" {
+			eraTag = &doc.Settings.EraTag
+		}
+		if doc.Settings.FeaturedArtistStyle != "" {
+			featuredArtistStyle = &doc.Settings.FeaturedArtistStyle
+		}
+		if _, err := a.UpdateSettings(UpdateSettingsInput{
+			ClearTrackNumberOnUpload: &doc.Settings.ClearTrackNumberOnUpload,
+			ImportToAppleMusic:       &doc.Settings.ImportToAppleMusic,
+			AutomaticallyMakeSingles: &doc.Settings.AutomaticallyMakeSingles,
+			CollapseSongVariants:     &doc.Settings.CollapseSongVariants,
+			WriteProvenanceTags:      &doc.Settings.WriteProvenanceTags,
+			EraTag:                   eraTag,
+			FeaturedArtistStyle:      featuredArtistStyle,
+			TagFieldPolicies:         doc.Settings.TagFieldPolicies,
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// importLibraryEras matches each exported era by artist and name or
+// creates it after the artist's existing eras, and returns the export-ID ->
+// local-ID map. Matched eras keep their dates.
+func (a *App) importLibraryEras(eras []ExportedEra, artistIDs map[int]int, result *LibraryImportResult, warn func(string, ...any)) (map[int]int, error) {
+	ids := make(map[int]int)
+	for _, exported := range eras {
+		artistID, ok := artistIDs[exported.ArtistID]
+		if !ok {
+			warn("era %q skipped: its artist wasn't imported", exported.Name)
+			continue
+		}
+		existing, err := a.GetEras(artistID)
+		if err != nil {
+			return nil, err
+		}
+		matched := false
+		for _, era := range existing {
+			if strings.EqualFold(era.Name, strings.TrimSpace(exported.Name)) {
+				ids[exported.ID] = era.ID
+				matched = true
+				break
+			}
+		}
+		if matched {
+			result.ErasMatched++
+			continue
+		}
+		era, err := a.CreateEra(CreateEraInput{ArtistID: artistID, Name: exported.Name, StartDate: exported.StartDate, EndDate: exported.EndDate})
+		if err != nil {
+			warn("era %q skipped: %v", exported.Name, err)
+			continue
+		}
+		ids[exported.ID] = era.ID
+		result.ErasCreated++
+	}
+	return ids, nil
+}
+
+// importLibrarySongGroups recreates the exported groups from the songs that
+// aren't grouped here already; grouping in this library wins.
+func (a *App) importLibrarySongGroups(groups []ExportedSongGroup, songIDs map[int]int, result *LibraryImportResult, warn func(string, ...any)) error {
+	for _, group := range groups {
+		variants := []ExportedVariant{}
+		members := []int{}
+		for _, v := range group.Variants {
+			id, ok := songIDs[v.SongID]
+			if !ok {
+				continue
+			}
+			info, err := a.getSongVariantInfo(id)
+			if err != nil {
+				return err
+			}
+			if info != nil {
+				continue
+			}
+			variants = append(variants, v)
+			members = append(members, id)
+		}
+		if len(members) < 2 {
+			if len(group.Variants) >= 2 {
+				warn("song group of song %d skipped: fewer than two of its songs are free to group", group.PrimarySongID)
+			}
+			continue
+		}
+
+		input := GroupSongsInput{SongIDs: members, Name: group.Name}
+		if id, ok := songIDs[group.PrimarySongID]; ok {
+			for _, member := range members {
+				if member == id {
+					input.PrimarySongID = &id
+				}
+			}
+		}
+		if _, err := a.GroupSongs(input); err != nil {
+			return err
+		}
+		for i, v := range variants {
+			variantType := v.VariantType
+			if !validVariantTypes[variantType] {
+				variantType = variantOther
+			}
+			label := ""
+			if v.QualityLabel != nil {
+				label = *v.QualityLabel
+			}
+			if _, err := a.UpdateSongVariant(UpdateSongVariantInput{SongID: members[i], VariantType: &variantType, QualityLabel: &label}); err != nil {
+				return err
+			}
+		}
+		result.SongGroups++
+	}
+	return nil
+}
+
+// importLibraryPlaylists creates the exported playlists whose names aren't
+// taken here, keeping the songs and query terms that were imported.
+func (a *App) importLibraryPlaylists(playlists []ExportedPlaylist, songIDs, artistIDs, producerIDs map[int]int, result *LibraryImportResult, warn func(string, ...any)) error {
+	existing, err := a.GetPlaylists()
+	if err != nil {
+		return err
+	}
+	taken := make(map[string]bool)
+	for _, playlist := range existing {
+		taken[strings.ToLower(playlist.Name)] = true
+	}
+
+	for _, exported := range playlists {
+		name := strings.TrimSpace(exported.Name)
+		if taken[strings.ToLower(name)] {
+			warn("playlist %q skipped: a playlist with that name already exists", name)
+			continue
+		}
+		input := CreatePlaylistInput{Name: name}
+		if exported.Kind == playlistSmart && exported.Query != nil {
+			query := *exported.Query
+			query.ArtistIDs = mapLibraryIDs(query.ArtistIDs, artistIDs)
+			query.ProducerIDs = mapLibraryIDs(query.ProducerIDs, producerIDs)
+			// a query left with none of its artists would match everyone's songs
+			if (len(exported.Query.ArtistIDs) > 0 && len(query.ArtistIDs) == 0) ||
+				(len(exported.Query.ProducerIDs) > 0 && len(query.ProducerIDs) == 0) {
+				warn("playlist %q skipped: none of its query's artists or producers were imported", name)
+				continue
+			}
+			if len(query.ArtistIDs) < len(exported.Query.ArtistIDs) || len(query.ProducerIDs) < len(exported.Query.ProducerIDs) {
+				warn("playlist %q: some of its query's artists or producers weren't imported", name)
+			}
+			input.Query = &query
+		} else {
+			input.SongIDs = mapLibraryIDs(exported.SongIDs, songIDs)
+			if len(input.SongIDs) < len(exported.SongIDs) {
+				warn("playlist %q: %d of its songs weren't imported", name, len(exported.SongIDs)-len(input.SongIDs))
+			}
+		}
+		if _, err := a.CreatePlaylist(input); err != nil {
+			warn("playlist %q skipped: %v", name, err)
+			continue
+		}
+		taken[strings.ToLower(name)] = true
+		result.Playlists++
+	}
+	return nil
+}
+
+// importLibraryArtists matches or creates each exported artist and returns
+// the export-ID -> local-ID map.
+func (a *App) importLibraryArtists(artists []ExportedArtist, result *LibraryImportResult, warn func(string, ...any)) (map[int]int, error) {
+	ids := make(map[int]int)
+	for _, art := range artists {
+		if strings.TrimSpace(art.Name) == "" {
+			warn("artist %d skipped: it has no name", art.ID)
+			continue
+		}
+
+		existing, err := a.resolveArtistName(art.Name)
+		if err != nil {
+			return nil, err
+		}
+		var localID int
+		if existing != nil {
+			localID = existing.ID
+			result.ArtistsMatched++
+		} else {
+			created, err := a.CreateArtist(CreateArtistInput{
+				Name:            art.Name,
+				CareerStartYear: art.CareerStartYear,
+				CareerEndYear:   art.CareerEndYear,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("artist %q: %w", art.Name, err)
+			}
+			if art.Image != nil {
+				if _, err := a.db.Exec(`UPDATE artists SET image = ? WHERE id = ?`, art.Image, created.ID); err != nil {
+					return nil, err
+				}
+			}
+			localID = created.ID
+			result.ArtistsCreated++
+		}
+		ids[art.ID] = localID
+
+		for _, alias := range art.Aliases {
+			owner, err := a.resolveArtistName(alias)
+			if err != nil {
+				return nil, err
+			}
+			if owner != nil {
+				if owner.ID != localID {
+					warn("alias %q of artist %q skipped: it already names %q", alias, art.Name, owner.Name)
+				}
+				continue
+			}
+			if _, err := a.CreateArtistAlias(localID, alias); err != nil {
+				warn("alias %q of artist %q skipped: %v", alias, art.Name, err)
+			}
+		}
+	}
+	return ids, nil
+}
+
+// importLibraryProducers matches producers by case-insensitive name or
+// creates them, and adds aliases (with their artist restrictions) that no
+// producer has yet.
+func (a *App) importLibraryProducers(producers []ExportedProducer, artistIDs map[int]int, result *LibraryImportResult, warn func(string, ...any)) (map[int]int, error) {
+	ids := make(map[int]int)
+	for _, prod := range producers {
+		if strings.TrimSpace(prod.Name) == "" {
+			warn("producer %d skipped: it has no name", prod.ID)
+			continue
+		}
+
+		var localID int
+		err := a.db.QueryRow(`SELECT id FROM producers WHERE LOWER(name) = LOWER(?) ORDER BY id LIMIT 1`, prod.Name).Scan(&localID)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+
+		// keep only aliases no producer has yet
+		newAliases := []AliasInput{}
+		for _, alias := range prod.Aliases {
+			var ownerID int
+			err := a.db.QueryRow(`SELECT producer_id FROM producer_aliases WHERE LOWER(alias) = LOWER(?)`, alias.Name).Scan(&ownerID)
+			if err == sql.ErrNoRows {
+				newAliases = append(newAliases, AliasInput{Name: alias.Name, ArtistIDs: mapLibraryIDs(alias.ArtistIDs, artistIDs)})
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			if localID == 0 || ownerID != localID {
+				warn("alias %q of producer %q skipped: another producer has it", alias.Name, prod.Name)
+			}
+		}
+
+		if localID == 0 {
+			created, err := a.CreateProducerWithAliases(CreateProducerInput{Name: prod.Name, Aliases: newAliases})
+			if err != nil {
+				return nil, fmt.Errorf("producer %q: %w", prod.Name, err)
+			}
+			ids[prod.ID] = created.ID
+			result.ProducersCreated++
+			continue
+		}
+
+		ids[prod.ID] = localID
+		result.ProducersMatched++
+		if err := a.addProducerAliases(localID, newAliases); err != nil {
+			return nil, fmt.Errorf("producer %q: %w", prod.Name, err)
+		}
+	}
+	return ids, nil
+}
+
+// addProducerAliases adds aliases, with artist restrictions, to an existing
+// producer.
+func (a *App) addProducerAliases(producerID int, aliases []AliasInput) error {
+	now := time.Now().Unix()
+	return a.InTx(func(tx *sql.Tx) error {
+		for _, alias := range aliases {
+			aliasResult, err := tx.Exec(
+				`INSERT INTO producer_aliases (producer_id, alias, created_at) VALUES (?, ?, ?)`,
+				producerID, alias.Name, now,
+			)
+			if err != nil {
+				return err
+			}
+			aliasID, err := aliasResult.LastInsertId()
+			if err != nil {
+				return err
+			}
+			for _, artistID := range alias.ArtistIDs {
+				if _, err := tx.Exec(
+					`INSERT INTO producer_alias_artists (alias_id, artist_id, created_at) VALUES (?, ?, ?)`,
+					aliasID, artistID, now,
+				); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// mapLibraryIDs translates export IDs to local IDs, dropping any that
+// weren't imported.
+func mapLibraryIDs(ids []int, mapping map[int]int) []int {
+	mapped := []int{}
+	for _, id := range ids {
+		if local, ok := mapping[id]; ok {
+			mapped = append(mapped, local)
+		}
+	}
+	return mapped
+}
+
+// mapLibraryCredits is mapLibraryIDs for a song's artists or producers,
+// keeping each imported credit's role. Missing and unknown roles become
+// defaultRole.
+func mapLibraryCredits(ids []int, roles []string, mapping map[int]int, validRoles map[string]bool, defaultRole string) ([]int, []string) {
+	mappedIDs, mappedRoles := []int{}, []string{}
+	for i, id := range ids {
+		local, ok := mapping[id]
+		if !ok {
+			continue
+		}
+		role := defaultRole
+		if i < len(roles) && validRoles[roles[i]] {
+			role = roles[i]
+		}
+		mappedIDs = append(mappedIDs, local)
+		mappedRoles = append(mappedRoles, role)
+	}
+	return mappedIDs, mappedRoles
+}
+
+// mapLibraryID maps an optional export ID, returning nil when it's unset
+// or wasn't imported.
+func mapLibraryID(id *int, mapping map[int]int) *int {
+	if id == nil {
+		return nil
+	}
+	local, ok := mapping[*id]
+	if !ok {
+		return nil
+	}
+	return &local
+}
+
+// findSongByNameAndArtists returns the ID of a song with this name
+// (case-insensitive) and exactly this ordered artist list, or 0.
+func (a *App) findSongByNameAndArtists(name string, artistIDs []int) (int, error) {
+	rows, err := a.db.Query(`SELECT id FROM songs WHERE LOWER(name) = LOWER(?) ORDER BY id`, strings.TrimSpace(name))
+	if err != nil {
+		return 0, err
+	}
+	candidates := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		candidates = append(candidates, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, id := range candidates {
+		artists, err := a.getArtistsForSong(id)
+		if err != nil {
+			return 0, err
+		}
+		existing := artistIDsOf(artists)
+		if len(existing) != len(artistIDs) {
+			continue
+		}
+		match := true
+		for i := range existing {
+			if existing[i] != artistIDs[i] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return id, nil
+		}
+	}
+	return 0, nil
+}
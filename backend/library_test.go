@@ -0,0 +1,167 @@
+package backend
+
+import (
+	"bytes"
+	"encoding/csv"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLibraryExportImportRoundTrip(t *testing.T) {
+	source := newTestApp(t)
+
+	thug, err := source.CreateArtist(CreateArtistInput{Name: "Young Thug"})
+	if err != nil {
+		t.Fatalf("CreateArtist: %v", err)
+	}
+	gunna, err := source.CreateArtist(CreateArtistInput{Name: "Gunna"})
+	if err != nil {
+		t.Fatalf("CreateArtist: %v", err)
+	}
+	if _, err := source.CreateArtistAlias(thug.ID, "Thugger"); err != nil {
+		t.Fatalf("CreateArtistAlias: %v", err)
+	}
+	wheezy, err := source.CreateProducerWithAliases(CreateProducerInput{
+		Name:    "Wheezy",
+		Aliases: []AliasInput{{Name: "Wheezy Outta Here", ArtistIDs: []int{thug.ID}}},
+	})
+	if err != nil {
+		t.Fatalf("CreateProducerWithAliases: %v", err)
+	}
+	year := 2019
+	album, err := source.CreateAlbum(CreateAlbumInput{Name: "So Much Fun", ArtistIDs: []int{thug.ID}, Year: &year})
+	if err != nil {
+		t.Fatalf("CreateAlbum: %v", err)
+	}
+	if _, err := source.CreateSong(CreateSongInput{
+		Name: "Hot", Filepath: "uploads/songs/hot.mp3", ArtistIDs: []int{gunna.ID, thug.ID}, ArtistRoles: []string{"", artistRoleFeatured},
+		ProducerIDs: []int{wheezy.ID}, ProducerRoles: []string{producerRoleCoProducer}, AlbumID: &album.ID,
+	}); err != nil {
+		t.Fatalf("CreateSong: %v", err)
+	}
+	solo, err := source.CreateSong(CreateSongInput{Name: "Solo", Filepath: "uploads/songs/solo.mp3", ArtistIDs: []int{thug.ID}})
+	if err != nil {
+		t.Fatalf("CreateSong: %v", err)
+	}
+	if _, err := source.CreatePlaylist(CreatePlaylistInput{Name: "Solo Only", SongIDs: []int{solo.ID}}); err != nil {
+		t.Fatalf("CreatePlaylist: %v", err)
+	}
+	if _, err := source.CreatePlaylist(CreatePlaylistInput{Name: "Gunna", Query: &SmartPlaylistQuery{ArtistIDs: []int{gunna.ID}}}); err != nil {
+		t.Fatalf("CreatePlaylist: %v", err)
+	}
+
+	var export bytes.Buffer
+	if err := source.writeLibraryExport(&export, libraryFormatJSON); err != nil {
+		t.Fatalf("writeLibraryExport: %v", err)
+	}
+
+	// the target already knows Young Thug under an alias and has a song
+	// that shouldn't be touched
+	target := newTestApp(t)
+	existing, err := target.CreateArtist(CreateArtistInput{Name: "Jeffery"})
+	if err != nil {
+		t.Fatalf("CreateArtist: %v", err)
+	}
+	if _, err := target.CreateArtistAlias(existing.ID, "Young Thug"); err != nil {
+		t.Fatalf("CreateArtistAlias: %v", err)
+	}
+
+	result, err := target.importLibrary(bytes.NewReader(export.Bytes()), false)
+	if err != nil {
+		t.Fatalf("importLibrary: %v", err)
+	}
+	if result.ArtistsMatched != 1 || result.ArtistsCreated != 1 || result.AlbumsCreated != 1 ||
+		result.ProducersCreated != 1 || result.SongsCreated != 2 || result.Playlists != 2 {
+		t.Fatalf("unexpected first import counts: %+v", result)
+	}
+
+	songID, err := target.findSongByNameAndArtists("hot", nil)
+	if err != nil || songID != 0 {
+		t.Fatalf("expected no match without the artist set, got %d (err %v)", songID, err)
+	}
+	newGunna, _ := target.resolveArtistName("Gunna")
+	songID, err = target.findSongByNameAndArtists("Hot", []int{newGunna.ID, existing.ID})
+	if err != nil || songID == 0 {
+		t.Fatalf("expected Hot to be credited to Gunna and the matched artist in order (err %v)", err)
+	}
+	song, err := target.GetSongReadable(songID)
+	if err != nil {
+		t.Fatalf("GetSongReadable: %v", err)
+	}
+	if song.Album == nil || song.Album.Name != "So Much Fun" || song.Album.Year == nil || *song.Album.Year != 2019 {
+		t.Fatalf("expected the album to come across, got %+v", song.Album)
+	}
+	if song.Artists[0].Role != artistRolePrimary || song.Artists[1].Role != artistRoleFeatured {
+		t.Fatalf("expected the artist roles to come across, got %+v", song.Artists)
+	}
+	if len(song.Producers) != 1 || song.Producers[0].Name != "Wheezy" || song.Producers[0].Role != producerRoleCoProducer {
+		t.Fatalf("expected the producer credit, got %+v", song.Producers)
+	}
+	if thugger, _ := target.resolveArtistName("Thugger"); thugger == nil || thugger.ID != existing.ID {
+		t.Fatalf("expected the alias to land on the matched artist, got %+v", thugger)
+	}
+	producers, err := target.GetProducersWithAliases()
+	if err != nil {
+		t.Fatalf("GetProducersWithAliases: %v", err)
+	}
+	if len(producers) != 1 || len(producers[0].Aliases) != 1 ||
+		!reflect.DeepEqual(producers[0].Aliases[0].ArtistIDs, []int{existing.ID}) {
+		t.Fatalf("expected the alias restriction to map onto the matched artist, got %+v", producers)
+	}
+
+	playlists, err := target.GetPlaylists()
+	if err != nil {
+		t.Fatalf("GetPlaylists: %v", err)
+	}
+	if len(playlists) != 2 || playlists[0].Name != "Gunna" || !reflect.DeepEqual(playlists[0].Query.ArtistIDs, []int{newGunna.ID}) ||
+		playlists[1].SongCount != 1 {
+		t.Fatalf("expected both playlists with mapped songs and artists, got %+v", playlists)
+	}
+
+	// importing again merges everything
+	again, err := target.importLibrary(bytes.NewReader(export.Bytes()), false)
+	if err != nil {
+		t.Fatalf("importLibrary: %v", err)
+	}
+	if again.ArtistsCreated != 0 || again.AlbumsCreated != 0 || again.ProducersCreated != 0 || again.SongsCreated != 0 ||
+		again.SongsMatched != 2 || again.AlbumsMatched != 1 || again.Playlists != 0 {
+		t.Fatalf("expected a second import to match everything, got %+v", again)
+	}
+	if count, _ := target.GetSongsCount(); count != 2 {
+		t.Fatalf("expected no duplicate songs, got %d", count)
+	}
+}
+
+func TestLibraryExportCSV(t *testing.T) {
+	app := newTestApp(t)
+
+	artist, err := app.CreateArtist(CreateArtistInput{Name: "Future"})
+	if err != nil {
+		t.Fatalf("CreateArtist: %v", err)
+	}
+	if _, err := app.CreateSong(CreateSongInput{Name: "Mask Off, Pt. 2", Filepath: "uploads/songs/m.mp3", ArtistIDs: []int{artist.ID}}); err != nil {
+		t.Fatalf("CreateSong: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := app.writeLibraryExport(&out, libraryFormatCSV); err != nil {
+		t.Fatalf("writeLibraryExport: %v", err)
+	}
+	records, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatalf("export is not CSV: %v", err)
+	}
+	if len(records) != 2 || records[0][1] != "name" || records[1][1] != "Mask Off, Pt. 2" || records[1][2] != "Future" {
+		t.Fatalf("unexpected CSV: %v", records)
+	}
+}
+
+func TestImportLibraryRejectsNewerVersion(t *testing.T) {
+	app := newTestApp(t)
+
+	_, err := app.importLibrary(strings.NewReader(`{"version": 99}`), false)
+	if err == nil || !strings.Contains(err.Error(), "version 99") {
+		t.Fatalf("expected a version error, got %v", err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -26,10 +27,23 @@ type SongTags struct {
 	TrackNumberStr string
 	TrackNumber    int32
 	TrackTotal     int32
-	Producers      string
+	// only MP4 writes disc numbers, and only when they're set
+	DiscNumber int32
+	DiscTotal  int32
+	// Producers is the names credited as producer, for the composer-style
+	// field; Credits has every production credit with its role
+	Producers string
+	Credits   []ProducerCredit
+	Grouping  string
 	// resolved absolute artwork path (empty if none)
 	ArtworkPath     string
 	ArtworkMimeType string
+	// Custom holds free-form tags keyed by customTagKeys; adapters write
+	// them in that order and read back only those keys
+	Custom map[string]string
+	// Policies maps tagFields to what Write does to each; fields left out
+	// get defaultTagPolicy
+	Policies map[string]string
 }
 
 // MetadataWriter is the seam each container format implements.
@@ -49,6 +63,12 @@ func pickAdapter(ext string) MetadataWriter {
 		return mp4Adapter{}
 	case ".ogg", ".oga":
 		return oggAdapter{}
+	case ".opus":
+		return opusAdapter{}
+	case ".wav":
+		return wavAdapter{}
+	case ".aif", ".aiff", ".aifc":
+		return aiffAdapter{}
 	}
 	return nil
 }
@@ -66,28 +86,63 @@ func (a *App) ExtractMetadata(relPath string) (*ExtractedMetadata, error) {
 	}
 	defer f.Close()
 
-	// dhowden/tag handles all supported containers for reads
-	m, err := tag.ReadFrom(f)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse metadata: %v", err)
+	// dhowden/tag reads most containers; it doesn't report duration, so
+	// stream properties come from ProbeAudio. It can't read WAV or AIFF
+	// chunks, nor TIPL or repeated Vorbis comments, so the format's own
+	// reader fills those in. Untagged leaks are common, so any one source
+	// alone is enough.
+	m, tagErr := tag.ReadFrom(f)
+	props, probeErr := ProbeAudio(fullPath)
+	var own *SongTags
+	if adapter := pickAdapter(filepath.Ext(fullPath)); adapter != nil {
+		if tags, err := adapter.Read(fullPath); err == nil {
+			own = &tags
+		}
 	}
-
-	result := &ExtractedMetadata{
-		Title:       m.Title(),
-		Artist:      m.Artist(),
-		Album:       m.Album(),
-		AlbumArtist: m.AlbumArtist(),
-		Genre:       m.Genre(),
-		Year:        m.Year(),
+	if tagErr != nil && probeErr != nil && own == nil {
+		return nil, fmt.Errorf("failed to parse metadata: %v", tagErr)
 	}
 
-	track, _ := m.Track()
-	result.TrackNumber = track
+	result := &ExtractedMetadata{}
+	if tagErr == nil {
+		result.Title = m.Title()
+		result.Artist = m.Artist()
+		result.Album = m.Album()
+		result.AlbumArtist = m.AlbumArtist()
+		result.Genre = m.Genre()
+		result.Year = m.Year()
+
+		track, _ := m.Track()
+		result.TrackNumber = track
+
+		if pic := m.Picture(); pic != nil {
+			result.Artwork = &ArtworkData{
+				MimeType: pic.MIMEType,
+				Data:     base64.StdEncoding.EncodeToString(pic.Data),
+			}
+		}
+	} else if own != nil {
+		result.Title = own.Title
+		result.Artist = own.Artist
+		result.Album = own.Album
+		result.AlbumArtist = own.AlbumArtist
+		result.Genre = own.Genre
+		result.Year = int(own.Year)
+		result.TrackNumber = int(own.TrackNumber)
+	}
+	if own != nil && len(own.Credits) > 0 {
+		result.ProducerCredits = own.Credits
+		result.Producer = creditedProducers(own.Credits)
+	}
+	if probeErr == nil {
+		result.Duration = props.Duration
+		result.Audio = props
+	}
 
-	if pic := m.Picture(); pic != nil {
-		result.Artwork = &ArtworkData{
-			MimeType: pic.MIMEType,
-			Data:     base64.StdEncoding.EncodeToString(pic.Data),
+	// untagged leaks usually carry their details in the filename
+	if result.Title == "" || result.Artist == "" {
+		if parsed, err := a.ParseFilename(originalUploadFilename(relPath)); err == nil {
+			mergeParsedFilename(result, parsed)
 		}
 	}
 
@@ -123,6 +178,12 @@ func (a *App) WriteAlbumMetadata(albumID int) (BatchResult, error) {
 		return BatchResult{}, err
 	}
 
+	return a.writeMetadataBatch(songIDs, fmt.Sprintf("Processed album %d", albumID)), nil
+}
+
+// writeMetadataBatch writes metadata to songIDs, four at a time, and reports
+// per-song outcomes. Individual failures don't stop the batch.
+func (a *App) writeMetadataBatch(songIDs []int, message string) BatchResult {
 	results := make([]SongProcessingResult, len(songIDs))
 	var wg sync.WaitGroup
 	semaphore := make(chan struct{}, 4)
@@ -158,11 +219,11 @@ func (a *App) WriteAlbumMetadata(albumID int) (BatchResult, error) {
 
 	return BatchResult{
 		Success:        true,
-		Message:        fmt.Sprintf("Processed album %d", albumID),
+		Message:        message,
 		SongsProcessed: successCount,
 		SongsFailed:    failCount,
 		Results:        results,
-	}, nil
+	}
 }
 
 func (a *App) writeSongMetadataInternal(songID int) error {
@@ -193,41 +254,47 @@ func nullInt(ns sql.NullInt32) int32 {
 	return 0
 }
 
+// tagYear reads the year from a date tag: its leading four digits, so full
+// dates like "2019-06-14" work too. Anything else is 0.
+func tagYear(value string) int32 {
+	if len(value) < 4 {
+		return 0
+	}
+	y, err := strconv.Atoi(value[:4])
+	if err != nil || y < 0 {
+		return 0
+	}
+	return int32(y)
+}
+
 // buildSongTags assembles SongTags from the DB. Returns the resolved file path too.
 func (a *App) buildSongTags(songID int) (SongTags, string, error) {
 	query := `
     SELECT
         s.name, s.filepath, s.genre, s.year, s.track_number,
         s.artwork_path, a.name, a.genre, a.artwork_path,
-        GROUP_CONCAT(ar.name, ', '),
+        s.leak_date, s.leak_source, s.recording_date, s.leak_status, s.notes,
         (
             SELECT GROUP_CONCAT(ar2.name, ', ')
             FROM album_artists aa
             LEFT JOIN artists ar2 ON aa.artist_id = ar2.id
             WHERE aa.album_id = s.album_id
             ORDER BY aa."order"
-        ),
-        (
-            SELECT GROUP_CONCAT(p.name, ', ')
-            FROM song_producers sp
-            LEFT JOIN producers p ON sp.producer_id = p.id
-            WHERE sp.song_id = s.id
-            ORDER BY sp."order"
         )
     FROM songs s
     LEFT JOIN albums a ON s.album_id = a.id
-    LEFT JOIN song_artists sa ON s.id = sa.song_id
-    LEFT JOIN artists ar ON sa.artist_id = ar.id
-    WHERE s.id = ?
-    GROUP BY s.id`
+    WHERE s.id = ?`
 
 	var sName, sPath string
-	var sGenre, sArt, aName, aGenre, aArt, artists, albumArtists, producers sql.NullString
+	var sGenre, sArt, aName, aGenre, aArt, albumArtists sql.NullString
 	var sYear, sTrack sql.NullInt32
+	var provenance Provenance
 
 	err := a.db.QueryRow(query, songID).Scan(
 		&sName, &sPath, &sGenre, &sYear, &sTrack,
-		&sArt, &aName, &aGenre, &aArt, &artists, &albumArtists, &producers,
+		&sArt, &aName, &aGenre, &aArt,
+		&provenance.LeakDate, &provenance.LeakSource, &provenance.RecordingDate, &provenance.LeakStatus, &provenance.Notes,
+		&albumArtists,
 	)
 	if err == sql.ErrNoRows {
 		return SongTags{}, "", fmt.Errorf("song not found")
@@ -249,13 +316,17 @@ func (a *App) buildSongTags(songID int) (SongTags, string, error) {
 	songArt := nullStr(sArt)
 	albumArt := nullStr(aArt)
 
-	artistStr := nullStr(artists)
-	albumArtist := nullStr(albumArtists)
-	if albumArtist == "" {
-		albumArtist = artistStr
+	credits, err := a.getSongArtists(songID)
+	if err != nil {
+		return SongTags{}, "", err
+	}
+
+	producers, err := a.getProducersForSong(songID)
+	if err != nil {
+		return SongTags{}, "", err
 	}
+	producerTags := songProducerCredits(producers)
 
-	producersStr := nullStr(producers)
 	year := nullInt(sYear)
 	trackNumber := nullInt(sTrack)
 
@@ -265,6 +336,35 @@ func (a *App) buildSongTags(songID int) (SongTags, string, error) {
 	if err != nil {
 		return SongTags{}, "", err
 	}
+
+	artistStr, title := renderArtistCredits(sName, credits, settings.FeaturedArtistStyle)
+	albumArtist := nullStr(albumArtists)
+	if albumArtist == "" {
+		// a song's own album artist is its primary artists, not its guests
+		albumArtist, _ = renderArtistCredits(sName, credits, featuredStyleTitle)
+	}
+
+	grouping := ""
+	if settings.EraTag != eraTagOff {
+		song, err := a.getSongByID(songID)
+		if err != nil {
+			return SongTags{}, "", err
+		}
+		era, _, err := a.resolveSongEra(*song)
+		if err != nil {
+			return SongTags{}, "", err
+		}
+		if era != nil {
+			if settings.EraTag == eraTagGrouping {
+				grouping = era.Name
+			} else if albumName == "" {
+				// the era stands in for the album; track numbers have no meaning there
+				albumName = era.Name
+				trackNumber = 0
+			}
+		}
+	}
+
 	if albumName == "" {
 		if settings.AutomaticallyMakeSingles {
 			albumName = fmt.Sprintf("%s - Single", sName)
@@ -315,8 +415,13 @@ func (a *App) buildSongTags(songID int) (SongTags, string, error) {
 		}
 	}
 
+	var custom map[string]string
+	if settings.WriteProvenanceTags {
+		custom = provenanceTags(provenance)
+	}
+
 	return SongTags{
-		Title:           sName,
+		Title:           title,
 		Artist:          artistStr,
 		AlbumArtist:     albumArtist,
 		Album:           albumName,
@@ -325,8 +430,12 @@ func (a *App) buildSongTags(songID int) (SongTags, string, error) {
 		TrackNumberStr:  trackNumberStr,
 		TrackNumber:     trackNumber,
 		TrackTotal:      trackTotal,
-		Producers:       producersStr,
+		Producers:       creditedProducers(producerTags),
+		Credits:         producerTags,
+		Grouping:        grouping,
 		ArtworkPath:     artPath,
 		ArtworkMimeType: artMime,
+		Custom:          custom,
+		Policies:        settings.TagFieldPolicies,
 	}, fullPath, nil
 }
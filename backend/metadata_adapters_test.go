@@ -1,12 +1,16 @@
 package backend
 
 import (
+	"bytes"
+	"encoding/binary"
 	"image"
 	"image/color"
 	"image/png"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -61,18 +65,26 @@ func makeArtwork(t *testing.T, dir string) string {
 
 func sampleTags(artPath string) SongTags {
 	return SongTags{
-		Title:           "Test Title",
-		Artist:          "Test Artist",
-		AlbumArtist:     "Various Artists",
-		Album:           "Test Album",
-		Genre:           "Hip-Hop",
-		Year:            2024,
-		TrackNumberStr:  "3/10",
-		TrackNumber:     3,
-		TrackTotal:      10,
-		Producers:       "Producer A, Producer B",
+		Title:          "Test Title",
+		Artist:         "Test Artist",
+		AlbumArtist:    "Various Artists",
+		Album:          "Test Album",
+		Genre:          "Hip-Hop",
+		Year:           2024,
+		TrackNumberStr: "3/10",
+		TrackNumber:    3,
+		TrackTotal:     10,
+		Producers:      "Producer A, Producer B",
+		Credits: []ProducerCredit{
+			{Role: producerRoleProducer, Name: "Producer A"},
+			{Role: producerRoleProducer, Name: "Producer B"},
+			{Role: producerRoleAdditionalProduction, Name: "Producer C"},
+			{Role: producerRoleEngineer, Name: "Engineer D"},
+		},
+		Grouping:        "Test Era",
 		ArtworkPath:     artPath,
 		ArtworkMimeType: "image/png",
+		Custom:          map[string]string{tagLeakDate: "2019-06", tagLeakStatus: leakStatusLeaked},
 	}
 }
 
@@ -102,9 +114,20 @@ func assertCoreTagsMatch(t *testing.T, got, want SongTags) {
 	if got.Producers != want.Producers {
 		t.Errorf("producers: got %q want %q", got.Producers, want.Producers)
 	}
+	if !reflect.DeepEqual(got.Credits, want.Credits) {
+		t.Errorf("credits: got %+v want %+v", got.Credits, want.Credits)
+	}
+	if got.Grouping != want.Grouping {
+		t.Errorf("grouping: got %q want %q", got.Grouping, want.Grouping)
+	}
 	if got.ArtworkPath == "" {
 		t.Errorf("expected embedded artwork to survive, got empty ArtworkPath")
 	}
+	for key, value := range want.Custom {
+		if got.Custom[key] != value {
+			t.Errorf("custom tag %s: got %q want %q", key, got.Custom[key], value)
+		}
+	}
 }
 
 func TestID3AdapterRoundTrip(t *testing.T) {
@@ -139,6 +162,30 @@ func TestFlacAdapterRoundTrip(t *testing.T) {
 		t.Fatalf("flac Read: %v", err)
 	}
 	assertCoreTagsMatch(t, got, tags)
+
+	// a hard link is rewritten, leaving the original's bytes alone
+	linked := filepath.Join(dir, "linked.flac")
+	if err := os.Link(path, linked); err != nil {
+		t.Skipf("hard links unsupported: %v", err)
+	}
+	before, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read flac: %v", err)
+	}
+	if err := a.Write(linked, SongTags{Title: "Library"}); err != nil {
+		t.Fatalf("flac Write: %v", err)
+	}
+	if after, _ := os.ReadFile(path); !bytes.Equal(after, before) {
+		t.Fatal("expected the original to be unchanged")
+	}
+	if got, _ := a.Read(linked); got.Title != "Library" {
+		t.Fatalf("expected the link to be retagged, got %q", got.Title)
+	}
+	srcInfo, _ := os.Stat(path)
+	linkInfo, _ := os.Stat(linked)
+	if os.SameFile(srcInfo, linkInfo) {
+		t.Fatal("expected the write to break the hard link")
+	}
 }
 
 func TestOggAdapterRoundTrip(t *testing.T) {
@@ -160,7 +207,10 @@ func TestOggAdapterRoundTrip(t *testing.T) {
 
 func TestMP4AdapterRoundTrip(t *testing.T) {
 	dir := t.TempDir()
-	path := makeSilentAudio(t, dir, "sample", "aac", ".m4a")
+	path := filepath.Join(dir, "sample.m4a")
+	if err := os.WriteFile(path, minimalMP4(44100, 44100*4, bytes.Repeat([]byte("audio"), 1000)), 0644); err != nil {
+		t.Fatalf("write m4a: %v", err)
+	}
 	art := makeArtwork(t, dir)
 	tags := sampleTags(art)
 
@@ -173,6 +223,316 @@ func TestMP4AdapterRoundTrip(t *testing.T) {
 		t.Fatalf("mp4 Read: %v", err)
 	}
 	assertCoreTagsMatch(t, got, tags)
+
+	// moov grew in front of mdat, so the chunk offset must have moved with it
+	chunkStart := func() (string, int64) {
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatalf("open m4a: %v", err)
+		}
+		defer f.Close()
+		file, err := readMP4Moov(f)
+		if err != nil {
+			t.Fatalf("readMP4Moov: %v", err)
+		}
+		offset := int64(binary.BigEndian.Uint32(file.moov.chunkOffsetTables()[0].payload[8:]))
+		b := make([]byte, 5)
+		f.ReadAt(b, offset)
+		return string(b), file.size
+	}
+	if audio, _ := chunkStart(); audio != "audio" {
+		t.Fatalf("expected the chunk offset to point at the audio, got %q", audio)
+	}
+
+	// items the adapter doesn't write survive, and an unchanged rewrite
+	// happens in place
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("open m4a: %v", err)
+	}
+	file, err := readMP4Moov(f)
+	if err != nil {
+		t.Fatalf("readMP4Moov: %v", err)
+	}
+	ilst := file.moov.find("udta", "meta", "ilst")
+	ilst.children = append(ilst.children, mp4Item("\xa9too", mp4DataUTF8, []byte("Lavf60")),
+		mp4Item(mp4ItemDisc, mp4DataImplicit, mp4NumberPairData(1, 2, 6)))
+	if err := file.save(f, path); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	f.Close()
+	_, before := chunkStart()
+	if err := a.Write(path, tags); err != nil {
+		t.Fatalf("mp4 Write: %v", err)
+	}
+	if audio, after := chunkStart(); audio != "audio" || after != before {
+		t.Fatalf("expected an in-place rewrite, got %q and %d bytes (was %d)", audio, after, before)
+	}
+	f, _ = os.Open(path)
+	file, err = readMP4Moov(f)
+	f.Close()
+	if err != nil {
+		t.Fatalf("readMP4Moov: %v", err)
+	}
+	if encoder := file.moov.find("udta", "meta", "ilst", "\xa9too"); encoder == nil || string(encoder.dataValues()[0].value) != "Lavf60" {
+		t.Fatal("expected the encoder item to be preserved")
+	}
+	if got, _ := a.Read(path); got.DiscNumber != 1 || got.DiscTotal != 2 {
+		t.Fatalf("expected the disc number to be preserved, got %d/%d", got.DiscNumber, got.DiscTotal)
+	}
+	if props, err := ProbeAudio(path); err != nil || !approxEqual(props.Duration, 4) {
+		t.Fatalf("expected the file to still probe, got %+v (err %v)", props, err)
+	}
+
+	// a hard link is rewritten even when there's room, leaving the original
+	linked := filepath.Join(dir, "linked.m4a")
+	if err := os.Link(path, linked); err != nil {
+		t.Skipf("hard links unsupported: %v", err)
+	}
+	if err := a.Write(linked, SongTags{Title: "Library"}); err != nil {
+		t.Fatalf("mp4 Write: %v", err)
+	}
+	if got, _ := a.Read(path); got.Title != tags.Title {
+		t.Fatalf("expected the original to keep its title, got %q", got.Title)
+	}
+	if got, _ := a.Read(linked); got.Title != "Library" {
+		t.Fatalf("expected the link to be retagged, got %q", got.Title)
+	}
+	srcInfo, _ := os.Stat(path)
+	linkInfo, _ := os.Stat(linked)
+	if os.SameFile(srcInfo, linkInfo) {
+		t.Fatal("expected the write to break the hard link")
+	}
+}
+
+func TestShiftChunkOffsetsWidensToCo64(t *testing.T) {
+	stco := func(offsets ...uint32) *mp4Node {
+		payload := make([]byte, 8+4*len(offsets))
+		binary.BigEndian.PutUint32(payload[4:], uint32(len(offsets)))
+		for i, offset := range offsets {
+			binary.BigEndian.PutUint32(payload[8+4*i:], offset)
+		}
+		return &mp4Node{kind: "stco", payload: payload}
+	}
+	track := func(table *mp4Node) *mp4Node {
+		stbl := &mp4Node{kind: "stbl", children: []*mp4Node{table}}
+		minf := &mp4Node{kind: "minf", children: []*mp4Node{stbl}}
+		mdia := &mp4Node{kind: "mdia", children: []*mp4Node{minf}}
+		return &mp4Node{kind: "trak", children: []*mp4Node{mdia}}
+	}
+	near4G, small := stco(100, 0xFFFFFF00), stco(40, 200)
+	moov := &mp4Node{kind: "moov", children: []*mp4Node{track(near4G), track(small)}}
+
+	if err := moov.shiftChunkOffsets(50, 0x200); err != nil {
+		t.Fatalf("shiftChunkOffsets: %v", err)
+	}
+	if near4G.kind != "co64" || small.kind != "stco" {
+		t.Fatalf("expected only the overflowing table to widen, got %s and %s", near4G.kind, small.kind)
+	}
+	// widening grew the moov by 8 more bytes
+	delta := uint64(0x200 + 8)
+	if got := binary.BigEndian.Uint64(near4G.payload[8:]); got != 100+delta {
+		t.Fatalf("unexpected first co64 offset %d", got)
+	}
+	if got := binary.BigEndian.Uint64(near4G.payload[16:]); got != 0xFFFFFF00+delta {
+		t.Fatalf("unexpected second co64 offset %d", got)
+	}
+	if first, second := binary.BigEndian.Uint32(small.payload[8:]), binary.BigEndian.Uint32(small.payload[12:]); first != 40 || uint64(second) != 200+delta {
+		t.Fatalf("unexpected stco offsets %d and %d", first, second)
+	}
+}
+
+// makeSilentOpus writes a minimal Ogg Opus stream by hand: the two header
+// packets and two audio pages holding 1.5 s of (undecodable) payload.
+func makeSilentOpus(t *testing.T, dir string) string {
+	t.Helper()
+	head := make([]byte, 19)
+	copy(head, "OpusHead")
+	head[8], head[9] = 1, 2
+	binary.LittleEndian.PutUint16(head[10:], 312)
+	binary.LittleEndian.PutUint32(head[12:], 48000)
+
+	var buf bytes.Buffer
+	for i, page := range [][]byte{
+		oggPage(9, 0, head),
+		oggPage(9, 0, buildOpusTags("test encoder", []string{"TITLE=Old Title"})),
+		oggPage(9, 48000+312, bytes.Repeat([]byte{0xF8}, 300)),
+		oggPage(9, 72000+312, bytes.Repeat([]byte{0xF8}, 300)),
+	} {
+		binary.LittleEndian.PutUint32(page[18:], uint32(i))
+		buf.Write(page)
+	}
+	out := filepath.Join(dir, "sample.opus")
+	if err := os.WriteFile(out, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("write opus: %v", err)
+	}
+	return out
+}
+
+// makeSilentPCM writes 0.1 s of 16-bit stereo silence as WAV or AIFF, with
+// an odd-sized chunk of its own to check padding survives rewrites.
+func makeSilentPCM(t *testing.T, dir, ext string) string {
+	t.Helper()
+	samples := make([]byte, 4410*4)
+	var chunks bytes.Buffer
+	var magic, form string
+	var order binary.ByteOrder
+	if ext == ".wav" {
+		magic, form, order = "RIFF", "WAVE", binary.LittleEndian
+		fmtChunk := make([]byte, 16)
+		binary.LittleEndian.PutUint16(fmtChunk[0:], 1)
+		binary.LittleEndian.PutUint16(fmtChunk[2:], 2)
+		binary.LittleEndian.PutUint32(fmtChunk[4:], 44100)
+		binary.LittleEndian.PutUint32(fmtChunk[8:], 44100*4)
+		binary.LittleEndian.PutUint16(fmtChunk[12:], 4)
+		binary.LittleEndian.PutUint16(fmtChunk[14:], 16)
+		writeIFFChunk(&chunks, order, "fmt ", fmtChunk)
+		writeIFFChunk(&chunks, order, "junk", []byte("odd"))
+		writeIFFChunk(&chunks, order, "data", samples)
+	} else {
+		magic, form, order = "FORM", "AIFF", binary.BigEndian
+		comm := make([]byte, 18)
+		binary.BigEndian.PutUint16(comm[0:], 2)
+		binary.BigEndian.PutUint32(comm[2:], 4410)
+		binary.BigEndian.PutUint16(comm[6:], 16)
+		copy(comm[8:], []byte{0x40, 0x0E, 0xAC, 0x44}) // 44100 as an 80-bit float
+		writeIFFChunk(&chunks, order, "COMM", comm)
+		writeIFFChunk(&chunks, order, "NAME", []byte("odd"))
+		writeIFFChunk(&chunks, order, "SSND", append(make([]byte, 8), samples...))
+	}
+	hdr := make([]byte, 12)
+	copy(hdr, magic)
+	order.PutUint32(hdr[4:], uint32(4+chunks.Len()))
+	copy(hdr[8:], form)
+	out := filepath.Join(dir, "sample"+ext)
+	if err := os.WriteFile(out, append(hdr, chunks.Bytes()...), 0644); err != nil {
+		t.Fatalf("write %s: %v", ext, err)
+	}
+	return out
+}
+
+func TestOpusAdapterRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	path := makeSilentOpus(t, dir)
+	art := makeArtwork(t, dir)
+	tags := sampleTags(art)
+
+	a := opusAdapter{}
+	if err := a.Write(path, tags); err != nil {
+		t.Fatalf("opus Write: %v", err)
+	}
+	got, err := a.Read(path)
+	if err != nil {
+		t.Fatalf("opus Read: %v", err)
+	}
+	assertCoreTagsMatch(t, got, tags)
+
+	// comments past one page's worth spread over several pages; the audio
+	// pages follow with their sequence numbers shifted
+	tags.Custom[tagLeakNotes] = strings.Repeat("a long story ", 10000)
+	if err := a.Write(path, tags); err != nil {
+		t.Fatalf("opus Write: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read opus: %v", err)
+	}
+	pages, err := readOggPages(data)
+	if err != nil {
+		t.Fatalf("readOggPages: %v", err)
+	}
+	if len(pages) < 5 {
+		t.Fatalf("expected the comments to span pages, got %d pages", len(pages))
+	}
+	pos := 0
+	for i, page := range pages {
+		raw := data[pos : pos+27+len(page.lacing)+len(page.body)]
+		if page.seq != uint32(i) || !bytes.Equal(raw, page.marshal()) {
+			t.Fatalf("page %d: expected sequence %d with a valid crc, got %d", i, i, page.seq)
+		}
+		pos += len(raw)
+	}
+	if got, err := a.Read(path); err != nil || got.Custom[tagLeakNotes] != tags.Custom[tagLeakNotes] {
+		t.Fatalf("expected the multi-page comments to read back, got %d bytes of notes (err %v)", len(got.Custom[tagLeakNotes]), err)
+	}
+	props, err := ProbeAudio(path)
+	if err != nil || !approxEqual(props.Duration, 1.5) {
+		t.Fatalf("expected the audio pages intact, got %+v (err %v)", props, err)
+	}
+}
+
+func TestWAVAdapterRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	path := makeSilentPCM(t, dir, ".wav")
+	art := makeArtwork(t, dir)
+	tags := sampleTags(art)
+
+	a := wavAdapter{}
+	for i := 0; i < 2; i++ { // a rewrite replaces the chunks rather than adding more
+		if err := a.Write(path, tags); err != nil {
+			t.Fatalf("wav Write: %v", err)
+		}
+	}
+	got, err := a.Read(path)
+	if err != nil {
+		t.Fatalf("wav Read: %v", err)
+	}
+	assertCoreTagsMatch(t, got, tags)
+
+	file, err := openIFF(path, "RIFF", binary.LittleEndian, "WAVE")
+	if err != nil {
+		t.Fatalf("openIFF: %v", err)
+	}
+	defer file.f.Close()
+	var ids []string
+	for _, c := range file.chunks {
+		ids = append(ids, c.id)
+	}
+	if !reflect.DeepEqual(ids, []string{"fmt ", "junk", "data", "LIST", "id3 "}) || file.chunks[2].size != 4410*4 {
+		t.Fatalf("unexpected chunks: %v", ids)
+	}
+
+	// players without id3 support see the INFO list
+	info := SongTags{}
+	data, _ := file.read(file.chunks[3])
+	for _, field := range wavInfoFields {
+		if i := bytes.Index(data, []byte(field.id)); i >= 0 {
+			size := int(binary.LittleEndian.Uint32(data[i+4:]))
+			field.set(&info, strings.TrimRight(string(data[i+8:i+8+size]), "\x00"))
+		}
+	}
+	if info.Title != tags.Title || info.Album != tags.Album || info.Year != tags.Year || info.TrackNumber != tags.TrackNumber {
+		t.Fatalf("unexpected INFO tags: %+v", info)
+	}
+}
+
+func TestAIFFAdapterRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	path := makeSilentPCM(t, dir, ".aiff")
+	art := makeArtwork(t, dir)
+	tags := sampleTags(art)
+
+	a := aiffAdapter{}
+	if err := a.Write(path, tags); err != nil {
+		t.Fatalf("aiff Write: %v", err)
+	}
+	got, err := a.Read(path)
+	if err != nil {
+		t.Fatalf("aiff Read: %v", err)
+	}
+	assertCoreTagsMatch(t, got, tags)
+}
+
+func TestTagYearReadsFullDates(t *testing.T) {
+	for value, want := range map[string]int32{
+		"2019": 2019, "2019-06-14": 2019, "2019-06-14T10:00:00": 2019, "19": 0, "June 2019": 0, "": 0,
+	} {
+		var got SongTags
+		fillFromVorbis(&got, []string{"DATE=" + value})
+		if got.Year != want {
+			t.Errorf("DATE=%q: got year %d, want %d", value, got.Year, want)
+		}
+	}
 }
 
 func TestPickAdapter(t *testing.T) {
@@ -184,7 +544,10 @@ func TestPickAdapter(t *testing.T) {
 		".mp4":     true,
 		".ogg":     true,
 		".oga":     true,
-		".wav":     false,
+		".opus":    true,
+		".wav":     true,
+		".aiff":    true,
+		".aif":     true,
 		".unknown": false,
 	}
 	for ext, want := range cases {
@@ -194,3 +557,20 @@ func TestPickAdapter(t *testing.T) {
 		}
 	}
 }
+
+func TestExtractMetadataReadsWAVChunks(t *testing.T) {
+	app := newTestApp(t)
+
+	dir := filepath.Join(app.staticPath, "uploads", "songs")
+	path := makeSilentPCM(t, dir, ".wav")
+	if err := (wavAdapter{}).Write(path, SongTags{Title: "Snippet", Artist: "Lil Uzi Vert", TrackNumberStr: "4", TrackNumber: 4}); err != nil {
+		t.Fatalf("wav Write: %v", err)
+	}
+	metadata, err := app.ExtractMetadata("uploads/songs/sample.wav")
+	if err != nil {
+		t.Fatalf("ExtractMetadata: %v", err)
+	}
+	if metadata.Title != "Snippet" || metadata.Artist != "Lil Uzi Vert" || metadata.TrackNumber != 4 {
+		t.Fatalf("expected the id3 chunk to be read, got %+v", metadata)
+	}
+}
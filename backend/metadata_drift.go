@@ -0,0 +1,184 @@
+package backend
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// --- Metadata Drift ---
+
+// CheckMetadataDrift reads the tags of a song, an album's songs, or the
+// whole library, and compares them field by field with what writing
+// metadata would put there now. Fields the settings preserve aren't
+// compared, and removed ones are expected to be empty. It changes nothing;
+// see RewriteDriftedMetadata.
+func (a *App) CheckMetadataDrift(input MetadataDriftInput) (*MetadataDriftReport, error) {
+	songIDs, err := a.metadataDriftSongIDs(input)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &MetadataDriftReport{SongsChecked: len(songIDs), Songs: []SongDrift{}}
+	for _, songID := range songIDs {
+		drift := SongDrift{SongID: songID, Fields: []FieldDrift{}}
+		if err := a.db.QueryRow(`SELECT name, filepath FROM songs WHERE id = ?`, songID).Scan(&drift.SongName, &drift.Filepath); err != nil {
+			return nil, err
+		}
+		fields, err := a.songMetadataDrift(songID)
+		if err != nil {
+			drift.Error = err.Error()
+		}
+		drift.Fields = append(drift.Fields, fields...)
+		if len(drift.Fields) > 0 || drift.Error != "" {
+			report.Songs = append(report.Songs, drift)
+		}
+	}
+	return report, nil
+}
+
+// RewriteDriftedMetadata writes metadata to the songs CheckMetadataDrift
+// finds drifted, leaving the rest of the files untouched. Songs that
+// couldn't be checked are skipped.
+func (a *App) RewriteDriftedMetadata(input MetadataDriftInput) (BatchResult, error) {
+	report, err := a.CheckMetadataDrift(input)
+	if err != nil {
+		return BatchResult{}, err
+	}
+	songIDs := []int{}
+	for _, song := range report.Songs {
+		if song.Error == "" {
+			songIDs = append(songIDs, song.SongID)
+		}
+	}
+	return a.writeMetadataBatch(songIDs, fmt.Sprintf("Rewrote %d of %d songs", len(songIDs), report.SongsChecked)), nil
+}
+
+func (a *App) metadataDriftSongIDs(input MetadataDriftInput) ([]int, error) {
+	switch {
+	case input.SongID != nil && input.AlbumID != nil:
+		return nil, fmt.Errorf("check a song or an album, not both")
+	case input.SongID != nil:
+		var id int
+		if err := a.db.QueryRow(`SELECT id FROM songs WHERE id = ?`, *input.SongID).Scan(&id); err != nil {
+			return nil, fmt.Errorf("song not found")
+		}
+		return []int{id}, nil
+	}
+
+	query := `SELECT id FROM songs ORDER BY id`
+	args := []any{}
+	if input.AlbumID != nil {
+		query = `SELECT id FROM songs WHERE album_id = ? ORDER BY track_number, id`
+		args = append(args, *input.AlbumID)
+	}
+	rows, err := a.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	songIDs := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		songIDs = append(songIDs, id)
+	}
+	return songIDs, rows.Err()
+}
+
+// songMetadataDrift compares a song's file with buildSongTags.
+func (a *App) songMetadataDrift(songID int) ([]FieldDrift, error) {
+	expected, fullPath, err := a.buildSongTags(songID)
+	if err != nil {
+		return nil, err
+	}
+	ext := strings.ToLower(filepath.Ext(fullPath))
+	adapter := pickAdapter(ext)
+	if adapter == nil {
+		return nil, fmt.Errorf("reading support for %s not yet implemented", ext)
+	}
+	if _, err := os.Stat(fullPath); err != nil {
+		return nil, fmt.Errorf("file not found: %s", fullPath)
+	}
+	actual, err := adapter.Read(fullPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tags: %w", err)
+	}
+	return metadataDrift(expected, actual), nil
+}
+
+// metadataDrift lists the fields where actual, read from a file, differs
+// from what writing expected would leave there. Values are compared as
+// they're written, so "3/12" against "3/10" is a track total that went
+// stale when the album changed.
+func metadataDrift(expected, actual SongTags) []FieldDrift {
+	want := expected.forWrite()
+	// artwork that's gone from disk can't be embedded, so it isn't expected
+	if want.ArtworkPath != "" {
+		if _, err := os.Stat(want.ArtworkPath); err != nil {
+			want.ArtworkPath = ""
+		}
+	}
+
+	drift := []FieldDrift{}
+	compare := func(field, wantValue, gotValue string) {
+		if !expected.keeps(field) && wantValue != gotValue {
+			drift = append(drift, FieldDrift{Field: field, Expected: wantValue, Actual: gotValue})
+		}
+	}
+	compare(tagFieldTitle, want.Title, actual.Title)
+	compare(tagFieldArtist, want.Artist, actual.Artist)
+	compare(tagFieldAlbumArtist, want.AlbumArtist, actual.AlbumArtist)
+	compare(tagFieldAlbum, want.Album, actual.Album)
+	compare(tagFieldGenre, want.Genre, actual.Genre)
+	compare(tagFieldYear, driftYear(want.Year), driftYear(actual.Year))
+	compare(tagFieldTrack, want.TrackNumberStr, actual.TrackNumberStr)
+	compare(tagFieldProducers, driftCredits(want.producerCredits()), driftCredits(actual.producerCredits()))
+	compare(tagFieldGrouping, want.Grouping, actual.Grouping)
+	compare(tagFieldArtwork, driftArtwork(want), driftArtwork(actual))
+	compare(tagFieldCustom, driftCustom(want.Custom), driftCustom(actual.Custom))
+	return drift
+}
+
+func driftYear(year int32) string {
+	if year <= 0 {
+		return ""
+	}
+	return strconv.Itoa(int(year))
+}
+
+// driftCredits renders credits as "Name, Name (co_producer)".
+func driftCredits(credits []ProducerCredit) string {
+	parts := make([]string, len(credits))
+	for i, credit := range credits {
+		parts[i] = credit.Name
+		if credit.Role != producerRoleProducer {
+			parts[i] += " (" + credit.Role + ")"
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// driftArtwork only tells embedded artwork from none; Read doesn't return
+// the image to compare.
+func driftArtwork(tags SongTags) string {
+	if tags.ArtworkPath == "" {
+		return ""
+	}
+	return "embedded"
+}
+
+func driftCustom(custom map[string]string) string {
+	parts := []string{}
+	for _, key := range customTagKeys {
+		if value := custom[key]; value != "" {
+			parts = append(parts, key+"="+value)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,106 @@
+package backend
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCheckMetadataDrift(t *testing.T) {
+	app := newTestApp(t)
+
+	artist, err := app.CreateArtist(CreateArtistInput{Name: "Future"})
+	if err != nil {
+		t.Fatalf("CreateArtist: %v", err)
+	}
+	album, err := app.CreateAlbum(CreateAlbumInput{Name: "HNDRXX", ArtistIDs: []int{artist.ID}})
+	if err != nil {
+		t.Fatalf("CreateAlbum: %v", err)
+	}
+	createSong := func(name string, track int) *Song {
+		relPath := "uploads/songs/" + name + ".mp3"
+		writeSilentMP3(t, app, relPath)
+		song, err := app.CreateSong(CreateSongInput{Name: name, Filepath: relPath, ArtistIDs: []int{artist.ID}, AlbumID: &album.ID, TrackNumber: &track})
+		if err != nil {
+			t.Fatalf("CreateSong: %v", err)
+		}
+		return song
+	}
+	first := createSong("Use Me", 1)
+	second := createSong("Lie To Me", 2)
+	if res, _ := app.WriteAlbumMetadata(album.ID); res.SongsFailed > 0 {
+		t.Fatalf("WriteAlbumMetadata: %+v", res.Results)
+	}
+
+	input := MetadataDriftInput{AlbumID: &album.ID}
+	report, err := app.CheckMetadataDrift(input)
+	if err != nil {
+		t.Fatalf("CheckMetadataDrift: %v", err)
+	}
+	if report.SongsChecked != 2 || len(report.Songs) != 0 {
+		t.Fatalf("expected freshly written files not to drift, got %+v", report)
+	}
+
+	// a rename, a new album track, new artwork, and a song whose file is gone
+	renamed, track := "Use Me (Remix)", 1
+	if _, err := app.UpdateSong(UpdateSongInput{ID: first.ID, Name: &renamed, AlbumID: &album.ID, TrackNumber: &track}); err != nil {
+		t.Fatalf("UpdateSong: %v", err)
+	}
+	createSong("Incredible", 3)
+	art := makeArtwork(t, filepath.Join(app.staticPath, "uploads", "songs"))
+	if _, err := app.db.Exec(`UPDATE songs SET artwork_path = ? WHERE id = ?`, "uploads/songs/"+filepath.Base(art), second.ID); err != nil {
+		t.Fatalf("set artwork: %v", err)
+	}
+	if _, err := app.CreateSong(CreateSongInput{Name: "Gone", Filepath: "uploads/songs/gone.mp3"}); err != nil {
+		t.Fatalf("CreateSong: %v", err)
+	}
+
+	report, err = app.CheckMetadataDrift(MetadataDriftInput{})
+	if err != nil {
+		t.Fatalf("CheckMetadataDrift: %v", err)
+	}
+	if report.SongsChecked != 4 || len(report.Songs) != 4 {
+		t.Fatalf("expected every song to be reported, got %+v", report)
+	}
+	if want := []FieldDrift{
+		{Field: tagFieldTitle, Expected: "Use Me (Remix)", Actual: "Use Me"},
+		{Field: tagFieldTrack, Expected: "1/3", Actual: "1/2"},
+	}; !reflect.DeepEqual(report.Songs[0].Fields, want) {
+		t.Fatalf("unexpected drift for the renamed song: %+v", report.Songs[0].Fields)
+	}
+	if want := []FieldDrift{
+		{Field: tagFieldTrack, Expected: "2/3", Actual: "2/2"},
+		{Field: tagFieldArtwork, Expected: "embedded", Actual: ""},
+	}; !reflect.DeepEqual(report.Songs[1].Fields, want) {
+		t.Fatalf("unexpected drift for the second song: %+v", report.Songs[1].Fields)
+	}
+	if report.Songs[3].SongName != "Gone" || report.Songs[3].Error == "" {
+		t.Fatalf("expected the missing file to be reported as an error, got %+v", report.Songs[3])
+	}
+
+	res, err := app.RewriteDriftedMetadata(MetadataDriftInput{})
+	if err != nil {
+		t.Fatalf("RewriteDriftedMetadata: %v", err)
+	}
+	if res.SongsProcessed != 3 || res.SongsFailed != 0 {
+		t.Fatalf("expected the three drifted songs to be rewritten, got %+v", res)
+	}
+	if report, _ = app.CheckMetadataDrift(input); report.SongsChecked != 3 || len(report.Songs) != 0 {
+		t.Fatalf("expected no drift after the rewrite, got %+v", report)
+	}
+
+	if _, err := app.CheckMetadataDrift(MetadataDriftInput{SongID: &first.ID, AlbumID: &album.ID}); err == nil {
+		t.Fatal("expected a song and an album together to be rejected")
+	}
+}
+
+func TestMetadataDriftFollowsPolicies(t *testing.T) {
+	expected := SongTags{Title: "Mask Off", Genre: "Hip-Hop", Year: 2017, Policies: map[string]string{
+		tagFieldGenre: tagPolicyPreserve, tagFieldYear: tagPolicyRemove,
+	}}
+	actual := SongTags{Title: "Mask Off", Genre: "Trap", Year: 2016}
+	want := []FieldDrift{{Field: tagFieldYear, Expected: "", Actual: "2016"}}
+	if got := metadataDrift(expected, actual); !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected drift: %+v", got)
+	}
+}
@@ -2,7 +2,9 @@ package backend
 
 import (
 	"fmt"
+	"maps"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -19,7 +21,112 @@ func (id3Adapter) Write(path string, tags SongTags) error {
 	}
 	defer t.Close()
 
+	mergeID3Frames(t, tags)
+	return t.Save()
+}
+
+// mergeID3Frames replaces t's frames with those for tags, keeping the
+// frames of the fields tags preserves. Every frame is rewritten, so older
+// tags are upgraded to v2.4 for TIPL.
+func mergeID3Frames(t *id3v2.Tag, tags SongTags) {
+	all := t.AllFrames()
+	if t.Version() < 4 {
+		all = upgradeID3Frames(all)
+	}
 	t.DeleteAllFrames()
+	t.SetVersion(4)
+	for _, id := range slices.Sorted(maps.Keys(all)) {
+		for _, f := range all[id] {
+			if tags.keeps(id3FrameField(id, f)) {
+				t.AddFrame(id, f)
+			}
+		}
+	}
+	setID3Frames(t, tags.forWrite())
+}
+
+// upgradeID3Frames converts v2.3 frames to their v2.4 forms: TYER, TDAT
+// and TIME become one TDRC, IPLS becomes TIPL and TORY becomes TDOR. TRDA
+// and TSIZ have no v2.4 form and are dropped.
+func upgradeID3Frames(all map[string][]id3v2.Framer) map[string][]id3v2.Framer {
+	out := make(map[string][]id3v2.Framer, len(all))
+	for id, frames := range all {
+		switch id {
+		case "TYER", "TDAT", "TIME", "TRDA", "TSIZ":
+		case "IPLS":
+			// same body as TIPL: an encoding byte and null-separated pairs
+			out["TIPL"] = append(out["TIPL"], frames...)
+		case "TORY":
+			out["TDOR"] = append(out["TDOR"], frames...)
+		default:
+			out[id] = append(out[id], frames...)
+		}
+	}
+	if date := id3v23Date(all); date != "" && len(out["TDRC"]) == 0 {
+		out["TDRC"] = []id3v2.Framer{id3v2.TextFrame{Encoding: id3v2.EncodingUTF8, Text: date}}
+	}
+	return out
+}
+
+// id3v23Date joins TYER, TDAT (DDMM) and TIME (HHMM) into a v2.4
+// timestamp.
+func id3v23Date(all map[string][]id3v2.Framer) string {
+	text := func(id string) string {
+		if frames := all[id]; len(frames) > 0 {
+			if tf, ok := frames[0].(id3v2.TextFrame); ok {
+				return strings.TrimSpace(tf.Text)
+			}
+		}
+		return ""
+	}
+	date := text("TYER")
+	if date == "" {
+		return ""
+	}
+	if ddmm := text("TDAT"); len(ddmm) == 4 {
+		date += "-" + ddmm[2:] + "-" + ddmm[:2]
+		if hhmm := text("TIME"); len(hhmm) == 4 {
+			date += "T" + hhmm[:2] + ":" + hhmm[2:]
+		}
+	}
+	return date
+}
+
+// id3FrameField returns the tag field a frame belongs to, for v2.3 and
+// v2.4 frame IDs alike.
+func id3FrameField(id string, f id3v2.Framer) string {
+	switch id {
+	case "TIT2":
+		return tagFieldTitle
+	case "TPE1":
+		return tagFieldArtist
+	case "TPE2":
+		return tagFieldAlbumArtist
+	case "TALB":
+		return tagFieldAlbum
+	case "TCON":
+		return tagFieldGenre
+	case "TYER", "TDAT", "TIME", "TDRC":
+		return tagFieldYear
+	case "TRCK":
+		return tagFieldTrack
+	case "TCOM", "TIPL", "IPLS":
+		return tagFieldProducers
+	case "TIT1":
+		return tagFieldGrouping
+	case "APIC":
+		return tagFieldArtwork
+	case "TXXX":
+		if udtf, ok := f.(id3v2.UserDefinedTextFrame); ok && isCustomTagKey(udtf.Description) {
+			return tagFieldCustom
+		}
+	}
+	return tagFieldOther
+}
+
+// setID3Frames adds the frames for tags to t. WAV and AIFF embed the same
+// tag in a chunk.
+func setID3Frames(t *id3v2.Tag, tags SongTags) {
 
 	if tags.Title != "" {
 		t.SetTitle(tags.Title)
@@ -42,8 +149,20 @@ func (id3Adapter) Write(path string, tags SongTags) error {
 	if tags.TrackNumberStr != "" {
 		t.AddTextFrame(t.CommonID("Track number/Position in set"), t.DefaultEncoding(), tags.TrackNumberStr)
 	}
-	if tags.Producers != "" {
-		t.AddTextFrame(t.CommonID("Composer"), t.DefaultEncoding(), tags.Producers)
+	credits := tags.producerCredits()
+	if producers := creditedProducers(credits); producers != "" {
+		t.AddTextFrame(t.CommonID("Composer"), t.DefaultEncoding(), producers)
+	}
+	if len(credits) > 0 {
+		t.AddTextFrame(t.CommonID("Involved people list"), t.DefaultEncoding(), involvementList(credits))
+	}
+	if tags.Grouping != "" {
+		t.AddTextFrame(t.CommonID("Content group description"), t.DefaultEncoding(), tags.Grouping)
+	}
+	for _, key := range customTagKeys {
+		if value := tags.Custom[key]; value != "" {
+			t.AddUserDefinedTextFrame(id3v2.UserDefinedTextFrame{Encoding: t.DefaultEncoding(), Description: key, Value: value})
+		}
 	}
 
 	if tags.ArtworkPath != "" {
@@ -63,8 +182,6 @@ func (id3Adapter) Write(path string, tags SongTags) error {
 			t.AddAttachedPicture(pic)
 		}
 	}
-
-	return t.Save()
 }
 
 func (id3Adapter) Read(path string) (SongTags, error) {
@@ -73,7 +190,10 @@ func (id3Adapter) Read(path string) (SongTags, error) {
 		return SongTags{}, err
 	}
 	defer t.Close()
+	return songTagsFromID3(t), nil
+}
 
+func songTagsFromID3(t *id3v2.Tag) SongTags {
 	out := SongTags{
 		Title:       t.Title(),
 		Artist:      t.Artist(),
@@ -81,9 +201,8 @@ func (id3Adapter) Read(path string) (SongTags, error) {
 		Genre:       t.Genre(),
 		AlbumArtist: t.GetTextFrame(t.CommonID("Band/Orchestra/Accompaniment")).Text,
 	}
-	if y, err := strconv.Atoi(t.Year()); err == nil {
-		out.Year = int32(y)
-	}
+	// v2.4 TDRC may be a full timestamp
+	out.Year = tagYear(t.Year())
 	out.TrackNumberStr = t.GetTextFrame(t.CommonID("Track number/Position in set")).Text
 	if out.TrackNumberStr != "" {
 		// parse "n" or "n/total"
@@ -98,6 +217,19 @@ func (id3Adapter) Read(path string) (SongTags, error) {
 		}
 	}
 	out.Producers = t.GetTextFrame(t.CommonID("Composer")).Text
+	// v2.3 tags carry IPLS, which id3v2 doesn't parse as text
+	if tf, ok := t.GetLastFrame(t.CommonID("Involved people list")).(id3v2.TextFrame); ok {
+		out.Credits = parseInvolvementList(tf.Text)
+	}
+	out.Grouping = t.GetTextFrame(t.CommonID("Content group description")).Text
+	for _, f := range t.GetFrames("TXXX") {
+		if udtf, ok := f.(id3v2.UserDefinedTextFrame); ok && isCustomTagKey(udtf.Description) {
+			if out.Custom == nil {
+				out.Custom = make(map[string]string)
+			}
+			out.Custom[udtf.Description] = udtf.Value
+		}
+	}
 
 	if pics := t.GetFrames(t.CommonID("Attached picture")); len(pics) > 0 {
 		if pf, ok := pics[0].(id3v2.PictureFrame); ok {
@@ -109,5 +241,5 @@ func (id3Adapter) Read(path string) (SongTags, error) {
 			}
 		}
 	}
-	return out, nil
+	return out
 }
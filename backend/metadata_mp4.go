@@ -1,130 +1,616 @@
 package backend
 
 import (
+	"bytes"
+	"encoding/binary"
 	"fmt"
+	"io"
 	"os"
-	"os/exec"
 	"strconv"
 	"strings"
-
-	"github.com/dhowden/tag"
 )
 
-// mp4Adapter handles M4A/MP4 via ffmpeg shell-out. ffmpeg dependency is
-// contained here; no other adapter shells out for metadata.
+// mp4Adapter handles M4A/MP4 by editing the iTunes item list
+// (moov/udta/meta/ilst) directly. Items of preserved fields are kept, as
+// are all other atoms; the audio is never touched.
 type mp4Adapter struct{}
 
+// iTunes item atoms; \xa9 is the © that starts the classic names.
+const (
+	mp4ItemTitle       = "\xa9nam"
+	mp4ItemArtist      = "\xa9ART"
+	mp4ItemAlbumArtist = "aART"
+	mp4ItemAlbum       = "\xa9alb"
+	mp4ItemGenre       = "\xa9gen"
+	mp4ItemGenreID     = "gnre"
+	mp4ItemYear        = "\xa9day"
+	mp4ItemComposer    = "\xa9wrt"
+	mp4ItemGrouping    = "\xa9grp"
+	mp4ItemTrack       = "trkn"
+	mp4ItemDisc        = "disk"
+	mp4ItemCover       = "covr"
+	mp4ItemFreeform    = "----"
+)
+
+// data atom type codes
+const (
+	mp4DataImplicit = 0
+	mp4DataUTF8     = 1
+	mp4DataJPEG     = 13
+	mp4DataPNG      = 14
+)
+
+const mp4FreeformMean = "com.apple.iTunes"
+
 func (mp4Adapter) Write(path string, tags SongTags) error {
-	if _, err := exec.LookPath("ffmpeg"); err != nil {
-		return fmt.Errorf("ffmpeg not found: %w", err)
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	file, err := readMP4Moov(f)
+	if err != nil {
+		return err
 	}
 
-	tempPath := path + ".ffmpeg.m4a"
+	ilst := file.moov.ensurePath("udta", "meta", "ilst")
+	meta := file.moov.find("udta", "meta")
+	if meta.child("hdlr") == nil {
+		// iTunes needs the mdir handler to look at the item list
+		hdlr := make([]byte, 25)
+		copy(hdlr[8:], "mdirappl")
+		meta.children = append([]*mp4Node{{kind: "hdlr", payload: hdlr}}, meta.children...)
+	}
 
-	args := []string{"-i", path}
-	if tags.ArtworkPath != "" {
-		args = append(args, "-i", tags.ArtworkPath)
+	kept := []*mp4Node{}
+	for _, item := range ilst.children {
+		if tags.keeps(mp4ItemField(item, tags)) {
+			kept = append(kept, item)
+		}
 	}
+	ilst.children = append(kept, mp4Items(tags.forWrite())...)
+
+	return file.save(f, path)
+}
+
+func (mp4Adapter) Read(path string) (SongTags, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return SongTags{}, err
+	}
+	defer f.Close()
+
+	file, err := readMP4Moov(f)
+	if err != nil {
+		return SongTags{}, err
+	}
+
+	out := SongTags{}
+	ilst := file.moov.find("udta", "meta", "ilst")
+	if ilst == nil {
+		return out, nil
+	}
+	for _, item := range ilst.children {
+		values := item.dataValues()
+		if len(values) == 0 {
+			continue
+		}
+		text := string(values[0].value)
+		switch item.kind {
+		case mp4ItemTitle:
+			out.Title = text
+		case mp4ItemArtist:
+			out.Artist = text
+		case mp4ItemAlbumArtist:
+			out.AlbumArtist = text
+		case mp4ItemAlbum:
+			out.Album = text
+		case mp4ItemGenre:
+			out.Genre = text
+		case mp4ItemYear:
+			out.Year = tagYear(text)
+		case mp4ItemComposer:
+			out.Producers = text
+		case mp4ItemGrouping:
+			out.Grouping = text
+		case mp4ItemTrack:
+			out.TrackNumber, out.TrackTotal = mp4NumberPair(values[0].value)
+			if out.TrackNumber > 0 {
+				out.TrackNumberStr = strconv.Itoa(int(out.TrackNumber))
+				if out.TrackTotal > 0 {
+					out.TrackNumberStr += "/" + strconv.Itoa(int(out.TrackTotal))
+				}
+			}
+		case mp4ItemDisc:
+			out.DiscNumber, out.DiscTotal = mp4NumberPair(values[0].value)
+		case mp4ItemCover:
+			out.ArtworkPath = "embedded"
+			out.ArtworkMimeType = "image/jpeg"
+			if values[0].typ == mp4DataPNG {
+				out.ArtworkMimeType = "image/png"
+			}
+		case mp4ItemFreeform:
+			name := item.freeformName()
+			if role, ok := producerRoleForField(name); ok {
+				for _, v := range values {
+					out.Credits = append(out.Credits, ProducerCredit{Role: role, Name: string(v.value)})
+				}
+			} else if key := strings.ToUpper(name); isCustomTagKey(key) {
+				if out.Custom == nil {
+					out.Custom = make(map[string]string)
+				}
+				out.Custom[key] = text
+			}
+		}
+	}
+	return out, nil
+}
+
+// mp4Items builds the item atoms for tags, in the order iTunes writes them.
+func mp4Items(tags SongTags) []*mp4Node {
+	items := []*mp4Node{}
+	text := func(kind, value string) {
+		if value != "" {
+			items = append(items, mp4Item(kind, mp4DataUTF8, []byte(value)))
+		}
+	}
+	text(mp4ItemTitle, tags.Title)
+	text(mp4ItemArtist, tags.Artist)
+	text(mp4ItemAlbumArtist, tags.AlbumArtist)
+	text(mp4ItemAlbum, tags.Album)
+	text(mp4ItemGenre, tags.Genre)
+	if tags.Year > 0 {
+		text(mp4ItemYear, strconv.Itoa(int(tags.Year)))
+	}
+	if tags.TrackNumber > 0 {
+		items = append(items, mp4Item(mp4ItemTrack, mp4DataImplicit, mp4NumberPairData(tags.TrackNumber, tags.TrackTotal, 8)))
+	}
+	if tags.DiscNumber > 0 {
+		items = append(items, mp4Item(mp4ItemDisc, mp4DataImplicit, mp4NumberPairData(tags.DiscNumber, tags.DiscTotal, 6)))
+	}
+	credits := tags.producerCredits()
+	text(mp4ItemComposer, creditedProducers(credits))
+	text(mp4ItemGrouping, tags.Grouping)
 
-	args = append(args,
-		"-map_metadata", "-1",
-		"-map", "0:a",
-	)
 	if tags.ArtworkPath != "" {
-		args = append(args,
-			"-map", "1:0",
-			"-c:a", "copy",
-			"-c:v", "copy",
-			"-disposition:v:0", "attached_pic",
-		)
+		artData, err := os.ReadFile(tags.ArtworkPath)
+		if err == nil {
+			typ := uint32(mp4DataJPEG)
+			if tags.ArtworkMimeType == "image/png" {
+				typ = mp4DataPNG
+			}
+			items = append(items, mp4Item(mp4ItemCover, typ, artData))
+		}
+	}
+
+	// each role is one freeform item, with a data atom per name
+	for _, tag := range producerCreditTags {
+		names := []string{}
+		for _, credit := range credits {
+			if credit.Role == tag.role {
+				names = append(names, credit.Name)
+			}
+		}
+		if len(names) > 0 {
+			items = append(items, mp4FreeformItem(tag.field, names...))
+		}
+	}
+	for _, key := range customTagKeys {
+		if value := tags.Custom[key]; value != "" {
+			items = append(items, mp4FreeformItem(key, value))
+		}
+	}
+	return items
+}
+
+// mp4ItemField returns the tag field an item belongs to. Disc numbers
+// only count as track details when tags has one, since nothing in the
+// library sets them.
+func mp4ItemField(item *mp4Node, tags SongTags) string {
+	switch item.kind {
+	case mp4ItemTitle:
+		return tagFieldTitle
+	case mp4ItemArtist:
+		return tagFieldArtist
+	case mp4ItemAlbumArtist:
+		return tagFieldAlbumArtist
+	case mp4ItemAlbum:
+		return tagFieldAlbum
+	case mp4ItemGenre, mp4ItemGenreID:
+		return tagFieldGenre
+	case mp4ItemYear:
+		return tagFieldYear
+	case mp4ItemTrack:
+		return tagFieldTrack
+	case mp4ItemDisc:
+		if tags.DiscNumber > 0 {
+			return tagFieldTrack
+		}
+	case mp4ItemComposer:
+		return tagFieldProducers
+	case mp4ItemGrouping:
+		return tagFieldGrouping
+	case mp4ItemCover:
+		return tagFieldArtwork
+	case mp4ItemFreeform:
+		name := item.freeformName()
+		if _, ok := producerRoleForField(name); ok {
+			return tagFieldProducers
+		}
+		if isCustomTagKey(strings.ToUpper(name)) {
+			return tagFieldCustom
+		}
+	}
+	return tagFieldOther
+}
+
+func mp4Item(kind string, typ uint32, value []byte) *mp4Node {
+	return &mp4Node{kind: kind, children: []*mp4Node{mp4DataAtom(typ, value)}}
+}
+
+func mp4FreeformItem(name string, values ...string) *mp4Node {
+	item := &mp4Node{kind: mp4ItemFreeform, children: []*mp4Node{
+		{kind: "mean", payload: append([]byte{0, 0, 0, 0}, mp4FreeformMean...)},
+		{kind: "name", payload: append([]byte{0, 0, 0, 0}, name...)},
+	}}
+	for _, v := range values {
+		item.children = append(item.children, mp4DataAtom(mp4DataUTF8, []byte(v)))
+	}
+	return item
+}
+
+// mp4DataAtom is a data atom: version and type, a zero locale, the value.
+func mp4DataAtom(typ uint32, value []byte) *mp4Node {
+	payload := make([]byte, 8, 8+len(value))
+	binary.BigEndian.PutUint32(payload, typ)
+	return &mp4Node{kind: "data", payload: append(payload, value...)}
+}
+
+// mp4NumberPairData encodes trkn (8 bytes) and disk (6 bytes) values.
+func mp4NumberPairData(n, total int32, size int) []byte {
+	b := make([]byte, size)
+	binary.BigEndian.PutUint16(b[2:], uint16(n))
+	binary.BigEndian.PutUint16(b[4:], uint16(total))
+	return b
+}
+
+func mp4NumberPair(b []byte) (int32, int32) {
+	if len(b) < 6 {
+		return 0, 0
+	}
+	return int32(binary.BigEndian.Uint16(b[2:])), int32(binary.BigEndian.Uint16(b[4:]))
+}
+
+// --- MP4 atom tree ---
+
+// mp4Node is an atom held in memory. Containers keep their children and
+// leaves their payload; prefix is the version and flags a full-box
+// container (meta) carries ahead of its children.
+type mp4Node struct {
+	kind     string
+	prefix   []byte
+	payload  []byte
+	children []*mp4Node
+}
+
+// mp4Containers are the atoms parsed into children: the path to the item
+// list and the path to each track's chunk offsets.
+var mp4Containers = map[string]bool{
+	"moov": true, "trak": true, "mdia": true, "minf": true, "stbl": true,
+	"udta": true, "meta": true, "ilst": true, "edts": true, "dinf": true,
+}
+
+func parseMP4Nodes(b []byte, parent string) ([]*mp4Node, error) {
+	nodes := []*mp4Node{}
+	for pos := 0; pos < len(b); {
+		if len(b)-pos < 8 {
+			return nil, fmt.Errorf("truncated mp4 atom at %d", pos)
+		}
+		size := int(binary.BigEndian.Uint32(b[pos:]))
+		kind := string(b[pos+4 : pos+8])
+		header := 8
+		switch size {
+		case 0:
+			size = len(b) - pos
+		case 1:
+			if len(b)-pos < 16 {
+				return nil, fmt.Errorf("truncated mp4 atom at %d", pos)
+			}
+			size64 := binary.BigEndian.Uint64(b[pos+8:])
+			if size64 > uint64(len(b)-pos) {
+				return nil, fmt.Errorf("invalid mp4 atom %q at %d", kind, pos)
+			}
+			size, header = int(size64), 16
+		}
+		if size < header || size > len(b)-pos {
+			return nil, fmt.Errorf("invalid mp4 atom %q at %d", kind, pos)
+		}
+		node := &mp4Node{kind: kind, payload: b[pos+header : pos+size]}
+		// items (children of ilst) hold data, mean and name atoms
+		if mp4Containers[kind] || parent == "ilst" {
+			body := node.payload
+			// ISO meta is a full box; QuickTime's starts with hdlr directly
+			if kind == "meta" && !(len(body) >= 8 && string(body[4:8]) == "hdlr") && len(body) >= 4 {
+				node.prefix, body = body[:4], body[4:]
+			}
+			// anything that doesn't parse as atoms is kept as it is
+			if children, err := parseMP4Nodes(body, kind); err == nil {
+				node.children, node.payload = children, nil
+			} else {
+				node.prefix = nil
+			}
+		}
+		nodes = append(nodes, node)
+		pos += size
+	}
+	return nodes, nil
+}
+
+func (n *mp4Node) isContainer() bool {
+	return n.payload == nil
+}
+
+func (n *mp4Node) marshal() []byte {
+	var body bytes.Buffer
+	if n.isContainer() {
+		body.Write(n.prefix)
+		for _, c := range n.children {
+			body.Write(c.marshal())
+		}
 	} else {
-		args = append(args, "-c", "copy")
+		body.Write(n.payload)
 	}
-	args = append(args, "-movflags", "+faststart")
+	out := make([]byte, 8, 8+body.Len())
+	binary.BigEndian.PutUint32(out, uint32(8+body.Len()))
+	copy(out[4:], n.kind)
+	return append(out, body.Bytes()...)
+}
 
-	if tags.Title != "" {
-		args = append(args, "-metadata", fmt.Sprintf("title=%s", tags.Title))
+func (n *mp4Node) child(kind string) *mp4Node {
+	for _, c := range n.children {
+		if c.kind == kind {
+			return c
+		}
 	}
-	if tags.Artist != "" {
-		args = append(args, "-metadata", fmt.Sprintf("artist=%s", tags.Artist))
+	return nil
+}
+
+// find follows path down from n, returning nil if any step is missing.
+func (n *mp4Node) find(path ...string) *mp4Node {
+	for _, kind := range path {
+		if n = n.child(kind); n == nil {
+			return nil
+		}
+	}
+	return n
+}
+
+// ensurePath is find that creates the missing containers; a new meta
+// gets the full-box prefix.
+func (n *mp4Node) ensurePath(path ...string) *mp4Node {
+	for _, kind := range path {
+		next := n.child(kind)
+		if next == nil {
+			next = &mp4Node{kind: kind, children: []*mp4Node{}}
+			if kind == "meta" {
+				next.prefix = []byte{0, 0, 0, 0}
+			}
+			n.children = append(n.children, next)
+		}
+		n = next
+	}
+	return n
+}
+
+type mp4DataValue struct {
+	typ   uint32
+	value []byte
+}
+
+// dataValues returns an item's data atoms in order.
+func (n *mp4Node) dataValues() []mp4DataValue {
+	values := []mp4DataValue{}
+	for _, c := range n.children {
+		if c.kind == "data" && len(c.payload) >= 8 {
+			values = append(values, mp4DataValue{typ: binary.BigEndian.Uint32(c.payload) & 0xFFFFFF, value: c.payload[8:]})
+		}
 	}
-	if tags.Album != "" {
-		args = append(args, "-metadata", fmt.Sprintf("album=%s", tags.Album))
+	return values
+}
+
+// freeformName is the name of a ---- item, or "" for other items.
+func (n *mp4Node) freeformName() string {
+	if n.kind != mp4ItemFreeform {
+		return ""
 	}
-	if tags.AlbumArtist != "" {
-		args = append(args, "-metadata", fmt.Sprintf("album_artist=%s", tags.AlbumArtist))
+	if name := n.child("name"); name != nil && len(name.payload) >= 4 {
+		return string(name.payload[4:])
 	}
-	if tags.Genre != "" {
-		args = append(args, "-metadata", fmt.Sprintf("genre=%s", tags.Genre))
+	return ""
+}
+
+// chunkOffsetTables returns every track's stco and co64 atoms.
+func (n *mp4Node) chunkOffsetTables() []*mp4Node {
+	tables := []*mp4Node{}
+	for _, c := range n.children {
+		if c.kind == "stco" || c.kind == "co64" {
+			tables = append(tables, c)
+		} else if c.isContainer() && c.kind != "udta" {
+			tables = append(tables, c.chunkOffsetTables()...)
+		}
 	}
-	if tags.Year > 0 {
-		args = append(args, "-metadata", fmt.Sprintf("date=%d", tags.Year))
+	return tables
+}
+
+// chunkOffsetEntries returns a stco or co64 table's entry count and width.
+func (n *mp4Node) chunkOffsetEntries() (int, int, error) {
+	if len(n.payload) < 8 {
+		return 0, 0, fmt.Errorf("invalid %s atom", n.kind)
 	}
-	if tags.TrackNumberStr != "" {
-		args = append(args, "-metadata", fmt.Sprintf("track=%s", tags.TrackNumberStr))
+	count := int(binary.BigEndian.Uint32(n.payload[4:]))
+	width := 4
+	if n.kind == "co64" {
+		width = 8
 	}
-	if tags.Producers != "" {
-		args = append(args, "-metadata", fmt.Sprintf("composer=%s", tags.Producers))
+	if len(n.payload) < 8+count*width {
+		return 0, 0, fmt.Errorf("invalid %s atom", n.kind)
 	}
+	return count, width, nil
+}
 
-	args = append(args, "-y", tempPath)
+// toCo64 widens a stco table to co64 and returns how many bytes it grew.
+func (n *mp4Node) toCo64(count int) int64 {
+	payload := make([]byte, 8+count*8)
+	copy(payload, n.payload[:8])
+	for i := 0; i < count; i++ {
+		binary.BigEndian.PutUint64(payload[8+i*8:], uint64(binary.BigEndian.Uint32(n.payload[8+i*4:])))
+	}
+	grown := int64(len(payload) - len(n.payload))
+	n.kind, n.payload = "co64", payload
+	return grown
+}
 
-	cmd := exec.Command("ffmpeg", args...)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		os.Remove(tempPath)
-		return fmt.Errorf("ffmpeg failed: %w (%s)", err, strings.TrimSpace(string(output)))
+// shiftChunkOffsets moves every chunk offset at or past from by delta, the
+// growth of the moov that ends at from. A stco table with an offset that
+// would pass 4 GiB is widened to co64 first, which grows the moov further.
+func (n *mp4Node) shiftChunkOffsets(from, delta int64) error {
+	tables := n.chunkOffsetTables()
+	for _, table := range tables {
+		if _, _, err := table.chunkOffsetEntries(); err != nil {
+			return err
+		}
 	}
 
-	if _, err := os.Stat(tempPath); os.IsNotExist(err) {
-		return fmt.Errorf("temp file was not created")
+	// widening one table can push another past the limit
+	for widened := true; widened; {
+		widened = false
+		for _, table := range tables {
+			if table.kind != "stco" {
+				continue
+			}
+			count, _, _ := table.chunkOffsetEntries()
+			for i := 0; i < count; i++ {
+				offset := int64(binary.BigEndian.Uint32(table.payload[8+i*4:]))
+				if offset >= from && offset+delta > 0xFFFFFFFF {
+					delta += table.toCo64(count)
+					widened = true
+					break
+				}
+			}
+		}
 	}
 
-	if err := os.Rename(tempPath, path); err != nil {
-		os.Remove(tempPath)
-		return fmt.Errorf("failed to replace original file: %w", err)
+	for _, table := range tables {
+		count, width, _ := table.chunkOffsetEntries()
+		entries := table.payload[8:]
+		for i := 0; i < count; i++ {
+			entry := entries[i*width:]
+			if width == 4 {
+				if offset := int64(binary.BigEndian.Uint32(entry)); offset >= from {
+					binary.BigEndian.PutUint32(entry, uint32(offset+delta))
+				}
+			} else if offset := int64(binary.BigEndian.Uint64(entry)); offset >= from {
+				binary.BigEndian.PutUint64(entry, uint64(offset+delta))
+			}
+		}
 	}
 	return nil
 }
 
-func (mp4Adapter) Read(path string) (SongTags, error) {
-	f, err := os.Open(path)
+// mp4File is a file's top-level boxes and its parsed moov.
+type mp4File struct {
+	top  []mp4Box
+	box  mp4Box
+	size int64
+	moov *mp4Node
+}
+
+func readMP4Moov(f *os.File) (*mp4File, error) {
+	info, err := f.Stat()
 	if err != nil {
-		return SongTags{}, err
+		return nil, err
 	}
-	defer f.Close()
-
-	m, err := tag.ReadFrom(f)
+	top, err := readMP4Boxes(f, 0, info.Size())
 	if err != nil {
-		return SongTags{}, err
+		return nil, err
 	}
-
-	out := SongTags{
-		Title:       m.Title(),
-		Artist:      m.Artist(),
-		Album:       m.Album(),
-		AlbumArtist: m.AlbumArtist(),
-		Genre:       m.Genre(),
-		Year:        int32(m.Year()),
+	box := findMP4Box(top, "moov")
+	if box == nil {
+		return nil, fmt.Errorf("mp4 has no moov box")
 	}
-	if raw := m.Raw(); raw != nil {
-		if v, ok := raw["\xa9wrt"]; ok {
-			out.Producers = fmt.Sprint(v)
+	data := make([]byte, box.size-box.headerSize)
+	if _, err := f.ReadAt(data, box.start+box.headerSize); err != nil {
+		return nil, err
+	}
+	children, err := parseMP4Nodes(data, "moov")
+	if err != nil {
+		return nil, err
+	}
+	return &mp4File{top: top, box: *box, size: info.Size(), moov: &mp4Node{kind: "moov", children: children}}, nil
+}
+
+// save writes the edited moov back. When it fits in the old moov plus a
+// free atom right after it, it's written over them, padded with free;
+// otherwise the file is rewritten with the audio chunk offsets shifted. A
+// file with other hard links (an import made with hardLink) is always
+// rewritten, so the linked original keeps its tags.
+func (file *mp4File) save(f *os.File, path string) error {
+	moov := file.moov.marshal()
+	end := file.box.start + file.box.size
+	room := file.box.size
+	for i, b := range file.top {
+		if b.start == file.box.start && i+1 < len(file.top) && (file.top[i+1].kind == "free" || file.top[i+1].kind == "skip") {
+			room += file.top[i+1].size
 		}
 	}
-	track, total := m.Track()
-	out.TrackNumber = int32(track)
-	out.TrackTotal = int32(total)
-	if track > 0 {
-		if total > 0 {
-			out.TrackNumberStr = strconv.Itoa(track) + "/" + strconv.Itoa(total)
-		} else {
-			out.TrackNumberStr = strconv.Itoa(track)
+	if spare := room - int64(len(moov)); spare == 0 || spare >= 8 {
+		if spare > 0 {
+			moov = append(moov, (&mp4Node{kind: "free", payload: make([]byte, spare-8)}).marshal()...)
+		}
+		if hasOtherLinks(f) {
+			return file.rewrite(f, path, moov, file.box.start+room)
 		}
+		if _, err := f.WriteAt(moov, file.box.start); err != nil {
+			return fmt.Errorf("failed to write moov: %w", err)
+		}
+		return nil
 	}
-	if pic := m.Picture(); pic != nil && len(pic.Data) > 0 {
-		out.ArtworkPath = "embedded"
-		out.ArtworkMimeType = pic.MIMEType
+
+	if findMP4Box(file.top, "moof") != nil {
+		return fmt.Errorf("fragmented mp4 files can't grow their moov")
 	}
-	return out, nil
+	if err := file.moov.shiftChunkOffsets(end, int64(len(moov))-file.box.size); err != nil {
+		return err
+	}
+	return file.rewrite(f, path, file.moov.marshal(), end)
+}
+
+// rewrite saves a copy of f with moov in place of the bytes up to tail, and
+// renames it over path.
+func (file *mp4File) rewrite(f *os.File, path string, moov []byte, tail int64) error {
+	tempPath := path + ".tmp"
+	out, err := os.Create(tempPath)
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	_, err = io.Copy(out, io.NewSectionReader(f, 0, file.box.start))
+	if err == nil {
+		_, err = out.Write(moov)
+	}
+	if err == nil {
+		_, err = io.Copy(out, io.NewSectionReader(f, tail, file.size-tail))
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to write mp4: %w", err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to replace original file: %w", err)
+	}
+	return nil
 }
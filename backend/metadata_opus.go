@@ -0,0 +1,265 @@
+package backend
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+)
+
+// opusAdapter handles Ogg Opus. The OpusTags packet holds the same comments
+// as Ogg Vorbis, but ambeloe/oggv only reads Vorbis streams, so the header
+// pages are rewritten here and the audio pages are copied through with
+// their sequence numbers shifted.
+type opusAdapter struct{}
+
+var opusTagsMagic = []byte("OpusTags")
+
+func (opusAdapter) Write(path string, tags SongTags) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to open opus file: %w", err)
+	}
+	stream, err := readOpusHeaders(data)
+	if err != nil {
+		return err
+	}
+	vendor, comments, err := parseOpusTags(stream.tagsPacket)
+	if err != nil {
+		return err
+	}
+
+	tagPages := paginateOggPacket(buildOpusTags(vendor, mergeVorbisComments(comments, tags)), stream.serial, stream.pages[stream.tagsStart].seq)
+	shift := uint32(len(tagPages) - (stream.tagsEnd - stream.tagsStart + 1))
+
+	var out bytes.Buffer
+	for _, page := range stream.pages[:stream.tagsStart] {
+		out.Write(page.marshal())
+	}
+	for _, page := range tagPages {
+		out.Write(page.marshal())
+	}
+	for _, page := range stream.pages[stream.tagsEnd+1:] {
+		if page.serial == stream.serial {
+			page.seq += shift
+		}
+		out.Write(page.marshal())
+	}
+
+	tempPath := path + ".tmp"
+	if err := os.WriteFile(tempPath, out.Bytes(), 0644); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to write opus tags: %w", err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to replace original file: %w", err)
+	}
+	return nil
+}
+
+func (opusAdapter) Read(path string) (SongTags, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return SongTags{}, err
+	}
+	stream, err := readOpusHeaders(data)
+	if err != nil {
+		return SongTags{}, err
+	}
+	_, comments, err := parseOpusTags(stream.tagsPacket)
+	if err != nil {
+		return SongTags{}, err
+	}
+
+	out := SongTags{}
+	fillFromVorbis(&out, comments)
+	return out, nil
+}
+
+// oggPageData is a whole Ogg page: header fields plus its lacing values and
+// body.
+type oggPageData struct {
+	headerType byte
+	granule    int64
+	serial     uint32
+	seq        uint32
+	lacing     []byte
+	body       []byte
+}
+
+const oggContinued = 0x01
+
+var oggCRCTable = func() (table [256]uint32) {
+	for i := range table {
+		r := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if r&0x80000000 != 0 {
+				r = r<<1 ^ 0x04c11db7
+			} else {
+				r <<= 1
+			}
+		}
+		table[i] = r
+	}
+	return table
+}()
+
+func oggCRC(b []byte) uint32 {
+	var crc uint32
+	for _, c := range b {
+		crc = crc<<8 ^ oggCRCTable[byte(crc>>24)^c]
+	}
+	return crc
+}
+
+// readOggPages splits a whole Ogg file into pages.
+func readOggPages(data []byte) ([]oggPageData, error) {
+	pages := []oggPageData{}
+	for pos := 0; pos < len(data); {
+		hdr, ok := parseOggPageHeader(data[pos:])
+		if !ok || pos+hdr.headerSize+hdr.bodySize > len(data) {
+			return nil, fmt.Errorf("invalid ogg page at offset %d", pos)
+		}
+		pages = append(pages, oggPageData{
+			headerType: data[pos+5],
+			granule:    hdr.granule,
+			serial:     hdr.serial,
+			seq:        binary.LittleEndian.Uint32(data[pos+18 : pos+22]),
+			lacing:     data[pos+27 : pos+hdr.headerSize],
+			body:       data[pos+hdr.headerSize : pos+hdr.headerSize+hdr.bodySize],
+		})
+		pos += hdr.headerSize + hdr.bodySize
+	}
+	return pages, nil
+}
+
+func (p oggPageData) marshal() []byte {
+	b := make([]byte, 27, 27+len(p.lacing)+len(p.body))
+	copy(b, "OggS")
+	b[5] = p.headerType
+	binary.LittleEndian.PutUint64(b[6:14], uint64(p.granule))
+	binary.LittleEndian.PutUint32(b[14:18], p.serial)
+	binary.LittleEndian.PutUint32(b[18:22], p.seq)
+	b[26] = byte(len(p.lacing))
+	b = append(append(b, p.lacing...), p.body...)
+	binary.LittleEndian.PutUint32(b[22:26], oggCRC(b))
+	return b
+}
+
+// paginateOggPacket lays a header packet out over as many pages as it
+// needs, numbered from seq. Only the page that ends the packet gets a
+// granule position.
+func paginateOggPacket(packet []byte, serial, seq uint32) []oggPageData {
+	lacing := bytes.Repeat([]byte{255}, len(packet)/255)
+	lacing = append(lacing, byte(len(packet)%255))
+
+	pages := []oggPageData{}
+	for len(lacing) > 0 {
+		n := min(len(lacing), 255)
+		size := 0
+		for _, l := range lacing[:n] {
+			size += int(l)
+		}
+		page := oggPageData{granule: -1, serial: serial, seq: seq + uint32(len(pages)), lacing: lacing[:n], body: packet[:size]}
+		if len(pages) > 0 {
+			page.headerType = oggContinued
+		}
+		if n == len(lacing) {
+			page.granule = 0
+		}
+		pages = append(pages, page)
+		lacing, packet = lacing[n:], packet[size:]
+	}
+	return pages
+}
+
+// opusHeaders locates the OpusTags packet in a file's pages: it spans
+// pages[tagsStart:tagsEnd+1], and the audio starts on the next page.
+type opusHeaders struct {
+	pages              []oggPageData
+	serial             uint32
+	tagsStart, tagsEnd int
+	tagsPacket         []byte
+}
+
+func readOpusHeaders(data []byte) (*opusHeaders, error) {
+	pages, err := readOggPages(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(pages) == 0 || !bytes.HasPrefix(pages[0].body, []byte("OpusHead")) {
+		return nil, fmt.Errorf("not an ogg opus file")
+	}
+	stream := &opusHeaders{pages: pages, serial: pages[0].serial, tagsStart: 1}
+
+	// OpusHead fills the first page on its own, and OpusTags ends its last
+	for i := 1; i < len(pages); i++ {
+		page := pages[i]
+		if page.serial != stream.serial {
+			return nil, fmt.Errorf("multiplexed ogg streams aren't supported")
+		}
+		offset := 0
+		for j, l := range page.lacing {
+			stream.tagsPacket = append(stream.tagsPacket, page.body[offset:offset+int(l)]...)
+			offset += int(l)
+			if l < 255 {
+				if j != len(page.lacing)-1 {
+					return nil, fmt.Errorf("opus comment header shares its page with audio")
+				}
+				stream.tagsEnd = i
+				if !bytes.HasPrefix(stream.tagsPacket, opusTagsMagic) {
+					return nil, fmt.Errorf("opus comment header not found")
+				}
+				return stream, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("truncated opus comment header")
+}
+
+// parseOpusTags splits an OpusTags packet into its vendor string and
+// "KEY=VALUE" comments.
+func parseOpusTags(packet []byte) (string, []string, error) {
+	b := packet[len(opusTagsMagic):]
+	next := func() (string, bool) {
+		if len(b) < 4 {
+			return "", false
+		}
+		n := binary.LittleEndian.Uint32(b)
+		if uint64(n) > uint64(len(b)-4) {
+			return "", false
+		}
+		s := string(b[4 : 4+n])
+		b = b[4+n:]
+		return s, true
+	}
+	vendor, ok := next()
+	if !ok || len(b) < 4 {
+		return "", nil, fmt.Errorf("invalid opus comment header")
+	}
+	count := binary.LittleEndian.Uint32(b)
+	b = b[4:]
+	comments := []string{}
+	for i := uint32(0); i < count; i++ {
+		comment, ok := next()
+		if !ok {
+			return "", nil, fmt.Errorf("invalid opus comment header")
+		}
+		comments = append(comments, comment)
+	}
+	return vendor, comments, nil
+}
+
+func buildOpusTags(vendor string, comments []string) []byte {
+	var b bytes.Buffer
+	b.Write(opusTagsMagic)
+	binary.Write(&b, binary.LittleEndian, uint32(len(vendor)))
+	b.WriteString(vendor)
+	binary.Write(&b, binary.LittleEndian, uint32(len(comments)))
+	for _, c := range comments {
+		binary.Write(&b, binary.LittleEndian, uint32(len(c)))
+		b.WriteString(c)
+	}
+	return b.Bytes()
+}
@@ -0,0 +1,390 @@
+package backend
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/bogem/id3v2"
+)
+
+// wavAdapter handles WAV: a RIFF LIST/INFO chunk for players that only read
+// that, plus an "id3 " chunk carrying the full tag.
+type wavAdapter struct{}
+
+// aiffAdapter handles AIFF and AIFF-C, whose only widely read tag is an
+// "ID3 " chunk.
+type aiffAdapter struct{}
+
+// wavInfoFields maps RIFF INFO chunk IDs to the tags they carry.
+var wavInfoFields = []struct {
+	id    string
+	field string
+	value func(SongTags) string
+	set   func(*SongTags, string)
+}{
+	{"INAM", tagFieldTitle, func(t SongTags) string { return t.Title }, func(t *SongTags, v string) { t.Title = v }},
+	{"IART", tagFieldArtist, func(t SongTags) string { return t.Artist }, func(t *SongTags, v string) { t.Artist = v }},
+	{"IPRD", tagFieldAlbum, func(t SongTags) string { return t.Album }, func(t *SongTags, v string) { t.Album = v }},
+	{"IGNR", tagFieldGenre, func(t SongTags) string { return t.Genre }, func(t *SongTags, v string) { t.Genre = v }},
+	{"ICRD", tagFieldYear, func(t SongTags) string {
+		if t.Year > 0 {
+			return strconv.Itoa(int(t.Year))
+		}
+		return ""
+	}, func(t *SongTags, v string) {
+		t.Year = tagYear(v)
+	}},
+	{"ITRK", tagFieldTrack, func(t SongTags) string { return t.TrackNumberStr }, func(t *SongTags, v string) {
+		t.TrackNumberStr = v
+		if n, err := strconv.Atoi(strings.SplitN(v, "/", 2)[0]); err == nil {
+			t.TrackNumber = int32(n)
+		}
+	}},
+}
+
+func (wavAdapter) Write(path string, tags SongTags) error {
+	file, err := openIFF(path, "RIFF", binary.LittleEndian, "WAVE")
+	if err != nil {
+		return err
+	}
+	defer file.f.Close()
+
+	keep := []iffChunk{}
+	var info bytes.Buffer
+	info.WriteString("INFO")
+	var oldID3 []byte
+	for _, c := range file.chunks {
+		switch {
+		case isID3Chunk(c.id):
+			if oldID3, err = file.read(c); err != nil {
+				return err
+			}
+		case file.isInfoList(c):
+			// entries of preserved fields carry over
+			data, err := file.read(c)
+			if err != nil {
+				return err
+			}
+			for _, entry := range infoEntries(data) {
+				if tags.keeps(wavInfoField(entry.id)) {
+					writeIFFChunk(&info, binary.LittleEndian, entry.id, entry.data)
+				}
+			}
+		default:
+			keep = append(keep, c)
+		}
+	}
+
+	written := tags.forWrite()
+	for _, field := range wavInfoFields {
+		if v := field.value(written); v != "" {
+			writeIFFChunk(&info, binary.LittleEndian, field.id, append([]byte(v), 0))
+		}
+	}
+	extra := []iffChunkData{}
+	if info.Len() > 4 {
+		extra = append(extra, iffChunkData{"LIST", info.Bytes()})
+	}
+	id3, err := id3ChunkData(oldID3, tags)
+	if err != nil {
+		return err
+	}
+	if len(id3) > 0 {
+		extra = append(extra, iffChunkData{"id3 ", id3})
+	}
+	return file.rewrite(path, keep, extra)
+}
+
+func (wavAdapter) Read(path string) (SongTags, error) {
+	file, err := openIFF(path, "RIFF", binary.LittleEndian, "WAVE")
+	if err != nil {
+		return SongTags{}, err
+	}
+	defer file.f.Close()
+
+	// the id3 chunk has every field; INFO is the fallback
+	out := SongTags{}
+	for _, c := range file.chunks {
+		if isID3Chunk(c.id) {
+			return file.readID3Chunk(c)
+		}
+	}
+	for _, c := range file.chunks {
+		if !file.isInfoList(c) {
+			continue
+		}
+		data, err := file.read(c)
+		if err != nil {
+			return SongTags{}, err
+		}
+		for _, entry := range infoEntries(data) {
+			value := strings.TrimRight(string(entry.data), "\x00")
+			for _, field := range wavInfoFields {
+				if field.id == entry.id {
+					field.set(&out, value)
+				}
+			}
+		}
+	}
+	return out, nil
+}
+
+// infoEntries splits a LIST/INFO chunk into its entries.
+func infoEntries(data []byte) []iffChunkData {
+	entries := []iffChunkData{}
+	for pos := 4; pos+8 <= len(data); {
+		size := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		end := min(pos+8+size, len(data))
+		entries = append(entries, iffChunkData{string(data[pos : pos+4]), data[pos+8 : end]})
+		pos = end + size&1
+	}
+	return entries
+}
+
+// wavInfoField returns the tag field an INFO entry belongs to.
+func wavInfoField(id string) string {
+	for _, field := range wavInfoFields {
+		if field.id == id {
+			return field.field
+		}
+	}
+	return tagFieldOther
+}
+
+func (aiffAdapter) Write(path string, tags SongTags) error {
+	file, err := openIFF(path, "FORM", binary.BigEndian, "AIFF", "AIFC")
+	if err != nil {
+		return err
+	}
+	defer file.f.Close()
+
+	keep := []iffChunk{}
+	var oldID3 []byte
+	for _, c := range file.chunks {
+		if !isID3Chunk(c.id) {
+			keep = append(keep, c)
+		} else if oldID3, err = file.read(c); err != nil {
+			return err
+		}
+	}
+	extra := []iffChunkData{}
+	id3, err := id3ChunkData(oldID3, tags)
+	if err != nil {
+		return err
+	}
+	if len(id3) > 0 {
+		extra = append(extra, iffChunkData{"ID3 ", id3})
+	}
+	return file.rewrite(path, keep, extra)
+}
+
+func (aiffAdapter) Read(path string) (SongTags, error) {
+	file, err := openIFF(path, "FORM", binary.BigEndian, "AIFF", "AIFC")
+	if err != nil {
+		return SongTags{}, err
+	}
+	defer file.f.Close()
+
+	for _, c := range file.chunks {
+		if isID3Chunk(c.id) {
+			return file.readID3Chunk(c)
+		}
+	}
+	return SongTags{}, nil
+}
+
+// --- RIFF / IFF chunks ---
+
+// iffFile is an open RIFF (little-endian) or IFF (big-endian) file and its
+// top-level chunks. Audio chunks are never loaded, only copied.
+type iffFile struct {
+	f        *os.File
+	magic    string
+	formType string
+	order    binary.ByteOrder
+	chunks   []iffChunk
+}
+
+// iffChunk locates a chunk's data in the file.
+type iffChunk struct {
+	id     string
+	offset int64
+	size   int64
+}
+
+type iffChunkData struct {
+	id   string
+	data []byte
+}
+
+func openIFF(path, magic string, order binary.ByteOrder, formTypes ...string) (*iffFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	hdr := make([]byte, 12)
+	if _, err := io.ReadFull(f, hdr); err != nil || string(hdr[:4]) != magic {
+		f.Close()
+		return nil, fmt.Errorf("not a %s file", magic)
+	}
+	file := &iffFile{f: f, magic: magic, formType: string(hdr[8:12]), order: order}
+	known := false
+	for _, t := range formTypes {
+		known = known || file.formType == t
+	}
+	if !known {
+		f.Close()
+		return nil, fmt.Errorf("unsupported %s form type %q", magic, file.formType)
+	}
+
+	// chunks are padded to an even length; a truncated last chunk is cut
+	// to what's there
+	for pos := int64(12); pos+8 <= info.Size(); {
+		if _, err := f.ReadAt(hdr[:8], pos); err != nil {
+			f.Close()
+			return nil, err
+		}
+		c := iffChunk{id: string(hdr[:4]), offset: pos + 8, size: int64(order.Uint32(hdr[4:8]))}
+		c.size = min(c.size, info.Size()-c.offset)
+		file.chunks = append(file.chunks, c)
+		pos = c.offset + c.size + c.size&1
+	}
+	return file, nil
+}
+
+func (file *iffFile) read(c iffChunk) ([]byte, error) {
+	data := make([]byte, c.size)
+	if _, err := file.f.ReadAt(data, c.offset); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (file *iffFile) isInfoList(c iffChunk) bool {
+	listType := make([]byte, 4)
+	if c.id != "LIST" || c.size < 4 {
+		return false
+	}
+	_, err := file.f.ReadAt(listType, c.offset)
+	return err == nil && string(listType) == "INFO"
+}
+
+func (file *iffFile) readID3Chunk(c iffChunk) (SongTags, error) {
+	data, err := file.read(c)
+	if err != nil {
+		return SongTags{}, err
+	}
+	t, err := id3v2.ParseReader(bytes.NewReader(data), id3v2.Options{Parse: true})
+	if err != nil {
+		return SongTags{}, err
+	}
+	return songTagsFromID3(t), nil
+}
+
+// rewrite writes keep, copied from the file, and then extra to a temp file
+// that replaces path.
+func (file *iffFile) rewrite(path string, keep []iffChunk, extra []iffChunkData) error {
+	size := int64(4)
+	for _, c := range keep {
+		size += 8 + c.size + c.size&1
+	}
+	for _, c := range extra {
+		size += 8 + int64(len(c.data)) + int64(len(c.data)&1)
+	}
+	if size > 0xFFFFFFFF {
+		return fmt.Errorf("%s file too large", file.magic)
+	}
+
+	tempPath := path + ".tmp"
+	out, err := os.Create(tempPath)
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	err = func() error {
+		hdr := make([]byte, 8)
+		copy(hdr, file.magic)
+		file.order.PutUint32(hdr[4:], uint32(size))
+		if _, err := out.Write(append(hdr, file.formType...)); err != nil {
+			return err
+		}
+		for _, c := range keep {
+			copy(hdr, c.id)
+			file.order.PutUint32(hdr[4:], uint32(c.size))
+			if _, err := out.Write(hdr); err != nil {
+				return err
+			}
+			if _, err := io.Copy(out, io.NewSectionReader(file.f, c.offset, c.size)); err != nil {
+				return err
+			}
+			if c.size&1 == 1 {
+				if _, err := out.Write([]byte{0}); err != nil {
+					return err
+				}
+			}
+		}
+		var chunks bytes.Buffer
+		for _, c := range extra {
+			writeIFFChunk(&chunks, file.order, c.id, c.data)
+		}
+		_, err := out.Write(chunks.Bytes())
+		return err
+	}()
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to write %s chunks: %w", file.magic, err)
+	}
+
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to replace original file: %w", err)
+	}
+	return nil
+}
+
+func writeIFFChunk(w *bytes.Buffer, order binary.ByteOrder, id string, data []byte) {
+	hdr := make([]byte, 8)
+	copy(hdr, id)
+	order.PutUint32(hdr[4:], uint32(len(data)))
+	w.Write(hdr)
+	w.Write(data)
+	if len(data)%2 == 1 {
+		w.WriteByte(0)
+	}
+}
+
+func isID3Chunk(id string) bool {
+	return id == "id3 " || id == "ID3 "
+}
+
+// id3ChunkData renders tags as a standalone ID3v2.4 tag, merged into the
+// file's old tag if it had one; empty tags render as nothing.
+func id3ChunkData(old []byte, tags SongTags) ([]byte, error) {
+	t := id3v2.NewEmptyTag()
+	if len(old) > 0 {
+		parsed, err := id3v2.ParseReader(bytes.NewReader(old), id3v2.Options{Parse: true})
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse id3 chunk: %w", err)
+		}
+		t = parsed
+	}
+	mergeID3Frames(t, tags)
+	var buf bytes.Buffer
+	if _, err := t.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
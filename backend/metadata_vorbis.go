@@ -4,6 +4,7 @@ import (
 	"encoding/base64"
 	"fmt"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -38,31 +39,10 @@ func (flacAdapter) Write(path string, tags SongTags) error {
 		cmt = flacvorbis.New()
 	}
 
-	cmt.Comments = []string{}
-	if tags.Title != "" {
-		cmt.Add(flacvorbis.FIELD_TITLE, tags.Title)
-	}
-	if tags.Artist != "" {
-		cmt.Add(flacvorbis.FIELD_ARTIST, tags.Artist)
-	}
-	if tags.AlbumArtist != "" {
-		cmt.Add("ALBUMARTIST", tags.AlbumArtist)
-	}
-	if tags.Album != "" {
-		cmt.Add(flacvorbis.FIELD_ALBUM, tags.Album)
-	}
-	if tags.Genre != "" {
-		cmt.Add(flacvorbis.FIELD_GENRE, tags.Genre)
-	}
-	if tags.Year > 0 {
-		cmt.Add(flacvorbis.FIELD_DATE, fmt.Sprintf("%d", tags.Year))
-	}
-	if tags.TrackNumberStr != "" {
-		cmt.Add(flacvorbis.FIELD_TRACKNUMBER, tags.TrackNumberStr)
-	}
-	if tags.Producers != "" {
-		cmt.Add("PRODUCER", tags.Producers)
-	}
+	// artwork goes in a picture block rather than a comment
+	commentTags := tags
+	commentTags.ArtworkPath = ""
+	cmt.Comments = mergeVorbisComments(cmt.Comments, commentTags)
 
 	cmtBlock := cmt.Marshal()
 	if cmtIndex >= 0 {
@@ -71,10 +51,14 @@ func (flacAdapter) Write(path string, tags SongTags) error {
 		f.Meta = append(f.Meta, &cmtBlock)
 	}
 
-	if tags.ArtworkPath != "" {
-		artData, err := os.ReadFile(tags.ArtworkPath)
+	if tags.keeps(tagFieldArtwork) {
+		return saveFLAC(f, path)
+	}
+	f.Meta = slices.DeleteFunc(f.Meta, func(m *flac.MetaDataBlock) bool { return m.Type == flac.Picture })
+	if art := tags.forWrite(); art.ArtworkPath != "" {
+		artData, err := os.ReadFile(art.ArtworkPath)
 		if err == nil {
-			mime := tags.ArtworkMimeType
+			mime := art.ArtworkMimeType
 			if mime == "" {
 				mime = "image/jpeg"
 			}
@@ -86,19 +70,28 @@ func (flacAdapter) Write(path string, tags SongTags) error {
 			)
 			if perr == nil {
 				pictureBlock := picture.Marshal()
-				newMeta := make([]*flac.MetaDataBlock, 0, len(f.Meta))
-				for _, m := range f.Meta {
-					if m.Type != flac.Picture {
-						newMeta = append(newMeta, m)
-					}
-				}
-				newMeta = append(newMeta, &pictureBlock)
-				f.Meta = newMeta
+				f.Meta = append(f.Meta, &pictureBlock)
 			}
 		}
 	}
 
-	return f.Save(path)
+	return saveFLAC(f, path)
+}
+
+// saveFLAC writes f to a temp file and renames it over path. go-flac's Save
+// truncates and rewrites the file in place, which would also retag the
+// original of a hard-linked import.
+func saveFLAC(f *flac.File, path string) error {
+	tempPath := path + ".tmp"
+	if err := f.Save(tempPath); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to write flac file: %w", err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to replace original file: %w", err)
+	}
+	return nil
 }
 
 func (flacAdapter) Read(path string) (SongTags, error) {
@@ -143,17 +136,94 @@ func (oggAdapter) Write(path string, tags SongTags) error {
 	}
 	file.Close()
 
-	comments.Comments = []string{}
+	comments.Comments = mergeVorbisComments(comments.Comments, tags)
 
-	setComment := func(key, value string) {
-		newComments := make([]string, 0, len(comments.Comments)+1)
-		for _, c := range comments.Comments {
-			if !strings.HasPrefix(strings.ToUpper(c), strings.ToUpper(key)+"=") {
-				newComments = append(newComments, c)
-			}
+	tempPath := path + ".tmp"
+	tempFile, err := os.Create(tempPath)
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	if err := vorbiscomment.WriteOggVorbis(tempFile, comments); err != nil {
+		tempFile.Close()
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to write vorbis comments: %w", err)
+	}
+	tempFile.Close()
+
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to replace original file: %w", err)
+	}
+	return nil
+}
+
+func (oggAdapter) Read(path string) (SongTags, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return SongTags{}, err
+	}
+	defer file.Close()
+
+	comments, err := vorbiscomment.ReadOggVorbis(file)
+	if err != nil {
+		return SongTags{}, err
+	}
+
+	out := SongTags{}
+	fillFromVorbis(&out, comments.Comments)
+	return out, nil
+}
+
+// mergeVorbisComments keeps the existing comments of the fields tags
+// preserves and adds the comments for the fields it overwrites.
+func mergeVorbisComments(existing []string, tags SongTags) []string {
+	comments := []string{}
+	for _, c := range existing {
+		key, _, _ := strings.Cut(c, "=")
+		if tags.keeps(vorbisCommentField(key)) {
+			comments = append(comments, c)
 		}
-		newComments = append(newComments, key+"="+value)
-		comments.Comments = newComments
+	}
+	return append(comments, vorbisComments(tags.forWrite())...)
+}
+
+// vorbisCommentField returns the tag field a comment key belongs to.
+func vorbisCommentField(key string) string {
+	switch key = strings.ToUpper(key); key {
+	case "TITLE":
+		return tagFieldTitle
+	case "ARTIST":
+		return tagFieldArtist
+	case "ALBUMARTIST":
+		return tagFieldAlbumArtist
+	case "ALBUM":
+		return tagFieldAlbum
+	case "GENRE":
+		return tagFieldGenre
+	case "DATE":
+		return tagFieldYear
+	case "TRACKNUMBER", "TRACKTOTAL", "TOTALTRACKS":
+		return tagFieldTrack
+	case "GROUPING":
+		return tagFieldGrouping
+	case "METADATA_BLOCK_PICTURE":
+		return tagFieldArtwork
+	}
+	if _, ok := producerRoleForField(key); ok {
+		return tagFieldProducers
+	}
+	if isCustomTagKey(key) {
+		return tagFieldCustom
+	}
+	return tagFieldOther
+}
+
+// vorbisComments renders tags as "KEY=VALUE" comments, the artwork as a
+// METADATA_BLOCK_PICTURE comment.
+func vorbisComments(tags SongTags) []string {
+	comments := []string{}
+	setComment := func(key, value string) {
+		comments = append(comments, key+"="+value)
 	}
 
 	if tags.Title != "" {
@@ -177,8 +247,19 @@ func (oggAdapter) Write(path string, tags SongTags) error {
 	if tags.TrackNumberStr != "" {
 		setComment("TRACKNUMBER", tags.TrackNumberStr)
 	}
-	if tags.Producers != "" {
-		setComment("PRODUCER", tags.Producers)
+	// credits are multi-valued, one comment per name
+	for _, credit := range tags.producerCredits() {
+		if field := producerCreditField(credit.Role); field != "" {
+			setComment(field, credit.Name)
+		}
+	}
+	if tags.Grouping != "" {
+		setComment("GROUPING", tags.Grouping)
+	}
+	for _, key := range customTagKeys {
+		if value := tags.Custom[key]; value != "" {
+			setComment(key, value)
+		}
 	}
 
 	if tags.ArtworkPath != "" {
@@ -201,45 +282,12 @@ func (oggAdapter) Write(path string, tags SongTags) error {
 			}
 		}
 	}
-
-	tempPath := path + ".tmp"
-	tempFile, err := os.Create(tempPath)
-	if err != nil {
-		return fmt.Errorf("failed to create temp file: %w", err)
-	}
-	if err := vorbiscomment.WriteOggVorbis(tempFile, comments); err != nil {
-		tempFile.Close()
-		os.Remove(tempPath)
-		return fmt.Errorf("failed to write vorbis comments: %w", err)
-	}
-	tempFile.Close()
-
-	if err := os.Rename(tempPath, path); err != nil {
-		os.Remove(tempPath)
-		return fmt.Errorf("failed to replace original file: %w", err)
-	}
-	return nil
-}
-
-func (oggAdapter) Read(path string) (SongTags, error) {
-	file, err := os.Open(path)
-	if err != nil {
-		return SongTags{}, err
-	}
-	defer file.Close()
-
-	comments, err := vorbiscomment.ReadOggVorbis(file)
-	if err != nil {
-		return SongTags{}, err
-	}
-
-	out := SongTags{}
-	fillFromVorbis(&out, comments.Comments)
-	return out, nil
+	return comments
 }
 
 // fillFromVorbis populates SongTags from a slice of "KEY=VALUE" strings.
 func fillFromVorbis(out *SongTags, comments []string) {
+
 	for _, c := range comments {
 		eq := strings.IndexByte(c, '=')
 		if eq < 0 {
@@ -259,9 +307,7 @@ func fillFromVorbis(out *SongTags, comments []string) {
 		case "GENRE":
 			out.Genre = val
 		case "DATE":
-			if y, err := strconv.Atoi(val); err == nil {
-				out.Year = int32(y)
-			}
+			out.Year = tagYear(val)
 		case "TRACKNUMBER":
 			out.TrackNumberStr = val
 			parts := strings.SplitN(val, "/", 2)
@@ -273,8 +319,8 @@ func fillFromVorbis(out *SongTags, comments []string) {
 					out.TrackTotal = int32(n)
 				}
 			}
-		case "PRODUCER":
-			out.Producers = val
+		case "GROUPING":
+			out.Grouping = val
 		case "METADATA_BLOCK_PICTURE":
 			if val != "" {
 				out.ArtworkPath = "embedded"
@@ -282,6 +328,16 @@ func fillFromVorbis(out *SongTags, comments []string) {
 					out.ArtworkMimeType = "image/jpeg"
 				}
 			}
+		default:
+			if role, ok := producerRoleForField(key); ok {
+				out.Credits = append(out.Credits, ProducerCredit{Role: role, Name: val})
+			} else if isCustomTagKey(key) {
+				if out.Custom == nil {
+					out.Custom = make(map[string]string)
+				}
+				out.Custom[key] = val
+			}
 		}
 	}
+	out.Producers = creditedProducers(out.Credits)
 }
@@ -0,0 +1,19 @@
+DROP TRIGGER IF EXISTS song_search_producer_aliases_delete;
+DROP TRIGGER IF EXISTS song_search_producer_aliases_update;
+DROP TRIGGER IF EXISTS song_search_producer_aliases_insert;
+DROP TRIGGER IF EXISTS song_search_producer_delete;
+DROP TRIGGER IF EXISTS song_search_producer_update;
+DROP TRIGGER IF EXISTS song_search_song_producers_update;
+DROP TRIGGER IF EXISTS song_search_song_producers_delete;
+DROP TRIGGER IF EXISTS song_search_song_producers_insert;
+DROP TRIGGER IF EXISTS song_search_song_artists_update;
+DROP TRIGGER IF EXISTS song_search_song_artists_delete;
+DROP TRIGGER IF EXISTS song_search_song_artists_insert;
+DROP TRIGGER IF EXISTS song_search_artist_delete;
+DROP TRIGGER IF EXISTS song_search_artist_update;
+DROP TRIGGER IF EXISTS song_search_album_update;
+DROP TRIGGER IF EXISTS song_search_song_delete;
+DROP TRIGGER IF EXISTS song_search_song_update;
+DROP TRIGGER IF EXISTS song_search_song_insert;
+DROP VIEW IF EXISTS song_search_source;
+DROP TABLE IF EXISTS song_search;
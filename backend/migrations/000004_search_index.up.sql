@@ -0,0 +1,222 @@
+-- Full-text index over songs. One document per song (rowid = songs.id) that
+-- carries the song name plus the names of everything it is linked to, so a
+-- single MATCH finds songs by album, artist, producer, or producer alias.
+CREATE VIRTUAL TABLE IF NOT EXISTS song_search USING fts5(
+    song_name,
+    album_name,
+    artist_names,
+    producer_names,
+    tokenize = 'unicode61 remove_diacritics 2'
+);
+
+-- Source rows for song_search. Triggers below re-derive a song's document
+-- from this view whenever anything that feeds it changes.
+CREATE VIEW IF NOT EXISTS song_search_source AS
+SELECT
+    s.id AS song_id,
+    s.name AS song_name,
+    COALESCE(al.name, '') AS album_name,
+    COALESCE((
+        SELECT GROUP_CONCAT(ar.name, ' ')
+        FROM song_artists sa
+        JOIN artists ar ON ar.id = sa.artist_id
+        WHERE sa.song_id = s.id
+    ), '') AS artist_names,
+    TRIM(COALESCE((
+        SELECT GROUP_CONCAT(p.name, ' ')
+        FROM song_producers sp
+        JOIN producers p ON p.id = sp.producer_id
+        WHERE sp.song_id = s.id
+    ), '') || ' ' || COALESCE((
+        SELECT GROUP_CONCAT(pa.alias, ' ')
+        FROM song_producers sp
+        JOIN producer_aliases pa ON pa.producer_id = sp.producer_id
+        WHERE sp.song_id = s.id
+    ), '')) AS producer_names
+FROM songs s
+LEFT JOIN albums al ON al.id = s.album_id;
+
+-- Backfill existing songs
+INSERT INTO song_search (rowid, song_name, album_name, artist_names, producer_names)
+SELECT song_id, song_name, album_name, artist_names, producer_names FROM song_search_source;
+
+-- Song rows
+CREATE TRIGGER IF NOT EXISTS song_search_song_insert
+AFTER INSERT ON songs
+FOR EACH ROW
+BEGIN
+    INSERT INTO song_search (rowid, song_name, album_name, artist_names, producer_names)
+    SELECT song_id, song_name, album_name, artist_names, producer_names
+    FROM song_search_source WHERE song_id = NEW.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS song_search_song_update
+AFTER UPDATE OF name, album_id ON songs
+FOR EACH ROW
+WHEN OLD.name IS NOT NEW.name
+  OR OLD.album_id IS NOT NEW.album_id
+BEGIN
+    DELETE FROM song_search WHERE rowid = NEW.id;
+    INSERT INTO song_search (rowid, song_name, album_name, artist_names, producer_names)
+    SELECT song_id, song_name, album_name, artist_names, producer_names
+    FROM song_search_source WHERE song_id = NEW.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS song_search_song_delete
+AFTER DELETE ON songs
+FOR EACH ROW
+BEGIN
+    DELETE FROM song_search WHERE rowid = OLD.id;
+END;
+
+-- Album renames reach every song on the album
+CREATE TRIGGER IF NOT EXISTS song_search_album_update
+AFTER UPDATE OF name ON albums
+FOR EACH ROW
+WHEN OLD.name IS NOT NEW.name
+BEGIN
+    DELETE FROM song_search WHERE rowid IN (SELECT id FROM songs WHERE album_id = NEW.id);
+    INSERT INTO song_search (rowid, song_name, album_name, artist_names, producer_names)
+    SELECT song_id, song_name, album_name, artist_names, producer_names
+    FROM song_search_source WHERE song_id IN (SELECT id FROM songs WHERE album_id = NEW.id);
+END;
+
+-- Artist renames and deletes
+CREATE TRIGGER IF NOT EXISTS song_search_artist_update
+AFTER UPDATE OF name ON artists
+FOR EACH ROW
+WHEN OLD.name IS NOT NEW.name
+BEGIN
+    DELETE FROM song_search WHERE rowid IN (SELECT song_id FROM song_artists WHERE artist_id = NEW.id);
+    INSERT INTO song_search (rowid, song_name, album_name, artist_names, producer_names)
+    SELECT song_id, song_name, album_name, artist_names, producer_names
+    FROM song_search_source WHERE song_id IN (SELECT song_id FROM song_artists WHERE artist_id = NEW.id);
+END;
+
+CREATE TRIGGER IF NOT EXISTS song_search_artist_delete
+AFTER DELETE ON artists
+FOR EACH ROW
+BEGIN
+    DELETE FROM song_search WHERE rowid IN (SELECT song_id FROM song_artists WHERE artist_id = OLD.id);
+    INSERT INTO song_search (rowid, song_name, album_name, artist_names, producer_names)
+    SELECT song_id, song_name, album_name, artist_names, producer_names
+    FROM song_search_source WHERE song_id IN (SELECT song_id FROM song_artists WHERE artist_id = OLD.id);
+END;
+
+-- Song artist links
+CREATE TRIGGER IF NOT EXISTS song_search_song_artists_insert
+AFTER INSERT ON song_artists
+FOR EACH ROW
+BEGIN
+    DELETE FROM song_search WHERE rowid = NEW.song_id;
+    INSERT INTO song_search (rowid, song_name, album_name, artist_names, producer_names)
+    SELECT song_id, song_name, album_name, artist_names, producer_names
+    FROM song_search_source WHERE song_id = NEW.song_id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS song_search_song_artists_delete
+AFTER DELETE ON song_artists
+FOR EACH ROW
+BEGIN
+    DELETE FROM song_search WHERE rowid = OLD.song_id;
+    INSERT INTO song_search (rowid, song_name, album_name, artist_names, producer_names)
+    SELECT song_id, song_name, album_name, artist_names, producer_names
+    FROM song_search_source WHERE song_id = OLD.song_id;
+END;
+
+-- Links relinked in place (merges) refresh both the old and the new song
+CREATE TRIGGER IF NOT EXISTS song_search_song_artists_update
+AFTER UPDATE ON song_artists
+FOR EACH ROW
+BEGIN
+    DELETE FROM song_search WHERE rowid IN (OLD.song_id, NEW.song_id);
+    INSERT INTO song_search (rowid, song_name, album_name, artist_names, producer_names)
+    SELECT song_id, song_name, album_name, artist_names, producer_names
+    FROM song_search_source WHERE song_id IN (OLD.song_id, NEW.song_id);
+END;
+
+-- Song producer links
+CREATE TRIGGER IF NOT EXISTS song_search_song_producers_insert
+AFTER INSERT ON song_producers
+FOR EACH ROW
+BEGIN
+    DELETE FROM song_search WHERE rowid = NEW.song_id;
+    INSERT INTO song_search (rowid, song_name, album_name, artist_names, producer_names)
+    SELECT song_id, song_name, album_name, artist_names, producer_names
+    FROM song_search_source WHERE song_id = NEW.song_id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS song_search_song_producers_delete
+AFTER DELETE ON song_producers
+FOR EACH ROW
+BEGIN
+    DELETE FROM song_search WHERE rowid = OLD.song_id;
+    INSERT INTO song_search (rowid, song_name, album_name, artist_names, producer_names)
+    SELECT song_id, song_name, album_name, artist_names, producer_names
+    FROM song_search_source WHERE song_id = OLD.song_id;
+END;
+
+-- Links relinked in place (merges) refresh both the old and the new song
+CREATE TRIGGER IF NOT EXISTS song_search_song_producers_update
+AFTER UPDATE ON song_producers
+FOR EACH ROW
+BEGIN
+    DELETE FROM song_search WHERE rowid IN (OLD.song_id, NEW.song_id);
+    INSERT INTO song_search (rowid, song_name, album_name, artist_names, producer_names)
+    SELECT song_id, song_name, album_name, artist_names, producer_names
+    FROM song_search_source WHERE song_id IN (OLD.song_id, NEW.song_id);
+END;
+
+-- Producer renames and deletes
+CREATE TRIGGER IF NOT EXISTS song_search_producer_update
+AFTER UPDATE OF name ON producers
+FOR EACH ROW
+WHEN OLD.name IS NOT NEW.name
+BEGIN
+    DELETE FROM song_search WHERE rowid IN (SELECT song_id FROM song_producers WHERE producer_id = NEW.id);
+    INSERT INTO song_search (rowid, song_name, album_name, artist_names, producer_names)
+    SELECT song_id, song_name, album_name, artist_names, producer_names
+    FROM song_search_source WHERE song_id IN (SELECT song_id FROM song_producers WHERE producer_id = NEW.id);
+END;
+
+CREATE TRIGGER IF NOT EXISTS song_search_producer_delete
+AFTER DELETE ON producers
+FOR EACH ROW
+BEGIN
+    DELETE FROM song_search WHERE rowid IN (SELECT song_id FROM song_producers WHERE producer_id = OLD.id);
+    INSERT INTO song_search (rowid, song_name, album_name, artist_names, producer_names)
+    SELECT song_id, song_name, album_name, artist_names, producer_names
+    FROM song_search_source WHERE song_id IN (SELECT song_id FROM song_producers WHERE producer_id = OLD.id);
+END;
+
+-- Producer aliases feed producer_names for every song by that producer
+CREATE TRIGGER IF NOT EXISTS song_search_producer_aliases_insert
+AFTER INSERT ON producer_aliases
+FOR EACH ROW
+BEGIN
+    DELETE FROM song_search WHERE rowid IN (SELECT song_id FROM song_producers WHERE producer_id = NEW.producer_id);
+    INSERT INTO song_search (rowid, song_name, album_name, artist_names, producer_names)
+    SELECT song_id, song_name, album_name, artist_names, producer_names
+    FROM song_search_source WHERE song_id IN (SELECT song_id FROM song_producers WHERE producer_id = NEW.producer_id);
+END;
+
+CREATE TRIGGER IF NOT EXISTS song_search_producer_aliases_update
+AFTER UPDATE OF alias ON producer_aliases
+FOR EACH ROW
+WHEN OLD.alias IS NOT NEW.alias
+BEGIN
+    DELETE FROM song_search WHERE rowid IN (SELECT song_id FROM song_producers WHERE producer_id = NEW.producer_id);
+    INSERT INTO song_search (rowid, song_name, album_name, artist_names, producer_names)
+    SELECT song_id, song_name, album_name, artist_names, producer_names
+    FROM song_search_source WHERE song_id IN (SELECT song_id FROM song_producers WHERE producer_id = NEW.producer_id);
+END;
+
+CREATE TRIGGER IF NOT EXISTS song_search_producer_aliases_delete
+AFTER DELETE ON producer_aliases
+FOR EACH ROW
+BEGIN
+    DELETE FROM song_search WHERE rowid IN (SELECT song_id FROM song_producers WHERE producer_id = OLD.producer_id);
+    INSERT INTO song_search (rowid, song_name, album_name, artist_names, producer_names)
+    SELECT song_id, song_name, album_name, artist_names, producer_names
+    FROM song_search_source WHERE song_id IN (SELECT song_id FROM song_producers WHERE producer_id = OLD.producer_id);
+END;
@@ -0,0 +1,5 @@
+ALTER TABLE songs DROP COLUMN codec;
+ALTER TABLE songs DROP COLUMN bit_depth;
+ALTER TABLE songs DROP COLUMN channels;
+ALTER TABLE songs DROP COLUMN sample_rate;
+ALTER TABLE songs DROP COLUMN bitrate;
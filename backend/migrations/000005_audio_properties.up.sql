@@ -0,0 +1,5 @@
+ALTER TABLE songs ADD COLUMN bitrate INTEGER;
+ALTER TABLE songs ADD COLUMN sample_rate INTEGER;
+ALTER TABLE songs ADD COLUMN channels INTEGER;
+ALTER TABLE songs ADD COLUMN bit_depth INTEGER;
+ALTER TABLE songs ADD COLUMN codec TEXT;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_songs_content_hash;
+
+ALTER TABLE songs DROP COLUMN fingerprint;
+ALTER TABLE songs DROP COLUMN content_hash;
@@ -0,0 +1,4 @@
+ALTER TABLE songs ADD COLUMN content_hash TEXT;
+ALTER TABLE songs ADD COLUMN fingerprint BLOB;
+
+CREATE INDEX IF NOT EXISTS idx_songs_content_hash ON songs(content_hash);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_artist_aliases_artist_id;
+DROP TABLE IF EXISTS "artist_aliases";
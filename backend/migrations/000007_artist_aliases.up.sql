@@ -0,0 +1,10 @@
+-- Artist aliases table
+CREATE TABLE IF NOT EXISTS "artist_aliases" (
+    "id" INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    "artist_id" INTEGER NOT NULL,
+    "alias" TEXT NOT NULL UNIQUE,
+    "created_at" INTEGER,
+    FOREIGN KEY ("artist_id") REFERENCES "artists"("id") ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_artist_aliases_artist_id ON artist_aliases(artist_id);
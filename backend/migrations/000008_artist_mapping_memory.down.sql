@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_artist_mapping_memory_artist_id;
+DROP TABLE IF EXISTS "artist_mapping_memory";
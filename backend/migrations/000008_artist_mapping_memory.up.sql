@@ -0,0 +1,13 @@
+-- Remembered upload artist-mapping decisions, keyed by the lowercased raw
+-- credit string. kind 'artist' maps to artist_id; 'create_new' has no artist.
+CREATE TABLE IF NOT EXISTS "artist_mapping_memory" (
+    "id" INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    "raw_name" TEXT NOT NULL UNIQUE,
+    "artist_id" INTEGER,
+    "kind" TEXT NOT NULL CHECK ("kind" IN ('artist', 'create_new')),
+    "created_at" INTEGER,
+    "updated_at" INTEGER,
+    FOREIGN KEY ("artist_id") REFERENCES "artists"("id") ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_artist_mapping_memory_artist_id ON artist_mapping_memory(artist_id);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_inbox_files_status;
+DROP TABLE IF EXISTS "inbox_files";
+ALTER TABLE settings DROP COLUMN inbox_path;
@@ -0,0 +1,25 @@
+-- Watched inbox folder. inbox_path is the directory to watch (NULL disables
+-- it); inbox_files records every file seen there so it's processed once.
+ALTER TABLE settings ADD COLUMN inbox_path TEXT;
+
+-- status: 'imported' (song_id set), 'pending' (waiting for artist mapping or
+-- duplicate review; upload_path holds the copy and file_data/duplicates the
+-- extraction as JSON), 'failed' (error set), or 'discarded'. A file is
+-- processed again only if its size or modification time changes.
+CREATE TABLE IF NOT EXISTS "inbox_files" (
+    "id" INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    "source_path" TEXT NOT NULL UNIQUE,
+    "size" INTEGER NOT NULL,
+    "mod_time" INTEGER NOT NULL,
+    "status" TEXT NOT NULL CHECK ("status" IN ('imported', 'pending', 'failed', 'discarded')),
+    "upload_path" TEXT,
+    "file_data" TEXT,
+    "duplicates" TEXT,
+    "song_id" INTEGER,
+    "error" TEXT,
+    "created_at" INTEGER,
+    "updated_at" INTEGER,
+    FOREIGN KEY ("song_id") REFERENCES "songs"("id") ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_inbox_files_status ON inbox_files(status);
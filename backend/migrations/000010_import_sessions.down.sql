@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_import_sessions_status;
+DROP TABLE IF EXISTS "import_sessions";
@@ -0,0 +1,14 @@
+-- Two-phase upload sessions. A session is opened when files are saved and
+-- extracted and closed when songs are created from them (completed) or the
+-- upload is abandoned (cancelled, files deleted). result holds the
+-- UploadAndExtractResult as JSON so a pending session survives a restart.
+CREATE TABLE IF NOT EXISTS "import_sessions" (
+    "id" INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    "status" TEXT NOT NULL CHECK ("status" IN ('pending', 'completed', 'cancelled')),
+    "album_id" INTEGER,
+    "result" TEXT NOT NULL,
+    "created_at" INTEGER,
+    "updated_at" INTEGER
+);
+
+CREATE INDEX IF NOT EXISTS idx_import_sessions_status ON import_sessions(status);
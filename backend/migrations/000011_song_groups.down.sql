@@ -0,0 +1,3 @@
+ALTER TABLE settings DROP COLUMN collapse_song_variants;
+DROP TABLE IF EXISTS "song_variants";
+DROP TABLE IF EXISTS "song_groups";
@@ -0,0 +1,26 @@
+-- Variants of one logical track (snippets, rips, CDQ files, alternate takes,
+-- session files). A song belongs to at most one group; the group's primary
+-- song stands in for it when variants are collapsed.
+CREATE TABLE IF NOT EXISTS "song_groups" (
+    "id" INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    "name" TEXT,
+    "primary_song_id" INTEGER NOT NULL,
+    "created_at" INTEGER,
+    "updated_at" INTEGER,
+    FOREIGN KEY ("primary_song_id") REFERENCES "songs"("id")
+);
+
+CREATE TABLE IF NOT EXISTS "song_variants" (
+    "group_id" INTEGER NOT NULL,
+    "song_id" INTEGER NOT NULL UNIQUE,
+    "variant_type" TEXT NOT NULL DEFAULT 'other'
+        CHECK ("variant_type" IN ('original', 'og_file', 'snippet', 'alternate', 'session', 'other')),
+    "quality_label" TEXT,
+    "order" INTEGER DEFAULT 0,
+    "created_at" INTEGER,
+    PRIMARY KEY ("group_id", "song_id"),
+    FOREIGN KEY ("group_id") REFERENCES "song_groups"("id") ON DELETE CASCADE,
+    FOREIGN KEY ("song_id") REFERENCES "songs"("id") ON DELETE CASCADE
+);
+
+ALTER TABLE settings ADD COLUMN collapse_song_variants INTEGER DEFAULT 0 NOT NULL;
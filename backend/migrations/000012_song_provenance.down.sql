@@ -0,0 +1,8 @@
+ALTER TABLE settings DROP COLUMN write_provenance_tags;
+DROP INDEX IF EXISTS idx_songs_leak_date;
+DROP INDEX IF EXISTS idx_songs_leak_status;
+ALTER TABLE songs DROP COLUMN notes;
+ALTER TABLE songs DROP COLUMN leak_status;
+ALTER TABLE songs DROP COLUMN recording_date;
+ALTER TABLE songs DROP COLUMN leak_source;
+ALTER TABLE songs DROP COLUMN leak_date;
@@ -0,0 +1,12 @@
+-- Where and when a leak surfaced. Dates are ISO 8601 and may be partial
+-- ("2019", "2019-06", "2019-06-14") since leak dates often are.
+ALTER TABLE songs ADD COLUMN leak_date TEXT;
+ALTER TABLE songs ADD COLUMN leak_source TEXT;
+ALTER TABLE songs ADD COLUMN recording_date TEXT;
+ALTER TABLE songs ADD COLUMN leak_status TEXT CHECK ("leak_status" IN ('unreleased', 'leaked', 'released'));
+ALTER TABLE songs ADD COLUMN notes TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_songs_leak_status ON songs(leak_status);
+CREATE INDEX IF NOT EXISTS idx_songs_leak_date ON songs(leak_date);
+
+ALTER TABLE settings ADD COLUMN write_provenance_tags INTEGER DEFAULT 0 NOT NULL;
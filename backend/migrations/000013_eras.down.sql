@@ -0,0 +1,7 @@
+ALTER TABLE settings DROP COLUMN era_tag;
+DROP INDEX IF EXISTS idx_albums_era_id;
+DROP INDEX IF EXISTS idx_songs_era_id;
+ALTER TABLE albums DROP COLUMN era_id;
+ALTER TABLE songs DROP COLUMN era_id;
+DROP INDEX IF EXISTS idx_eras_artist_id;
+DROP TABLE IF EXISTS "eras";
@@ -0,0 +1,28 @@
+-- Eras group an artist's work above albums (e.g. a scrapped album cycle).
+-- Dates are ISO 8601 and may be partial, like songs.recording_date.
+CREATE TABLE IF NOT EXISTS "eras" (
+    "id" INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    "artist_id" INTEGER NOT NULL,
+    "name" TEXT NOT NULL,
+    "start_date" TEXT,
+    "end_date" TEXT,
+    "order" INTEGER DEFAULT 0,
+    "created_at" INTEGER,
+    "updated_at" INTEGER,
+    UNIQUE ("artist_id", "name"),
+    FOREIGN KEY ("artist_id") REFERENCES "artists"("id") ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_eras_artist_id ON eras(artist_id);
+
+-- explicit assignments; songs without one fall back to their album's era,
+-- then to the era their recording date falls in
+ALTER TABLE songs ADD COLUMN era_id INTEGER REFERENCES eras(id);
+ALTER TABLE albums ADD COLUMN era_id INTEGER REFERENCES eras(id);
+
+CREATE INDEX IF NOT EXISTS idx_songs_era_id ON songs(era_id);
+CREATE INDEX IF NOT EXISTS idx_albums_era_id ON albums(era_id);
+
+-- where era names go when metadata is written: nowhere, the grouping tag,
+-- or the album tag of songs without an album
+ALTER TABLE settings ADD COLUMN era_tag TEXT DEFAULT 'off' NOT NULL CHECK ("era_tag" IN ('off', 'grouping', 'album'));
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_playlist_songs_song_id;
+DROP TABLE IF EXISTS "playlist_songs";
+DROP TABLE IF EXISTS "playlists";
@@ -0,0 +1,22 @@
+-- Manual playlists list their songs in playlist_songs; smart playlists store
+-- a SmartPlaylistQuery as JSON and are evaluated when read.
+CREATE TABLE IF NOT EXISTS "playlists" (
+    "id" INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    "name" TEXT NOT NULL,
+    "kind" TEXT NOT NULL DEFAULT 'manual' CHECK ("kind" IN ('manual', 'smart')),
+    "query" TEXT,
+    "created_at" INTEGER,
+    "updated_at" INTEGER
+);
+
+CREATE TABLE IF NOT EXISTS "playlist_songs" (
+    "playlist_id" INTEGER NOT NULL,
+    "song_id" INTEGER NOT NULL,
+    "order" INTEGER DEFAULT 0,
+    "created_at" INTEGER,
+    PRIMARY KEY("playlist_id", "song_id"),
+    FOREIGN KEY ("playlist_id") REFERENCES "playlists"("id") ON DELETE CASCADE,
+    FOREIGN KEY ("song_id") REFERENCES "songs"("id") ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_playlist_songs_song_id ON playlist_songs(song_id);
@@ -0,0 +1,16 @@
+-- Filename templates pull song details out of untagged files' names. They
+-- are tried in order; the first enabled one that matches wins.
+CREATE TABLE IF NOT EXISTS "filename_templates" (
+    "id" INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    "name" TEXT NOT NULL,
+    "pattern" TEXT NOT NULL,
+    "enabled" BOOLEAN NOT NULL DEFAULT 1,
+    "order" INTEGER DEFAULT 0,
+    "created_at" INTEGER,
+    "updated_at" INTEGER
+);
+
+INSERT INTO filename_templates (name, pattern, "order", created_at, updated_at) VALUES
+    ('Track. Artist - Title', '{track}. {artist} - {title}', 0, strftime('%s', 'now'), strftime('%s', 'now')),
+    ('Track. Title', '{track}. {title}', 1, strftime('%s', 'now'), strftime('%s', 'now')),
+    ('Artist - Title', '{artist} - {title}', 2, strftime('%s', 'now'), strftime('%s', 'now'));
@@ -0,0 +1,2 @@
+ALTER TABLE settings DROP COLUMN featured_artist_style;
+ALTER TABLE song_artists DROP COLUMN role;
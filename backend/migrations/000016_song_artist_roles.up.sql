@@ -0,0 +1,7 @@
+-- How an artist is credited on a song. Featured artists and remixers are
+-- rendered into the artist and title tags per settings.featured_artist_style.
+ALTER TABLE song_artists ADD COLUMN role TEXT DEFAULT 'primary' NOT NULL CHECK ("role" IN ('primary', 'featured', 'remixer'));
+
+-- "list" joins every artist in the artist tag; "artist" writes
+-- "A feat. B"; "title" writes "A" and "Title (feat. B)"
+ALTER TABLE settings ADD COLUMN featured_artist_style TEXT DEFAULT 'list' NOT NULL CHECK ("featured_artist_style" IN ('list', 'artist', 'title'));
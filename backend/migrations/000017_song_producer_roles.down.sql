@@ -0,0 +1 @@
+ALTER TABLE song_producers DROP COLUMN role;
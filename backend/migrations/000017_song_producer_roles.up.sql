@@ -0,0 +1,3 @@
+-- What a producer did on a song. Written as an involvement list (ID3
+-- TIPL), one Vorbis comment per credit, or an MP4 freeform atom per role.
+ALTER TABLE song_producers ADD COLUMN role TEXT DEFAULT 'producer' NOT NULL CHECK ("role" IN ('producer', 'co_producer', 'additional_production', 'engineer', 'mixing'));
@@ -0,0 +1 @@
+ALTER TABLE settings DROP COLUMN tag_field_policies;
@@ -0,0 +1,4 @@
+-- What writing metadata does to each tag field: a JSON object of field ->
+-- "overwrite", "preserve" or "remove", holding only the fields changed
+-- from their default (see tag_policies.go).
+ALTER TABLE settings ADD COLUMN tag_field_policies TEXT DEFAULT '{}' NOT NULL;
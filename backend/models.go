@@ -18,6 +18,14 @@ type ExtractedMetadata struct {
 	Producer    string       `json:"producer"`
 	Duration    float64      `json:"duration"`
 	Artwork     *ArtworkData `json:"artwork"`
+	// Audio holds the probed stream properties; nil if the file couldn't be probed
+	Audio *AudioProperties `json:"audio"`
+	// ProducerCredits are the production credits read from the tags, in tag
+	// order; upload matches them to producers
+	ProducerCredits []ProducerCredit `json:"producerCredits"`
+	// Filename is what the filename templates found; it fills whichever of
+	// title, artist, producer, and track number the tags left empty
+	Filename *ParsedFilename `json:"filename"`
 }
 
 type BatchResult struct {
@@ -46,11 +54,21 @@ type Artist struct {
 	Synced          bool    `json:"synced"`
 }
 
-// ArtistWithRelations includes albums and songs
+// ArtistAlias is an alternate name resolved to an artist during upload
+// artist mapping (e.g. "Ye" for Kanye West)
+type ArtistAlias struct {
+	ID        int    `json:"id"`
+	ArtistID  int    `json:"artistId"`
+	Alias     string `json:"alias"`
+	CreatedAt int64  `json:"createdAt"`
+}
+
+// ArtistWithRelations includes albums, songs, and aliases
 type ArtistWithRelations struct {
 	Artist
-	Albums []Album `json:"albums"`
-	Songs  []Song  `json:"songs"`
+	Albums  []Album       `json:"albums"`
+	Songs   []Song        `json:"songs"`
+	Aliases []ArtistAlias `json:"aliases"`
 }
 
 // Album represents a music album
@@ -64,6 +82,7 @@ type Album struct {
 	CreatedAt   int64   `json:"createdAt"`
 	UpdatedAt   int64   `json:"updatedAt"`
 	Synced      bool    `json:"synced"`
+	EraID       *int    `json:"eraId"`
 }
 
 // AlbumWithArtists includes artist information
@@ -95,15 +114,104 @@ type Song struct {
 	UpdatedAt    int64    `json:"updatedAt"`
 	Synced       bool     `json:"synced"`
 	AppleMusicID *string  `json:"appleMusicId"`
+	Bitrate      *int     `json:"bitrate"` // kbps
+	SampleRate   *int     `json:"sampleRate"`
+	Channels     *int     `json:"channels"`
+	BitDepth     *int     `json:"bitDepth"`
+	Codec        *string  `json:"codec"`
+	Provenance
+	// EraID is the explicitly assigned era; see SongReadable.Era for the
+	// effective one
+	EraID *int `json:"eraId"`
 }
 
 // SongReadable includes formatted artist string for display
 type SongReadable struct {
 	Song
-	Artist    string     `json:"artist"`
-	Artists   []Artist   `json:"artists"`
-	Producers []Producer `json:"producers"`
-	Album     *Album     `json:"album"`
+	Artist    string         `json:"artist"`
+	Artists   []SongArtist   `json:"artists"`
+	Producers []SongProducer `json:"producers"`
+	Album     *Album         `json:"album"`
+	// Variant is set when the song belongs to a song group
+	Variant *SongVariantInfo `json:"variant"`
+	// Era is the song's effective era; EraSource says whether it was
+	// assigned to the song ("song"), to its album ("album"), or matched by
+	// recording date ("recording_date")
+	Era       *Era   `json:"era"`
+	EraSource string `json:"eraSource"`
+}
+
+// SongArtist is an artist credited on a song, with the credit's role:
+// "primary", "featured", or "remixer"
+type SongArtist struct {
+	Artist
+	Role string `json:"role"`
+}
+
+// SongProducer is a producer credited on a song, with the credit's role:
+// "producer", "co_producer", "additional_production", "engineer", or
+// "mixing"
+type SongProducer struct {
+	Producer
+	Role string `json:"role"`
+}
+
+// ProducerCredit is a production credit as it appears in a file's tags,
+// before it's matched to a producer
+type ProducerCredit struct {
+	Role string `json:"role"`
+	Name string `json:"name"`
+}
+
+// Era groups an artist's songs and albums above the album level, e.g. a
+// scrapped album cycle. Dates are ISO 8601 and may be partial.
+type Era struct {
+	ID        int     `json:"id"`
+	ArtistID  int     `json:"artistId"`
+	Name      string  `json:"name"`
+	StartDate *string `json:"startDate"`
+	EndDate   *string `json:"endDate"`
+	Order     int     `json:"order"`
+	CreatedAt int64   `json:"createdAt"`
+	UpdatedAt int64   `json:"updatedAt"`
+}
+
+// EraWithSongs is the era view: its albums and the songs whose effective
+// era it is, with their producers
+type EraWithSongs struct {
+	Era
+	Albums []Album        `json:"albums"`
+	Songs  []SongReadable `json:"songs"`
+}
+
+// SongVariantInfo describes a song's place in its song group
+type SongVariantInfo struct {
+	GroupID      int     `json:"groupId"`
+	VariantType  string  `json:"variantType"`
+	QualityLabel *string `json:"qualityLabel"`
+	IsPrimary    bool    `json:"isPrimary"`
+	VariantCount int     `json:"variantCount"`
+}
+
+// SongGroup links songs that are versions of one logical track (snippets,
+// rips, CDQ files, alternate takes, session files)
+type SongGroup struct {
+	ID            int           `json:"id"`
+	Name          *string       `json:"name"`
+	PrimarySongID int           `json:"primarySongId"`
+	Variants      []SongVariant `json:"variants"`
+	CreatedAt     int64         `json:"createdAt"`
+	UpdatedAt     int64         `json:"updatedAt"`
+}
+
+// SongVariant is one member of a song group
+type SongVariant struct {
+	SongID       int     `json:"songId"`
+	Name         string  `json:"name"`
+	Artist       string  `json:"artist"`
+	VariantType  string  `json:"variantType"`
+	QualityLabel *string `json:"qualityLabel"`
+	IsPrimary    bool    `json:"isPrimary"`
 }
 
 // AppleMusicTrack represents a track in the user's Apple Music library
@@ -154,11 +262,19 @@ type ProducerWithAliases struct {
 
 // Settings represents application settings
 type Settings struct {
-	ID                       int   `json:"id"`
-	ClearTrackNumberOnUpload bool  `json:"clearTrackNumberOnUpload"`
-	ImportToAppleMusic       bool  `json:"importToAppleMusic"`
-	AutomaticallyMakeSingles bool  `json:"automaticallyMakeSingles"`
-	UpdatedAt                int64 `json:"updatedAt"`
+	ID                       int     `json:"id"`
+	ClearTrackNumberOnUpload bool    `json:"clearTrackNumberOnUpload"`
+	ImportToAppleMusic       bool    `json:"importToAppleMusic"`
+	AutomaticallyMakeSingles bool    `json:"automaticallyMakeSingles"`
+	InboxPath                *string `json:"inboxPath"`
+	CollapseSongVariants     bool    `json:"collapseSongVariants"`
+	WriteProvenanceTags      bool    `json:"writeProvenanceTags"`
+	EraTag                   string  `json:"eraTag"`
+	FeaturedArtistStyle      string  `json:"featuredArtistStyle"`
+	// TagFieldPolicies maps every tag field to what writing metadata does
+	// to it: "overwrite", "preserve" or "remove"
+	TagFieldPolicies map[string]string `json:"tagFieldPolicies"`
+	UpdatedAt        int64             `json:"updatedAt"`
 }
 
 // InitialData is the payload returned for the main layout load
@@ -187,6 +303,21 @@ type CreateArtistInput struct {
 	CareerEndYear   *int   `json:"careerEndYear"`
 }
 
+type UpdateArtistInput struct {
+	ID   int     `json:"id"`
+	Name *string `json:"name"`
+	// Career years left nil are kept; 0 clears one
+	CareerStartYear *int `json:"careerStartYear"`
+	CareerEndYear   *int `json:"careerEndYear"`
+}
+
+// UpdateArtistResult is the updated artist and the metadata rewrite of its
+// songs, which is empty unless the name changed.
+type UpdateArtistResult struct {
+	Artist   Artist      `json:"artist"`
+	Metadata BatchResult `json:"metadata"`
+}
+
 type CreateAlbumInput struct {
 	Name      string  `json:"name"`
 	ArtistIDs []int   `json:"artistIds"`
@@ -214,6 +345,19 @@ type CreateSongInput struct {
 	Year        *int     `json:"year"`
 	TrackNumber *int     `json:"trackNumber"`
 	Duration    *float64 `json:"duration"`
+	FileType    *string  `json:"fileType"`
+	Bitrate     *int     `json:"bitrate"`
+	SampleRate  *int     `json:"sampleRate"`
+	Channels    *int     `json:"channels"`
+	BitDepth    *int     `json:"bitDepth"`
+	Codec       *string  `json:"codec"`
+	// ArtistRoles[i] is the role of ArtistIDs[i]; missing or empty roles
+	// are "primary"
+	ArtistRoles []string `json:"artistRoles"`
+	// ProducerRoles[i] is the role of ProducerIDs[i]; missing or empty
+	// roles are "producer"
+	ProducerRoles []string `json:"producerRoles"`
+	Provenance
 }
 
 type UpdateSongInput struct {
@@ -225,6 +369,22 @@ type UpdateSongInput struct {
 	ProducerIDs []int   `json:"producerIds"`
 	TrackNumber *int    `json:"trackNumber"`
 	IsSingle    bool    `json:"isSingle"`
+	// ArtistRoles goes with ArtistIDs, and ProducerRoles with ProducerIDs,
+	// as in CreateSongInput
+	ArtistRoles   []string `json:"artistRoles"`
+	ProducerRoles []string `json:"producerRoles"`
+	// Provenance fields left nil are kept; "" clears one
+	Provenance
+}
+
+// Provenance records where and when a leak surfaced. Dates are ISO 8601
+// and may be partial ("2019", "2019-06").
+type Provenance struct {
+	LeakDate      *string `json:"leakDate"`
+	LeakSource    *string `json:"leakSource"` // e.g. group buy, forum, snippet site
+	RecordingDate *string `json:"recordingDate"`
+	LeakStatus    *string `json:"leakStatus"` // unreleased, leaked, or released
+	Notes         *string `json:"notes"`
 }
 
 type AliasInput struct {
@@ -243,26 +403,245 @@ type UpdateProducerInput struct {
 	Aliases []AliasInput `json:"aliases"`
 }
 
+// SearchFilters narrows a Search. Nil fields are not applied.
+type SearchFilters struct {
+	YearFrom *int    `json:"yearFrom"`
+	YearTo   *int    `json:"yearTo"`
+	Genre    *string `json:"genre"`
+	FileType *string `json:"fileType"`
+	// IsSingle selects songs on singles (true) or on full albums (false).
+	// Songs without an album count as singles.
+	IsSingle *bool `json:"isSingle"`
+	// LeakStatus is unreleased, leaked, or released
+	LeakStatus *string `json:"leakStatus"`
+	LeakSource *string `json:"leakSource"`
+	// LeakedFrom/LeakedTo bound the leak date, inclusive of the whole
+	// period a partial date names: "2019" to "2019" matches all of 2019
+	LeakedFrom *string `json:"leakedFrom"`
+	LeakedTo   *string `json:"leakedTo"`
+	Limit      int     `json:"limit"`
+	Offset     int     `json:"offset"`
+}
+
 type UpdateSettingsInput struct {
 	ClearTrackNumberOnUpload *bool `json:"clearTrackNumberOnUpload"`
 	ImportToAppleMusic       *bool `json:"importToAppleMusic"`
 	AutomaticallyMakeSingles *bool `json:"automaticallyMakeSingles"`
+	// InboxPath sets the watched inbox folder; "" turns it off
+	InboxPath *string `json:"inboxPath"`
+	// CollapseSongVariants lists only the primary song of each song group
+	CollapseSongVariants *bool `json:"collapseSongVariants"`
+	// WriteProvenanceTags adds leak date, source, status, recording date,
+	// and notes to files as custom tags when metadata is written
+	WriteProvenanceTags *bool `json:"writeProvenanceTags"`
+	// EraTag writes era names to the grouping tag ("grouping"), to the
+	// album tag of songs without an album ("album"), or not at all ("off")
+	EraTag *string `json:"eraTag"`
+	// FeaturedArtistStyle renders featured artists and remixers into the
+	// written tags: every artist in the artist tag ("list"), "A feat. B" in
+	// the artist tag ("artist"), or "Title (feat. B)" ("title")
+	FeaturedArtistStyle *string `json:"featuredArtistStyle"`
+	// TagFieldPolicies changes the policies of the fields it names: title,
+	// artist, albumArtist, album, genre, year, track, producers, grouping,
+	// artwork, custom, or other (every tag the library doesn't manage,
+	// which can only be preserved or removed)
+	TagFieldPolicies map[string]string `json:"tagFieldPolicies"`
+}
+
+type CreateEraInput struct {
+	ArtistID  int     `json:"artistId"`
+	Name      string  `json:"name"`
+	StartDate *string `json:"startDate"`
+	EndDate   *string `json:"endDate"`
+}
+
+// UpdateEraInput keeps nil fields; an empty date opens that end of the range
+type UpdateEraInput struct {
+	ID        int     `json:"id"`
+	Name      *string `json:"name"`
+	StartDate *string `json:"startDate"`
+	EndDate   *string `json:"endDate"`
+}
+
+// GroupSongsInput groups songs as variants of one track. Without GroupID a
+// new group is created; songs already in another group are moved.
+type GroupSongsInput struct {
+	SongIDs       []int   `json:"songIds"`
+	GroupID       *int    `json:"groupId"`
+	PrimarySongID *int    `json:"primarySongId"`
+	Name          *string `json:"name"`
+}
+
+// UpdateSongVariantInput changes how a grouped song is labelled; an empty
+// QualityLabel clears it
+type UpdateSongVariantInput struct {
+	SongID       int     `json:"songId"`
+	VariantType  *string `json:"variantType"`
+	QualityLabel *string `json:"qualityLabel"`
+}
+
+// Playlist is an ordered set of songs: listed by hand ("manual") or the
+// result of a saved query ("smart")
+type Playlist struct {
+	ID        int                 `json:"id"`
+	Name      string              `json:"name"`
+	Kind      string              `json:"kind"`
+	Query     *SmartPlaylistQuery `json:"query"`
+	SongCount int                 `json:"songCount"`
+	CreatedAt int64               `json:"createdAt"`
+	UpdatedAt int64               `json:"updatedAt"`
+}
+
+type PlaylistWithSongs struct {
+	Playlist
+	Songs []SongReadable `json:"songs"`
+}
+
+// SmartPlaylistQuery selects songs matching every set field. A song matches
+// ArtistIDs or ProducerIDs when it credits any of them. Limit 0 means no
+// limit.
+type SmartPlaylistQuery struct {
+	Genre       *string `json:"genre"`
+	YearFrom    *int    `json:"yearFrom"`
+	YearTo      *int    `json:"yearTo"`
+	ArtistIDs   []int   `json:"artistIds"`
+	ProducerIDs []int   `json:"producerIds"`
+	Synced      *bool   `json:"synced"`
+	Limit       int     `json:"limit"`
+}
+
+// CreatePlaylistInput makes a smart playlist when Query is set, otherwise
+// a manual one holding SongIDs in order
+type CreatePlaylistInput struct {
+	Name    string              `json:"name"`
+	SongIDs []int               `json:"songIds"`
+	Query   *SmartPlaylistQuery `json:"query"`
+}
+
+// UpdatePlaylistInput renames a playlist or replaces a smart playlist's
+// query
+type UpdatePlaylistInput struct {
+	ID    int                 `json:"id"`
+	Name  *string             `json:"name"`
+	Query *SmartPlaylistQuery `json:"query"`
+}
+
+// FilenameTemplate is a filename pattern like "{artist} - {title}". See
+// filename_templates.go for the placeholders.
+type FilenameTemplate struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	Pattern   string `json:"pattern"`
+	Enabled   bool   `json:"enabled"`
+	Order     int    `json:"order"`
+	CreatedAt int64  `json:"createdAt"`
+	UpdatedAt int64  `json:"updatedAt"`
+}
+
+type CreateFilenameTemplateInput struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+}
+
+type UpdateFilenameTemplateInput struct {
+	ID      int     `json:"id"`
+	Name    *string `json:"name"`
+	Pattern *string `json:"pattern"`
+	Enabled *bool   `json:"enabled"`
+}
+
+// ParsedFilename is the song details found in a filename. Template is the
+// pattern that matched, empty if none did (Title is then the cleaned name).
+type ParsedFilename struct {
+	Template        string   `json:"template"`
+	Title           string   `json:"title"`
+	Artists         []string `json:"artists"`
+	FeaturedArtists []string `json:"featuredArtists"`
+	Producers       []string `json:"producers"`
+	Version         string   `json:"version"`
+	TrackNumber     int      `json:"trackNumber"`
 }
 
 // FileData represents uploaded file data for metadata extraction workflow
 type FileData struct {
-	OriginalFilename   string            `json:"originalFilename"`
-	Filepath           string            `json:"filepath"`
-	Metadata           ExtractedMetadata `json:"metadata"`
-	ParsedArtists      []string          `json:"parsedArtists"`
-	HasUnmappedArtists bool              `json:"hasUnmappedArtists"`
-	AlbumID            *int              `json:"albumId"`
+	OriginalFilename string            `json:"originalFilename"`
+	Filepath         string            `json:"filepath"`
+	Metadata         ExtractedMetadata `json:"metadata"`
+	ParsedArtists    []string          `json:"parsedArtists"`
+	// ArtistRoles[i] is the role ParsedArtists[i] was credited with
+	ArtistRoles        []string `json:"artistRoles"`
+	HasUnmappedArtists bool     `json:"hasUnmappedArtists"`
+	AlbumID            *int     `json:"albumId"`
 }
 
 type UploadAndExtractResult struct {
-	FilesData        []FileData `json:"filesData"`
-	UnmappedArtists  []string   `json:"unmappedArtists"`
-	FilesWithArtwork int        `json:"filesWithArtwork"`
+	FilesData          []FileData          `json:"filesData"`
+	UnmappedArtists    []string            `json:"unmappedArtists"`
+	FilesWithArtwork   int                 `json:"filesWithArtwork"`
+	PossibleDuplicates []PossibleDuplicate `json:"possibleDuplicates"`
+	// UnfingerprintedFiles were only checked for exact copies: their format
+	// needs ffmpeg to decode, or decoding failed
+	UnfingerprintedFiles []string `json:"unfingerprintedFiles"`
+	// FingerprintingUnavailable is set when some of UnfingerprintedFiles
+	// weren't decoded because ffmpeg isn't installed
+	FingerprintingUnavailable bool `json:"fingerprintingUnavailable"`
+	// ArtistMapping is pre-filled from remembered decisions, in the same shape
+	// as CreateSongsWithMetadataInput.ArtistMapping
+	ArtistMapping map[string]any `json:"artistMapping"`
+	// SessionID identifies the import session holding these files; pass it
+	// back to CreateSongsWithMetadata or CancelImportSession
+	SessionID int `json:"sessionId"`
+}
+
+// ImportSession is a saved-but-not-yet-created upload, resumable after a
+// restart. Status is "pending", "completed", or "cancelled".
+type ImportSession struct {
+	ID        int                    `json:"id"`
+	Status    string                 `json:"status"`
+	AlbumID   *int                   `json:"albumId"`
+	Result    UploadAndExtractResult `json:"result"`
+	CreatedAt int64                  `json:"createdAt"`
+	UpdatedAt int64                  `json:"updatedAt"`
+}
+
+// RememberedArtistMapping is a stored upload artist-mapping decision. Kind is
+// "artist" (ArtistID set) or "create_new".
+type RememberedArtistMapping struct {
+	ID         int     `json:"id"`
+	RawName    string  `json:"rawName"`
+	Kind       string  `json:"kind"`
+	ArtistID   *int    `json:"artistId"`
+	ArtistName *string `json:"artistName"`
+	CreatedAt  int64   `json:"createdAt"`
+	UpdatedAt  int64   `json:"updatedAt"`
+}
+
+// PossibleDuplicate points an uploaded file at an existing song it appears to
+// duplicate. MatchType is "exact" (same content hash) or "acoustic"
+// (fingerprint match, Similarity is 1 - bit error rate).
+type PossibleDuplicate struct {
+	Filepath   string  `json:"filepath"`
+	SongID     int     `json:"songId"`
+	SongName   string  `json:"songName"`
+	MatchType  string  `json:"matchType"`
+	Similarity float64 `json:"similarity"`
+}
+
+// DuplicateGroup is a set of library songs that are the same file or
+// recording. Similarity is the weakest match within the group.
+type DuplicateGroup struct {
+	Songs      []SongReadable `json:"songs"`
+	MatchType  string         `json:"matchType"`
+	Similarity float64        `json:"similarity"`
+}
+
+// DuplicateScan is the result of FindDuplicateSongs. UnfingerprintedSongs
+// were only compared by content hash; FingerprintingUnavailable is set when
+// some of them weren't decoded because ffmpeg isn't installed.
+type DuplicateScan struct {
+	Groups                    []DuplicateGroup `json:"groups"`
+	UnfingerprintedSongs      int              `json:"unfingerprintedSongs"`
+	FingerprintingUnavailable bool             `json:"fingerprintingUnavailable"`
 }
 
 type CreateSongsWithMetadataInput struct {
@@ -270,6 +649,343 @@ type CreateSongsWithMetadataInput struct {
 	ArtistMapping      map[string]any `json:"artistMapping"` // string -> int or "CREATE_NEW"
 	AlbumID            *int           `json:"albumId"`
 	UseEmbeddedArtwork bool           `json:"useEmbeddedArtwork"`
+	// SessionID completes the import session the files came from; session
+	// files left out of FilesData are deleted
+	SessionID *int `json:"sessionId"`
+}
+
+// ImportFromPathsInput names files or directories already on disk. HardLink
+// links files into uploads instead of copying them when both are on the same
+// filesystem; linked files aren't tagged when their songs are created, since
+// writing tags replaces the file and breaks the link.
+type ImportFromPathsInput struct {
+	Paths    []string `json:"paths"`
+	AlbumID  *int     `json:"albumId"`
+	HardLink bool     `json:"hardLink"`
+}
+
+// InboxItem is a file from the watched inbox folder that wasn't imported on
+// its own because a credit didn't resolve or it may duplicate a library song.
+// UnmappedArtists is worked out on every read, so artists added since drop out.
+type InboxItem struct {
+	ID                 int                 `json:"id"`
+	SourcePath         string              `json:"sourcePath"`
+	FileData           FileData            `json:"fileData"`
+	UnmappedArtists    []string            `json:"unmappedArtists"`
+	PossibleDuplicates []PossibleDuplicate `json:"possibleDuplicates"`
+	CreatedAt          int64               `json:"createdAt"`
+}
+
+// ResolveInboxItemInput finishes a pending inbox item, with the same mapping
+// shape as CreateSongsWithMetadataInput.
+type ResolveInboxItemInput struct {
+	ID                 int            `json:"id"`
+	ArtistMapping      map[string]any `json:"artistMapping"` // string -> int or "CREATE_NEW"
+	AlbumID            *int           `json:"albumId"`
+	UseEmbeddedArtwork bool           `json:"useEmbeddedArtwork"`
+}
+
+// LibraryExport is the versioned JSON document written by ExportLibrary.
+// Relationships refer to the exporting database's IDs, which ImportLibrary
+// maps onto the target database; ordered lists keep their order.
+type LibraryExport struct {
+	Version    int                 `json:"version"`
+	ExportedAt int64               `json:"exportedAt"`
+	Artists    []ExportedArtist    `json:"artists"`
+	Albums     []ExportedAlbum     `json:"albums"`
+	Eras       []ExportedEra       `json:"eras"`
+	Producers  []ExportedProducer  `json:"producers"`
+	Songs      []ExportedSong      `json:"songs"`
+	SongGroups []ExportedSongGroup `json:"songGroups"`
+	Playlists  []ExportedPlaylist  `json:"playlists"`
+	Settings   ExportedSettings    `json:"settings"`
+}
+
+type ExportedArtist struct {
+	ID              int      `json:"id"`
+	Name            string   `json:"name"`
+	Image           *string  `json:"image"`
+	CareerStartYear *int     `json:"careerStartYear"`
+	CareerEndYear   *int     `json:"careerEndYear"`
+	Aliases         []string `json:"aliases"`
+}
+
+type ExportedAlbum struct {
+	ID          int     `json:"id"`
+	Name        string  `json:"name"`
+	ArtistIDs   []int   `json:"artistIds"`
+	ArtworkPath *string `json:"artworkPath"`
+	Genre       *string `json:"genre"`
+	Year        *int    `json:"year"`
+	IsSingle    bool    `json:"isSingle"`
+	EraID       *int    `json:"eraId"`
+}
+
+// ExportedEra lists an artist's eras in order
+type ExportedEra struct {
+	ID        int     `json:"id"`
+	ArtistID  int     `json:"artistId"`
+	Name      string  `json:"name"`
+	StartDate *string `json:"startDate"`
+	EndDate   *string `json:"endDate"`
+}
+
+type ExportedProducer struct {
+	ID      int          `json:"id"`
+	Name    string       `json:"name"`
+	Aliases []AliasInput `json:"aliases"`
+}
+
+type ExportedSong struct {
+	ID          int      `json:"id"`
+	Name        string   `json:"name"`
+	ArtistIDs   []int    `json:"artistIds"`
+	ProducerIDs []int    `json:"producerIds"`
+	AlbumID     *int     `json:"albumId"`
+	Filepath    string   `json:"filepath"`
+	ArtworkPath *string  `json:"artworkPath"`
+	Genre       *string  `json:"genre"`
+	Year        *int     `json:"year"`
+	TrackNumber *int     `json:"trackNumber"`
+	Duration    *float64 `json:"duration"`
+	FileType    *string  `json:"fileType"`
+	Bitrate     *int     `json:"bitrate"`
+	SampleRate  *int     `json:"sampleRate"`
+	Channels    *int     `json:"channels"`
+	BitDepth    *int     `json:"bitDepth"`
+	Codec       *string  `json:"codec"`
+	Provenance
+	EraID         *int     `json:"eraId"`
+	ArtistRoles   []string `json:"artistRoles"`
+	ProducerRoles []string `json:"producerRoles"`
+}
+
+// ExportedSongGroup lists its variants in group order; SongIDs refer to
+// ExportedSong IDs
+type ExportedSongGroup struct {
+	Name          *string           `json:"name"`
+	PrimarySongID int               `json:"primarySongId"`
+	Variants      []ExportedVariant `json:"variants"`
+}
+
+type ExportedVariant struct {
+	SongID       int     `json:"songId"`
+	VariantType  string  `json:"variantType"`
+	QualityLabel *string `json:"qualityLabel"`
+}
+
+// ExportedPlaylist lists a manual playlist's songs in order; a smart
+// playlist has only its query, whose IDs refer to exported records
+type ExportedPlaylist struct {
+	Name    string              `json:"name"`
+	Kind    string              `json:"kind"`
+	Query   *SmartPlaylistQuery `json:"query"`
+	SongIDs []int               `json:"songIds"`
+}
+
+// ExportedSettings leaves out the inbox folder, which only means something
+// on the machine it was set on.
+type ExportedSettings struct {
+	ClearTrackNumberOnUpload bool              `json:"clearTrackNumberOnUpload"`
+	ImportToAppleMusic       bool              `json:"importToAppleMusic"`
+	AutomaticallyMakeSingles bool              `json:"automaticallyMakeSingles"`
+	CollapseSongVariants     bool              `json:"collapseSongVariants"`
+	WriteProvenanceTags      bool              `json:"writeProvenanceTags"`
+	EraTag                   string            `json:"eraTag"`
+	FeaturedArtistStyle      string            `json:"featuredArtistStyle"`
+	TagFieldPolicies         map[string]string `json:"tagFieldPolicies"`
+}
+
+// ImportLibraryInput names a LibraryExport JSON file. ApplySettings replaces
+// the current settings with the exported ones.
+type ImportLibraryInput struct {
+	Path          string `json:"path"`
+	ApplySettings bool   `json:"applySettings"`
+}
+
+// LibraryImportResult counts what ImportLibrary created and what it matched
+// to records already in the database.
+type LibraryImportResult struct {
+	ArtistsCreated   int `json:"artistsCreated"`
+	ArtistsMatched   int `json:"artistsMatched"`
+	AlbumsCreated    int `json:"albumsCreated"`
+	AlbumsMatched    int `json:"albumsMatched"`
+	ProducersCreated int `json:"producersCreated"`
+	ProducersMatched int `json:"producersMatched"`
+	SongsCreated     int `json:"songsCreated"`
+	SongsMatched     int `json:"songsMatched"`
+	ErasCreated      int `json:"erasCreated"`
+	ErasMatched      int `json:"erasMatched"`
+	SongGroups       int `json:"songGroups"`
+	Playlists        int `json:"playlists"`
+	// Warnings lists records skipped or partially merged (e.g. an alias
+	// already taken by another artist)
+	Warnings []string `json:"warnings"`
+}
+
+// BackupManifest describes a backup archive: the schema version of its
+// database snapshot and a checksum for every file in it.
+type BackupManifest struct {
+	FormatVersion    int          `json:"formatVersion"`
+	CreatedAt        int64        `json:"createdAt"`
+	MigrationVersion uint         `json:"migrationVersion"`
+	Files            []BackupFile `json:"files"`
+	// Missing lists upload paths the database referenced that weren't on
+	// disk when the backup was made
+	Missing []string `json:"missing"`
+}
+
+type BackupFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// IntegrityReport is what CheckLibraryIntegrity found wrong with the library.
+type IntegrityReport struct {
+	MissingFiles              []MissingFile              `json:"missingFiles"`
+	OrphanedUploads           []OrphanedUpload           `json:"orphanedUploads"`
+	SongsWithoutArtists       []IntegritySong            `json:"songsWithoutArtists"`
+	EmptyAlbums               []IntegrityAlbum           `json:"emptyAlbums"`
+	DanglingAliasRestrictions []DanglingAliasRestriction `json:"danglingAliasRestrictions"`
+	// DanglingLinks counts other rows pointing at deleted records, by table
+	DanglingLinks map[string]int `json:"danglingLinks"`
+}
+
+// MissingFile is a database path with no file behind it. Kind is "song",
+// "song_artwork", "album_artwork", or "artist_image"; ID is that record's.
+type MissingFile struct {
+	Kind string `json:"kind"`
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+// OrphanedUpload is a file under uploads/ that nothing references.
+type OrphanedUpload struct {
+	Path       string `json:"path"`
+	Size       int64  `json:"size"`
+	ModifiedAt int64  `json:"modifiedAt"`
+}
+
+type IntegritySong struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	AlbumID *int   `json:"albumId"`
+}
+
+type IntegrityAlbum struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// DanglingAliasRestriction limits a producer alias to an artist, where the
+// alias, its producer, or the artist no longer exists.
+type DanglingAliasRestriction struct {
+	AliasID  int     `json:"aliasId"`
+	Alias    *string `json:"alias"`
+	ArtistID int     `json:"artistId"`
+}
+
+// MetadataDriftInput picks the songs CheckMetadataDrift and
+// RewriteDriftedMetadata look at: one song, an album's songs, or with
+// neither set, the whole library.
+type MetadataDriftInput struct {
+	SongID  *int `json:"songId"`
+	AlbumID *int `json:"albumId"`
+}
+
+// MetadataDriftReport lists the songs whose files disagree with the
+// database, and those that couldn't be checked.
+type MetadataDriftReport struct {
+	SongsChecked int         `json:"songsChecked"`
+	Songs        []SongDrift `json:"songs"`
+}
+
+// SongDrift is one song's drifted fields, or the error that kept it from
+// being checked (a missing file, an unsupported format, a broken tag).
+type SongDrift struct {
+	SongID   int          `json:"songId"`
+	SongName string       `json:"songName"`
+	Filepath string       `json:"filepath"`
+	Fields   []FieldDrift `json:"fields"`
+	Error    string       `json:"error,omitempty"`
+}
+
+// FieldDrift is a tag field whose value in the file isn't the one the
+// database would write. Field is one of the tag field policy names.
+type FieldDrift struct {
+	Field    string `json:"field"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+// RescanProposal is what RescanSongFromFile would change on a song to
+// match its file's tags, or the error that kept the file from being read.
+type RescanProposal struct {
+	SongID   int            `json:"songId"`
+	SongName string         `json:"songName"`
+	Filepath string         `json:"filepath"`
+	Changes  []RescanChange `json:"changes"`
+	Error    string         `json:"error,omitempty"`
+}
+
+// RescanChange is one proposed database change. Field is title, artist,
+// album, genre, year, track, producers, or artwork; Current and Proposed
+// are display values.
+type RescanChange struct {
+	Field    string `json:"field"`
+	Current  string `json:"current"`
+	Proposed string `json:"proposed"`
+	// NewArtists are credited artists that don't exist yet and are created
+	// if the change is accepted
+	NewArtists []string `json:"newArtists,omitempty"`
+	// UnmatchedProducers are credited names matching no producer or alias;
+	// they're left off the song
+	UnmatchedProducers []string `json:"unmatchedProducers,omitempty"`
+}
+
+// RescanLibraryReport lists the songs whose files propose changes, and
+// those that couldn't be read.
+type RescanLibraryReport struct {
+	SongsChecked int              `json:"songsChecked"`
+	Proposals    []RescanProposal `json:"proposals"`
+}
+
+// ApplyRescanInput accepts proposed changes song by song.
+type ApplyRescanInput struct {
+	Songs []AcceptedRescan `json:"songs"`
+}
+
+// AcceptedRescan names the fields of a song's proposal to apply.
+type AcceptedRescan struct {
+	SongID int      `json:"songId"`
+	Fields []string `json:"fields"`
+}
+
+// RepairLibraryInput picks which CheckLibraryIntegrity problems to fix.
+type RepairLibraryInput struct {
+	// DeleteOrphanedUploads skips files changed within the last hour, which
+	// may belong to an upload still being previewed
+	DeleteOrphanedUploads bool `json:"deleteOrphanedUploads"`
+	DeleteMissingSongs    bool `json:"deleteMissingSongs"`
+	ClearMissingArtwork   bool `json:"clearMissingArtwork"`
+	// InheritAlbumArtists credits songs without artists to their album's
+	// artists and writes the tags back
+	InheritAlbumArtists bool `json:"inheritAlbumArtists"`
+	DeleteEmptyAlbums   bool `json:"deleteEmptyAlbums"`
+	RemoveDanglingLinks bool `json:"removeDanglingLinks"`
+}
+
+type RepairResult struct {
+	OrphansDeleted       int         `json:"orphansDeleted"`
+	OrphansSkipped       int         `json:"orphansSkipped"`
+	SongsDeleted         int         `json:"songsDeleted"`
+	ArtworkCleared       int         `json:"artworkCleared"`
+	SongsGivenArtists    int         `json:"songsGivenArtists"`
+	AlbumsDeleted        int         `json:"albumsDeleted"`
+	DanglingLinksRemoved int         `json:"danglingLinksRemoved"`
+	Metadata             BatchResult `json:"metadata"`
 }
 
 type FileUpload struct {
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"runtime"
 	"strings"
 	"time"
@@ -159,3 +160,11 @@ func resolveAppPaths(ctx context.Context) (dbPath string, staticPath string, err
 
 	return filepath.Join(dataDir, "local.db"), dataDir, nil
 }
+
+var uploadTimestampPrefix = regexp.MustCompile(`^\d+-`)
+
+// originalUploadFilename recovers the name a file was uploaded under by
+// stripping the timestamp prefix newUploadPath adds.
+func originalUploadFilename(relPath string) string {
+	return uploadTimestampPrefix.ReplaceAllString(filepath.Base(filepath.FromSlash(relPath)), "")
+}
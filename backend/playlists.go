@@ -0,0 +1,553 @@
+package backend
+
+import (
+	"database/sql"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	wailsruntime "github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// --- Playlists ---
+
+const (
+	playlistManual = "manual"
+	playlistSmart  = "smart"
+)
+
+const (
+	playlistFormatM3U8 = "m3u8"
+	playlistFormatXSPF = "xspf"
+)
+
+// playlistSongsDir is what exported playlist entries are relative to, so a
+// playlist saved there plays as-is.
+var playlistSongsDir = filepath.Join(uploadsRoot, "songs")
+
+func (a *App) CreatePlaylist(input CreatePlaylistInput) (*PlaylistWithSongs, error) {
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		return nil, fmt.Errorf("playlist name is required")
+	}
+	kind := playlistManual
+	var query *string
+	if input.Query != nil {
+		kind = playlistSmart
+		data, err := json.Marshal(input.Query)
+		if err != nil {
+			return nil, err
+		}
+		q := string(data)
+		query = &q
+	}
+
+	now := time.Now().Unix()
+	var playlistID int64
+	err := a.InTx(func(tx *sql.Tx) error {
+		res, err := tx.Exec(`INSERT INTO playlists (name, kind, query, created_at, updated_at) VALUES (?, ?, ?, ?, ?)`,
+			name, kind, query, now, now)
+		if err != nil {
+			return err
+		}
+		playlistID, err = res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		if kind == playlistManual {
+			return addPlaylistSongsTx(tx, int(playlistID), input.SongIDs, now)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return a.GetPlaylistWithSongs(int(playlistID))
+}
+
+// UpdatePlaylist renames a playlist or replaces a smart playlist's query.
+func (a *App) UpdatePlaylist(input UpdatePlaylistInput) (*PlaylistWithSongs, error) {
+	playlist, err := a.getPlaylistByID(input.ID)
+	if err != nil {
+		return nil, err
+	}
+	if input.Query != nil && playlist.Kind != playlistSmart {
+		return nil, fmt.Errorf("playlist %d is not a smart playlist", input.ID)
+	}
+
+	now := time.Now().Unix()
+	err = a.InTx(func(tx *sql.Tx) error {
+		if input.Name != nil {
+			name := strings.TrimSpace(*input.Name)
+			if name == "" {
+				return fmt.Errorf("playlist name is required")
+			}
+			if _, err := tx.Exec(`UPDATE playlists SET name = ? WHERE id = ?`, name, input.ID); err != nil {
+				return err
+			}
+		}
+		if input.Query != nil {
+			data, err := json.Marshal(input.Query)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`UPDATE playlists SET query = ? WHERE id = ?`, string(data), input.ID); err != nil {
+				return err
+			}
+		}
+		_, err := tx.Exec(`UPDATE playlists SET updated_at = ? WHERE id = ?`, now, input.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return a.GetPlaylistWithSongs(input.ID)
+}
+
+func (a *App) DeletePlaylist(playlistID int) error {
+	return a.InTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM playlist_songs WHERE playlist_id = ?`, playlistID); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`DELETE FROM playlists WHERE id = ?`, playlistID)
+		return err
+	})
+}
+
+// GetPlaylists lists every playlist by name, with its current song count.
+func (a *App) GetPlaylists() ([]Playlist, error) {
+	rows, err := a.db.Query(`SELECT id FROM playlists ORDER BY LOWER(name), id`)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := scanIDs(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	playlists := []Playlist{}
+	for _, id := range ids {
+		playlist, err := a.getPlaylistByID(id)
+		if err != nil {
+			return nil, err
+		}
+		songIDs, err := a.playlistSongIDs(playlist)
+		if err != nil {
+			return nil, err
+		}
+		playlist.SongCount = len(songIDs)
+		playlists = append(playlists, *playlist)
+	}
+	return playlists, nil
+}
+
+// GetPlaylistWithSongs returns a playlist with its songs in order. A smart
+// playlist's songs are evaluated now.
+func (a *App) GetPlaylistWithSongs(playlistID int) (*PlaylistWithSongs, error) {
+	playlist, err := a.getPlaylistByID(playlistID)
+	if err != nil {
+		return nil, err
+	}
+	songIDs, err := a.playlistSongIDs(playlist)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &PlaylistWithSongs{Playlist: *playlist, Songs: []SongReadable{}}
+	for _, id := range songIDs {
+		song, err := a.GetSongReadable(id)
+		if err != nil {
+			return nil, err
+		}
+		if song != nil {
+			result.Songs = append(result.Songs, *song)
+		}
+	}
+	result.SongCount = len(result.Songs)
+	return result, nil
+}
+
+// AddSongsToPlaylist appends songs to a manual playlist; songs already on
+// it keep their place.
+func (a *App) AddSongsToPlaylist(playlistID int, songIDs []int) (*PlaylistWithSongs, error) {
+	if err := a.requireManualPlaylist(playlistID); err != nil {
+		return nil, err
+	}
+	now := time.Now().Unix()
+	if err := a.InTx(func(tx *sql.Tx) error {
+		if err := addPlaylistSongsTx(tx, playlistID, songIDs, now); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`UPDATE playlists SET updated_at = ? WHERE id = ?`, now, playlistID)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return a.GetPlaylistWithSongs(playlistID)
+}
+
+// RemoveSongsFromPlaylist drops songs from a manual playlist and closes the
+// gaps they leave in the order.
+func (a *App) RemoveSongsFromPlaylist(playlistID int, songIDs []int) (*PlaylistWithSongs, error) {
+	if err := a.requireManualPlaylist(playlistID); err != nil {
+		return nil, err
+	}
+	now := time.Now().Unix()
+	if err := a.InTx(func(tx *sql.Tx) error {
+		for _, id := range songIDs {
+			if _, err := tx.Exec(`DELETE FROM playlist_songs WHERE playlist_id = ? AND song_id = ?`, playlistID, id); err != nil {
+				return err
+			}
+		}
+		if err := renumberPlaylistTx(tx, playlistID); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`UPDATE playlists SET updated_at = ? WHERE id = ?`, now, playlistID)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return a.GetPlaylistWithSongs(playlistID)
+}
+
+// ReorderPlaylist sets the order of a manual playlist. songIDs must list
+// every song on it.
+func (a *App) ReorderPlaylist(playlistID int, songIDs []int) (*PlaylistWithSongs, error) {
+	if err := a.requireManualPlaylist(playlistID); err != nil {
+		return nil, err
+	}
+	rows, err := a.db.Query(`SELECT song_id FROM playlist_songs WHERE playlist_id = ?`, playlistID)
+	if err != nil {
+		return nil, err
+	}
+	current, err := scanIDs(rows)
+	if err != nil {
+		return nil, err
+	}
+	onPlaylist := make(map[int]bool)
+	for _, id := range current {
+		onPlaylist[id] = true
+	}
+	seen := make(map[int]bool)
+	for _, id := range songIDs {
+		if !onPlaylist[id] || seen[id] {
+			return nil, fmt.Errorf("song %d is not on playlist %d or is listed twice", id, playlistID)
+		}
+		seen[id] = true
+	}
+	if len(songIDs) != len(current) {
+		return nil, fmt.Errorf("expected all %d songs of playlist %d, got %d", len(current), playlistID, len(songIDs))
+	}
+
+	now := time.Now().Unix()
+	if err := a.InTx(func(tx *sql.Tx) error {
+		for i, id := range songIDs {
+			if _, err := tx.Exec(`UPDATE playlist_songs SET "order" = ? WHERE playlist_id = ? AND song_id = ?`, i, playlistID, id); err != nil {
+				return err
+			}
+		}
+		_, err := tx.Exec(`UPDATE playlists SET updated_at = ? WHERE id = ?`, now, playlistID)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return a.GetPlaylistWithSongs(playlistID)
+}
+
+// ExportPlaylist writes a playlist to path as "m3u8" or "xspf", with entries
+// relative to uploads/songs.
+func (a *App) ExportPlaylist(playlistID int, format, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := a.writePlaylist(f, playlistID, format); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
+
+// SelectPlaylistExportPath opens a native save dialog for ExportPlaylist.
+func (a *App) SelectPlaylistExportPath(playlistID int, format string) (string, error) {
+	playlist, err := a.getPlaylistByID(playlistID)
+	if err != nil {
+		return "", err
+	}
+	return wailsruntime.SaveFileDialog(a.ctx, wailsruntime.SaveDialogOptions{
+		Title:           "Export Playlist",
+		DefaultFilename: playlist.Name + "." + format,
+		Filters:         []wailsruntime.FileFilter{{DisplayName: strings.ToUpper(format) + " Playlists", Pattern: "*." + format}},
+	})
+}
+
+func (a *App) writePlaylist(w io.Writer, playlistID int, format string) error {
+	if format != playlistFormatM3U8 && format != playlistFormatXSPF {
+		return fmt.Errorf("unknown playlist format %q (want m3u8 or xspf)", format)
+	}
+	playlist, err := a.GetPlaylistWithSongs(playlistID)
+	if err != nil {
+		return err
+	}
+	if format == playlistFormatM3U8 {
+		return writeM3U8(w, playlist)
+	}
+	return writeXSPF(w, playlist)
+}
+
+func writeM3U8(w io.Writer, playlist *PlaylistWithSongs) error {
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	fmt.Fprintf(&b, "#PLAYLIST:%s\n", singleLine(playlist.Name))
+	for _, song := range playlist.Songs {
+		location, err := playlistEntryPath(song.Filepath)
+		if err != nil {
+			return err
+		}
+		seconds := -1
+		if song.Duration != nil {
+			seconds = int(math.Round(*song.Duration))
+		}
+		display := song.Name
+		if song.Artist != "" {
+			display = song.Artist + " - " + song.Name
+		}
+		fmt.Fprintf(&b, "#EXTINF:%d,%s\n%s\n", seconds, singleLine(display), location)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+type xspfPlaylist struct {
+	XMLName   xml.Name    `xml:"playlist"`
+	Version   string      `xml:"version,attr"`
+	Namespace string      `xml:"xmlns,attr"`
+	Title     string      `xml:"title"`
+	Tracks    []xspfTrack `xml:"trackList>track"`
+}
+
+type xspfTrack struct {
+	Location string `xml:"location"`
+	Title    string `xml:"title,omitempty"`
+	Creator  string `xml:"creator,omitempty"`
+	Album    string `xml:"album,omitempty"`
+	TrackNum int    `xml:"trackNum,omitempty"`
+	Duration int64  `xml:"duration,omitempty"` // milliseconds
+}
+
+func writeXSPF(w io.Writer, playlist *PlaylistWithSongs) error {
+	doc := xspfPlaylist{Version: "1", Namespace: "http://xspf.org/ns/0/", Title: playlist.Name, Tracks: []xspfTrack{}}
+	for _, song := range playlist.Songs {
+		location, err := playlistEntryPath(song.Filepath)
+		if err != nil {
+			return err
+		}
+		// XSPF locations are URIs; a relative one resolves against the file
+		segments := strings.Split(location, "/")
+		for i, segment := range segments {
+			segments[i] = url.PathEscape(segment)
+		}
+		track := xspfTrack{Location: strings.Join(segments, "/"), Title: song.Name, Creator: song.Artist}
+		if song.Album != nil {
+			track.Album = song.Album.Name
+		}
+		if song.TrackNumber != nil {
+			track.TrackNum = *song.TrackNumber
+		}
+		if song.Duration != nil {
+			track.Duration = int64(math.Round(*song.Duration * 1000))
+		}
+		doc.Tracks = append(doc.Tracks, track)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// playlistEntryPath makes a song's path relative to uploads/songs, with
+// forward slashes.
+func playlistEntryPath(songPath string) (string, error) {
+	cleaned, err := normalizeUploadRelPath(songPath)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(playlistSongsDir, cleaned)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+func singleLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func (a *App) getPlaylistByID(playlistID int) (*Playlist, error) {
+	var playlist Playlist
+	var query sql.NullString
+	var createdAt, updatedAt sql.NullInt64
+	err := a.db.QueryRow(`SELECT id, name, kind, query, created_at, updated_at FROM playlists WHERE id = ?`, playlistID).
+		Scan(&playlist.ID, &playlist.Name, &playlist.Kind, &query, &createdAt, &updatedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("playlist %d not found", playlistID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if query.Valid {
+		var q SmartPlaylistQuery
+		if err := json.Unmarshal([]byte(query.String), &q); err != nil {
+			return nil, fmt.Errorf("playlist %d: %w", playlistID, err)
+		}
+		playlist.Query = &q
+	}
+	playlist.CreatedAt = createdAt.Int64
+	playlist.UpdatedAt = updatedAt.Int64
+	return &playlist, nil
+}
+
+func (a *App) requireManualPlaylist(playlistID int) error {
+	playlist, err := a.getPlaylistByID(playlistID)
+	if err != nil {
+		return err
+	}
+	if playlist.Kind != playlistManual {
+		return fmt.Errorf("playlist %d is a smart playlist; edit its query instead", playlistID)
+	}
+	return nil
+}
+
+// playlistSongIDs lists a manual playlist's songs in order, or runs a smart
+// playlist's query.
+func (a *App) playlistSongIDs(playlist *Playlist) ([]int, error) {
+	if playlist.Kind == playlistManual || playlist.Query == nil {
+		rows, err := a.db.Query(`
+			SELECT ps.song_id FROM playlist_songs ps JOIN songs s ON s.id = ps.song_id
+			WHERE ps.playlist_id = ? ORDER BY ps."order"
+		`, playlist.ID)
+		if err != nil {
+			return nil, err
+		}
+		return scanIDs(rows)
+	}
+	return a.smartPlaylistSongIDs(*playlist.Query)
+}
+
+// smartPlaylistSongIDs runs a smart query. Songs come out in release order:
+// by year, then album and track, then name.
+func (a *App) smartPlaylistSongIDs(q SmartPlaylistQuery) ([]int, error) {
+	where := []string{}
+	args := []any{}
+	if q.Genre != nil && strings.TrimSpace(*q.Genre) != "" {
+		where = append(where, "LOWER(COALESCE(s.genre, al.genre, '')) = LOWER(?)")
+		args = append(args, strings.TrimSpace(*q.Genre))
+	}
+	if q.YearFrom != nil {
+		where = append(where, "COALESCE(s.year, al.year) >= ?")
+		args = append(args, *q.YearFrom)
+	}
+	if q.YearTo != nil {
+		where = append(where, "COALESCE(s.year, al.year) <= ?")
+		args = append(args, *q.YearTo)
+	}
+	for _, link := range []struct {
+		table, column string
+		ids           []int
+	}{
+		{"song_artists", "artist_id", q.ArtistIDs},
+		{"song_producers", "producer_id", q.ProducerIDs},
+	} {
+		if len(link.ids) == 0 {
+			continue
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(link.ids)), ", ")
+		where = append(where, "s.id IN (SELECT song_id FROM "+link.table+" WHERE "+link.column+" IN ("+placeholders+"))")
+		for _, id := range link.ids {
+			args = append(args, id)
+		}
+	}
+	if q.Synced != nil {
+		where = append(where, "s.synced = ?")
+		args = append(args, *q.Synced)
+	}
+
+	whereClause := ""
+	if len(where) > 0 {
+		whereClause = "WHERE " + strings.Join(where, " AND ")
+	}
+	limitClause := ""
+	if q.Limit > 0 {
+		limitClause = "LIMIT ?"
+		args = append(args, q.Limit)
+	}
+	rows, err := a.db.Query(`
+		SELECT s.id FROM songs s
+		LEFT JOIN albums al ON al.id = s.album_id
+		`+whereClause+`
+		ORDER BY COALESCE(s.year, al.year) IS NULL, COALESCE(s.year, al.year), LOWER(COALESCE(al.name, '')), s.track_number, LOWER(s.name), s.id
+		`+limitClause, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanIDs(rows)
+}
+
+// addPlaylistSongsTx appends songs not already on the playlist.
+func addPlaylistSongsTx(tx *sql.Tx, playlistID int, songIDs []int, now int64) error {
+	var next int
+	if err := tx.QueryRow(`SELECT COALESCE(MAX("order"), -1) + 1 FROM playlist_songs WHERE playlist_id = ?`, playlistID).Scan(&next); err != nil {
+		return err
+	}
+	for _, id := range songIDs {
+		var exists int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM songs WHERE id = ?`, id).Scan(&exists); err != nil {
+			return err
+		}
+		if exists == 0 {
+			return fmt.Errorf("song %d not found", id)
+		}
+		res, err := tx.Exec(`INSERT OR IGNORE INTO playlist_songs (playlist_id, song_id, "order", created_at) VALUES (?, ?, ?, ?)`,
+			playlistID, id, next, now)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			next++
+		}
+	}
+	return nil
+}
+
+// renumberPlaylistTx closes gaps in a playlist's order.
+func renumberPlaylistTx(tx *sql.Tx, playlistID int) error {
+	rows, err := tx.Query(`SELECT song_id FROM playlist_songs WHERE playlist_id = ? ORDER BY "order"`, playlistID)
+	if err != nil {
+		return err
+	}
+	ids, err := scanIDs(rows)
+	if err != nil {
+		return err
+	}
+	for i, id := range ids {
+		if _, err := tx.Exec(`UPDATE playlist_songs SET "order" = ? WHERE playlist_id = ? AND song_id = ?`, i, playlistID, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package backend
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestManualPlaylistOrderAndExport(t *testing.T) {
+	app := newTestApp(t)
+
+	artist, err := app.CreateArtist(CreateArtistInput{Name: "Juice WRLD"})
+	if err != nil {
+		t.Fatalf("CreateArtist: %v", err)
+	}
+	duration := 185.4
+	var songs []*Song
+	for _, s := range []struct{ name, path string }{
+		{"Rich and Blind", "uploads/songs/rich and blind.mp3"},
+		{"Purple Moon", "uploads/songs/gbgr/purple-moon.mp3"},
+		{"Cavalier", "uploads/songs/cavalier.mp3"},
+	} {
+		song, err := app.CreateSong(CreateSongInput{Name: s.name, Filepath: s.path, ArtistIDs: []int{artist.ID}, Duration: &duration})
+		if err != nil {
+			t.Fatalf("CreateSong: %v", err)
+		}
+		songs = append(songs, song)
+	}
+
+	playlist, err := app.CreatePlaylist(CreatePlaylistInput{Name: "Night Drive", SongIDs: []int{songs[0].ID, songs[1].ID}})
+	if err != nil {
+		t.Fatalf("CreatePlaylist: %v", err)
+	}
+	if playlist.Kind != playlistManual || playlist.SongCount != 2 {
+		t.Fatalf("unexpected playlist: %+v", playlist.Playlist)
+	}
+	// a song already on the playlist keeps its place
+	playlist, err = app.AddSongsToPlaylist(playlist.ID, []int{songs[2].ID, songs[0].ID})
+	if err != nil {
+		t.Fatalf("AddSongsToPlaylist: %v", err)
+	}
+	if len(playlist.Songs) != 3 || playlist.Songs[2].ID != songs[2].ID {
+		t.Fatalf("expected the new song appended once, got %d songs", len(playlist.Songs))
+	}
+	if _, err := app.ReorderPlaylist(playlist.ID, []int{songs[2].ID, songs[0].ID}); err == nil {
+		t.Fatal("expected a partial reorder to be rejected")
+	}
+	playlist, err = app.ReorderPlaylist(playlist.ID, []int{songs[2].ID, songs[0].ID, songs[1].ID})
+	if err != nil {
+		t.Fatalf("ReorderPlaylist: %v", err)
+	}
+	playlist, err = app.RemoveSongsFromPlaylist(playlist.ID, []int{songs[0].ID})
+	if err != nil {
+		t.Fatalf("RemoveSongsFromPlaylist: %v", err)
+	}
+	if len(playlist.Songs) != 2 || playlist.Songs[0].ID != songs[2].ID || playlist.Songs[1].ID != songs[1].ID {
+		t.Fatalf("unexpected order after removal: %+v", playlist.Songs)
+	}
+
+	var m3u strings.Builder
+	if err := app.writePlaylist(&m3u, playlist.ID, playlistFormatM3U8); err != nil {
+		t.Fatalf("writePlaylist m3u8: %v", err)
+	}
+	want := "#EXTM3U\n#PLAYLIST:Night Drive\n" +
+		"#EXTINF:185,Juice WRLD - Cavalier\ncavalier.mp3\n" +
+		"#EXTINF:185,Juice WRLD - Purple Moon\ngbgr/purple-moon.mp3\n"
+	if m3u.String() != want {
+		t.Fatalf("unexpected m3u8:\n%s", m3u.String())
+	}
+
+	if _, err := app.AddSongsToPlaylist(playlist.ID, []int{songs[0].ID}); err != nil {
+		t.Fatalf("AddSongsToPlaylist: %v", err)
+	}
+	out := filepath.Join(t.TempDir(), "night.xspf")
+	if err := app.ExportPlaylist(playlist.ID, playlistFormatXSPF, out); err != nil {
+		t.Fatalf("ExportPlaylist xspf: %v", err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("read export: %v", err)
+	}
+	for _, fragment := range []string{`xmlns="http://xspf.org/ns/0/"`, "<location>rich%20and%20blind.mp3</location>", "<duration>185400</duration>"} {
+		if !strings.Contains(string(data), fragment) {
+			t.Errorf("expected %s in the xspf export:\n%s", fragment, data)
+		}
+	}
+	if err := app.ExportPlaylist(playlist.ID, "pls", out); err == nil {
+		t.Fatal("expected an unknown format to be rejected")
+	}
+
+	// deleting a song takes it off the playlist
+	if err := app.DeleteSong(songs[1].ID); err != nil {
+		t.Fatalf("DeleteSong: %v", err)
+	}
+	if playlist, _ = app.GetPlaylistWithSongs(playlist.ID); len(playlist.Songs) != 2 {
+		t.Fatalf("expected the deleted song to leave the playlist, got %d songs", len(playlist.Songs))
+	}
+	var dangling int
+	if err := app.db.QueryRow(`SELECT COUNT(*) FROM playlist_songs WHERE song_id = ?`, songs[1].ID).Scan(&dangling); err != nil || dangling != 0 {
+		t.Fatalf("expected no playlist rows for the deleted song, got %d (err %v)", dangling, err)
+	}
+}
+
+func TestSmartPlaylistQuery(t *testing.T) {
+	app := newTestApp(t)
+
+	carti, err := app.CreateArtist(CreateArtistInput{Name: "Playboi Carti"})
+	if err != nil {
+		t.Fatalf("CreateArtist: %v", err)
+	}
+	uzi, err := app.CreateArtist(CreateArtistInput{Name: "Lil Uzi Vert"})
+	if err != nil {
+		t.Fatalf("CreateArtist: %v", err)
+	}
+	genre := "Rap"
+	albumYear := 2018
+	album, err := app.CreateAlbum(CreateAlbumInput{Name: "Die Lit", ArtistIDs: []int{carti.ID}, Genre: &genre, Year: &albumYear})
+	if err != nil {
+		t.Fatalf("CreateAlbum: %v", err)
+	}
+	songYear := 2017
+	older, err := app.CreateSong(CreateSongInput{Name: "Kid Cudi", Filepath: "uploads/songs/kc.mp3", ArtistIDs: []int{carti.ID}, Genre: &genre, Year: &songYear})
+	if err != nil {
+		t.Fatalf("CreateSong: %v", err)
+	}
+	// year and genre come from the album
+	onAlbum, err := app.CreateSong(CreateSongInput{Name: "Shoota", Filepath: "uploads/songs/shoota.mp3", ArtistIDs: []int{carti.ID, uzi.ID}, AlbumID: &album.ID})
+	if err != nil {
+		t.Fatalf("CreateSong: %v", err)
+	}
+	if _, err := app.CreateSong(CreateSongInput{Name: "XO Tour Llif3", Filepath: "uploads/songs/xo.mp3", ArtistIDs: []int{uzi.ID}, Genre: &genre, Year: &songYear}); err != nil {
+		t.Fatalf("CreateSong: %v", err)
+	}
+
+	lower := "rap"
+	playlist, err := app.CreatePlaylist(CreatePlaylistInput{Name: "Carti Rap", Query: &SmartPlaylistQuery{Genre: &lower, ArtistIDs: []int{carti.ID}}})
+	if err != nil {
+		t.Fatalf("CreatePlaylist: %v", err)
+	}
+	if playlist.Kind != playlistSmart || len(playlist.Songs) != 2 || playlist.Songs[0].ID != older.ID || playlist.Songs[1].ID != onAlbum.ID {
+		t.Fatalf("unexpected smart playlist: %+v", playlist)
+	}
+	if _, err := app.AddSongsToPlaylist(playlist.ID, []int{older.ID}); err == nil {
+		t.Fatal("expected adding songs to a smart playlist to be rejected")
+	}
+
+	from := 2018
+	playlist, err = app.UpdatePlaylist(UpdatePlaylistInput{ID: playlist.ID, Query: &SmartPlaylistQuery{YearFrom: &from}})
+	if err != nil {
+		t.Fatalf("UpdatePlaylist: %v", err)
+	}
+	if len(playlist.Songs) != 1 || playlist.Songs[0].ID != onAlbum.ID {
+		t.Fatalf("expected only the album song from 2018 on, got %+v", playlist.Songs)
+	}
+
+	playlists, err := app.GetPlaylists()
+	if err != nil {
+		t.Fatalf("GetPlaylists: %v", err)
+	}
+	if len(playlists) != 1 || playlists[0].SongCount != 1 || playlists[0].Query == nil || *playlists[0].Query.YearFrom != 2018 {
+		t.Fatalf("unexpected playlists: %+v", playlists)
+	}
+	if err := app.DeletePlaylist(playlist.ID); err != nil {
+		t.Fatalf("DeletePlaylist: %v", err)
+	}
+	if playlists, _ := app.GetPlaylists(); len(playlists) != 0 {
+		t.Fatalf("expected no playlists after delete, got %d", len(playlists))
+	}
+}
@@ -0,0 +1,188 @@
+package backend
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// --- Song Producer Roles ---
+
+const (
+	producerRoleProducer             = "producer"
+	producerRoleCoProducer           = "co_producer"
+	producerRoleAdditionalProduction = "additional_production"
+	producerRoleEngineer             = "engineer"
+	producerRoleMixing               = "mixing"
+)
+
+var validProducerRoles = map[string]bool{
+	producerRoleProducer:             true,
+	producerRoleCoProducer:           true,
+	producerRoleAdditionalProduction: true,
+	producerRoleEngineer:             true,
+	producerRoleMixing:               true,
+}
+
+// producerCreditTags names each role in the tags, in the order credits
+// are written: involvement is the ID3 TIPL role and field the Vorbis
+// comment and MP4 freeform atom name.
+var producerCreditTags = []struct {
+	role, involvement, field string
+}{
+	{producerRoleProducer, "producer", "PRODUCER"},
+	{producerRoleCoProducer, "co-producer", "COPRODUCER"},
+	{producerRoleAdditionalProduction, "additional production", "ADDITIONALPRODUCER"},
+	{producerRoleEngineer, "engineer", "ENGINEER"},
+	{producerRoleMixing, "mix", "MIXER"},
+}
+
+// normalizeProducerRoles returns one role per producer ID, defaulting
+// missing and empty roles to producer.
+func normalizeProducerRoles(producerIDs []int, roles []string) ([]string, error) {
+	if len(roles) > len(producerIDs) {
+		return nil, fmt.Errorf("got %d producer roles for %d producers", len(roles), len(producerIDs))
+	}
+	out := make([]string, len(producerIDs))
+	for i := range producerIDs {
+		out[i] = producerRoleProducer
+		if i < len(roles) && strings.TrimSpace(roles[i]) != "" {
+			role := strings.ToLower(strings.TrimSpace(roles[i]))
+			if !validProducerRoles[role] {
+				return nil, fmt.Errorf("unknown producer role %q (want producer, co_producer, additional_production, engineer, or mixing)", roles[i])
+			}
+			out[i] = role
+		}
+	}
+	return out, nil
+}
+
+// linkSongProducersTx credits producerIDs on a song in order, with roles
+// from normalizeProducerRoles.
+func linkSongProducersTx(tx *sql.Tx, songID int64, producerIDs []int, roles []string, now int64) error {
+	for i, producerID := range producerIDs {
+		if _, err := tx.Exec(
+			`INSERT INTO song_producers (song_id, producer_id, "order", role, created_at) VALUES (?, ?, ?, ?, ?)`,
+			songID, producerID, i, roles[i], now,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// songProducerCredits returns the tag credits for a song's producers.
+func songProducerCredits(producers []SongProducer) []ProducerCredit {
+	credits := make([]ProducerCredit, len(producers))
+	for i, prod := range producers {
+		credits[i] = ProducerCredit{Role: prod.Role, Name: prod.Name}
+	}
+	return credits
+}
+
+// creditedProducers joins the names credited as producer, for the single
+// composer-style field every format also gets.
+func creditedProducers(credits []ProducerCredit) string {
+	names := []string{}
+	for _, credit := range credits {
+		if credit.Role == producerRoleProducer {
+			names = append(names, credit.Name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// producerCredits returns the tags' credits, or a producer credit per name
+// in Producers when there are none.
+func (t SongTags) producerCredits() []ProducerCredit {
+	if len(t.Credits) > 0 {
+		return t.Credits
+	}
+	credits := []ProducerCredit{}
+	for _, name := range strings.Split(t.Producers, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			credits = append(credits, ProducerCredit{Role: producerRoleProducer, Name: name})
+		}
+	}
+	return credits
+}
+
+// producerRoleForField maps a Vorbis comment or MP4 freeform atom name to
+// a role.
+func producerRoleForField(field string) (string, bool) {
+	for _, tag := range producerCreditTags {
+		if strings.EqualFold(field, tag.field) {
+			return tag.role, true
+		}
+	}
+	return "", false
+}
+
+func producerCreditField(role string) string {
+	for _, tag := range producerCreditTags {
+		if tag.role == role {
+			return tag.field
+		}
+	}
+	return ""
+}
+
+// involvementList encodes credits as a TIPL frame's NUL-separated
+// role/name pairs.
+func involvementList(credits []ProducerCredit) string {
+	parts := []string{}
+	for _, credit := range credits {
+		for _, tag := range producerCreditTags {
+			if tag.role == credit.Role {
+				parts = append(parts, tag.involvement, credit.Name)
+			}
+		}
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// parseInvolvementList reads the production credits out of a TIPL frame,
+// skipping the involvements that aren't production roles.
+func parseInvolvementList(text string) []ProducerCredit {
+	parts := strings.Split(text, "\x00")
+	credits := []ProducerCredit{}
+	for i := 0; i+1 < len(parts); i += 2 {
+		involvement := strings.ToLower(strings.TrimSpace(parts[i]))
+		name := strings.TrimSpace(parts[i+1])
+		if name == "" {
+			continue
+		}
+		for _, tag := range producerCreditTags {
+			if involvement == tag.involvement || involvement == tag.role {
+				credits = append(credits, ProducerCredit{Role: tag.role, Name: name})
+				break
+			}
+		}
+	}
+	return credits
+}
+
+// matchProducerCredits resolves tag credits to producers by exact name or
+// alias, under the same artist restrictions as filename matching. A
+// producer credited twice keeps its first role.
+func matchProducerCredits(credits []ProducerCredit, patterns []Pattern, songArtistIDs []int) ([]int, []string) {
+	ids, roles := []int{}, []string{}
+	seen := make(map[int]bool)
+	for _, credit := range credits {
+		for _, name := range ParseArtists(credit.Name) {
+			term := strings.ToLower(name)
+			for _, p := range patterns {
+				if p.Term != term || seen[p.ProducerID] {
+					continue
+				}
+				if p.IsAlias && !aliasAppliesTo(p.AliasArtistIDs, songArtistIDs) {
+					continue
+				}
+				seen[p.ProducerID] = true
+				ids = append(ids, p.ProducerID)
+				roles = append(roles, credit.Role)
+			}
+		}
+	}
+	return ids, roles
+}
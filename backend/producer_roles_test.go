@@ -0,0 +1,119 @@
+package backend
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestProducerCreditsRoundTripID3(t *testing.T) {
+	app := newTestApp(t)
+
+	var ids []int
+	for _, name := range []string{"Metro Boomin", "Southside", "Ethan Stevens"} {
+		prod, err := app.CreateProducerWithAliases(CreateProducerInput{Name: name})
+		if err != nil {
+			t.Fatalf("CreateProducerWithAliases: %v", err)
+		}
+		ids = append(ids, prod.ID)
+	}
+	if _, err := app.CreateSong(CreateSongInput{Name: "Bad", Filepath: "uploads/songs/bad.mp3", ProducerIDs: ids[:1], ProducerRoles: []string{"composer"}}); err == nil {
+		t.Fatal("expected an unknown role to be rejected")
+	}
+
+	relPath := "uploads/songs/mask-off.mp3"
+	fullPath := writeSilentMP3(t, app, relPath)
+	song, err := app.CreateSong(CreateSongInput{Name: "Mask Off", Filepath: relPath, ProducerIDs: ids,
+		ProducerRoles: []string{"", producerRoleCoProducer, producerRoleMixing}})
+	if err != nil {
+		t.Fatalf("CreateSong: %v", err)
+	}
+	if res, _ := app.WriteSongMetadata(song.ID); !res.Success {
+		t.Fatalf("WriteSongMetadata: %s", res.Error)
+	}
+	tags, err := (id3Adapter{}).Read(fullPath)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	want := []ProducerCredit{
+		{Role: producerRoleProducer, Name: "Metro Boomin"},
+		{Role: producerRoleCoProducer, Name: "Southside"},
+		{Role: producerRoleMixing, Name: "Ethan Stevens"},
+	}
+	if tags.Producers != "Metro Boomin" || !reflect.DeepEqual(tags.Credits, want) {
+		t.Fatalf("unexpected credits: %q %+v", tags.Producers, tags.Credits)
+	}
+
+	metadata, err := app.ExtractMetadata(relPath)
+	if err != nil {
+		t.Fatalf("ExtractMetadata: %v", err)
+	}
+	if metadata.Producer != "Metro Boomin" || !reflect.DeepEqual(metadata.ProducerCredits, want) {
+		t.Fatalf("expected the credits as suggestions, got %q %+v", metadata.Producer, metadata.ProducerCredits)
+	}
+}
+
+func TestUploadMatchesProducerCredits(t *testing.T) {
+	app := newTestApp(t)
+
+	var ids []int
+	for _, name := range []string{"Metro Boomin", "Southside", "Wheezy"} {
+		prod, err := app.CreateProducerWithAliases(CreateProducerInput{Name: name})
+		if err != nil {
+			t.Fatalf("CreateProducerWithAliases: %v", err)
+		}
+		ids = append(ids, prod.ID)
+	}
+
+	relPath := "uploads/songs/1700000000000-Mask Off (prod. Wheezy).mp3"
+	fullPath := writeSilentMP3(t, app, relPath)
+	if err := (id3Adapter{}).Write(fullPath, SongTags{Title: "Mask Off", Credits: []ProducerCredit{
+		{Role: producerRoleProducer, Name: "metro boomin"},
+		{Role: producerRoleCoProducer, Name: "Southside"},
+		{Role: producerRoleEngineer, Name: "Somebody Unknown"},
+	}}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	metadata, err := app.ExtractMetadata(relPath)
+	if err != nil {
+		t.Fatalf("ExtractMetadata: %v", err)
+	}
+	settings, err := app.GetSettings()
+	if err != nil {
+		t.Fatalf("GetSettings: %v", err)
+	}
+	songs, err := app.createSongsFromSpecs([]songCreationSpec{{
+		Filepath: relPath, OriginalFilename: "Mask Off (prod. Wheezy).mp3", Metadata: *metadata, MatchProducers: true,
+	}}, settings)
+	if err != nil {
+		t.Fatalf("createSongsFromSpecs: %v", err)
+	}
+	readable, err := app.GetSongReadable(songs[0].ID)
+	if err != nil {
+		t.Fatalf("GetSongReadable: %v", err)
+	}
+	var got []string
+	for _, prod := range readable.Producers {
+		got = append(got, prod.Name+"/"+prod.Role)
+	}
+	// tag credits come first with their roles; the filename adds the rest
+	if !reflect.DeepEqual(got, []string{"Metro Boomin/producer", "Southside/co_producer", "Wheezy/producer"}) {
+		t.Fatalf("unexpected producers: %v", got)
+	}
+}
+
+func TestVorbisProducerCredits(t *testing.T) {
+	var tags SongTags
+	fillFromVorbis(&tags, []string{
+		"TITLE=Mask Off", "PRODUCER=Metro Boomin", "producer=Zaytoven", "COPRODUCER=Southside", "MIXER=Ethan Stevens",
+	})
+	want := []ProducerCredit{
+		{Role: producerRoleProducer, Name: "Metro Boomin"},
+		{Role: producerRoleProducer, Name: "Zaytoven"},
+		{Role: producerRoleCoProducer, Name: "Southside"},
+		{Role: producerRoleMixing, Name: "Ethan Stevens"},
+	}
+	if tags.Producers != "Metro Boomin, Zaytoven" || !reflect.DeepEqual(tags.Credits, want) {
+		t.Fatalf("unexpected credits: %q %+v", tags.Producers, tags.Credits)
+	}
+}
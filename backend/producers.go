@@ -6,7 +6,6 @@ import (
 	"regexp"
 	"sort"
 	"strings"
-	"sync"
 	"time"
 )
 
@@ -282,7 +281,7 @@ func (a *App) getAliasesForProducer(producerID int) ([]ProducerAliasWithArtists,
 func (a *App) getSongsForProducer(producerID int) ([]Song, error) {
 
 	rows, err := a.db.Query(`
-		SELECT s.id, s.name, s.album_id, s.artwork_path, s.genre, s.year, s.track_number, s.duration, s.filepath, s.file_type, s.created_at, s.updated_at, s.synced, s.apple_music_id
+		SELECT s.id, s.name, s.album_id, s.artwork_path, s.genre, s.year, s.track_number, s.duration, s.filepath, s.file_type, s.created_at, s.updated_at, s.synced, s.apple_music_id, s.bitrate, s.sample_rate, s.channels, s.bit_depth, s.codec, s.leak_date, s.leak_source, s.recording_date, s.leak_status, s.notes, s.era_id
 		FROM songs s
 		JOIN song_producers sp ON s.id = sp.song_id
 		WHERE sp.producer_id = ?
@@ -296,7 +295,7 @@ func (a *App) getSongsForProducer(producerID int) ([]Song, error) {
 	for rows.Next() {
 		var song Song
 		var createdAt, updatedAt sql.NullInt64
-		err := rows.Scan(&song.ID, &song.Name, &song.AlbumID, &song.ArtworkPath, &song.Genre, &song.Year, &song.TrackNumber, &song.Duration, &song.Filepath, &song.FileType, &createdAt, &updatedAt, &song.Synced, &song.AppleMusicID)
+		err := rows.Scan(&song.ID, &song.Name, &song.AlbumID, &song.ArtworkPath, &song.Genre, &song.Year, &song.TrackNumber, &song.Duration, &song.Filepath, &song.FileType, &createdAt, &updatedAt, &song.Synced, &song.AppleMusicID, &song.Bitrate, &song.SampleRate, &song.Channels, &song.BitDepth, &song.Codec, &song.LeakDate, &song.LeakSource, &song.RecordingDate, &song.LeakStatus, &song.Notes, &song.EraID)
 		if err != nil {
 			return nil, err
 		}
@@ -310,6 +309,39 @@ func (a *App) getSongsForProducer(producerID int) ([]Song, error) {
 	return songs, nil
 }
 
+// addProducersToSong appends producer links that a song doesn't already have,
+// after its existing ones, and returns how many were added. roles goes with
+// producerIDs as in CreateSongInput.
+func (a *App) addProducersToSong(songID int, producerIDs []int, roles []string) (int, error) {
+	roles, err := normalizeProducerRoles(producerIDs, roles)
+	if err != nil {
+		return 0, err
+	}
+	added := 0
+	now := time.Now().Unix()
+	err = a.InTx(func(tx *sql.Tx) error {
+		var nextOrder int
+		if err := tx.QueryRow(`SELECT COALESCE(MAX("order") + 1, 0) FROM song_producers WHERE song_id = ?`, songID).Scan(&nextOrder); err != nil {
+			return err
+		}
+		for i, producerID := range producerIDs {
+			result, err := tx.Exec(
+				`INSERT OR IGNORE INTO song_producers (song_id, producer_id, "order", role, created_at) VALUES (?, ?, ?, ?, ?)`,
+				songID, producerID, nextOrder, roles[i], now,
+			)
+			if err != nil {
+				return err
+			}
+			if n, _ := result.RowsAffected(); n > 0 {
+				nextOrder++
+				added++
+			}
+		}
+		return nil
+	})
+	return added, err
+}
+
 // WriteProducerMetadata writes metadata to all songs by a producer
 func (a *App) WriteProducerMetadata(producerID int) (BatchResult, error) {
 
@@ -331,47 +363,7 @@ func (a *App) WriteProducerMetadata(producerID int) (BatchResult, error) {
 		return BatchResult{}, err
 	}
 
-	// Parallel processing
-	results := make([]SongProcessingResult, len(songIDs))
-	var wg sync.WaitGroup
-	semaphore := make(chan struct{}, 4)
-
-	for i, id := range songIDs {
-		wg.Add(1)
-		go func(index int, sID int) {
-			defer wg.Done()
-			semaphore <- struct{}{}
-			defer func() { <-semaphore }()
-
-			err := a.writeSongMetadataInternal(sID)
-			res := SongProcessingResult{SongID: sID, Success: true}
-			if err != nil {
-				res.Success = false
-				res.Error = err.Error()
-			}
-			results[index] = res
-		}(i, id)
-	}
-
-	wg.Wait()
-
-	successCount := 0
-	failCount := 0
-	for _, r := range results {
-		if r.Success {
-			successCount++
-		} else {
-			failCount++
-		}
-	}
-
-	return BatchResult{
-		Success:        true,
-		Message:        fmt.Sprintf("Processed producer %d", producerID),
-		SongsProcessed: successCount,
-		SongsFailed:    failCount,
-		Results:        results,
-	}, nil
+	return a.writeMetadataBatch(songIDs, fmt.Sprintf("Processed producer %d", producerID)), nil
 }
 
 // LoadProducerPatterns loads all producer-matching patterns in one query (no N+1).
@@ -445,23 +437,6 @@ func MatchPatterns(filename string, patterns []Pattern, songArtistIDs []int) []i
 		return len(sorted[i].Term) > len(sorted[j].Term)
 	})
 
-	isArtistContextValid := func(aliasArtistIDs []int) bool {
-		if len(aliasArtistIDs) == 0 {
-			return true
-		}
-		if len(songArtistIDs) == 0 {
-			return false
-		}
-		for _, aid := range songArtistIDs {
-			for _, aaid := range aliasArtistIDs {
-				if aid == aaid {
-					return true
-				}
-			}
-		}
-		return false
-	}
-
 	consumedRanges := make([]struct{ start, end int }, 0)
 	isRangeConsumed := func(start, end int) bool {
 		for _, r := range consumedRanges {
@@ -476,7 +451,7 @@ func MatchPatterns(filename string, patterns []Pattern, songArtistIDs []int) []i
 
 	// pass 1: word-boundary matches
 	for _, p := range sorted {
-		if p.IsAlias && !isArtistContextValid(p.AliasArtistIDs) {
+		if p.IsAlias && !aliasAppliesTo(p.AliasArtistIDs, songArtistIDs) {
 			continue
 		}
 		if p.Term == "" {
@@ -493,7 +468,7 @@ func MatchPatterns(filename string, patterns []Pattern, songArtistIDs []int) []i
 
 	// pass 2: substring fallback
 	for _, p := range sorted {
-		if p.IsAlias && !isArtistContextValid(p.AliasArtistIDs) {
+		if p.IsAlias && !aliasAppliesTo(p.AliasArtistIDs, songArtistIDs) {
 			continue
 		}
 		if p.Term == "" {
@@ -518,6 +493,22 @@ func MatchPatterns(filename string, patterns []Pattern, songArtistIDs []int) []i
 	return result
 }
 
+// aliasAppliesTo reports whether an alias restricted to aliasArtistIDs may
+// match on a song by songArtistIDs; unrestricted aliases always may.
+func aliasAppliesTo(aliasArtistIDs, songArtistIDs []int) bool {
+	if len(aliasArtistIDs) == 0 {
+		return true
+	}
+	for _, aid := range songArtistIDs {
+		for _, aaid := range aliasArtistIDs {
+			if aid == aaid {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // MatchProducersFromFilename composes LoadProducerPatterns + MatchPatterns.
 func (a *App) MatchProducersFromFilename(filename string, songArtistIDs []int) ([]int, error) {
 	patterns, err := a.LoadProducerPatterns()
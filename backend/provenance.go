@@ -0,0 +1,133 @@
+package backend
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// --- Leak Provenance ---
+
+const (
+	leakStatusUnreleased = "unreleased"
+	leakStatusLeaked     = "leaked"
+	leakStatusReleased   = "released"
+)
+
+var validLeakStatuses = map[string]bool{
+	leakStatusUnreleased: true,
+	leakStatusLeaked:     true,
+	leakStatusReleased:   true,
+}
+
+// Custom tag keys provenance is written under. ID3 stores them as TXXX
+// frames, Vorbis comments as fields, and MP4 as freeform atoms.
+const (
+	tagLeakDate      = "LEAK_DATE"
+	tagLeakSource    = "LEAK_SOURCE"
+	tagRecordingDate = "RECORDING_DATE"
+	tagLeakStatus    = "LEAK_STATUS"
+	tagLeakNotes     = "LEAK_NOTES"
+)
+
+// customTagKeys are the custom tags adapters read back, in write order.
+var customTagKeys = []string{tagLeakDate, tagLeakSource, tagRecordingDate, tagLeakStatus, tagLeakNotes}
+
+func isCustomTagKey(key string) bool {
+	for _, k := range customTagKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+var partialDatePattern = regexp.MustCompile(`^\d{4}(-\d{2}(-\d{2})?)?$`)
+
+// partialDateEnd is appended to a partial date used as an inclusive upper
+// bound, so "2019" sorts after every date in 2019 when compared as text.
+const partialDateEnd = "\uffff"
+
+// normalizeProvenance trims every set field and checks the dates and status.
+// Empty fields stay empty rather than nil, so updates can clear them.
+func normalizeProvenance(p Provenance) (Provenance, error) {
+	trim := func(v *string) *string {
+		if v == nil {
+			return nil
+		}
+		t := strings.TrimSpace(*v)
+		return &t
+	}
+	out := Provenance{
+		LeakDate:      trim(p.LeakDate),
+		LeakSource:    trim(p.LeakSource),
+		RecordingDate: trim(p.RecordingDate),
+		LeakStatus:    trim(p.LeakStatus),
+		Notes:         trim(p.Notes),
+	}
+	if out.LeakStatus != nil {
+		status := strings.ToLower(*out.LeakStatus)
+		if status != "" && !validLeakStatuses[status] {
+			return Provenance{}, fmt.Errorf("unknown leak status %q (want unreleased, leaked, or released)", *out.LeakStatus)
+		}
+		out.LeakStatus = &status
+	}
+	for _, date := range []struct {
+		name  string
+		value *string
+	}{{"leak date", out.LeakDate}, {"recording date", out.RecordingDate}} {
+		if date.value == nil || *date.value == "" {
+			continue
+		}
+		if !validPartialDate(*date.value) {
+			return Provenance{}, fmt.Errorf("%s %q is not a date like 2019, 2019-06, or 2019-06-14", date.name, *date.value)
+		}
+	}
+	return out, nil
+}
+
+// validPartialDate accepts YYYY, YYYY-MM, and YYYY-MM-DD.
+func validPartialDate(s string) bool {
+	if !partialDatePattern.MatchString(s) {
+		return false
+	}
+	layout := "2006-01-02"[:len(s)]
+	_, err := time.Parse(layout, s)
+	return err == nil
+}
+
+// provenanceColumns pairs each provenance field with its songs column.
+func provenanceColumns(p Provenance) []struct {
+	column string
+	value  *string
+} {
+	return []struct {
+		column string
+		value  *string
+	}{
+		{"leak_date", p.LeakDate},
+		{"leak_source", p.LeakSource},
+		{"recording_date", p.RecordingDate},
+		{"leak_status", p.LeakStatus},
+		{"notes", p.Notes},
+	}
+}
+
+// provenanceTags maps a song's provenance to the custom tags written into
+// its file, skipping unset fields.
+func provenanceTags(p Provenance) map[string]string {
+	tags := make(map[string]string)
+	for key, value := range map[string]*string{
+		tagLeakDate:      p.LeakDate,
+		tagLeakSource:    p.LeakSource,
+		tagRecordingDate: p.RecordingDate,
+		tagLeakStatus:    p.LeakStatus,
+		tagLeakNotes:     p.Notes,
+	} {
+		if value != nil && *value != "" {
+			tags[key] = *value
+		}
+	}
+	return tags
+}
@@ -0,0 +1,100 @@
+package backend
+
+import (
+	"testing"
+)
+
+func TestSongProvenanceEditFilterAndTags(t *testing.T) {
+	app := newTestApp(t)
+
+	artist, err := app.CreateArtist(CreateArtistInput{Name: "Kanye West"})
+	if err != nil {
+		t.Fatalf("CreateArtist: %v", err)
+	}
+	relPath := "uploads/songs/prov.mp3"
+	fullPath := writeSilentMP3(t, app, relPath)
+	leakDate, source := "2019-06-14", "Group Buy"
+	song, err := app.CreateSong(CreateSongInput{
+		Name: "Law of Attraction", Filepath: relPath, ArtistIDs: []int{artist.ID},
+		Provenance: Provenance{LeakDate: &leakDate, LeakSource: &source},
+	})
+	if err != nil {
+		t.Fatalf("CreateSong: %v", err)
+	}
+	if _, err := app.CreateSong(CreateSongInput{Name: "Other", Filepath: "uploads/songs/other.mp3", ArtistIDs: []int{artist.ID}}); err != nil {
+		t.Fatalf("CreateSong: %v", err)
+	}
+
+	status, recorded, notes := "Leaked", "2018", "  from the Wyoming sessions "
+	updated, err := app.UpdateSong(UpdateSongInput{ID: song.ID, Provenance: Provenance{LeakStatus: &status, RecordingDate: &recorded, Notes: &notes}})
+	if err != nil {
+		t.Fatalf("UpdateSong: %v", err)
+	}
+	if updated.LeakStatus == nil || *updated.LeakStatus != leakStatusLeaked || *updated.Notes != "from the Wyoming sessions" ||
+		updated.LeakDate == nil || *updated.LeakDate != leakDate {
+		t.Fatalf("unexpected provenance after update: %+v", updated.Provenance)
+	}
+
+	bootleg, spelledOut, badMonth := "bootleg", "June 2019", "2019-13"
+	for _, bad := range []Provenance{
+		{LeakStatus: &bootleg},
+		{LeakDate: &spelledOut},
+		{RecordingDate: &badMonth},
+	} {
+		if _, err := app.UpdateSong(UpdateSongInput{ID: song.ID, Provenance: bad}); err == nil {
+			t.Errorf("expected %+v to be rejected", bad)
+		}
+	}
+
+	year := "2019"
+	results, err := app.Search("", SearchFilters{LeakStatus: &status, LeakedFrom: &year, LeakedTo: &year})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != song.ID {
+		t.Fatalf("expected only the leaked song, got %+v", results)
+	}
+	lowerSource := "group buy"
+	if results, _ := app.Search("", SearchFilters{LeakSource: &lowerSource}); len(results) != 1 {
+		t.Fatalf("expected the source filter to ignore case, got %d results", len(results))
+	}
+	before := "2019-05"
+	if results, _ := app.Search("", SearchFilters{LeakedTo: &before}); len(results) != 0 {
+		t.Fatalf("expected no songs leaked by May 2019, got %d", len(results))
+	}
+
+	// custom tags are only written when the setting is on
+	if res, _ := app.WriteSongMetadata(song.ID); !res.Success {
+		t.Fatalf("WriteSongMetadata: %s", res.Error)
+	}
+	if tags, _ := (id3Adapter{}).Read(fullPath); len(tags.Custom) != 0 {
+		t.Fatalf("expected no custom tags by default, got %v", tags.Custom)
+	}
+	on := true
+	if _, err := app.UpdateSettings(UpdateSettingsInput{WriteProvenanceTags: &on}); err != nil {
+		t.Fatalf("UpdateSettings: %v", err)
+	}
+	if res, _ := app.WriteSongMetadata(song.ID); !res.Success {
+		t.Fatalf("WriteSongMetadata: %s", res.Error)
+	}
+	tags, err := (id3Adapter{}).Read(fullPath)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if tags.Custom[tagLeakDate] != leakDate || tags.Custom[tagLeakSource] != source || tags.Custom[tagLeakStatus] != leakStatusLeaked ||
+		tags.Custom[tagRecordingDate] != "2018" || tags.Custom[tagLeakNotes] != "from the Wyoming sessions" {
+		t.Fatalf("unexpected custom tags: %v", tags.Custom)
+	}
+
+	// clearing a field removes its tag on the next write
+	empty := ""
+	if _, err := app.UpdateSong(UpdateSongInput{ID: song.ID, Provenance: Provenance{Notes: &empty}}); err != nil {
+		t.Fatalf("UpdateSong: %v", err)
+	}
+	if res, _ := app.WriteSongMetadata(song.ID); !res.Success {
+		t.Fatalf("WriteSongMetadata: %s", res.Error)
+	}
+	if tags, _ := (id3Adapter{}).Read(fullPath); tags.Custom[tagLeakNotes] != "" || tags.Custom[tagLeakDate] != leakDate {
+		t.Fatalf("expected only the notes tag to go, got %v", tags.Custom)
+	}
+}
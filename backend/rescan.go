@@ -0,0 +1,497 @@
+package backend
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dhowden/tag"
+)
+
+// --- Rescan From File ---
+
+// rescanFields are the song details a rescan can take from a file, in the
+// order changes are proposed.
+var rescanFields = []string{
+	tagFieldTitle, tagFieldArtist, tagFieldAlbum, tagFieldGenre, tagFieldYear, tagFieldTrack, tagFieldProducers, tagFieldArtwork,
+}
+
+// rescanPlan is a song's proposal plus what applying each change writes.
+type rescanPlan struct {
+	RescanProposal
+	song           *Song
+	name           string
+	artists        []rescanArtist
+	albumName      string
+	albumArtistIDs []int
+	genre          string
+	year           int
+	trackNumber    int
+	producerIDs    []int
+	producerRoles  []string
+	artwork        *tag.Picture
+}
+
+// rescanArtist is a parsed artist credit; ID 0 is an artist to create.
+type rescanArtist struct {
+	name string
+	id   int
+	role string
+}
+
+// RescanSongFromFile reads a song's tags, as edited in another tagger,
+// and proposes the database changes that would match them. It changes
+// nothing; see ApplyRescanChanges.
+func (a *App) RescanSongFromFile(songID int) (*RescanProposal, error) {
+	plan, err := a.planRescan(songID)
+	if err != nil {
+		return nil, err
+	}
+	return &plan.RescanProposal, nil
+}
+
+// RescanLibrary proposes changes for every song. Songs whose files match
+// the database are left out; those that couldn't be read are listed with
+// their error.
+func (a *App) RescanLibrary() (*RescanLibraryReport, error) {
+	songIDs, err := a.metadataDriftSongIDs(MetadataDriftInput{})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &RescanLibraryReport{SongsChecked: len(songIDs), Proposals: []RescanProposal{}}
+	for _, songID := range songIDs {
+		plan, err := a.planRescan(songID)
+		if err != nil {
+			proposal := RescanProposal{SongID: songID, Changes: []RescanChange{}, Error: err.Error()}
+			if err := a.db.QueryRow(`SELECT name, filepath FROM songs WHERE id = ?`, songID).Scan(&proposal.SongName, &proposal.Filepath); err != nil {
+				return nil, err
+			}
+			report.Proposals = append(report.Proposals, proposal)
+			continue
+		}
+		if len(plan.Changes) > 0 {
+			report.Proposals = append(report.Proposals, plan.RescanProposal)
+		}
+	}
+	return report, nil
+}
+
+// ApplyRescanChanges applies the accepted fields of each song's proposal.
+// Files are read again, so a field whose change is no longer proposed is
+// left alone. Albums are re-resolved through ResolveOrCreateAlbum, and
+// artists that don't exist yet are created.
+func (a *App) ApplyRescanChanges(input ApplyRescanInput) (BatchResult, error) {
+	results := make([]SongProcessingResult, 0, len(input.Songs))
+	failed := 0
+	for _, accepted := range input.Songs {
+		res := SongProcessingResult{SongID: accepted.SongID, Success: true}
+		if err := a.applyRescan(accepted); err != nil {
+			res.Success = false
+			res.Error = err.Error()
+			failed++
+		}
+		results = append(results, res)
+	}
+	return BatchResult{
+		Success:        true,
+		Message:        fmt.Sprintf("Applied rescanned tags to %d songs", len(results)-failed),
+		SongsProcessed: len(results) - failed,
+		SongsFailed:    failed,
+		Results:        results,
+	}, nil
+}
+
+// planRescan compares a song's file with buildSongTags. A field is only
+// proposed when the file disagrees with what writing metadata would put
+// there, so details the library derives (single album names, era albums,
+// track totals, rendered features) don't come back as changes. Fields the
+// library doesn't overwrite, and fields the file leaves empty, are skipped.
+func (a *App) planRescan(songID int) (*rescanPlan, error) {
+	song, err := a.getSongByID(songID)
+	if err != nil {
+		return nil, err
+	}
+	if song == nil {
+		return nil, fmt.Errorf("song not found")
+	}
+	tags, fullPath, err := a.buildSongTags(songID)
+	if err != nil {
+		return nil, err
+	}
+	expected := tags.forWrite()
+	rescans := func(field string) bool { return tags.tagPolicy(field) == tagPolicyOverwrite }
+	ext := strings.ToLower(filepath.Ext(fullPath))
+	adapter := pickAdapter(ext)
+	if adapter == nil {
+		return nil, fmt.Errorf("reading support for %s not yet implemented", ext)
+	}
+	if _, err := os.Stat(fullPath); err != nil {
+		return nil, fmt.Errorf("file not found: %s", fullPath)
+	}
+	actual, err := adapter.Read(fullPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tags: %w", err)
+	}
+	settings, err := a.GetSettings()
+	if err != nil {
+		return nil, err
+	}
+	credits, err := a.getSongArtists(songID)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &rescanPlan{
+		RescanProposal: RescanProposal{SongID: song.ID, SongName: song.Name, Filepath: song.Filepath, Changes: []RescanChange{}},
+		song:           song,
+	}
+	propose := func(field, current, proposed string) *RescanChange {
+		plan.Changes = append(plan.Changes, RescanChange{Field: field, Current: current, Proposed: proposed})
+		return &plan.Changes[len(plan.Changes)-1]
+	}
+
+	if rescans(tagFieldTitle) && actual.Title != "" && actual.Title != expected.Title {
+		// writing adds the feature and remix credits to the title in some styles
+		_, suffix := renderArtistCredits("", credits, settings.FeaturedArtistStyle)
+		if name := strings.TrimSpace(strings.TrimSuffix(actual.Title, suffix)); name != "" && name != song.Name {
+			plan.name = name
+			propose(tagFieldTitle, song.Name, name)
+		}
+	}
+
+	songArtistIDs := []int{}
+	for _, credit := range credits {
+		songArtistIDs = append(songArtistIDs, credit.ID)
+	}
+	if rescans(tagFieldArtist) && actual.Artist != "" && actual.Artist != expected.Artist {
+		names, roles := ParseArtistCredits(actual.Artist, actual.Title)
+		seen := make(map[int]bool)
+		newArtists := []string{}
+		for i, name := range names {
+			artist := rescanArtist{name: name, role: roles[i]}
+			if found, err := a.resolveArtistName(name); err != nil {
+				return nil, err
+			} else if found != nil {
+				artist.id, artist.name = found.ID, found.Name
+			} else if resolution, ok, _ := a.recallArtistMapping(name); ok {
+				if id, ok := resolution.(float64); ok {
+					artist.id = int(id)
+				}
+			}
+			if artist.id == 0 {
+				newArtists = append(newArtists, name)
+			} else if seen[artist.id] {
+				continue
+			}
+			seen[artist.id] = true
+			plan.artists = append(plan.artists, artist)
+		}
+		if len(plan.artists) > 0 && !sameRescanArtists(plan.artists, credits) {
+			current := make([]rescanArtist, len(credits))
+			for i, credit := range credits {
+				current[i] = rescanArtist{name: credit.Name, id: credit.ID, role: credit.Role}
+			}
+			change := propose(tagFieldArtist, formatRescanArtists(current), formatRescanArtists(plan.artists))
+			change.NewArtists = newArtists
+			songArtistIDs = songArtistIDs[:0]
+			for _, artist := range plan.artists {
+				songArtistIDs = append(songArtistIDs, artist.id)
+			}
+		} else {
+			plan.artists = nil
+		}
+	}
+
+	currentAlbum := ""
+	if song.AlbumID != nil {
+		if err := a.db.QueryRow(`SELECT name FROM albums WHERE id = ?`, *song.AlbumID).Scan(&currentAlbum); err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+	}
+	if albumName := strings.TrimSpace(actual.Album); rescans(tagFieldAlbum) && albumName != "" &&
+		actual.Album != expected.Album && !strings.EqualFold(albumName, currentAlbum) {
+		plan.albumName = albumName
+		for _, name := range ParseArtists(actual.AlbumArtist) {
+			if found, err := a.resolveArtistName(name); err != nil {
+				return nil, err
+			} else if found != nil && !slices.Contains(plan.albumArtistIDs, found.ID) {
+				plan.albumArtistIDs = append(plan.albumArtistIDs, found.ID)
+			}
+		}
+		propose(tagFieldAlbum, currentAlbum, albumName)
+	}
+
+	if current := derefString(song.Genre); rescans(tagFieldGenre) && actual.Genre != "" &&
+		actual.Genre != expected.Genre && actual.Genre != current {
+		plan.genre = actual.Genre
+		propose(tagFieldGenre, current, actual.Genre)
+	}
+	if current := derefInt(song.Year); rescans(tagFieldYear) && actual.Year != 0 &&
+		actual.Year != expected.Year && int(actual.Year) != current {
+		plan.year = int(actual.Year)
+		propose(tagFieldYear, rescanNumber(current), rescanNumber(plan.year))
+	}
+	if current := derefInt(song.TrackNumber); rescans(tagFieldTrack) && actual.TrackNumber != 0 &&
+		actual.TrackNumber != expected.TrackNumber && int(actual.TrackNumber) != current {
+		plan.trackNumber = int(actual.TrackNumber)
+		propose(tagFieldTrack, rescanNumber(current), rescanNumber(plan.trackNumber))
+	}
+
+	fileCredits := actual.producerCredits()
+	if rescans(tagFieldProducers) && len(fileCredits) > 0 && driftCredits(fileCredits) != driftCredits(expected.producerCredits()) {
+		if err := a.planRescanProducers(plan, fileCredits, songArtistIDs, propose); err != nil {
+			return nil, err
+		}
+	}
+
+	// Read only says whether there's artwork; the image comes from dhowden/tag
+	if rescans(tagFieldArtwork) && actual.ArtworkPath != "" {
+		if pic := embeddedPicture(fullPath); pic != nil {
+			var currentArt []byte
+			if expected.ArtworkPath != "" {
+				currentArt, _ = os.ReadFile(expected.ArtworkPath)
+			}
+			if !bytes.Equal(pic.Data, currentArt) {
+				plan.artwork = pic
+				propose(tagFieldArtwork, derefString(song.ArtworkPath), fmt.Sprintf("embedded %s (%d KB)", pic.MIMEType, (len(pic.Data)+1023)/1024))
+			}
+		}
+	}
+	return plan, nil
+}
+
+// planRescanProducers matches the file's credits to producers, as uploads
+// do, and proposes them if they differ from the song's.
+func (a *App) planRescanProducers(plan *rescanPlan, credits []ProducerCredit, songArtistIDs []int, propose func(field, current, proposed string) *RescanChange) error {
+	patterns, err := a.LoadProducerPatterns()
+	if err != nil {
+		return err
+	}
+	plan.producerIDs, plan.producerRoles = matchProducerCredits(credits, patterns, songArtistIDs)
+	unmatched := []string{}
+	for _, credit := range credits {
+		for _, name := range ParseArtists(credit.Name) {
+			ids, _ := matchProducerCredits([]ProducerCredit{{Role: credit.Role, Name: name}}, patterns, songArtistIDs)
+			if len(ids) == 0 {
+				unmatched = append(unmatched, name)
+			}
+		}
+	}
+
+	current, err := a.getProducersForSong(plan.SongID)
+	if err != nil {
+		return err
+	}
+	same := len(current) == len(plan.producerIDs)
+	for i := 0; same && i < len(current); i++ {
+		same = current[i].ID == plan.producerIDs[i] && current[i].Role == plan.producerRoles[i]
+	}
+	if same {
+		plan.producerIDs, plan.producerRoles = nil, nil
+		return nil
+	}
+
+	proposed := make([]ProducerCredit, len(plan.producerIDs))
+	for i, id := range plan.producerIDs {
+		proposed[i].Role = plan.producerRoles[i]
+		if err := a.db.QueryRow(`SELECT name FROM producers WHERE id = ?`, id).Scan(&proposed[i].Name); err != nil {
+			return err
+		}
+	}
+	change := propose(tagFieldProducers, driftCredits(songProducerCredits(current)), driftCredits(proposed))
+	change.UnmatchedProducers = unmatched
+	return nil
+}
+
+// applyRescan re-plans a song and writes the accepted changes.
+func (a *App) applyRescan(accepted AcceptedRescan) error {
+	for _, field := range accepted.Fields {
+		if !slices.Contains(rescanFields, field) {
+			return fmt.Errorf("unknown rescan field %q", field)
+		}
+	}
+	plan, err := a.planRescan(accepted.SongID)
+	if err != nil {
+		return err
+	}
+	apply := func(field string) bool {
+		if !slices.Contains(accepted.Fields, field) {
+			return false
+		}
+		for _, change := range plan.Changes {
+			if change.Field == field {
+				return true
+			}
+		}
+		return false
+	}
+	song := plan.song
+
+	// artists come first: a new album is created with them
+	var artistIDs []int
+	var artistRoles []string
+	if apply(tagFieldArtist) {
+		for _, artist := range plan.artists {
+			if artist.id == 0 {
+				created, err := a.CreateArtist(CreateArtistInput{Name: artist.name})
+				if err != nil {
+					return err
+				}
+				artist.id = created.ID
+			}
+			artistIDs = append(artistIDs, artist.id)
+			artistRoles = append(artistRoles, artist.role)
+		}
+	}
+
+	albumID := song.AlbumID
+	if apply(tagFieldAlbum) {
+		// without album artists in the file, the song's primary artists
+		albumArtistIDs := plan.albumArtistIDs
+		if len(albumArtistIDs) == 0 {
+			ids, roles := artistIDs, artistRoles
+			if ids == nil {
+				credits, err := a.getSongArtists(song.ID)
+				if err != nil {
+					return err
+				}
+				for _, credit := range credits {
+					ids, roles = append(ids, credit.ID), append(roles, credit.Role)
+				}
+			}
+			for i, id := range ids {
+				if roles[i] == artistRolePrimary {
+					albumArtistIDs = append(albumArtistIDs, id)
+				}
+			}
+		}
+		album, _, err := a.ResolveOrCreateAlbum(plan.albumName, albumArtistIDs, AlbumResolutionOpts{InheritArtworkFromSongID: &song.ID})
+		if err != nil {
+			return err
+		}
+		albumID = nil
+		if album != nil {
+			albumID = &album.ID
+		}
+	}
+
+	name, genre, year, trackNumber, artworkPath := song.Name, song.Genre, song.Year, song.TrackNumber, song.ArtworkPath
+	if apply(tagFieldTitle) {
+		name = plan.name
+	}
+	if apply(tagFieldGenre) {
+		genre = trimmedOrNil(&plan.genre)
+	}
+	if apply(tagFieldYear) {
+		year = positiveIntPtr(plan.year)
+	}
+	if apply(tagFieldTrack) {
+		trackNumber = positiveIntPtr(plan.trackNumber)
+	}
+	if apply(tagFieldArtwork) {
+		ext := "jpg"
+		if plan.artwork.MIMEType == "image/png" {
+			ext = "png"
+		}
+		path, err := a.SaveArtwork("artwork."+ext, base64.StdEncoding.EncodeToString(plan.artwork.Data))
+		if err != nil {
+			return err
+		}
+		artworkPath = &path
+	}
+
+	now := time.Now().Unix()
+	return a.InTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(
+			`UPDATE songs SET name = ?, album_id = ?, genre = ?, year = ?, track_number = ?, artwork_path = ?, updated_at = ? WHERE id = ?`,
+			name, albumID, genre, year, trackNumber, artworkPath, now, song.ID,
+		); err != nil {
+			return err
+		}
+		if artistIDs != nil {
+			if _, err := tx.Exec(`DELETE FROM song_artists WHERE song_id = ?`, song.ID); err != nil {
+				return err
+			}
+			if err := linkSongArtistsTx(tx, int64(song.ID), artistIDs, artistRoles, now); err != nil {
+				return err
+			}
+		}
+		if apply(tagFieldProducers) {
+			if _, err := tx.Exec(`DELETE FROM song_producers WHERE song_id = ?`, song.ID); err != nil {
+				return err
+			}
+			if err := linkSongProducersTx(tx, int64(song.ID), plan.producerIDs, plan.producerRoles, now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func sameRescanArtists(artists []rescanArtist, credits []SongArtist) bool {
+	if len(artists) != len(credits) {
+		return false
+	}
+	for i, artist := range artists {
+		if artist.id != credits[i].ID || artist.role != credits[i].Role {
+			return false
+		}
+	}
+	return true
+}
+
+// formatRescanArtists renders credits as "A, B (featured)".
+func formatRescanArtists(artists []rescanArtist) string {
+	parts := make([]string, len(artists))
+	for i, artist := range artists {
+		parts[i] = artist.name
+		if artist.role != artistRolePrimary {
+			parts[i] += " (" + artist.role + ")"
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func rescanNumber(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+// embeddedPicture returns a file's artwork, or nil if it has none or
+// dhowden/tag can't read the format.
+func embeddedPicture(path string) *tag.Picture {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	m, err := tag.ReadFrom(f)
+	if err != nil || m.Picture() == nil || len(m.Picture().Data) == 0 {
+		return nil
+	}
+	return m.Picture()
+}
+
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func derefInt(n *int) int {
+	if n == nil {
+		return 0
+	}
+	return *n
+}
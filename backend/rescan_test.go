@@ -0,0 +1,156 @@
+package backend
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRescanSongFromFile(t *testing.T) {
+	app := newTestApp(t)
+
+	future, err := app.CreateArtist(CreateArtistInput{Name: "Future"})
+	if err != nil {
+		t.Fatalf("CreateArtist: %v", err)
+	}
+	metro, err := app.CreateProducerWithAliases(CreateProducerInput{Name: "Metro Boomin"})
+	if err != nil {
+		t.Fatalf("CreateProducerWithAliases: %v", err)
+	}
+	album, err := app.CreateAlbum(CreateAlbumInput{Name: "HNDRXX", ArtistIDs: []int{future.ID}})
+	if err != nil {
+		t.Fatalf("CreateAlbum: %v", err)
+	}
+
+	relPath := "uploads/songs/use-me.mp3"
+	fullPath := writeSilentMP3(t, app, relPath)
+	track := 1
+	song, err := app.CreateSong(CreateSongInput{Name: "Use Me", Filepath: relPath, ArtistIDs: []int{future.ID}, AlbumID: &album.ID, TrackNumber: &track})
+	if err != nil {
+		t.Fatalf("CreateSong: %v", err)
+	}
+	if res, _ := app.WriteSongMetadata(song.ID); !res.Success {
+		t.Fatalf("WriteSongMetadata: %s", res.Error)
+	}
+	proposal, err := app.RescanSongFromFile(song.ID)
+	if err != nil {
+		t.Fatalf("RescanSongFromFile: %v", err)
+	}
+	if len(proposal.Changes) != 0 {
+		t.Fatalf("expected a freshly written file to propose nothing, got %+v", proposal.Changes)
+	}
+
+	// the file is retagged elsewhere
+	art := makeArtwork(t, t.TempDir())
+	if err := (id3Adapter{}).Write(fullPath, SongTags{
+		Title: "Use Me Too", Artist: "Future feat. Drake", AlbumArtist: "Future", Album: "DS2", Genre: "Trap", Year: 2015,
+		TrackNumberStr: "4", TrackNumber: 4, ArtworkPath: art, ArtworkMimeType: "image/png",
+		Credits: []ProducerCredit{{Role: producerRoleProducer, Name: "metro boomin"}, {Role: producerRoleEngineer, Name: "Seth Firkins"}},
+	}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	proposal, err = app.RescanSongFromFile(song.ID)
+	if err != nil {
+		t.Fatalf("RescanSongFromFile: %v", err)
+	}
+	var fields []string
+	for _, change := range proposal.Changes {
+		fields = append(fields, change.Field)
+		switch change.Field {
+		case tagFieldArtist:
+			if change.Proposed != "Future, Drake (featured)" || !reflect.DeepEqual(change.NewArtists, []string{"Drake"}) {
+				t.Fatalf("unexpected artist change: %+v", change)
+			}
+		case tagFieldProducers:
+			if change.Proposed != "Metro Boomin" || !reflect.DeepEqual(change.UnmatchedProducers, []string{"Seth Firkins"}) {
+				t.Fatalf("unexpected producer change: %+v", change)
+			}
+		}
+	}
+	if !reflect.DeepEqual(fields, rescanFields) {
+		t.Fatalf("expected a change for every field, got %v", fields)
+	}
+
+	// everything but the genre is accepted
+	res, err := app.ApplyRescanChanges(ApplyRescanInput{Songs: []AcceptedRescan{{SongID: song.ID, Fields: []string{
+		tagFieldTitle, tagFieldArtist, tagFieldAlbum, tagFieldYear, tagFieldTrack, tagFieldProducers, tagFieldArtwork,
+	}}}})
+	if err != nil || res.SongsFailed > 0 {
+		t.Fatalf("ApplyRescanChanges: %v %+v", err, res.Results)
+	}
+	readable, err := app.GetSongReadable(song.ID)
+	if err != nil {
+		t.Fatalf("GetSongReadable: %v", err)
+	}
+	var artists, producers []string
+	for _, artist := range readable.Artists {
+		artists = append(artists, artist.Name+"/"+artist.Role)
+	}
+	for _, prod := range readable.Producers {
+		producers = append(producers, prod.Name)
+	}
+	if readable.Name != "Use Me Too" || *readable.Year != 2015 || *readable.TrackNumber != 4 || readable.Genre != nil ||
+		!reflect.DeepEqual(artists, []string{"Future/primary", "Drake/featured"}) || !reflect.DeepEqual(producers, []string{metro.Name}) {
+		t.Fatalf("unexpected song after applying: %+v %v %v", readable.Song, artists, producers)
+	}
+	if readable.AlbumID == nil || *readable.AlbumID == album.ID {
+		t.Fatalf("expected the song to move to a new album, got %v", readable.AlbumID)
+	}
+	if dest, _ := app.GetAlbumWithArtists(*readable.AlbumID); dest.Name != "DS2" || len(dest.Artists) != 1 || dest.Artists[0].ID != future.ID {
+		t.Fatalf("expected DS2 by Future, got %+v", dest)
+	}
+	if readable.ArtworkPath == nil {
+		t.Fatal("expected the embedded artwork to be saved")
+	}
+
+	// only the declined change is left, and unreadable songs are reported
+	if _, err := app.CreateSong(CreateSongInput{Name: "Gone", Filepath: "uploads/songs/gone.mp3"}); err != nil {
+		t.Fatalf("CreateSong: %v", err)
+	}
+	report, err := app.RescanLibrary()
+	if err != nil {
+		t.Fatalf("RescanLibrary: %v", err)
+	}
+	if report.SongsChecked != 2 || len(report.Proposals) != 2 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if changes := report.Proposals[0].Changes; len(changes) != 1 || changes[0].Field != tagFieldGenre || changes[0].Proposed != "Trap" {
+		t.Fatalf("expected only the genre change to remain, got %+v", changes)
+	}
+	if report.Proposals[1].SongName != "Gone" || report.Proposals[1].Error == "" {
+		t.Fatalf("expected the missing file to be reported, got %+v", report.Proposals[1])
+	}
+
+	if res, _ := app.ApplyRescanChanges(ApplyRescanInput{Songs: []AcceptedRescan{{SongID: song.ID, Fields: []string{"lyrics"}}}}); res.SongsFailed != 1 {
+		t.Fatalf("expected an unknown field to fail, got %+v", res)
+	}
+}
+
+func TestRescanSkipsUnmanagedAndEmptyFields(t *testing.T) {
+	app := newTestApp(t)
+
+	relPath := "uploads/songs/x.mp3"
+	fullPath := writeSilentMP3(t, app, relPath)
+	year, track := 2017, 3
+	song, err := app.CreateSong(CreateSongInput{Name: "XO Tour Llif3", Filepath: relPath, Year: &year, TrackNumber: &track})
+	if err != nil {
+		t.Fatalf("CreateSong: %v", err)
+	}
+	if _, err := app.UpdateSettings(UpdateSettingsInput{TagFieldPolicies: map[string]string{
+		tagFieldGenre: tagPolicyRemove, tagFieldYear: tagPolicyRemove,
+	}}); err != nil {
+		t.Fatalf("UpdateSettings: %v", err)
+	}
+
+	// genre and year aren't written, and the file has no track number
+	if err := (id3Adapter{}).Write(fullPath, SongTags{Title: "XO Tour Llif3", Genre: "Emo Rap", Year: 2016}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	proposal, err := app.RescanSongFromFile(song.ID)
+	if err != nil {
+		t.Fatalf("RescanSongFromFile: %v", err)
+	}
+	if len(proposal.Changes) != 0 {
+		t.Fatalf("expected nothing to be proposed, got %+v", proposal.Changes)
+	}
+}
@@ -0,0 +1,143 @@
+package backend
+
+import (
+	"database/sql"
+	"strings"
+	"unicode"
+)
+
+// --- Search ---
+
+const defaultSearchLimit = 50
+
+// Search finds songs whose name, album, artists, producers, or producer
+// aliases match query, narrowed by filters. An empty query returns every song
+// that passes the filters, newest first; otherwise results are ranked by bm25.
+func (a *App) Search(query string, filters SearchFilters) ([]SongReadable, error) {
+	where := []string{}
+	args := []any{}
+	join := ""
+	orderBy := "s.created_at DESC"
+
+	if ftsQuery := buildFTSQuery(query); ftsQuery != "" {
+		join = "JOIN song_search ON song_search.rowid = s.id"
+		where = append(where, "song_search MATCH ?")
+		args = append(args, ftsQuery)
+		orderBy = "bm25(song_search), s.created_at DESC"
+	}
+
+	if filters.YearFrom != nil {
+		where = append(where, "COALESCE(s.year, al.year) >= ?")
+		args = append(args, *filters.YearFrom)
+	}
+	if filters.YearTo != nil {
+		where = append(where, "COALESCE(s.year, al.year) <= ?")
+		args = append(args, *filters.YearTo)
+	}
+	if filters.Genre != nil && strings.TrimSpace(*filters.Genre) != "" {
+		where = append(where, "LOWER(COALESCE(s.genre, al.genre, '')) = LOWER(?)")
+		args = append(args, strings.TrimSpace(*filters.Genre))
+	}
+	if filters.FileType != nil && strings.TrimSpace(*filters.FileType) != "" {
+		// songs uploaded before file_type was recorded fall back to the extension.
+		// the registered LOWER rejects NULL, hence the COALESCE.
+		fileType := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(*filters.FileType)), ".")
+		where = append(where, "(LOWER(COALESCE(s.file_type, '')) = ? OR (s.file_type IS NULL AND LOWER(s.filepath) LIKE '%.' || ?))")
+		args = append(args, fileType, fileType)
+	}
+	if filters.IsSingle != nil {
+		if *filters.IsSingle {
+			where = append(where, "(al.id IS NULL OR al.is_single = 1)")
+		} else {
+			where = append(where, "(al.id IS NOT NULL AND al.is_single = 0)")
+		}
+	}
+
+	if filters.LeakStatus != nil && strings.TrimSpace(*filters.LeakStatus) != "" {
+		where = append(where, "s.leak_status = ?")
+		args = append(args, strings.ToLower(strings.TrimSpace(*filters.LeakStatus)))
+	}
+	if filters.LeakSource != nil && strings.TrimSpace(*filters.LeakSource) != "" {
+		where = append(where, "LOWER(COALESCE(s.leak_source, '')) = LOWER(?)")
+		args = append(args, strings.TrimSpace(*filters.LeakSource))
+	}
+	// ISO dates compare as text; a partial bound covers its whole period
+	if filters.LeakedFrom != nil && strings.TrimSpace(*filters.LeakedFrom) != "" {
+		where = append(where, "s.leak_date >= ?")
+		args = append(args, strings.TrimSpace(*filters.LeakedFrom))
+	}
+	if filters.LeakedTo != nil && strings.TrimSpace(*filters.LeakedTo) != "" {
+		where = append(where, "s.leak_date <= ?")
+		args = append(args, strings.TrimSpace(*filters.LeakedTo)+partialDateEnd)
+	}
+
+	limit := filters.Limit
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	offset := filters.Offset
+	if offset < 0 {
+		offset = 0
+	}
+
+	whereClause := ""
+	if len(where) > 0 {
+		whereClause = "WHERE " + strings.Join(where, " AND ")
+	}
+
+	rows, err := a.db.Query(`
+		SELECT s.id, s.name, s.album_id, s.artwork_path, s.genre, s.year, s.track_number, s.duration, s.filepath, s.file_type, s.created_at, s.updated_at, s.synced, s.apple_music_id, s.bitrate, s.sample_rate, s.channels, s.bit_depth, s.codec, s.leak_date, s.leak_source, s.recording_date, s.leak_status, s.notes, s.era_id
+		FROM songs s
+		LEFT JOIN albums al ON al.id = s.album_id
+		`+join+`
+		`+whereClause+`
+		ORDER BY `+orderBy+`
+		LIMIT ? OFFSET ?
+	`, append(args, limit, offset)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	songs := []Song{}
+	for rows.Next() {
+		var song Song
+		var createdAt, updatedAt sql.NullInt64
+		err := rows.Scan(&song.ID, &song.Name, &song.AlbumID, &song.ArtworkPath, &song.Genre, &song.Year, &song.TrackNumber, &song.Duration, &song.Filepath, &song.FileType, &createdAt, &updatedAt, &song.Synced, &song.AppleMusicID, &song.Bitrate, &song.SampleRate, &song.Channels, &song.BitDepth, &song.Codec, &song.LeakDate, &song.LeakSource, &song.RecordingDate, &song.LeakStatus, &song.Notes, &song.EraID)
+		if err != nil {
+			return nil, err
+		}
+		song.CreatedAt = createdAt.Int64
+		song.UpdatedAt = updatedAt.Int64
+		songs = append(songs, song)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	results := make([]SongReadable, 0, len(songs))
+	for _, song := range songs {
+		readable, err := a.buildSongReadable(song)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, *readable)
+	}
+	return results, nil
+}
+
+// buildFTSQuery turns free text into an FTS5 query: every whitespace-separated
+// term is quoted (so FTS5 operators and punctuation in user input are inert)
+// and prefix-matched, and all terms must match.
+func buildFTSQuery(query string) string {
+	terms := []string{}
+	for _, field := range strings.Fields(query) {
+		term := strings.ReplaceAll(field, `"`, "")
+		if !strings.ContainsFunc(term, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) {
+			continue
+		}
+		terms = append(terms, `"`+term+`"*`)
+	}
+	return strings.Join(terms, " ")
+}
@@ -0,0 +1,158 @@
+package backend
+
+import "testing"
+
+func TestSearchMatchesLinkedNamesAndTracksRenames(t *testing.T) {
+	app := newTestApp(t)
+
+	artist, err := app.CreateArtist(CreateArtistInput{Name: "Playboi Carti"})
+	if err != nil {
+		t.Fatalf("CreateArtist: %v", err)
+	}
+	producer, err := app.CreateProducerWithAliases(CreateProducerInput{
+		Name:    "Pierre Bourne",
+		Aliases: []AliasInput{{Name: "Pi'erre"}},
+	})
+	if err != nil {
+		t.Fatalf("CreateProducerWithAliases: %v", err)
+	}
+	album, err := app.CreateAlbum(CreateAlbumInput{Name: "Whole Lotta Red", ArtistIDs: []int{artist.ID}})
+	if err != nil {
+		t.Fatalf("CreateAlbum: %v", err)
+	}
+
+	song, err := app.CreateSong(CreateSongInput{
+		Name:        "Kid Cudi",
+		Filepath:    "uploads/songs/kid-cudi.mp3",
+		ArtistIDs:   []int{artist.ID},
+		ProducerIDs: []int{producer.ID},
+		AlbumID:     &album.ID,
+	})
+	if err != nil {
+		t.Fatalf("CreateSong: %v", err)
+	}
+	if _, err := app.CreateSong(CreateSongInput{
+		Name:     "Unrelated",
+		Filepath: "uploads/songs/unrelated.flac",
+	}); err != nil {
+		t.Fatalf("CreateSong: %v", err)
+	}
+
+	for _, query := range []string{"kid", "carti", "whole red", "bourne", "pi'erre"} {
+		results, err := app.Search(query, SearchFilters{})
+		if err != nil {
+			t.Fatalf("Search(%q): %v", query, err)
+		}
+		if len(results) != 1 || results[0].ID != song.ID {
+			t.Fatalf("Search(%q): expected song %d, got %#v", query, song.ID, results)
+		}
+	}
+
+	// renames flow through the triggers
+	newName := "WLR"
+	if err := app.UpdateAlbum(UpdateAlbumInput{ID: album.ID, Name: &newName}); err != nil {
+		t.Fatalf("UpdateAlbum: %v", err)
+	}
+	if results, err := app.Search("whole", SearchFilters{}); err != nil || len(results) != 0 {
+		t.Fatalf("expected old album name to be gone from index, got %d results (err %v)", len(results), err)
+	}
+	if results, err := app.Search("wlr", SearchFilters{}); err != nil || len(results) != 1 {
+		t.Fatalf("expected new album name to be indexed, got %d results (err %v)", len(results), err)
+	}
+
+	// links changed in place are reindexed too
+	other, err := app.CreateArtist(CreateArtistInput{Name: "Lil Uzi Vert"})
+	if err != nil {
+		t.Fatalf("CreateArtist: %v", err)
+	}
+	if _, err := app.db.Exec(`UPDATE song_artists SET artist_id = ? WHERE song_id = ?`, other.ID, song.ID); err != nil {
+		t.Fatalf("relink artist: %v", err)
+	}
+	if results, err := app.Search("carti", SearchFilters{}); err != nil || len(results) != 0 {
+		t.Fatalf("expected the old artist to be gone from index, got %d results (err %v)", len(results), err)
+	}
+	if results, err := app.Search("uzi", SearchFilters{}); err != nil || len(results) != 1 {
+		t.Fatalf("expected the relinked artist to be indexed, got %d results (err %v)", len(results), err)
+	}
+
+	if err := app.DeleteSong(song.ID); err != nil {
+		t.Fatalf("DeleteSong: %v", err)
+	}
+	if results, err := app.Search("kid", SearchFilters{}); err != nil || len(results) != 0 {
+		t.Fatalf("expected deleted song to leave the index, got %d results (err %v)", len(results), err)
+	}
+}
+
+func TestSearchFilters(t *testing.T) {
+	app := newTestApp(t)
+
+	artist, err := app.CreateArtist(CreateArtistInput{Name: "Artist"})
+	if err != nil {
+		t.Fatalf("CreateArtist: %v", err)
+	}
+	album, err := app.CreateAlbum(CreateAlbumInput{Name: "Full Album", ArtistIDs: []int{artist.ID}})
+	if err != nil {
+		t.Fatalf("CreateAlbum: %v", err)
+	}
+
+	year2019, year2021 := 2019, 2021
+	rap, pop := "Rap", "Pop"
+	onAlbum, err := app.CreateSong(CreateSongInput{
+		Name: "Album Cut", Filepath: "uploads/songs/a.mp3", ArtistIDs: []int{artist.ID},
+		AlbumID: &album.ID, Year: &year2019, Genre: &rap,
+	})
+	if err != nil {
+		t.Fatalf("CreateSong: %v", err)
+	}
+	loose, err := app.CreateSong(CreateSongInput{
+		Name: "Loose Track", Filepath: "uploads/songs/b.flac", ArtistIDs: []int{artist.ID},
+		Year: &year2021, Genre: &pop,
+	})
+	if err != nil {
+		t.Fatalf("CreateSong: %v", err)
+	}
+
+	isSingle, notSingle := true, false
+	flac := "FLAC"
+	from2020 := 2020
+	rapLower := "rap"
+
+	tests := []struct {
+		name    string
+		filters SearchFilters
+		want    int
+	}{
+		{name: "year range", filters: SearchFilters{YearFrom: &from2020}, want: loose.ID},
+		{name: "genre is case-insensitive", filters: SearchFilters{Genre: &rapLower}, want: onAlbum.ID},
+		{name: "file type falls back to extension", filters: SearchFilters{FileType: &flac}, want: loose.ID},
+		{name: "singles include songs without album", filters: SearchFilters{IsSingle: &isSingle}, want: loose.ID},
+		{name: "albums only", filters: SearchFilters{IsSingle: &notSingle}, want: onAlbum.ID},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			results, err := app.Search("", tc.filters)
+			if err != nil {
+				t.Fatalf("Search: %v", err)
+			}
+			if len(results) != 1 || results[0].ID != tc.want {
+				t.Fatalf("expected only song %d, got %#v", tc.want, results)
+			}
+		})
+	}
+}
+
+func TestBuildFTSQueryNeutralizesOperators(t *testing.T) {
+	cases := map[string]string{
+		"":                   "",
+		"  ":                 "",
+		"metro":              `"metro"*`,
+		`say "NOT" OR - (x)`: `"say"* "NOT"* "OR"* "(x)"*`,
+		"pi'erre bourne":     `"pi'erre"* "bourne"*`,
+		"-- & ***":           "",
+	}
+	for in, want := range cases {
+		if got := buildFTSQuery(in); got != want {
+			t.Errorf("buildFTSQuery(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -2,7 +2,10 @@ package backend
 
 import (
 	"database/sql"
+	"fmt"
+	"os"
 	"runtime"
+	"strings"
 	"time"
 )
 
@@ -11,10 +14,11 @@ import (
 func (a *App) GetSettings() (*Settings, error) {
 	var s Settings
 	var updatedAt sql.NullInt64
+	var tagFieldPolicies string
 	err := a.db.QueryRow(`
-		SELECT id, clear_track_number_on_upload, import_to_apple_music, automatically_make_singles, updated_at
+		SELECT id, clear_track_number_on_upload, import_to_apple_music, automatically_make_singles, inbox_path, collapse_song_variants, write_provenance_tags, era_tag, featured_artist_style, tag_field_policies, updated_at
 		FROM settings WHERE id = 1
-	`).Scan(&s.ID, &s.ClearTrackNumberOnUpload, &s.ImportToAppleMusic, &s.AutomaticallyMakeSingles, &updatedAt)
+	`).Scan(&s.ID, &s.ClearTrackNumberOnUpload, &s.ImportToAppleMusic, &s.AutomaticallyMakeSingles, &s.InboxPath, &s.CollapseSongVariants, &s.WriteProvenanceTags, &s.EraTag, &s.FeaturedArtistStyle, &tagFieldPolicies, &updatedAt)
 
 	if err == sql.ErrNoRows {
 		// Initialize default settings
@@ -23,15 +27,22 @@ func (a *App) GetSettings() (*Settings, error) {
 		if _, err := a.db.Exec(`INSERT INTO settings (id, clear_track_number_on_upload, import_to_apple_music, automatically_make_singles, updated_at) VALUES (1, 0, ?, 0, ?)`, importToAppleMusic, now); err != nil {
 			return nil, err
 		}
+		policies, _ := parseTagFieldPolicies("")
 		return &Settings{
-			ID:                 1,
-			ImportToAppleMusic: importToAppleMusic,
-			UpdatedAt:          now,
+			ID:                  1,
+			ImportToAppleMusic:  importToAppleMusic,
+			EraTag:              eraTagOff,
+			FeaturedArtistStyle: featuredStyleList,
+			TagFieldPolicies:    policies,
+			UpdatedAt:           now,
 		}, nil
 	}
 	if err != nil {
 		return nil, err
 	}
+	if s.TagFieldPolicies, err = parseTagFieldPolicies(tagFieldPolicies); err != nil {
+		return nil, err
+	}
 
 	if runtime.GOOS != "darwin" {
 		s.ImportToAppleMusic = false
@@ -41,6 +52,12 @@ func (a *App) GetSettings() (*Settings, error) {
 }
 
 func (a *App) UpdateSettings(input UpdateSettingsInput) (*Settings, error) {
+	// make sure the settings row exists so the updates below land
+	current, err := a.GetSettings()
+	if err != nil {
+		return nil, err
+	}
+
 	now := time.Now().Unix()
 	if err := a.InTx(func(tx *sql.Tx) error {
 		// Build dynamic update
@@ -60,6 +77,59 @@ func (a *App) UpdateSettings(input UpdateSettingsInput) (*Settings, error) {
 				return err
 			}
 		}
+		if input.CollapseSongVariants != nil {
+			if _, err := tx.Exec(`UPDATE settings SET collapse_song_variants = ? WHERE id = 1`, *input.CollapseSongVariants); err != nil {
+				return err
+			}
+		}
+		if input.WriteProvenanceTags != nil {
+			if _, err := tx.Exec(`UPDATE settings SET write_provenance_tags = ? WHERE id = 1`, *input.WriteProvenanceTags); err != nil {
+				return err
+			}
+		}
+		if input.EraTag != nil {
+			switch *input.EraTag {
+			case eraTagOff, eraTagGrouping, eraTagAlbum:
+			default:
+				return fmt.Errorf("unknown era tag %q (want off, grouping, or album)", *input.EraTag)
+			}
+			if _, err := tx.Exec(`UPDATE settings SET era_tag = ? WHERE id = 1`, *input.EraTag); err != nil {
+				return err
+			}
+		}
+		if input.FeaturedArtistStyle != nil {
+			switch *input.FeaturedArtistStyle {
+			case featuredStyleList, featuredStyleArtist, featuredStyleTitle:
+			default:
+				return fmt.Errorf("unknown featured artist style %q (want list, artist, or title)", *input.FeaturedArtistStyle)
+			}
+			if _, err := tx.Exec(`UPDATE settings SET featured_artist_style = ? WHERE id = 1`, *input.FeaturedArtistStyle); err != nil {
+				return err
+			}
+		}
+		if len(input.TagFieldPolicies) > 0 {
+			policies, err := mergeTagFieldPolicies(current.TagFieldPolicies, input.TagFieldPolicies)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`UPDATE settings SET tag_field_policies = ? WHERE id = 1`, policies); err != nil {
+				return err
+			}
+		}
+		if input.InboxPath != nil {
+			// an empty path turns the inbox off
+			var inboxPath *string
+			if path := strings.TrimSpace(*input.InboxPath); path != "" {
+				info, err := os.Stat(path)
+				if err != nil || !info.IsDir() {
+					return fmt.Errorf("inbox folder %q is not a directory", path)
+				}
+				inboxPath = &path
+			}
+			if _, err := tx.Exec(`UPDATE settings SET inbox_path = ? WHERE id = 1`, inboxPath); err != nil {
+				return err
+			}
+		}
 		if _, err := tx.Exec(`UPDATE settings SET updated_at = ? WHERE id = 1`, now); err != nil {
 			return err
 		}
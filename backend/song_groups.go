@@ -0,0 +1,412 @@
+package backend
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// --- Song Groups ---
+
+// Variant types a grouped song can have. "og_file" is the untouched file as
+// it leaked; "session" covers stems and project files.
+const (
+	variantOriginal  = "original"
+	variantOGFile    = "og_file"
+	variantSnippet   = "snippet"
+	variantAlternate = "alternate"
+	variantSession   = "session"
+	variantOther     = "other"
+)
+
+var validVariantTypes = map[string]bool{
+	variantOriginal:  true,
+	variantOGFile:    true,
+	variantSnippet:   true,
+	variantAlternate: true,
+	variantSession:   true,
+	variantOther:     true,
+}
+
+// hiddenVariantsClause matches songs that are grouped but not their group's
+// primary, for listings that collapse variants.
+const hiddenVariantsClause = `id IN (
+	SELECT v.song_id FROM song_variants v JOIN song_groups g ON g.id = v.group_id
+	WHERE v.song_id != g.primary_song_id
+)`
+
+// GroupSongs links songs as variants of one track. Without a GroupID a new
+// group is made from at least two songs, the first being the primary unless
+// PrimarySongID says otherwise. With one, the songs are added to that group.
+// Songs already in another group are moved, and a group left with a single
+// song is dissolved.
+func (a *App) GroupSongs(input GroupSongsInput) (*SongGroup, error) {
+	songIDs := []int{}
+	seen := make(map[int]bool)
+	for _, id := range input.SongIDs {
+		if !seen[id] {
+			seen[id] = true
+			songIDs = append(songIDs, id)
+		}
+	}
+	if input.GroupID == nil && len(songIDs) < 2 {
+		return nil, fmt.Errorf("a song group needs at least two songs")
+	}
+	if len(songIDs) == 0 {
+		return nil, fmt.Errorf("no songs to add")
+	}
+	for _, id := range songIDs {
+		song, err := a.getSongByID(id)
+		if err != nil {
+			return nil, err
+		}
+		if song == nil {
+			return nil, fmt.Errorf("song %d not found", id)
+		}
+	}
+
+	now := time.Now().Unix()
+	var groupID int
+	err := a.InTx(func(tx *sql.Tx) error {
+		members := make(map[int]bool)
+		if input.GroupID != nil {
+			groupID = *input.GroupID
+			ids, err := songGroupMembersTx(tx, groupID)
+			if err != nil {
+				return err
+			}
+			if len(ids) == 0 {
+				return fmt.Errorf("song group %d not found", groupID)
+			}
+			for _, id := range ids {
+				members[id] = true
+			}
+		}
+
+		moving := []int{}
+		for _, id := range songIDs {
+			if !members[id] {
+				moving = append(moving, id)
+			}
+		}
+		if err := removeSongsFromGroupsTx(tx, moving); err != nil {
+			return err
+		}
+
+		primary := songIDs[0]
+		if input.PrimarySongID != nil {
+			primary = *input.PrimarySongID
+			if !seen[primary] && !members[primary] {
+				return fmt.Errorf("primary song %d is not in the group", primary)
+			}
+		}
+
+		if input.GroupID == nil {
+			res, err := tx.Exec(`INSERT INTO song_groups (name, primary_song_id, created_at, updated_at) VALUES (?, ?, ?, ?)`,
+				trimmedOrNil(input.Name), primary, now, now)
+			if err != nil {
+				return err
+			}
+			id, err := res.LastInsertId()
+			if err != nil {
+				return err
+			}
+			groupID = int(id)
+		} else {
+			if input.PrimarySongID != nil {
+				if _, err := tx.Exec(`UPDATE song_groups SET primary_song_id = ? WHERE id = ?`, primary, groupID); err != nil {
+					return err
+				}
+			}
+			if input.Name != nil {
+				if _, err := tx.Exec(`UPDATE song_groups SET name = ? WHERE id = ?`, trimmedOrNil(input.Name), groupID); err != nil {
+					return err
+				}
+			}
+			if _, err := tx.Exec(`UPDATE song_groups SET updated_at = ? WHERE id = ?`, now, groupID); err != nil {
+				return err
+			}
+		}
+
+		var order int
+		if err := tx.QueryRow(`SELECT COALESCE(MAX("order"), -1) + 1 FROM song_variants WHERE group_id = ?`, groupID).Scan(&order); err != nil {
+			return err
+		}
+		for _, id := range moving {
+			if _, err := tx.Exec(`INSERT INTO song_variants (group_id, song_id, variant_type, "order", created_at) VALUES (?, ?, ?, ?, ?)`,
+				groupID, id, variantOther, order, now); err != nil {
+				return err
+			}
+			order++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return a.GetSongGroup(groupID)
+}
+
+// UngroupSongs takes songs out of their groups. A group left with a single
+// song is dissolved, and one that lost its primary falls back to its first
+// remaining song.
+func (a *App) UngroupSongs(songIDs []int) error {
+	return a.InTx(func(tx *sql.Tx) error {
+		return removeSongsFromGroupsTx(tx, songIDs)
+	})
+}
+
+// DeleteSongGroup dissolves a group; its songs are left alone.
+func (a *App) DeleteSongGroup(groupID int) error {
+	return a.InTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM song_variants WHERE group_id = ?`, groupID); err != nil {
+			return err
+		}
+		res, err := tx.Exec(`DELETE FROM song_groups WHERE id = ?`, groupID)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return fmt.Errorf("song group %d not found", groupID)
+		}
+		return nil
+	})
+}
+
+// SetPrimaryVariant makes a grouped song the one that stands in for its
+// group.
+func (a *App) SetPrimaryVariant(songID int) (*SongGroup, error) {
+	var groupID int
+	err := a.db.QueryRow(`SELECT group_id FROM song_variants WHERE song_id = ?`, songID).Scan(&groupID)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("song %d is not in a group", songID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := a.db.Exec(`UPDATE song_groups SET primary_song_id = ?, updated_at = ? WHERE id = ?`, songID, time.Now().Unix(), groupID); err != nil {
+		return nil, err
+	}
+	return a.GetSongGroup(groupID)
+}
+
+// UpdateSongVariant sets a grouped song's variant type and quality label
+// (free text such as "CDQ", "HQ" or "LQ").
+func (a *App) UpdateSongVariant(input UpdateSongVariantInput) (*SongGroup, error) {
+	var groupID int
+	err := a.db.QueryRow(`SELECT group_id FROM song_variants WHERE song_id = ?`, input.SongID).Scan(&groupID)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("song %d is not in a group", input.SongID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = a.InTx(func(tx *sql.Tx) error {
+		if input.VariantType != nil {
+			if !validVariantTypes[*input.VariantType] {
+				return fmt.Errorf("unknown variant type %q", *input.VariantType)
+			}
+			if _, err := tx.Exec(`UPDATE song_variants SET variant_type = ? WHERE song_id = ?`, *input.VariantType, input.SongID); err != nil {
+				return err
+			}
+		}
+		if input.QualityLabel != nil {
+			if _, err := tx.Exec(`UPDATE song_variants SET quality_label = ? WHERE song_id = ?`, trimmedOrNil(input.QualityLabel), input.SongID); err != nil {
+				return err
+			}
+		}
+		_, err := tx.Exec(`UPDATE song_groups SET updated_at = ? WHERE id = ?`, time.Now().Unix(), groupID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return a.GetSongGroup(groupID)
+}
+
+// GetSongGroup returns a group with its variants, primary first.
+func (a *App) GetSongGroup(groupID int) (*SongGroup, error) {
+	var group SongGroup
+	var createdAt, updatedAt sql.NullInt64
+	err := a.db.QueryRow(`SELECT id, name, primary_song_id, created_at, updated_at FROM song_groups WHERE id = ?`, groupID).
+		Scan(&group.ID, &group.Name, &group.PrimarySongID, &createdAt, &updatedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("song group %d not found", groupID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	group.CreatedAt = createdAt.Int64
+	group.UpdatedAt = updatedAt.Int64
+
+	rows, err := a.db.Query(`
+		SELECT v.song_id, s.name, v.variant_type, v.quality_label
+		FROM song_variants v
+		JOIN songs s ON s.id = v.song_id
+		WHERE v.group_id = ?
+		ORDER BY v.song_id != ?, v."order", v.song_id
+	`, groupID, group.PrimarySongID)
+	if err != nil {
+		return nil, err
+	}
+	group.Variants = []SongVariant{}
+	for rows.Next() {
+		var v SongVariant
+		if err := rows.Scan(&v.SongID, &v.Name, &v.VariantType, &v.QualityLabel); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		v.IsPrimary = v.SongID == group.PrimarySongID
+		group.Variants = append(group.Variants, v)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range group.Variants {
+		artists, err := a.getArtistsForSong(group.Variants[i].SongID)
+		if err != nil {
+			return nil, err
+		}
+		names := make([]string, len(artists))
+		for j, art := range artists {
+			names[j] = art.Name
+		}
+		group.Variants[i].Artist = strings.Join(names, ", ")
+	}
+	return &group, nil
+}
+
+// GetSongGroups returns every song group, newest first.
+func (a *App) GetSongGroups() ([]SongGroup, error) {
+	rows, err := a.db.Query(`SELECT id FROM song_groups ORDER BY created_at DESC, id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	groups := []SongGroup{}
+	for _, id := range ids {
+		group, err := a.GetSongGroup(id)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, *group)
+	}
+	return groups, nil
+}
+
+// getSongVariantInfo returns the song's place in its group, or nil when it
+// isn't grouped.
+func (a *App) getSongVariantInfo(songID int) (*SongVariantInfo, error) {
+	var info SongVariantInfo
+	var primaryID int
+	err := a.db.QueryRow(`
+		SELECT v.group_id, v.variant_type, v.quality_label, g.primary_song_id,
+			(SELECT COUNT(*) FROM song_variants WHERE group_id = v.group_id)
+		FROM song_variants v
+		JOIN song_groups g ON g.id = v.group_id
+		WHERE v.song_id = ?
+	`, songID).Scan(&info.GroupID, &info.VariantType, &info.QualityLabel, &primaryID, &info.VariantCount)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	info.IsPrimary = primaryID == songID
+	return &info, nil
+}
+
+func songGroupMembersTx(tx *sql.Tx, groupID int) ([]int, error) {
+	rows, err := tx.Query(`SELECT song_id FROM song_variants WHERE group_id = ? ORDER BY "order", song_id`, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// removeSongsFromGroupsTx drops the songs' variant rows, then dissolves any
+// group left with fewer than two songs and moves the primary of one that
+// lost it to its first remaining song.
+func removeSongsFromGroupsTx(tx *sql.Tx, songIDs []int) error {
+	touched := []int{}
+	seen := make(map[int]bool)
+	for _, songID := range songIDs {
+		var groupID int
+		err := tx.QueryRow(`SELECT group_id FROM song_variants WHERE song_id = ?`, songID).Scan(&groupID)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM song_variants WHERE song_id = ?`, songID); err != nil {
+			return err
+		}
+		if !seen[groupID] {
+			seen[groupID] = true
+			touched = append(touched, groupID)
+		}
+	}
+
+	now := time.Now().Unix()
+	for _, groupID := range touched {
+		members, err := songGroupMembersTx(tx, groupID)
+		if err != nil {
+			return err
+		}
+		if len(members) < 2 {
+			if _, err := tx.Exec(`DELETE FROM song_variants WHERE group_id = ?`, groupID); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`DELETE FROM song_groups WHERE id = ?`, groupID); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := tx.Exec(`
+			UPDATE song_groups SET primary_song_id = ?, updated_at = ?
+			WHERE id = ? AND primary_song_id NOT IN (SELECT song_id FROM song_variants WHERE group_id = ?)
+		`, members[0], now, groupID, groupID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func trimmedOrNil(s *string) *string {
+	if s == nil {
+		return nil
+	}
+	trimmed := strings.TrimSpace(*s)
+	if trimmed == "" {
+		return nil
+	}
+	return &trimmed
+}
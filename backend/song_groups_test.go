@@ -0,0 +1,143 @@
+package backend
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSongGroupsAndCollapsedListing(t *testing.T) {
+	app := newTestApp(t)
+
+	artist, err := app.CreateArtist(CreateArtistInput{Name: "Juice WRLD"})
+	if err != nil {
+		t.Fatalf("CreateArtist: %v", err)
+	}
+	newSong := func(name string) *Song {
+		t.Helper()
+		song, err := app.CreateSong(CreateSongInput{Name: name, Filepath: "uploads/songs/" + name + ".mp3", ArtistIDs: []int{artist.ID}})
+		if err != nil {
+			t.Fatalf("CreateSong: %v", err)
+		}
+		return song
+	}
+	snippet := newSong("Cigarettes (Snippet)")
+	cdq := newSong("Cigarettes (CDQ)")
+	session := newSong("Cigarettes (Session)")
+	other := newSong("Other")
+
+	name := "Cigarettes"
+	group, err := app.GroupSongs(GroupSongsInput{SongIDs: []int{snippet.ID, cdq.ID}, PrimarySongID: &cdq.ID, Name: &name})
+	if err != nil {
+		t.Fatalf("GroupSongs: %v", err)
+	}
+	if _, err := app.GroupSongs(GroupSongsInput{SongIDs: []int{session.ID}, GroupID: &group.ID}); err != nil {
+		t.Fatalf("GroupSongs (add): %v", err)
+	}
+	variantType, label := variantSnippet, "LQ"
+	group, err = app.UpdateSongVariant(UpdateSongVariantInput{SongID: snippet.ID, VariantType: &variantType, QualityLabel: &label})
+	if err != nil {
+		t.Fatalf("UpdateSongVariant: %v", err)
+	}
+	if len(group.Variants) != 3 || group.Variants[0].SongID != cdq.ID || !group.Variants[0].IsPrimary ||
+		group.Variants[1].VariantType != variantSnippet || *group.Variants[1].QualityLabel != "LQ" {
+		t.Fatalf("unexpected group: %+v", group)
+	}
+	bogus := "demo"
+	if _, err := app.UpdateSongVariant(UpdateSongVariantInput{SongID: snippet.ID, VariantType: &bogus}); err == nil {
+		t.Fatal("expected an unknown variant type to be rejected")
+	}
+
+	readable, err := app.GetSongReadable(snippet.ID)
+	if err != nil {
+		t.Fatalf("GetSongReadable: %v", err)
+	}
+	if readable.Variant == nil || readable.Variant.IsPrimary || readable.Variant.VariantCount != 3 {
+		t.Fatalf("unexpected variant info: %+v", readable.Variant)
+	}
+
+	if songs, _ := app.GetSongsReadable(10, 0); len(songs) != 4 {
+		t.Fatalf("expected every song while not collapsed, got %d", len(songs))
+	}
+	collapse := true
+	if _, err := app.UpdateSettings(UpdateSettingsInput{CollapseSongVariants: &collapse}); err != nil {
+		t.Fatalf("UpdateSettings: %v", err)
+	}
+	songs, err := app.GetSongsReadable(10, 0)
+	if err != nil {
+		t.Fatalf("GetSongsReadable: %v", err)
+	}
+	if count, _ := app.GetSongsCount(); count != 2 || len(songs) != 2 {
+		t.Fatalf("expected the primary and the ungrouped song, got %d songs (count %d)", len(songs), count)
+	}
+	for _, song := range songs {
+		if song.ID != cdq.ID && song.ID != other.ID {
+			t.Fatalf("unexpected song %q in collapsed list", song.Name)
+		}
+	}
+
+	// deleting the primary hands it to the first remaining variant
+	if err := app.DeleteSong(cdq.ID); err != nil {
+		t.Fatalf("DeleteSong: %v", err)
+	}
+	group, err = app.GetSongGroup(group.ID)
+	if err != nil {
+		t.Fatalf("GetSongGroup: %v", err)
+	}
+	if group.PrimarySongID != snippet.ID || len(group.Variants) != 2 {
+		t.Fatalf("expected the snippet to become primary, got %+v", group)
+	}
+
+	// a group left with one song is dissolved
+	if err := app.UngroupSongs([]int{session.ID}); err != nil {
+		t.Fatalf("UngroupSongs: %v", err)
+	}
+	if groups, _ := app.GetSongGroups(); len(groups) != 0 {
+		t.Fatalf("expected the group to be dissolved, got %+v", groups)
+	}
+	if info, _ := app.getSongVariantInfo(snippet.ID); info != nil {
+		t.Fatalf("expected the snippet to be ungrouped, got %+v", info)
+	}
+}
+
+func TestSongGroupsSurviveLibraryExport(t *testing.T) {
+	source := newTestApp(t)
+	artist, err := source.CreateArtist(CreateArtistInput{Name: "Lil Uzi Vert"})
+	if err != nil {
+		t.Fatalf("CreateArtist: %v", err)
+	}
+	first, err := source.CreateSong(CreateSongInput{Name: "Demon High", Filepath: "uploads/songs/a.mp3", ArtistIDs: []int{artist.ID}})
+	if err != nil {
+		t.Fatalf("CreateSong: %v", err)
+	}
+	second, err := source.CreateSong(CreateSongInput{Name: "Demon High (OG)", Filepath: "uploads/songs/b.mp3", ArtistIDs: []int{artist.ID}})
+	if err != nil {
+		t.Fatalf("CreateSong: %v", err)
+	}
+	if _, err := source.GroupSongs(GroupSongsInput{SongIDs: []int{first.ID, second.ID}, PrimarySongID: &second.ID}); err != nil {
+		t.Fatalf("GroupSongs: %v", err)
+	}
+	variantType := variantOGFile
+	if _, err := source.UpdateSongVariant(UpdateSongVariantInput{SongID: second.ID, VariantType: &variantType}); err != nil {
+		t.Fatalf("UpdateSongVariant: %v", err)
+	}
+
+	var export bytes.Buffer
+	if err := source.writeLibraryExport(&export, libraryFormatJSON); err != nil {
+		t.Fatalf("writeLibraryExport: %v", err)
+	}
+	target := newTestApp(t)
+	result, err := target.importLibrary(bytes.NewReader(export.Bytes()), false)
+	if err != nil {
+		t.Fatalf("importLibrary: %v", err)
+	}
+	if result.SongGroups != 1 {
+		t.Fatalf("expected one song group, got %+v", result)
+	}
+	groups, err := target.GetSongGroups()
+	if err != nil {
+		t.Fatalf("GetSongGroups: %v", err)
+	}
+	if len(groups) != 1 || groups[0].Variants[0].Name != "Demon High (OG)" || groups[0].Variants[0].VariantType != variantOGFile {
+		t.Fatalf("unexpected imported groups: %+v", groups)
+	}
+}
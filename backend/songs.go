@@ -4,20 +4,36 @@ import (
 	"database/sql"
 	"fmt"
 	"os"
-	"strings"
 	"time"
 )
 
 // --- Song CRUD ---
 
 func (a *App) CreateSong(input CreateSongInput) (*Song, error) {
+	provenance, err := normalizeProvenance(input.Provenance)
+	if err != nil {
+		return nil, err
+	}
+	roles, err := normalizeArtistRoles(input.ArtistIDs, input.ArtistRoles)
+	if err != nil {
+		return nil, err
+	}
+	producerRoles, err := normalizeProducerRoles(input.ProducerIDs, input.ProducerRoles)
+	if err != nil {
+		return nil, err
+	}
+
 	now := time.Now().Unix()
 	var songID int64
-	err := a.InTx(func(tx *sql.Tx) error {
+	err = a.InTx(func(tx *sql.Tx) error {
 		result, err := tx.Exec(
-			`INSERT INTO songs (name, filepath, album_id, artwork_path, genre, year, track_number, duration, created_at, updated_at)
-			 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-			input.Name, input.Filepath, input.AlbumID, input.ArtworkPath, input.Genre, input.Year, input.TrackNumber, input.Duration, now, now,
+			`INSERT INTO songs (name, filepath, album_id, artwork_path, genre, year, track_number, duration, file_type, bitrate, sample_rate, channels, bit_depth, codec,
+				leak_date, leak_source, recording_date, leak_status, notes, created_at, updated_at)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			input.Name, input.Filepath, input.AlbumID, input.ArtworkPath, input.Genre, input.Year, input.TrackNumber, input.Duration,
+			input.FileType, input.Bitrate, input.SampleRate, input.Channels, input.BitDepth, input.Codec,
+			trimmedOrNil(provenance.LeakDate), trimmedOrNil(provenance.LeakSource), trimmedOrNil(provenance.RecordingDate),
+			trimmedOrNil(provenance.LeakStatus), trimmedOrNil(provenance.Notes), now, now,
 		)
 		if err != nil {
 			return err
@@ -29,25 +45,12 @@ func (a *App) CreateSong(input CreateSongInput) (*Song, error) {
 		}
 
 		// Link artists
-		for i, artistID := range input.ArtistIDs {
-			if _, err := tx.Exec(
-				`INSERT INTO song_artists (song_id, artist_id, "order", created_at) VALUES (?, ?, ?, ?)`,
-				songID, artistID, i, now,
-			); err != nil {
-				return err
-			}
+		if err := linkSongArtistsTx(tx, songID, input.ArtistIDs, roles, now); err != nil {
+			return err
 		}
 
 		// Link producers
-		for i, producerID := range input.ProducerIDs {
-			if _, err := tx.Exec(
-				`INSERT INTO song_producers (song_id, producer_id, "order", created_at) VALUES (?, ?, ?, ?)`,
-				songID, producerID, i, now,
-			); err != nil {
-				return err
-			}
-		}
-		return nil
+		return linkSongProducersTx(tx, songID, input.ProducerIDs, producerRoles, now)
 	})
 	if err != nil {
 		return nil, err
@@ -63,12 +66,30 @@ func (a *App) CreateSong(input CreateSongInput) (*Song, error) {
 		Year:        input.Year,
 		TrackNumber: input.TrackNumber,
 		Duration:    input.Duration,
+		FileType:    input.FileType,
+		Bitrate:     input.Bitrate,
+		SampleRate:  input.SampleRate,
+		Channels:    input.Channels,
+		BitDepth:    input.BitDepth,
+		Codec:       input.Codec,
 		CreatedAt:   now,
 		UpdatedAt:   now,
 	}, nil
 }
 
 func (a *App) UpdateSong(input UpdateSongInput) (*SongReadable, error) {
+	provenance, err := normalizeProvenance(input.Provenance)
+	if err != nil {
+		return nil, err
+	}
+	roles, err := normalizeArtistRoles(input.ArtistIDs, input.ArtistRoles)
+	if err != nil {
+		return nil, err
+	}
+	producerRoles, err := normalizeProducerRoles(input.ProducerIDs, input.ProducerRoles)
+	if err != nil {
+		return nil, err
+	}
 	now := time.Now().Unix()
 
 	albumID := input.AlbumID
@@ -88,7 +109,7 @@ func (a *App) UpdateSong(input UpdateSongInput) (*SongReadable, error) {
 		}
 	}
 
-	err := a.InTx(func(tx *sql.Tx) error {
+	err = a.InTx(func(tx *sql.Tx) error {
 		// Update song
 		if _, err := tx.Exec(
 			`UPDATE songs SET name = COALESCE(?, name), album_id = ?, track_number = ?, updated_at = ? WHERE id = ?`,
@@ -97,18 +118,23 @@ func (a *App) UpdateSong(input UpdateSongInput) (*SongReadable, error) {
 			return err
 		}
 
+		// Update provenance fields that were sent
+		for _, field := range provenanceColumns(provenance) {
+			if field.value == nil {
+				continue
+			}
+			if _, err := tx.Exec(`UPDATE songs SET `+field.column+` = ? WHERE id = ?`, trimmedOrNil(field.value), input.ID); err != nil {
+				return err
+			}
+		}
+
 		// Update artist links
 		if input.ArtistIDs != nil {
 			if _, err := tx.Exec(`DELETE FROM song_artists WHERE song_id = ?`, input.ID); err != nil {
 				return err
 			}
-			for i, artistID := range input.ArtistIDs {
-				if _, err := tx.Exec(
-					`INSERT INTO song_artists (song_id, artist_id, "order", created_at) VALUES (?, ?, ?, ?)`,
-					input.ID, artistID, i, now,
-				); err != nil {
-					return err
-				}
+			if err := linkSongArtistsTx(tx, int64(input.ID), input.ArtistIDs, roles, now); err != nil {
+				return err
 			}
 		}
 
@@ -117,13 +143,8 @@ func (a *App) UpdateSong(input UpdateSongInput) (*SongReadable, error) {
 			if _, err := tx.Exec(`DELETE FROM song_producers WHERE song_id = ?`, input.ID); err != nil {
 				return err
 			}
-			for i, producerID := range input.ProducerIDs {
-				if _, err := tx.Exec(
-					`INSERT INTO song_producers (song_id, producer_id, "order", created_at) VALUES (?, ?, ?, ?)`,
-					input.ID, producerID, i, now,
-				); err != nil {
-					return err
-				}
+			if err := linkSongProducersTx(tx, int64(input.ID), input.ProducerIDs, producerRoles, now); err != nil {
+				return err
 			}
 		}
 		return nil
@@ -143,6 +164,13 @@ func (a *App) DeleteSong(songID int) error {
 			return err
 		}
 
+		if err := removeSongsFromGroupsTx(tx, []int{songID}); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM playlist_songs WHERE song_id = ?`, songID); err != nil {
+			return err
+		}
+
 		// Delete song from DB
 		if _, err := tx.Exec(`DELETE FROM songs WHERE id = ?`, songID); err != nil {
 			return err
@@ -175,10 +203,17 @@ func (a *App) GetSongReadable(songID int) (*SongReadable, error) {
 	return a.buildSongReadable(*song)
 }
 
+// GetSongsReadable lists songs, newest first. With the collapse setting on,
+// a song group shows up only as its primary song.
 func (a *App) GetSongsReadable(limit, offset int) ([]SongReadable, error) {
+	where, err := a.songListFilter()
+	if err != nil {
+		return nil, err
+	}
 	rows, err := a.db.Query(`
-		SELECT id, name, album_id, artwork_path, genre, year, track_number, duration, filepath, file_type, created_at, updated_at, synced, apple_music_id
+		SELECT id, name, album_id, artwork_path, genre, year, track_number, duration, filepath, file_type, created_at, updated_at, synced, apple_music_id, bitrate, sample_rate, channels, bit_depth, codec, leak_date, leak_source, recording_date, leak_status, notes, era_id
 		FROM songs
+		`+where+`
 		ORDER BY created_at DESC
 		LIMIT ? OFFSET ?
 	`, limit, offset)
@@ -191,7 +226,7 @@ func (a *App) GetSongsReadable(limit, offset int) ([]SongReadable, error) {
 	for rows.Next() {
 		var song Song
 		var createdAt, updatedAt sql.NullInt64
-		err := rows.Scan(&song.ID, &song.Name, &song.AlbumID, &song.ArtworkPath, &song.Genre, &song.Year, &song.TrackNumber, &song.Duration, &song.Filepath, &song.FileType, &createdAt, &updatedAt, &song.Synced, &song.AppleMusicID)
+		err := rows.Scan(&song.ID, &song.Name, &song.AlbumID, &song.ArtworkPath, &song.Genre, &song.Year, &song.TrackNumber, &song.Duration, &song.Filepath, &song.FileType, &createdAt, &updatedAt, &song.Synced, &song.AppleMusicID, &song.Bitrate, &song.SampleRate, &song.Channels, &song.BitDepth, &song.Codec, &song.LeakDate, &song.LeakSource, &song.RecordingDate, &song.LeakStatus, &song.Notes, &song.EraID)
 		if err != nil {
 			return nil, err
 		}
@@ -212,14 +247,11 @@ func (a *App) GetSongsReadable(limit, offset int) ([]SongReadable, error) {
 }
 
 func (a *App) buildSongReadable(song Song) (*SongReadable, error) {
-	artists, err := a.getArtistsForSong(song.ID)
+	artists, err := a.getSongArtists(song.ID)
 	if err != nil {
 		return nil, fmt.Errorf("load artists for song %d: %w", song.ID, err)
 	}
-	artistNames := make([]string, len(artists))
-	for i, art := range artists {
-		artistNames[i] = art.Name
-	}
+	artistDisplay, _ := renderArtistCredits(song.Name, artists, featuredStyleArtist)
 
 	producers, err := a.getProducersForSong(song.ID)
 	if err != nil {
@@ -234,12 +266,25 @@ func (a *App) buildSongReadable(song Song) (*SongReadable, error) {
 		}
 	}
 
+	variant, err := a.getSongVariantInfo(song.ID)
+	if err != nil {
+		return nil, fmt.Errorf("load variant info for song %d: %w", song.ID, err)
+	}
+
+	era, eraSource, err := a.resolveSongEra(song)
+	if err != nil {
+		return nil, fmt.Errorf("load era for song %d: %w", song.ID, err)
+	}
+
 	return &SongReadable{
 		Song:      song,
-		Artist:    strings.Join(artistNames, ", "),
+		Artist:    artistDisplay,
 		Artists:   artists,
 		Producers: producers,
 		Album:     album,
+		Variant:   variant,
+		Era:       era,
+		EraSource: eraSource,
 	}, nil
 }
 
@@ -247,7 +292,7 @@ func (a *App) getSongByID(songID int) (*Song, error) {
 	var song Song
 	var createdAt, updatedAt sql.NullInt64
 	err := a.db.QueryRow(`
-		SELECT id, name, album_id, artwork_path, genre, year, track_number, duration, filepath, file_type, created_at, updated_at, synced, apple_music_id
+		SELECT id, name, album_id, artwork_path, genre, year, track_number, duration, filepath, file_type, created_at, updated_at, synced, apple_music_id, bitrate, sample_rate, channels, bit_depth, codec, leak_date, leak_source, recording_date, leak_status, notes, era_id
 		FROM songs
 		WHERE id = ?
 	`, songID).Scan(
@@ -265,6 +310,17 @@ func (a *App) getSongByID(songID int) (*Song, error) {
 		&updatedAt,
 		&song.Synced,
 		&song.AppleMusicID,
+		&song.Bitrate,
+		&song.SampleRate,
+		&song.Channels,
+		&song.BitDepth,
+		&song.Codec,
+		&song.LeakDate,
+		&song.LeakSource,
+		&song.RecordingDate,
+		&song.LeakStatus,
+		&song.Notes,
+		&song.EraID,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -278,46 +334,55 @@ func (a *App) getSongByID(songID int) (*Song, error) {
 	return &song, nil
 }
 
+// songIDByFilepath returns the ID of a song stored at relPath, or 0.
+func (a *App) songIDByFilepath(relPath string) (int, error) {
+	var id int
+	err := a.db.QueryRow(`SELECT id FROM songs WHERE filepath = ? ORDER BY id LIMIT 1`, relPath).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return id, err
+}
+
+// GetSongsCount counts the songs GetSongsReadable pages through.
 func (a *App) GetSongsCount() (int, error) {
+	where, err := a.songListFilter()
+	if err != nil {
+		return 0, err
+	}
 	var count int
-	err := a.db.QueryRow(`SELECT COUNT(*) FROM songs`).Scan(&count)
+	err = a.db.QueryRow(`SELECT COUNT(*) FROM songs ` + where).Scan(&count)
 	return count, err
 }
 
-func (a *App) getArtistsForSong(songID int) ([]Artist, error) {
-	rows, err := a.db.Query(`
-		SELECT ar.id, ar.name, ar.image, ar.career_start_year, ar.career_end_year, ar.created_at, ar.updated_at, ar.synced
-		FROM artists ar
-		JOIN song_artists sa ON ar.id = sa.artist_id
-		WHERE sa.song_id = ?
-		ORDER BY sa."order"
-	`, songID)
+// songListFilter is the WHERE clause for the main song list, hiding
+// non-primary variants when the collapse setting is on.
+func (a *App) songListFilter() (string, error) {
+	settings, err := a.GetSettings()
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	defer rows.Close()
-
-	artists := []Artist{}
-	for rows.Next() {
-		var art Artist
-		var createdAt, updatedAt sql.NullInt64
-		err := rows.Scan(&art.ID, &art.Name, &art.Image, &art.CareerStartYear, &art.CareerEndYear, &createdAt, &updatedAt, &art.Synced)
-		if err != nil {
-			return nil, err
-		}
-		art.CreatedAt = createdAt.Int64
-		art.UpdatedAt = updatedAt.Int64
-		artists = append(artists, art)
+	if settings.CollapseSongVariants {
+		return "WHERE NOT " + hiddenVariantsClause, nil
 	}
-	if err := rows.Err(); err != nil {
+	return "", nil
+}
+
+func (a *App) getArtistsForSong(songID int) ([]Artist, error) {
+	credits, err := a.getSongArtists(songID)
+	if err != nil {
 		return nil, err
 	}
+	artists := make([]Artist, len(credits))
+	for i, credit := range credits {
+		artists[i] = credit.Artist
+	}
 	return artists, nil
 }
 
-func (a *App) getProducersForSong(songID int) ([]Producer, error) {
+func (a *App) getProducersForSong(songID int) ([]SongProducer, error) {
 	rows, err := a.db.Query(`
-		SELECT p.id, p.name, p.created_at, p.updated_at
+		SELECT p.id, p.name, p.created_at, p.updated_at, sp.role
 		FROM producers p
 		JOIN song_producers sp ON p.id = sp.producer_id
 		WHERE sp.song_id = ?
@@ -328,11 +393,11 @@ func (a *App) getProducersForSong(songID int) ([]Producer, error) {
 	}
 	defer rows.Close()
 
-	producers := []Producer{}
+	producers := []SongProducer{}
 	for rows.Next() {
-		var prod Producer
+		var prod SongProducer
 		var createdAt, updatedAt sql.NullInt64
-		err := rows.Scan(&prod.ID, &prod.Name, &createdAt, &updatedAt)
+		err := rows.Scan(&prod.ID, &prod.Name, &createdAt, &updatedAt, &prod.Role)
 		if err != nil {
 			return nil, err
 		}
@@ -350,9 +415,9 @@ func (a *App) getAlbumByID(albumID int) (*Album, error) {
 	var alb Album
 	var createdAt, updatedAt sql.NullInt64
 	err := a.db.QueryRow(`
-		SELECT id, name, artwork_path, genre, year, is_single, created_at, updated_at, synced
+		SELECT id, name, artwork_path, genre, year, is_single, created_at, updated_at, synced, era_id
 		FROM albums WHERE id = ?
-	`, albumID).Scan(&alb.ID, &alb.Name, &alb.ArtworkPath, &alb.Genre, &alb.Year, &alb.IsSingle, &createdAt, &updatedAt, &alb.Synced)
+	`, albumID).Scan(&alb.ID, &alb.Name, &alb.ArtworkPath, &alb.Genre, &alb.Year, &alb.IsSingle, &createdAt, &updatedAt, &alb.Synced, &alb.EraID)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -0,0 +1,154 @@
+package backend
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// --- Tag Field Policies ---
+
+// The fields the library writes, plus tagFieldOther for everything else a
+// file carries: comments, lyrics, ReplayGain, ISRCs, encoder details,
+// source URLs.
+const (
+	tagFieldTitle       = "title"
+	tagFieldArtist      = "artist"
+	tagFieldAlbumArtist = "albumArtist"
+	tagFieldAlbum       = "album"
+	tagFieldGenre       = "genre"
+	tagFieldYear        = "year"
+	tagFieldTrack       = "track"
+	tagFieldProducers   = "producers"
+	tagFieldGrouping    = "grouping"
+	tagFieldArtwork     = "artwork"
+	tagFieldCustom      = "custom"
+	tagFieldOther       = "other"
+)
+
+// What writing metadata does to a field: overwrite it with the library's
+// value (clearing it when the library has none), preserve whatever the
+// file has, or remove it.
+const (
+	tagPolicyOverwrite = "overwrite"
+	tagPolicyPreserve  = "preserve"
+	tagPolicyRemove    = "remove"
+)
+
+var tagFields = []string{
+	tagFieldTitle, tagFieldArtist, tagFieldAlbumArtist, tagFieldAlbum, tagFieldGenre, tagFieldYear,
+	tagFieldTrack, tagFieldProducers, tagFieldGrouping, tagFieldArtwork, tagFieldCustom, tagFieldOther,
+}
+
+// defaultTagPolicy overwrites the library's fields and keeps the rest.
+func defaultTagPolicy(field string) string {
+	if field == tagFieldOther {
+		return tagPolicyPreserve
+	}
+	return tagPolicyOverwrite
+}
+
+// parseTagFieldPolicies reads settings.tag_field_policies, which only
+// holds the fields that were changed, and fills in the defaults.
+func parseTagFieldPolicies(data string) (map[string]string, error) {
+	stored := map[string]string{}
+	if data != "" {
+		if err := json.Unmarshal([]byte(data), &stored); err != nil {
+			return nil, fmt.Errorf("invalid tag field policies: %w", err)
+		}
+	}
+	policies := make(map[string]string, len(tagFields))
+	for _, field := range tagFields {
+		policies[field] = defaultTagPolicy(field)
+		if policy, ok := stored[field]; ok {
+			policies[field] = policy
+		}
+	}
+	return policies, nil
+}
+
+// mergeTagFieldPolicies applies changes to current, rejecting unknown
+// fields and policies, and returns the JSON to store: the fields that
+// differ from their default.
+func mergeTagFieldPolicies(current, changes map[string]string) (string, error) {
+	merged := maps.Clone(current)
+	for field, policy := range changes {
+		if !slices.Contains(tagFields, field) {
+			return "", fmt.Errorf("unknown tag field %q", field)
+		}
+		switch policy {
+		case tagPolicyOverwrite:
+			if field == tagFieldOther {
+				return "", fmt.Errorf("the library has no value to overwrite other tags with (want preserve or remove)")
+			}
+		case tagPolicyPreserve, tagPolicyRemove:
+		default:
+			return "", fmt.Errorf("unknown policy %q for %s (want overwrite, preserve, or remove)", policy, field)
+		}
+		merged[field] = policy
+	}
+	maps.DeleteFunc(merged, func(field, policy string) bool { return policy == defaultTagPolicy(field) })
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// tagPolicy returns what Write does to field; tags without policies get
+// the defaults.
+func (t SongTags) tagPolicy(field string) string {
+	if policy, ok := t.Policies[field]; ok {
+		return policy
+	}
+	return defaultTagPolicy(field)
+}
+
+// keeps reports whether an adapter leaves the file's own values for field
+// in place.
+func (t SongTags) keeps(field string) bool {
+	return t.tagPolicy(field) == tagPolicyPreserve
+}
+
+// forWrite clears the fields that aren't overwritten, so adapters only
+// render the ones they replace.
+func (t SongTags) forWrite() SongTags {
+	out := t
+	skip := func(field string) bool { return t.tagPolicy(field) != tagPolicyOverwrite }
+	if skip(tagFieldTitle) {
+		out.Title = ""
+	}
+	if skip(tagFieldArtist) {
+		out.Artist = ""
+	}
+	if skip(tagFieldAlbumArtist) {
+		out.AlbumArtist = ""
+	}
+	if skip(tagFieldAlbum) {
+		out.Album = ""
+	}
+	if skip(tagFieldGenre) {
+		out.Genre = ""
+	}
+	if skip(tagFieldYear) {
+		out.Year = 0
+	}
+	if skip(tagFieldTrack) {
+		out.TrackNumberStr, out.TrackNumber, out.TrackTotal = "", 0, 0
+		out.DiscNumber, out.DiscTotal = 0, 0
+	}
+	if skip(tagFieldProducers) {
+		out.Producers, out.Credits = "", nil
+	}
+	if skip(tagFieldGrouping) {
+		out.Grouping = ""
+	}
+	if skip(tagFieldArtwork) {
+		out.ArtworkPath, out.ArtworkMimeType = "", ""
+	}
+	if skip(tagFieldCustom) {
+		out.Custom = nil
+	}
+	return out
+}
@@ -0,0 +1,226 @@
+package backend
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bogem/id3v2"
+)
+
+func TestTagFieldPolicySettings(t *testing.T) {
+	app := newTestApp(t)
+
+	settings, err := app.GetSettings()
+	if err != nil {
+		t.Fatalf("GetSettings: %v", err)
+	}
+	if settings.TagFieldPolicies[tagFieldTitle] != tagPolicyOverwrite || settings.TagFieldPolicies[tagFieldOther] != tagPolicyPreserve {
+		t.Fatalf("unexpected default policies: %v", settings.TagFieldPolicies)
+	}
+
+	settings, err = app.UpdateSettings(UpdateSettingsInput{TagFieldPolicies: map[string]string{
+		tagFieldGenre: tagPolicyPreserve, tagFieldYear: tagPolicyRemove,
+	}})
+	if err != nil {
+		t.Fatalf("UpdateSettings: %v", err)
+	}
+	if settings.TagFieldPolicies[tagFieldGenre] != tagPolicyPreserve || settings.TagFieldPolicies[tagFieldYear] != tagPolicyRemove ||
+		settings.TagFieldPolicies[tagFieldTitle] != tagPolicyOverwrite {
+		t.Fatalf("unexpected policies: %v", settings.TagFieldPolicies)
+	}
+
+	for _, bad := range []map[string]string{
+		{"lyrics": tagPolicyPreserve},
+		{tagFieldTitle: "keep"},
+		{tagFieldOther: tagPolicyOverwrite},
+	} {
+		if _, err := app.UpdateSettings(UpdateSettingsInput{TagFieldPolicies: bad}); err == nil {
+			t.Fatalf("expected %v to be rejected", bad)
+		}
+	}
+}
+
+func TestWriteSongMetadataKeepsUnmanagedFrames(t *testing.T) {
+	app := newTestApp(t)
+
+	relPath := "uploads/songs/leak.mp3"
+	fullPath := writeSilentMP3(t, app, relPath)
+
+	// a leak as it usually arrives: a few of our fields plus extras
+	tag, err := id3v2.Open(fullPath, id3v2.Options{Parse: true})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	tag.SetVersion(3)
+	tag.SetTitle("leak_final_v2")
+	tag.SetGenre("Trap")
+	tag.SetYear("2016")
+	tag.AddCommentFrame(id3v2.CommentFrame{Encoding: id3v2.EncodingUTF8, Language: "eng", Text: "ripped from the group chat"})
+	tag.AddUnsynchronisedLyricsFrame(id3v2.UnsynchronisedLyricsFrame{Encoding: id3v2.EncodingUTF8, Language: "eng", Lyrics: "la la la"})
+	tag.AddTextFrame("TSRC", id3v2.EncodingUTF8, "USUM71703861")
+	tag.AddUserDefinedTextFrame(id3v2.UserDefinedTextFrame{Encoding: id3v2.EncodingUTF8, Description: "REPLAYGAIN_TRACK_GAIN", Value: "-6.20 dB"})
+	if err := tag.Save(); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	tag.Close()
+
+	song, err := app.CreateSong(CreateSongInput{Name: "Mask Off", Filepath: relPath})
+	if err != nil {
+		t.Fatalf("CreateSong: %v", err)
+	}
+	if _, err := app.UpdateSettings(UpdateSettingsInput{TagFieldPolicies: map[string]string{
+		tagFieldGenre: tagPolicyPreserve, tagFieldYear: tagPolicyRemove,
+	}}); err != nil {
+		t.Fatalf("UpdateSettings: %v", err)
+	}
+	if res, _ := app.WriteSongMetadata(song.ID); !res.Success {
+		t.Fatalf("WriteSongMetadata: %s", res.Error)
+	}
+
+	tag, err = id3v2.Open(fullPath, id3v2.Options{Parse: true})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	got := map[string]string{
+		"title": tag.Title(),
+		"genre": tag.Genre(),
+		"year":  tag.Year(),
+		"isrc":  tag.GetTextFrame("TSRC").Text,
+	}
+	if f, ok := tag.GetLastFrame("COMM").(id3v2.CommentFrame); ok {
+		got["comment"] = f.Text
+	}
+	if f, ok := tag.GetLastFrame("USLT").(id3v2.UnsynchronisedLyricsFrame); ok {
+		got["lyrics"] = f.Lyrics
+	}
+	if f, ok := tag.GetLastFrame("TXXX").(id3v2.UserDefinedTextFrame); ok {
+		got["replaygain"] = f.Value
+	}
+	tag.Close()
+	want := map[string]string{
+		"title": "Mask Off", "genre": "Trap", "year": "", "isrc": "USUM71703861",
+		"comment": "ripped from the group chat", "lyrics": "la la la", "replaygain": "-6.20 dB",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected frames after writing:\n got %v\nwant %v", got, want)
+	}
+
+	// removing the other tags strips everything the library doesn't manage
+	if _, err := app.UpdateSettings(UpdateSettingsInput{TagFieldPolicies: map[string]string{tagFieldOther: tagPolicyRemove}}); err != nil {
+		t.Fatalf("UpdateSettings: %v", err)
+	}
+	if res, _ := app.WriteSongMetadata(song.ID); !res.Success {
+		t.Fatalf("WriteSongMetadata: %s", res.Error)
+	}
+	tag, err = id3v2.Open(fullPath, id3v2.Options{Parse: true})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer tag.Close()
+	for _, id := range []string{"COMM", "USLT", "TSRC", "TXXX"} {
+		if len(tag.GetFrames(id)) > 0 {
+			t.Fatalf("expected %s to be removed", id)
+		}
+	}
+	if tag.Genre() != "Trap" {
+		t.Fatalf("expected the preserved genre to stay, got %q", tag.Genre())
+	}
+}
+
+func TestWriteSongMetadataUpgradesPreservedV23Frames(t *testing.T) {
+	app := newTestApp(t)
+
+	relPath := "uploads/songs/old-tag.mp3"
+	fullPath := writeSilentMP3(t, app, relPath)
+	tag, err := id3v2.Open(fullPath, id3v2.Options{Parse: true})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	tag.SetVersion(3)
+	tag.AddTextFrame("TYER", id3v2.EncodingISO, "2016")
+	tag.AddTextFrame("TDAT", id3v2.EncodingISO, "1406")
+	tag.AddTextFrame("TORY", id3v2.EncodingISO, "2015")
+	tag.AddTextFrame("TSIZ", id3v2.EncodingISO, "4170")
+	tag.AddFrame("IPLS", id3v2.UnknownFrame{Body: []byte("\x00producer\x00Metro Boomin")})
+	if err := tag.Save(); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	tag.Close()
+
+	song, err := app.CreateSong(CreateSongInput{Name: "Mask Off", Filepath: relPath})
+	if err != nil {
+		t.Fatalf("CreateSong: %v", err)
+	}
+	if _, err := app.UpdateSettings(UpdateSettingsInput{TagFieldPolicies: map[string]string{
+		tagFieldYear: tagPolicyPreserve, tagFieldProducers: tagPolicyPreserve,
+	}}); err != nil {
+		t.Fatalf("UpdateSettings: %v", err)
+	}
+	if res, _ := app.WriteSongMetadata(song.ID); !res.Success {
+		t.Fatalf("WriteSongMetadata: %s", res.Error)
+	}
+
+	tag, err = id3v2.Open(fullPath, id3v2.Options{Parse: true})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer tag.Close()
+	if tag.Version() != 4 {
+		t.Fatalf("expected a v2.4 tag, got v2.%d", tag.Version())
+	}
+	for _, id := range []string{"TYER", "TDAT", "TORY", "TSIZ", "IPLS"} {
+		if len(tag.GetFrames(id)) > 0 {
+			t.Fatalf("expected %s to be converted or dropped", id)
+		}
+	}
+	if got := tag.GetTextFrame("TDRC").Text; got != "2016-06-14" {
+		t.Fatalf("expected TYER and TDAT to become TDRC, got %q", got)
+	}
+	if got := tag.GetTextFrame("TDOR").Text; got != "2015" {
+		t.Fatalf("expected TORY to become TDOR, got %q", got)
+	}
+
+	tags, err := (id3Adapter{}).Read(fullPath)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if want := []ProducerCredit{{Role: producerRoleProducer, Name: "Metro Boomin"}}; tags.Year != 2016 || !reflect.DeepEqual(tags.Credits, want) {
+		t.Fatalf("expected the preserved year and credits to read back, got %d %+v", tags.Year, tags.Credits)
+	}
+	drift, err := app.songMetadataDrift(song.ID)
+	if err != nil {
+		t.Fatalf("songMetadataDrift: %v", err)
+	}
+	if len(drift) != 0 {
+		t.Fatalf("expected no drift after writing, got %+v", drift)
+	}
+}
+
+func TestMergeVorbisComments(t *testing.T) {
+	existing := []string{"TITLE=leak_final_v2", "GENRE=Trap", "DATE=2016", "COMMENT=ripped", "ISRC=USUM71703861", "PRODUCER=Unknown"}
+	tags := SongTags{Title: "Mask Off", Genre: "Hip-Hop", Year: 2017, Producers: "Metro Boomin", Policies: map[string]string{
+		tagFieldGenre: tagPolicyPreserve, tagFieldYear: tagPolicyRemove,
+	}}
+	got := mergeVorbisComments(existing, tags)
+	want := []string{"GENRE=Trap", "COMMENT=ripped", "ISRC=USUM71703861", "TITLE=Mask Off", "PRODUCER=Metro Boomin"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected comments:\n got %v\nwant %v", got, want)
+	}
+}
+
+func TestMergeVorbisCommentsReplacesTrackTotals(t *testing.T) {
+	existing := []string{"TITLE=Mask Off", "TRACKNUMBER=3", "TRACKTOTAL=10", "TOTALTRACKS=10", "DISCNUMBER=1"}
+	tags := SongTags{Title: "Mask Off", TrackNumberStr: "3/12", TrackNumber: 3, TrackTotal: 12}
+	got := mergeVorbisComments(existing, tags)
+	want := []string{"DISCNUMBER=1", "TITLE=Mask Off", "TRACKNUMBER=3/12"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected comments:\n got %v\nwant %v", got, want)
+	}
+
+	tags.Policies = map[string]string{tagFieldTrack: tagPolicyRemove}
+	got = mergeVorbisComments(existing, tags)
+	want = []string{"DISCNUMBER=1", "TITLE=Mask Off"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected comments with the track removed:\n got %v\nwant %v", got, want)
+	}
+}
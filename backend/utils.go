@@ -27,3 +27,39 @@ func ParseArtists(artistString string) []string {
 	}
 	return result
 }
+
+var titleRemixPattern = regexp.MustCompile(`(?i)[(\[]\s*([^)\]]+?)\s+remix\s*[)\]]`)
+
+// ParseArtistCredits is ParseArtists keeping each name's role: names after
+// "feat." in the artist string, or in a "(feat. X)" in the title, are
+// featured, and "(X Remix)" in the title credits X as remixer. roles[i] is
+// names[i]'s role; primary artists come first.
+func ParseArtistCredits(artistString, title string) (names []string, roles []string) {
+	names, roles = []string{}, []string{}
+	seen := make(map[string]bool)
+	add := func(role string, credited []string) {
+		for _, name := range credited {
+			if !seen[strings.ToLower(name)] {
+				seen[strings.ToLower(name)] = true
+				names = append(names, name)
+				roles = append(roles, role)
+			}
+		}
+	}
+
+	var bracketed []string
+	artistString = cutDecorations(artistString, bracketedFeatPattern, func(v string) {
+		bracketed = append(bracketed, ParseArtists(v)...)
+	})
+	primary, guests := splitInline(strings.TrimSpace(artistString), inlineFeatPattern)
+	add(artistRolePrimary, ParseArtists(primary))
+	add(artistRoleFeatured, ParseArtists(guests))
+	add(artistRoleFeatured, bracketed)
+	for _, m := range bracketedFeatPattern.FindAllStringSubmatch(title, -1) {
+		add(artistRoleFeatured, ParseArtists(m[1]))
+	}
+	for _, m := range titleRemixPattern.FindAllStringSubmatch(title, -1) {
+		add(artistRoleRemixer, ParseArtists(m[1]))
+	}
+	return names, roles
+}
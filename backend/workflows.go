@@ -3,6 +3,7 @@ package backend
 import (
 	"fmt"
 	"log"
+	"slices"
 	"strings"
 )
 
@@ -17,6 +18,7 @@ type songCreationSpec struct {
 	OriginalFilename string
 	Metadata         ExtractedMetadata
 	ArtistIDs        []int
+	ArtistRoles      []string // parallel to ArtistIDs; nil credits everyone as primary
 	AlbumID          *int
 	ArtworkPath      *string
 	MatchProducers   bool
@@ -42,16 +44,34 @@ func (a *App) resolveUploadArtwork(useEmbedded bool, metadata ExtractedMetadata,
 	return nil
 }
 
+// positiveIntPtr maps the zero "unknown" value of probed properties to NULL.
+func positiveIntPtr(v int) *int {
+	if v <= 0 {
+		return nil
+	}
+	return &v
+}
+
 // createSongsFromSpecs is the shared core of the upload flows. It creates a song
-// per spec and writes metadata back to each file. A metadata write failure does
-// not abort the batch (the song is already created); it is logged rather than
-// silently discarded.
+// per spec and writes metadata back to each file that isn't hard-linked. A
+// metadata write failure does not abort the batch (the song is already
+// created); it is logged rather than silently discarded.
 func (a *App) createSongsFromSpecs(specs []songCreationSpec, settings *Settings) ([]Song, error) {
 	createdSongs := []Song{}
 	for _, spec := range specs {
 		var producerIDs []int
+		var producerRoles []string
 		if spec.MatchProducers {
-			producerIDs, _ = a.MatchProducersFromFilename(spec.OriginalFilename, spec.ArtistIDs)
+			// credits from the tags keep their roles; the filename only adds
+			// plain producers
+			patterns, _ := a.LoadProducerPatterns()
+			producerIDs, producerRoles = matchProducerCredits(spec.Metadata.ProducerCredits, patterns, spec.ArtistIDs)
+			for _, id := range MatchPatterns(spec.OriginalFilename, patterns, spec.ArtistIDs) {
+				if !slices.Contains(producerIDs, id) {
+					producerIDs = append(producerIDs, id)
+					producerRoles = append(producerRoles, producerRoleProducer)
+				}
+			}
 		}
 
 		var trackNumber *int
@@ -83,17 +103,43 @@ func (a *App) createSongsFromSpecs(specs []songCreationSpec, settings *Settings)
 			duration = &d
 		}
 
+		var fileType *string
+		if ft := fileTypeFromPath(spec.Filepath); ft != "" {
+			fileType = &ft
+		}
+
+		var bitrate, sampleRate, channels, bitDepth *int
+		var codec *string
+		if audio := spec.Metadata.Audio; audio != nil {
+			bitrate = positiveIntPtr(audio.Bitrate)
+			sampleRate = positiveIntPtr(audio.SampleRate)
+			channels = positiveIntPtr(audio.Channels)
+			bitDepth = positiveIntPtr(audio.BitDepth)
+			if audio.Codec != "" {
+				c := audio.Codec
+				codec = &c
+			}
+		}
+
 		song, err := a.CreateSong(CreateSongInput{
-			Name:        songName,
-			Filepath:    spec.Filepath,
-			ArtistIDs:   spec.ArtistIDs,
-			ProducerIDs: producerIDs,
-			AlbumID:     spec.AlbumID,
-			ArtworkPath: spec.ArtworkPath,
-			Genre:       genre,
-			Year:        year,
-			TrackNumber: trackNumber,
-			Duration:    duration,
+			Name:          songName,
+			Filepath:      spec.Filepath,
+			ArtistIDs:     spec.ArtistIDs,
+			ArtistRoles:   spec.ArtistRoles,
+			ProducerIDs:   producerIDs,
+			ProducerRoles: producerRoles,
+			AlbumID:       spec.AlbumID,
+			ArtworkPath:   spec.ArtworkPath,
+			Genre:         genre,
+			Year:          year,
+			TrackNumber:   trackNumber,
+			Duration:      duration,
+			FileType:      fileType,
+			Bitrate:       bitrate,
+			SampleRate:    sampleRate,
+			Channels:      channels,
+			BitDepth:      bitDepth,
+			Codec:         codec,
 		})
 		if err != nil {
 			return nil, err
@@ -101,6 +147,26 @@ func (a *App) createSongsFromSpecs(specs []songCreationSpec, settings *Settings)
 
 		createdSongs = append(createdSongs, *song)
 
+		// Reuse the identity from the duplicate check when there was one
+		identity := a.takeFileIdentity(spec.Filepath)
+		if identity == nil {
+			if identity, err = a.identifyFile(spec.Filepath, true); err != nil {
+				log.Printf("upload: failed to fingerprint song %d (%s): %v", song.ID, spec.Filepath, err)
+			}
+		}
+		if identity != nil {
+			if err := a.storeSongIdentity(song.ID, identity); err != nil {
+				return nil, err
+			}
+		}
+
+		// A hard-linked import keeps the original's tags: every adapter
+		// writes a new file, which would break the link. An explicit write
+		// still tags it.
+		if a.uploadHasOtherLinks(spec.Filepath) {
+			continue
+		}
+
 		// Write metadata back to file. The song exists regardless, so a write
 		// failure is logged rather than failing the whole upload.
 		if result, _ := a.WriteSongMetadata(song.ID); !result.Success {
@@ -111,19 +177,70 @@ func (a *App) createSongsFromSpecs(specs []songCreationSpec, settings *Settings)
 	return createdSongs, nil
 }
 
+// savedUpload is a file already stored under uploads/songs, along with the
+// name it arrived under (used for titles and producer matching).
+type savedUpload struct {
+	OriginalFilename string
+	Filepath         string
+}
+
 // UploadAndExtractMetadata handles the first step of the metadata extraction workflow
 func (a *App) UploadAndExtractMetadata(files []FileUpload, albumID *int) (*UploadAndExtractResult, error) {
-	var filesData []FileData
-	allArtistNames := make(map[string]bool)
-	allAlbumNames := make(map[string]bool)
-	filesWithArtwork := 0
-
+	saved := make([]savedUpload, 0, len(files))
 	for _, file := range files {
-		// Save file
 		relPath, err := a.SaveUploadedFile(file.Filename, file.Base64Data)
 		if err != nil {
 			return nil, err
 		}
+		saved = append(saved, savedUpload{OriginalFilename: file.Filename, Filepath: relPath})
+	}
+	return a.extractIntoSession(saved, albumID)
+}
+
+// ImportFromPaths is UploadAndExtractMetadata for files already on disk: it
+// copies (or hard-links) them into uploads/songs instead of receiving base64
+// over the bridge. Directories are searched recursively for audio files.
+func (a *App) ImportFromPaths(input ImportFromPathsInput) (*UploadAndExtractResult, error) {
+	saved, err := a.importPaths(input.Paths, input.HardLink)
+	if err != nil {
+		return nil, err
+	}
+	return a.extractIntoSession(saved, input.AlbumID)
+}
+
+// extractIntoSession extracts saved uploads and opens an import session for
+// them, so they are cleaned up if the upload is never finished.
+func (a *App) extractIntoSession(saved []savedUpload, albumID *int) (*UploadAndExtractResult, error) {
+	result, err := a.extractUploads(saved, albumID)
+	if err != nil {
+		a.CleanupFiles(savedUploadPaths(saved))
+		return nil, err
+	}
+	if err := a.openImportSession(albumID, result); err != nil {
+		a.CleanupFiles(savedUploadPaths(saved))
+		return nil, err
+	}
+	return result, nil
+}
+
+func savedUploadPaths(saved []savedUpload) []string {
+	paths := make([]string, 0, len(saved))
+	for _, file := range saved {
+		paths = append(paths, file.Filepath)
+	}
+	return paths
+}
+
+// extractUploads is the shared extraction stage of the preview flows: it reads
+// each saved file's metadata and resolves artists, albums, and duplicates.
+func (a *App) extractUploads(saved []savedUpload, albumID *int) (*UploadAndExtractResult, error) {
+	var filesData []FileData
+	allArtistNames := make(map[string]bool)
+	allAlbumNames := make(map[string]bool)
+	filesWithArtwork := 0
+
+	for _, file := range saved {
+		relPath := file.Filepath
 
 		// Extract metadata
 		metadata, err := a.ExtractMetadata(relPath)
@@ -131,8 +248,8 @@ func (a *App) UploadAndExtractMetadata(files []FileUpload, albumID *int) (*Uploa
 			metadata = &ExtractedMetadata{} // Continue with empty metadata
 		}
 
-		// Parse artists
-		parsedArtists := ParseArtists(metadata.Artist)
+		// Parse artists, with featured guests and remixers named in the title
+		parsedArtists, artistRoles := ParseArtistCredits(metadata.Artist, metadata.Title)
 		for _, artist := range parsedArtists {
 			allArtistNames[artist] = true
 		}
@@ -147,27 +264,41 @@ func (a *App) UploadAndExtractMetadata(files []FileUpload, albumID *int) (*Uploa
 		}
 
 		filesData = append(filesData, FileData{
-			OriginalFilename:   file.Filename,
+			OriginalFilename:   file.OriginalFilename,
 			Filepath:           relPath,
 			Metadata:           *metadata,
 			ParsedArtists:      parsedArtists,
+			ArtistRoles:        artistRoles,
 			HasUnmappedArtists: false,
 		})
 	}
 
-	// Check which artists exist
+	// Check which artists exist, by name or alias
 	existingArtists := make(map[string]int) // lowercase -> id
 	for name := range allArtistNames {
-		artist, _ := a.FindArtistByName(name)
+		artist, _ := a.resolveArtistName(name)
 		if artist != nil {
 			existingArtists[strings.ToLower(name)] = artist.ID
 		}
 	}
 
+	// Pre-resolve names through remembered mapping decisions
+	artistMapping := make(map[string]any)
+	for name := range allArtistNames {
+		if _, exists := existingArtists[strings.ToLower(name)]; exists {
+			continue
+		}
+		if resolution, ok, _ := a.recallArtistMapping(name); ok {
+			artistMapping[name] = resolution
+		}
+	}
+
 	// Identify unmapped artists
 	unmappedArtists := []string{}
 	for name := range allArtistNames {
-		if _, exists := existingArtists[strings.ToLower(name)]; !exists {
+		_, exists := existingArtists[strings.ToLower(name)]
+		_, remembered := artistMapping[name]
+		if !exists && !remembered {
 			unmappedArtists = append(unmappedArtists, name)
 		}
 	}
@@ -175,7 +306,9 @@ func (a *App) UploadAndExtractMetadata(files []FileUpload, albumID *int) (*Uploa
 	// Mark files with unmapped artists
 	for i := range filesData {
 		for _, artist := range filesData[i].ParsedArtists {
-			if _, exists := existingArtists[strings.ToLower(artist)]; !exists {
+			_, exists := existingArtists[strings.ToLower(artist)]
+			_, remembered := artistMapping[artist]
+			if !exists && !remembered {
 				filesData[i].HasUnmappedArtists = true
 				break
 			}
@@ -196,41 +329,89 @@ func (a *App) UploadAndExtractMetadata(files []FileUpload, albumID *int) (*Uploa
 		}
 	}
 
+	// Flag files we already have
+	possibleDuplicates, unfingerprinted, err := a.findPossibleDuplicates(filesData)
+	if err != nil {
+		return nil, err
+	}
+
+	noDecoder := false
+	for _, path := range unfingerprinted {
+		if !canDecode(path) {
+			noDecoder = true
+			break
+		}
+	}
+
 	return &UploadAndExtractResult{
-		FilesData:        filesData,
-		UnmappedArtists:  unmappedArtists,
-		FilesWithArtwork: filesWithArtwork,
+		FilesData:                 filesData,
+		UnmappedArtists:           unmappedArtists,
+		ArtistMapping:             artistMapping,
+		FilesWithArtwork:          filesWithArtwork,
+		PossibleDuplicates:        possibleDuplicates,
+		UnfingerprintedFiles:      unfingerprinted,
+		FingerprintingUnavailable: noDecoder,
 	}, nil
 }
 
-// CreateSongsWithMetadata creates songs from extracted metadata
+// CreateSongsWithMetadata creates songs from extracted metadata. Files that
+// already have a song are skipped, so a session whose create failed partway
+// can be resumed and submitted again.
 func (a *App) CreateSongsWithMetadata(input CreateSongsWithMetadataInput) ([]Song, error) {
 	settings, err := a.GetSettings()
 	if err != nil {
 		return nil, fmt.Errorf("failed to load settings: %w", err)
 	}
 
+	var session *ImportSession
+	if input.SessionID != nil {
+		if session, err = a.getImportSession(*input.SessionID); err != nil {
+			return nil, err
+		}
+		if session.Status != importSessionPending {
+			return nil, fmt.Errorf("import session %d is %s", session.ID, session.Status)
+		}
+	}
+
 	// Build artist ID map
 	artistIDMap := make(map[string]int)
-	for artistName, resolution := range input.ArtistMapping {
-		if resolution == "CREATE_NEW" {
+	applyMapping := func(artistName string, resolution any) error {
+		if resolution == artistMappingCreateNew {
 			newArtist, err := a.CreateArtist(CreateArtistInput{Name: artistName})
 			if err != nil {
-				return nil, err
+				return err
 			}
 			artistIDMap[artistName] = newArtist.ID
 		} else if id, ok := resolution.(float64); ok {
 			artistIDMap[artistName] = int(id)
 		}
+		return nil
+	}
+	for artistName, resolution := range input.ArtistMapping {
+		if err := applyMapping(artistName, resolution); err != nil {
+			return nil, err
+		}
 	}
 
-	// Also check for existing artists not in mapping
+	// Remember the choices for the next upload. Losing them only costs a
+	// re-prompt, so a failure here doesn't fail the upload.
+	if err := a.rememberArtistMappings(input.ArtistMapping); err != nil {
+		log.Printf("upload: failed to remember artist mappings: %v", err)
+	}
+
+	// Also check for existing artists not in mapping, then remembered mappings
 	for _, fileData := range input.FilesData {
 		for _, artistName := range fileData.ParsedArtists {
-			if _, exists := artistIDMap[artistName]; !exists {
-				artist, _ := a.FindArtistByName(artistName)
-				if artist != nil {
-					artistIDMap[artistName] = artist.ID
+			if _, exists := artistIDMap[artistName]; exists {
+				continue
+			}
+			if artist, _ := a.resolveArtistName(artistName); artist != nil {
+				artistIDMap[artistName] = artist.ID
+				continue
+			}
+			if resolution, ok, _ := a.recallArtistMapping(artistName); ok {
+				if err := applyMapping(artistName, resolution); err != nil {
+					return nil, err
 				}
 			}
 		}
@@ -244,11 +425,27 @@ func (a *App) CreateSongsWithMetadata(input CreateSongsWithMetadataInput) ([]Son
 
 	specs := make([]songCreationSpec, 0, len(input.FilesData))
 	for _, fileData := range input.FilesData {
-		// Resolve artist IDs
+		// a retry after a partly failed create skips the files that already
+		// became songs
+		if songID, err := a.songIDByFilepath(fileData.Filepath); err != nil {
+			return nil, err
+		} else if songID != 0 {
+			continue
+		}
+
+		// Resolve artist IDs; a name and its alias can credit the same artist twice
 		songArtistIDs := []int{}
-		for _, artistName := range fileData.ParsedArtists {
-			if id, exists := artistIDMap[artistName]; exists {
+		songArtistRoles := []string{}
+		seenArtists := make(map[int]bool)
+		for i, artistName := range fileData.ParsedArtists {
+			if id, exists := artistIDMap[artistName]; exists && !seenArtists[id] {
+				seenArtists[id] = true
 				songArtistIDs = append(songArtistIDs, id)
+				role := artistRolePrimary
+				if i < len(fileData.ArtistRoles) && fileData.ArtistRoles[i] != "" {
+					role = fileData.ArtistRoles[i]
+				}
+				songArtistRoles = append(songArtistRoles, role)
 			}
 		}
 
@@ -260,8 +457,9 @@ func (a *App) CreateSongsWithMetadata(input CreateSongsWithMetadataInput) ([]Son
 		}
 
 		// Use artists from metadata or inherit from album
-		finalArtistIDs := songArtistIDs
+		finalArtistIDs, finalArtistRoles := songArtistIDs, songArtistRoles
 		if len(finalArtistIDs) == 0 && currentAlbum != nil {
+			finalArtistRoles = nil
 			for _, art := range currentAlbum.Artists {
 				finalArtistIDs = append(finalArtistIDs, art.ID)
 			}
@@ -280,17 +478,64 @@ func (a *App) CreateSongsWithMetadata(input CreateSongsWithMetadataInput) ([]Son
 			OriginalFilename: fileData.OriginalFilename,
 			Metadata:         fileData.Metadata,
 			ArtistIDs:        finalArtistIDs,
+			ArtistRoles:      finalArtistRoles,
 			AlbumID:          finalAlbumID,
 			ArtworkPath:      a.resolveUploadArtwork(input.UseEmbeddedArtwork, fileData.Metadata, currentAlbum),
 			MatchProducers:   true,
 		})
 	}
 
-	return a.createSongsFromSpecs(specs, settings)
+	songs, err := a.createSongsFromSpecs(specs, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	// files dropped from the preview are removed with the session
+	if session != nil {
+		keep := make(map[string]bool)
+		for _, fileData := range input.FilesData {
+			keep[fileData.Filepath] = true
+		}
+		if _, err := a.closeImportSession(session, importSessionCompleted, keep); err != nil {
+			log.Printf("upload: failed to complete import session %d: %v", session.ID, err)
+		}
+	} else {
+		paths := make([]string, 0, len(input.FilesData))
+		for _, fileData := range input.FilesData {
+			paths = append(paths, fileData.Filepath)
+		}
+		if err := a.completeImportSessionsFor(paths); err != nil {
+			log.Printf("upload: failed to complete import sessions: %v", err)
+		}
+	}
+	return songs, nil
 }
 
 // UploadSongs handles simple song upload without metadata preview
 func (a *App) UploadSongs(files []FileUpload, albumID *int) ([]Song, error) {
+	saved := make([]savedUpload, 0, len(files))
+	for _, file := range files {
+		relPath, err := a.SaveUploadedFile(file.Filename, file.Base64Data)
+		if err != nil {
+			return nil, err
+		}
+		saved = append(saved, savedUpload{OriginalFilename: file.Filename, Filepath: relPath})
+	}
+	return a.createUploadedSongs(saved, albumID)
+}
+
+// ImportSongsFromPaths is UploadSongs for files already on disk.
+func (a *App) ImportSongsFromPaths(input ImportFromPathsInput) ([]Song, error) {
+	saved, err := a.importPaths(input.Paths, input.HardLink)
+	if err != nil {
+		return nil, err
+	}
+	return a.createUploadedSongs(saved, input.AlbumID)
+}
+
+// createUploadedSongs is the no-preview flow: songs inherit the album's
+// artists and artwork and take the rest from extracted metadata.
+func (a *App) createUploadedSongs(saved []savedUpload, albumID *int) ([]Song, error) {
 	settings, err := a.GetSettings()
 	if err != nil {
 		return nil, fmt.Errorf("failed to load settings: %w", err)
@@ -309,23 +554,17 @@ func (a *App) UploadSongs(files []FileUpload, albumID *int) ([]Song, error) {
 		}
 	}
 
-	specs := make([]songCreationSpec, 0, len(files))
-	for _, file := range files {
-		// Save file
-		relPath, err := a.SaveUploadedFile(file.Filename, file.Base64Data)
-		if err != nil {
-			return nil, err
-		}
-
+	specs := make([]songCreationSpec, 0, len(saved))
+	for _, file := range saved {
 		// Extract metadata
-		metadata, _ := a.ExtractMetadata(relPath)
+		metadata, _ := a.ExtractMetadata(file.Filepath)
 		if metadata == nil {
 			metadata = &ExtractedMetadata{}
 		}
 
 		specs = append(specs, songCreationSpec{
-			Filepath:         relPath,
-			OriginalFilename: file.Filename,
+			Filepath:         file.Filepath,
+			OriginalFilename: file.OriginalFilename,
 			Metadata:         *metadata,
 			ArtistIDs:        artistIDs,
 			AlbumID:          albumID,
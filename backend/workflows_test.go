@@ -1,6 +1,7 @@
 package backend
 
 import (
+	"bytes"
 	"database/sql"
 	"encoding/base64"
 	"os"
@@ -212,3 +213,123 @@ func TestUpdateSongReturnsReadableSong(t *testing.T) {
 		t.Fatalf("expected artist links to be refreshed, got %#v", updatedSong.Artists)
 	}
 }
+
+func TestImportFromPathsCopiesOrLinksFiles(t *testing.T) {
+	app := newTestApp(t)
+
+	srcDir := t.TempDir()
+	nested := filepath.Join(srcDir, "disc 2")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	single := filepath.Join(srcDir, "single.mp3")
+	for path, body := range map[string]string{
+		single:                           "single payload",
+		filepath.Join(nested, "a.flac"):  "flac payload",
+		filepath.Join(nested, "art.jpg"): "not audio",
+	} {
+		if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+			t.Fatalf("write source: %v", err)
+		}
+	}
+
+	result, err := app.ImportFromPaths(ImportFromPathsInput{Paths: []string{single, nested}})
+	if err != nil {
+		t.Fatalf("ImportFromPaths: %v", err)
+	}
+	if len(result.FilesData) != 2 {
+		t.Fatalf("expected the file and the directory's audio file, got %+v", result.FilesData)
+	}
+	if got := result.FilesData[1].OriginalFilename; got != "a.flac" {
+		t.Fatalf("expected original filename to be kept, got %q", got)
+	}
+	for _, fd := range result.FilesData {
+		fullPath, err := app.staticFilePath(fd.Filepath)
+		if err != nil {
+			t.Fatalf("staticFilePath: %v", err)
+		}
+		if _, err := os.Stat(fullPath); err != nil {
+			t.Fatalf("expected imported copy at %q: %v", fullPath, err)
+		}
+	}
+	if _, err := os.Stat(single); err != nil {
+		t.Fatalf("expected source to be left in place: %v", err)
+	}
+
+	// before write-back (which may replace the file), the upload is the source
+	linked, err := app.ImportFromPaths(ImportFromPathsInput{Paths: []string{single}, HardLink: true})
+	if err != nil {
+		t.Fatalf("ImportFromPaths: %v", err)
+	}
+	fullPath, err := app.staticFilePath(linked.FilesData[0].Filepath)
+	if err != nil {
+		t.Fatalf("staticFilePath: %v", err)
+	}
+	srcInfo, _ := os.Stat(single)
+	dstInfo, err := os.Stat(fullPath)
+	if err != nil {
+		t.Fatalf("stat linked upload: %v", err)
+	}
+	if !os.SameFile(srcInfo, dstInfo) {
+		t.Fatalf("expected a hard link to the source file")
+	}
+}
+
+func TestImportSongsFromPathsCreatesSongs(t *testing.T) {
+	app := newTestApp(t)
+
+	src := filepath.Join(t.TempDir(), "Loose Leak.mp3")
+	if err := os.WriteFile(src, []byte("payload"), 0644); err != nil {
+		t.Fatalf("write source: %v", err)
+	}
+	songs, err := app.ImportSongsFromPaths(ImportFromPathsInput{Paths: []string{src}})
+	if err != nil {
+		t.Fatalf("ImportSongsFromPaths: %v", err)
+	}
+	if len(songs) != 1 || songs[0].Name != "Loose Leak.mp3" {
+		t.Fatalf("expected one song named after the file, got %+v", songs)
+	}
+}
+
+func TestImportSongsFromPathsKeepsHardLinks(t *testing.T) {
+	app := newTestApp(t)
+
+	var frames []byte
+	for i := 0; i < 10; i++ {
+		frames = append(frames, mp3Frame(nil)...)
+	}
+	src := filepath.Join(t.TempDir(), "Loose Leak.mp3")
+	if err := os.WriteFile(src, frames, 0644); err != nil {
+		t.Fatalf("write source: %v", err)
+	}
+	songs, err := app.ImportSongsFromPaths(ImportFromPathsInput{Paths: []string{src}, HardLink: true})
+	if err != nil {
+		t.Fatalf("ImportSongsFromPaths: %v", err)
+	}
+	fullPath, err := app.staticFilePath(songs[0].Filepath)
+	if err != nil {
+		t.Fatalf("staticFilePath: %v", err)
+	}
+	srcInfo, _ := os.Stat(src)
+	dstInfo, err := os.Stat(fullPath)
+	if err != nil {
+		t.Fatalf("stat linked upload: %v", err)
+	}
+	if !os.SameFile(srcInfo, dstInfo) {
+		t.Skip("hard links unsupported here")
+	}
+	if data, _ := os.ReadFile(src); !bytes.Equal(data, frames) {
+		t.Fatal("expected the source file to keep its tags")
+	}
+
+	// an explicit write tags the upload and breaks the link
+	if res, _ := app.WriteSongMetadata(songs[0].ID); !res.Success {
+		t.Fatalf("WriteSongMetadata: %s", res.Error)
+	}
+	if data, _ := os.ReadFile(src); !bytes.Equal(data, frames) {
+		t.Fatal("expected the source file to be left alone")
+	}
+	if dstInfo, err = os.Stat(fullPath); err != nil || os.SameFile(srcInfo, dstInfo) {
+		t.Fatalf("expected the write to replace the upload (err %v)", err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"embed"
 	"leaks-manager/backend"
 	"log"
+	"os"
 
 	"github.com/wailsapp/wails/v2"
 	"github.com/wailsapp/wails/v2/pkg/options"
@@ -16,6 +17,11 @@ import (
 var assets embed.FS
 
 func main() {
+	// headless subcommands (import, list, write, ...) skip the webview
+	if backend.IsCLICommand(os.Args[1:]) {
+		os.Exit(backend.RunCLI(os.Args[1:], os.Stdout, os.Stderr))
+	}
+
 	// create application instance
 	app := backend.NewApp()
 